@@ -49,7 +49,72 @@ func AdapterOf(typ reflect.Type) (a Adapter, ok bool) {
 	return
 }
 
+// InstallScoped adds an adapter for typ which is only used by the emitters and
+// parsers of scope, which report it from their AdapterScope method. Other
+// emitters and parsers use the adapter installed by Install if there is one,
+// or encode and decode values of typ as usual.
+//
+// The function panics if one of the encoder and decoder functions of the
+// adapter are nil.
+//
+// A typical use case for this function is to be called during the package
+// initialization phase of a format package to change how the format encodes
+// types that it has special support for.
+func InstallScoped(scope string, typ reflect.Type, adapter Adapter) {
+	if adapter.Encode == nil {
+		panic("objconv: the encoder function of an adapter cannot be nil")
+	}
+
+	if adapter.Decode == nil {
+		panic("objconv: the decoder function of an adapter cannot be nil")
+	}
+
+	adapterMutex.Lock()
+	scopes := scopedAdapterStore[typ]
+	if scopes == nil {
+		scopes = make(map[string]Adapter)
+		scopedAdapterStore[typ] = scopes
+	}
+	scopes[scope] = adapter
+	adapterMutex.Unlock()
+
+	// Same as in Install, the cached struct fields may not be checking for
+	// scoped adapters yet.
+	structCache.clear()
+}
+
+// ScopedAdapterOf returns the adapter for typ installed in scope, setting ok to
+// true if one was found, false otherwise.
+func ScopedAdapterOf(scope string, typ reflect.Type) (a Adapter, ok bool) {
+	adapterMutex.RLock()
+	a, ok = scopedAdapterStore[typ][scope]
+	adapterMutex.RUnlock()
+	return
+}
+
+func hasScopedAdapters(typ reflect.Type) (ok bool) {
+	adapterMutex.RLock()
+	ok = len(scopedAdapterStore[typ]) != 0
+	adapterMutex.RUnlock()
+	return
+}
+
+func emitterAdapterOf(emitter Emitter, typ reflect.Type) (a Adapter, ok bool) {
+	if e, _ := emitter.(scopedEmitter); e != nil {
+		a, ok = ScopedAdapterOf(e.AdapterScope(), typ)
+	}
+	return
+}
+
+func parserAdapterOf(parser Parser, typ reflect.Type) (a Adapter, ok bool) {
+	if p, _ := parser.(scopedParser); p != nil {
+		a, ok = ScopedAdapterOf(p.AdapterScope(), typ)
+	}
+	return
+}
+
 var (
-	adapterMutex sync.RWMutex
-	adapterStore = make(map[reflect.Type]Adapter)
+	adapterMutex       sync.RWMutex
+	adapterStore       = make(map[reflect.Type]Adapter)
+	scopedAdapterStore = make(map[reflect.Type]map[string]Adapter)
 )
@@ -0,0 +1,152 @@
+// Package bson implements an emitter and a parser of the BSON format used by
+// MongoDB.
+//
+// BSON only supports documents at the top level, values of other types are
+// wrapped in a document made of a single element with an empty name, which the
+// parser transparently unwraps. This is also how streams of values are encoded,
+// as an array wrapped in a top-level document.
+//
+// Times are encoded as UTC datetimes, which have a millisecond precision, so
+// they are truncated to the millisecond like the MongoDB drivers do. Durations
+// and errors are encoded as strings.
+package bson
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Element types of the BSON format.
+const (
+	Double     = 0x01
+	String     = 0x02
+	Document   = 0x03
+	Array      = 0x04
+	Binary     = 0x05
+	Undefined  = 0x06
+	ObjectId   = 0x07
+	Boolean    = 0x08
+	DateTime   = 0x09
+	Null       = 0x0A
+	Regex      = 0x0B
+	DBPointer  = 0x0C
+	JavaScript = 0x0D
+	Symbol     = 0x0E
+	CodeWScope = 0x0F
+	Int32      = 0x10
+	Timestamp  = 0x11
+	Int64      = 0x12
+	Decimal128 = 0x13
+	MinKey     = 0xFF
+	MaxKey     = 0x7F
+)
+
+// Subtypes of binary elements.
+const (
+	BinaryGeneric = 0x00
+	BinaryOld     = 0x02
+)
+
+func putInt32(b []byte, v int32) {
+	binary.LittleEndian.PutUint32(b, uint32(v))
+}
+
+func getInt32(b []byte) int32 {
+	return int32(binary.LittleEndian.Uint32(b))
+}
+
+func getUint64(b []byte) uint64 {
+	return binary.LittleEndian.Uint64(b)
+}
+
+func appendInt32(b []byte, v int32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return append(b,
+		byte(v), byte(v>>8), byte(v>>16), byte(v>>24),
+		byte(v>>32), byte(v>>40), byte(v>>48), byte(v>>56),
+	)
+}
+
+// valueSize returns the size of the value of an element of type t at the
+// beginning of b, validating that it doesn't overflow b.
+func valueSize(t byte, b []byte) (n int, err error) {
+	switch t {
+	case Null, Undefined, MinKey, MaxKey:
+		n = 0
+
+	case Boolean:
+		n = 1
+
+	case Int32:
+		n = 4
+
+	case Double, DateTime, Timestamp, Int64:
+		n = 8
+
+	case ObjectId:
+		n = 12
+
+	case Decimal128:
+		n = 16
+
+	case String, JavaScript, Symbol:
+		if n, err = lengthOf(b, 4); err == nil {
+			if m := int(getInt32(b)); m < 1 || b[n-1] != 0 {
+				err = fmt.Errorf("objconv/bson: invalid string length: %d", m)
+			}
+		}
+		return
+
+	case Document, Array, CodeWScope:
+		if n, err = lengthOf(b, 0); err == nil && (n < 5 || b[n-1] != 0) {
+			err = fmt.Errorf("objconv/bson: invalid document length: %d", n)
+		}
+		return
+
+	case Binary:
+		return lengthOf(b, 5)
+
+	case Regex:
+		for i, c := 0, 0; i != len(b); i++ {
+			if b[i] == 0 {
+				if c++; c == 2 {
+					return i + 1, nil
+				}
+			}
+		}
+		n = len(b) + 1
+
+	case DBPointer:
+		if n, err = valueSize(String, b); err == nil {
+			n += 12
+		}
+
+	default:
+		err = fmt.Errorf("objconv/bson: invalid element type: 0x%02X", t)
+		return
+	}
+
+	if n > len(b) {
+		err = errTruncated
+	}
+	return
+}
+
+// lengthOf reads the int32 length at the beginning of b and returns the size
+// of the value, which is the length plus extra bytes.
+func lengthOf(b []byte, extra int) (n int, err error) {
+	if len(b) < 4 {
+		err = errTruncated
+		return
+	}
+
+	if m := getInt32(b); m < 0 {
+		err = fmt.Errorf("objconv/bson: invalid negative length: %d", m)
+	} else if n = int(m) + extra; n > len(b) {
+		err = errTruncated
+	}
+	return
+}
@@ -0,0 +1,200 @@
+package bson
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dolab/objconv"
+	"github.com/dolab/objconv/objtests"
+)
+
+func TestCodec(t *testing.T) {
+	objtests.TestCodecWithTimePrecision(t, Codec, time.Millisecond)
+}
+
+func BenchmarkCodec(b *testing.B) {
+	objtests.BenchmarkCodec(b, Codec)
+}
+
+func TestMarshal(t *testing.T) {
+	tests := []struct {
+		v interface{}
+		b []byte
+	}{
+		{
+			v: map[string]string{"hello": "world"},
+			b: []byte("\x16\x00\x00\x00\x02hello\x00\x06\x00\x00\x00world\x00\x00"),
+		},
+		{
+			v: struct {
+				A int32 `objconv:"a"`
+				B int64 `objconv:"b"`
+			}{1, 2},
+			b: []byte("\x17\x00\x00\x00\x10a\x00\x01\x00\x00\x00\x12b\x00\x02\x00\x00\x00\x00\x00\x00\x00\x00"),
+		},
+		{
+			v: struct {
+				T time.Time `objconv:"t"`
+			}{time.Unix(1, int64(2*time.Millisecond))},
+			b: []byte("\x10\x00\x00\x00\x09t\x00\xea\x03\x00\x00\x00\x00\x00\x00\x00"),
+		},
+		{
+			v: struct {
+				B []byte `objconv:"b"`
+			}{[]byte("A")},
+			b: []byte("\x0e\x00\x00\x00\x05b\x00\x01\x00\x00\x00\x00A\x00"),
+		},
+		{
+			v: struct {
+				A []bool `objconv:"a"`
+			}{[]bool{true, false}},
+			b: []byte("\x15\x00\x00\x00\x04a\x00\x0d\x00\x00\x00\x080\x00\x01\x081\x00\x00\x00\x00"),
+		},
+		{
+			// values that are not documents are wrapped
+			v: int32(42),
+			b: []byte("\x0b\x00\x00\x00\x10\x00\x2a\x00\x00\x00\x00"),
+		},
+	}
+
+	for _, test := range tests {
+		b, err := Marshal(test.v)
+
+		if err != nil {
+			t.Errorf("%#v: %s", test.v, err)
+			continue
+		}
+
+		if !bytes.Equal(b, test.b) {
+			t.Errorf("%#v:\n- expected: %q\n- found:    %q", test.v, test.b, b)
+		}
+
+		v := reflect.New(reflect.TypeOf(test.v))
+
+		if err := Unmarshal(b, v.Interface()); err != nil {
+			t.Errorf("%#v: %s", test.v, err)
+			continue
+		}
+
+		if x := v.Elem().Interface(); !reflect.DeepEqual(x, test.v) {
+			if t1, ok := x.(struct {
+				T time.Time `objconv:"t"`
+			}); !ok || !t1.T.Equal(test.v.(struct {
+				T time.Time `objconv:"t"`
+			}).T) {
+				t.Errorf("%#v: bad value decoded: %#v", test.v, x)
+			}
+		}
+	}
+}
+
+func TestMarshalTimeTruncated(t *testing.T) {
+	v := struct {
+		T time.Time `objconv:"t"`
+	}{time.Unix(1, 123456789)}
+
+	b, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if x := []byte("\x10\x00\x00\x00\x09t\x00\x63\x04\x00\x00\x00\x00\x00\x00\x00"); !bytes.Equal(b, x) {
+		t.Errorf("\n- expected: %q\n- found:    %q", x, b)
+	}
+
+	v.T = time.Time{}
+
+	if err := Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if !v.T.Equal(time.Unix(1, 123000000)) {
+		t.Error("bad time decoded:", v.T)
+	}
+}
+
+func TestUnmarshalWrappedDocument(t *testing.T) {
+	// A document with a single element with an empty name is not unwrapped
+	// when decoding into a map.
+	b, _ := Marshal(map[string]int{"": 1})
+
+	var m map[string]int
+	var v interface{}
+
+	if err := Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(m, map[string]int{"": 1}) {
+		t.Errorf("bad map decoded: %#v", m)
+	}
+
+	if err := Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if v != int64(1) {
+		t.Errorf("bad value decoded: %#v", v)
+	}
+}
+
+func TestObjectID(t *testing.T) {
+	id := NewObjectID()
+
+	b, err := Marshal(struct {
+		ID ObjectID `objconv:"_id"`
+	}{id})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b[4] != ObjectId || !bytes.Equal(b[9:21], id[:]) {
+		t.Errorf("bad ObjectId element: %q", b)
+	}
+
+	var v struct {
+		ID ObjectID `objconv:"_id"`
+	}
+
+	if err := Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if v.ID != id {
+		t.Errorf("bad ObjectID decoded: %s != %s", v.ID, id)
+	}
+
+	// Other formats use the hexadecimal representation.
+	e := objconv.NewValueEmitter()
+
+	if err := objconv.NewEncoder(e).Encode(id); err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Value() != id.Hex() {
+		t.Errorf("bad ObjectID encoded: %#v", e.Value())
+	}
+
+	var x ObjectID
+
+	if err := objconv.NewDecoder(objconv.NewValueParser(id.Hex())).Decode(&x); err != nil {
+		t.Fatal(err)
+	}
+
+	if x != id {
+		t.Errorf("bad ObjectID decoded: %s != %s", x, id)
+	}
+
+	if s := id.Time().Unix(); s > time.Now().Unix() || s < time.Now().Unix()-60 {
+		t.Errorf("bad ObjectID time: %s", id.Time())
+	}
+}
+
+func TestEmitUintOverflow(t *testing.T) {
+	if _, err := Marshal(uint64(1 << 63)); err == nil {
+		t.Error("expected an error when encoding an unsigned integer that overflows int64")
+	}
+}
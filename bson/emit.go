@@ -0,0 +1,358 @@
+package bson
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/dolab/objconv/objutil"
+)
+
+// Emitter implements a BSON emitter that satisfies the objconv.Emitter
+// interface.
+//
+// Documents are buffered until they're complete because their length has to be
+// written before their elements.
+type Emitter struct {
+	w io.Writer
+	b []byte // the top-level document being encoded
+	k []byte // name of the next element of a document
+
+	// The stack of documents and arrays being encoded, the first element is
+	// the top-level document.
+	stack []context
+
+	// sback is used as the initial backing array for the stack slice to avoid
+	// dynamic memory allocations for the most common use cases.
+	sback [8]context
+}
+
+type context struct {
+	off   int  // offset of the document length in b
+	n     int  // number of elements written to the document
+	array bool // set when encoding an array
+	key   bool // set when the next value is the name of an element
+	wrap  bool // set for documents wrapping a top-level value
+}
+
+func NewEmitter(w io.Writer) *Emitter {
+	e := &Emitter{w: w}
+	e.stack = e.sback[:0]
+	return e
+}
+
+func (e *Emitter) Reset(w io.Writer) {
+	e.w = w
+	e.b = e.b[:0]
+	e.stack = e.stack[:0]
+}
+
+func (e *Emitter) EmitNil() error {
+	if e.isKey() {
+		return errKeyType("nil")
+	}
+	return e.emit(Null)
+}
+
+func (e *Emitter) EmitBool(v bool) error {
+	if e.isKey() {
+		return errKeyType("bool")
+	}
+
+	if err := e.header(Boolean); err != nil {
+		return err
+	}
+
+	if v {
+		e.b = append(e.b, 1)
+	} else {
+		e.b = append(e.b, 0)
+	}
+
+	return e.done()
+}
+
+func (e *Emitter) EmitInt(v int64, bitSize int) error {
+	if e.isKey() {
+		return e.emitKey(strconv.AppendInt(e.k[:0], v, 10))
+	}
+
+	if bitSize == 0 {
+		bitSize = strconv.IntSize
+	}
+
+	if bitSize <= 32 {
+		if err := e.header(Int32); err != nil {
+			return err
+		}
+		e.b = appendInt32(e.b, int32(v))
+	} else {
+		if err := e.header(Int64); err != nil {
+			return err
+		}
+		e.b = appendUint64(e.b, uint64(v))
+	}
+
+	return e.done()
+}
+
+func (e *Emitter) EmitUint(v uint64, bitSize int) error {
+	if e.isKey() {
+		return e.emitKey(strconv.AppendUint(e.k[:0], v, 10))
+	}
+
+	if bitSize == 0 {
+		bitSize = strconv.IntSize
+	}
+
+	// BSON has no unsigned integers, uint32 values may not fit in an int32
+	// so only smaller integers are encoded as int32.
+	if bitSize < 32 {
+		return e.EmitInt(int64(v), 32)
+	}
+
+	if v > objutil.Int64Max {
+		return fmt.Errorf("objconv/bson: unsigned integer %d overflows the BSON int64 type", v)
+	}
+
+	return e.EmitInt(int64(v), 64)
+}
+
+func (e *Emitter) EmitFloat(v float64, _ int) error {
+	if e.isKey() {
+		return errKeyType("float")
+	}
+
+	if err := e.header(Double); err != nil {
+		return err
+	}
+
+	e.b = appendUint64(e.b, math.Float64bits(v))
+	return e.done()
+}
+
+func (e *Emitter) EmitString(v string) error {
+	if e.isKey() {
+		return e.emitKey(append(e.k[:0], v...))
+	}
+
+	if err := e.header(String); err != nil {
+		return err
+	}
+
+	e.b = appendInt32(e.b, int32(len(v)+1))
+	e.b = append(e.b, v...)
+	e.b = append(e.b, 0)
+	return e.done()
+}
+
+func (e *Emitter) EmitBytes(v []byte) error {
+	if e.isKey() {
+		return e.emitKey(append(e.k[:0], v...))
+	}
+
+	if err := e.header(Binary); err != nil {
+		return err
+	}
+
+	e.b = appendInt32(e.b, int32(len(v)))
+	e.b = append(e.b, BinaryGeneric)
+	e.b = append(e.b, v...)
+	return e.done()
+}
+
+func (e *Emitter) EmitTime(v time.Time) error {
+	if e.isKey() {
+		return errKeyType("time")
+	}
+
+	if err := e.header(DateTime); err != nil {
+		return err
+	}
+
+	// BSON datetimes have a millisecond precision, like the MongoDB drivers
+	// the emitter truncates times to the millisecond.
+	ms := v.Unix()*1000 + int64(v.Nanosecond())/int64(time.Millisecond)
+	e.b = appendUint64(e.b, uint64(ms))
+	return e.done()
+}
+
+func (e *Emitter) EmitDuration(v time.Duration) error {
+	if e.isKey() {
+		return errKeyType("duration")
+	}
+	return e.EmitString(v.String())
+}
+
+func (e *Emitter) EmitError(v error) error {
+	if e.isKey() {
+		return errKeyType("error")
+	}
+	return e.EmitString(v.Error())
+}
+
+// EmitObjectID writes an ObjectId element, it is used by the adapter of the
+// ObjectID type.
+func (e *Emitter) EmitObjectID(v ObjectID) error {
+	if e.isKey() {
+		return e.emitKey(append(e.k[:0], v.Hex()...))
+	}
+
+	if err := e.header(ObjectId); err != nil {
+		return err
+	}
+
+	e.b = append(e.b, v[:]...)
+	return e.done()
+}
+
+func (e *Emitter) EmitArrayBegin(_ int) error {
+	if e.isKey() {
+		return errKeyType("array")
+	}
+
+	if err := e.header(Array); err != nil {
+		return err
+	}
+
+	e.push(true)
+	return nil
+}
+
+func (e *Emitter) EmitArrayEnd() error {
+	return e.end()
+}
+
+func (e *Emitter) EmitArrayNext() error {
+	return nil
+}
+
+func (e *Emitter) EmitMapBegin(_ int) error {
+	if e.isKey() {
+		return errKeyType("map")
+	}
+
+	// Documents at the top level don't need to be wrapped.
+	if len(e.stack) == 0 {
+		e.b = e.b[:0]
+	} else if err := e.header(Document); err != nil {
+		return err
+	}
+
+	e.push(false)
+	return nil
+}
+
+func (e *Emitter) EmitMapEnd() error {
+	return e.end()
+}
+
+func (e *Emitter) EmitMapValue() error {
+	e.top().key = false
+	return nil
+}
+
+func (e *Emitter) EmitMapNext() error {
+	e.top().key = true
+	return nil
+}
+
+func (e *Emitter) isKey() bool {
+	c := e.top()
+	return c != nil && c.key
+}
+
+func (e *Emitter) emitKey(k []byte) error {
+	if bytes.IndexByte(k, 0) >= 0 {
+		return fmt.Errorf("objconv/bson: element names cannot contain null bytes: %q", k)
+	}
+	e.k = k
+	return nil
+}
+
+func (e *Emitter) emit(t byte) error {
+	if err := e.header(t); err != nil {
+		return err
+	}
+	return e.done()
+}
+
+// header writes the type and name of the next element, wrapping the value in a
+// document if it is written at the top level.
+func (e *Emitter) header(t byte) error {
+	if len(e.stack) == 0 {
+		e.b = e.b[:0]
+		e.push(false)
+		e.top().wrap = true
+		e.k = e.k[:0]
+	}
+
+	c := e.top()
+	e.b = append(e.b, t)
+
+	if c.array {
+		e.b = strconv.AppendInt(e.b, int64(c.n), 10)
+	} else {
+		e.b = append(e.b, e.k...)
+	}
+
+	e.b = append(e.b, 0)
+	c.n++
+	return nil
+}
+
+// done is called after writing a value, it terminates the document wrapping
+// the value if it was written at the top level.
+func (e *Emitter) done() error {
+	if c := e.top(); c != nil && c.wrap {
+		return e.end()
+	}
+	return nil
+}
+
+func (e *Emitter) end() error {
+	n := len(e.stack) - 1
+	c := e.stack[n]
+	e.stack = e.stack[:n]
+
+	e.b = append(e.b, 0)
+	size := len(e.b) - c.off
+
+	if size > math.MaxInt32 {
+		return errors.New("objconv/bson: document size overflows the BSON int32 type")
+	}
+
+	putInt32(e.b[c.off:], int32(size))
+
+	if n == 0 {
+		_, err := e.w.Write(e.b)
+		e.b = e.b[:0]
+		return err
+	}
+
+	return e.done()
+}
+
+func (e *Emitter) top() *context {
+	if n := len(e.stack); n != 0 {
+		return &e.stack[n-1]
+	}
+	return nil
+}
+
+func (e *Emitter) push(array bool) {
+	e.stack = append(e.stack, context{
+		off:   len(e.b),
+		array: array,
+		key:   !array,
+	})
+	e.b = append(e.b, 0, 0, 0, 0)
+}
+
+func errKeyType(t string) error {
+	return fmt.Errorf("objconv/bson: element names must be strings, found %s", t)
+}
@@ -0,0 +1,28 @@
+package bson
+
+import (
+	"io"
+	"reflect"
+
+	"github.com/dolab/objconv"
+)
+
+// Codec for the BSON format.
+var Codec = objconv.Codec{
+	NewEmitter: func(w io.Writer) objconv.Emitter { return NewEmitter(w) },
+	NewParser:  func(r io.Reader) objconv.Parser { return NewParser(r) },
+}
+
+func init() {
+	for _, name := range [...]string{
+		"application/bson",
+		"bson",
+	} {
+		objconv.Register(name, Codec)
+	}
+
+	objconv.Install(reflect.TypeOf(ObjectID{}), objconv.Adapter{
+		Encode: encodeObjectID,
+		Decode: decodeObjectID,
+	})
+}
@@ -0,0 +1,137 @@
+package bson
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	"github.com/dolab/objconv"
+)
+
+// ObjectID represents the 12 bytes identifiers of MongoDB documents.
+//
+// ObjectID values are encoded as ObjectId elements by the BSON emitter, and as
+// hexadecimal strings by other emitters.
+type ObjectID [12]byte
+
+// NewObjectID generates a new ObjectID, made of the current time, a random
+// value unique to the process, and an incrementing counter.
+func NewObjectID() ObjectID {
+	return NewObjectIDFromTime(time.Now())
+}
+
+// NewObjectIDFromTime generates a new ObjectID from t.
+func NewObjectIDFromTime(t time.Time) ObjectID {
+	var id ObjectID
+	binary.BigEndian.PutUint32(id[:4], uint32(t.Unix()))
+	copy(id[4:9], processUnique[:])
+	c := atomic.AddUint32(&objectIDCounter, 1)
+	id[9], id[10], id[11] = byte(c>>16), byte(c>>8), byte(c)
+	return id
+}
+
+// ParseObjectID parses the hexadecimal representation of an ObjectID.
+func ParseObjectID(s string) (id ObjectID, err error) {
+	if len(s) != 2*len(id) {
+		err = fmt.Errorf("objconv/bson: invalid ObjectID: %q", s)
+		return
+	}
+	if _, err = hex.Decode(id[:], []byte(s)); err != nil {
+		err = fmt.Errorf("objconv/bson: invalid ObjectID: %q", s)
+	}
+	return
+}
+
+// Time returns the time at which id was generated, with a second precision.
+func (id ObjectID) Time() time.Time {
+	return time.Unix(int64(binary.BigEndian.Uint32(id[:4])), 0)
+}
+
+// Hex returns the hexadecimal representation of id.
+func (id ObjectID) Hex() string {
+	return hex.EncodeToString(id[:])
+}
+
+// String satisfies the fmt.Stringer interface.
+func (id ObjectID) String() string {
+	return id.Hex()
+}
+
+// IsZero returns true if id is the zero-value.
+func (id ObjectID) IsZero() bool {
+	return id == ObjectID{}
+}
+
+var (
+	processUnique   [5]byte
+	objectIDCounter uint32
+)
+
+func init() {
+	var b [8]byte
+
+	if _, err := io.ReadFull(rand.Reader, b[:]); err != nil {
+		panic("objconv/bson: cannot initialize the ObjectID generator: " + err.Error())
+	}
+
+	copy(processUnique[:], b[:5])
+	objectIDCounter = binary.BigEndian.Uint32(b[4:])
+}
+
+// The objectIDEmitter interface is implemented by the BSON emitter and the
+// types that embed it.
+type objectIDEmitter interface {
+	EmitObjectID(ObjectID) error
+}
+
+func encodeObjectID(e objconv.Encoder, v reflect.Value) error {
+	id := v.Interface().(ObjectID)
+
+	if x, ok := e.Emitter.(objectIDEmitter); ok {
+		return x.EmitObjectID(id)
+	}
+
+	return e.Encode(id.Hex())
+}
+
+func decodeObjectID(d objconv.Decoder, to reflect.Value) (err error) {
+	var t objconv.Type
+	var b []byte
+	var id ObjectID
+
+	if t, err = d.Parser.ParseType(); err != nil {
+		return
+	}
+
+	switch t {
+	case objconv.Nil:
+		err = d.Parser.ParseNil()
+
+	case objconv.Bytes:
+		if b, err = d.Parser.ParseBytes(); err == nil {
+			if len(b) != len(id) {
+				err = fmt.Errorf("objconv/bson: invalid ObjectID length: %d", len(b))
+			}
+			copy(id[:], b)
+		}
+
+	case objconv.String:
+		if b, err = d.Parser.ParseString(); err == nil {
+			id, err = ParseObjectID(string(b))
+		}
+
+	default:
+		err = errors.New("objconv/bson: cannot decode ObjectID from " + t.String())
+	}
+
+	if err == nil && to.IsValid() {
+		to.Set(reflect.ValueOf(id))
+	}
+	return
+}
@@ -0,0 +1,372 @@
+package bson
+
+import (
+	"bytes"
+	"encoding"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/dolab/objconv"
+)
+
+var errTruncated = errors.New("objconv/bson: unexpected end of document")
+
+// Parser implements a BSON parser that satisfies the objconv.Parser interface.
+//
+// Top-level documents are read entirely before being parsed.
+type Parser struct {
+	r    io.Reader
+	b    []byte       // the top-level document being parsed
+	i    int          // offset of the next byte to read in b
+	j    int          // offset in b where the top-level value ends
+	o    int64        // offset of b in the input
+	t    byte         // type of the element that the parser is positioned on
+	k    []byte       // name of the element that the parser is positioned on
+	key  bool         // set when the parser is positioned on an element name
+	hint reflect.Type // type of the destination of the top-level value
+
+	// The stack of documents and arrays being parsed.
+	stack []frame
+}
+
+type frame struct {
+	end   int  // offset of the terminating null byte in b
+	array bool // set when parsing an array
+}
+
+func NewParser(r io.Reader) *Parser {
+	return &Parser{r: r}
+}
+
+func (p *Parser) Reset(r io.Reader) {
+	p.r = r
+	p.b = p.b[:0]
+	p.i = 0
+	p.j = 0
+	p.o = 0
+	p.key = false
+	p.hint = nil
+	p.stack = p.stack[:0]
+}
+
+func (p *Parser) Buffered() io.Reader {
+	return bytes.NewReader(nil)
+}
+
+// Offset returns the number of bytes consumed from the input.
+func (p *Parser) Offset() int64 {
+	return p.o + int64(p.i)
+}
+
+// HintType is called by the decoder with the type of the values it decodes, the
+// parser uses it to avoid unwrapping top-level documents that are decoded into
+// maps or structs.
+func (p *Parser) HintType(t reflect.Type) {
+	if len(p.stack) == 0 {
+		p.hint = t
+	}
+}
+
+func (p *Parser) ParseType() (objconv.Type, error) {
+	if len(p.stack) == 0 && p.i >= p.j {
+		if err := p.load(); err != nil {
+			return objconv.Unknown, err
+		}
+	}
+
+	if p.key {
+		return objconv.String, nil
+	}
+
+	switch p.t {
+	case Null, Undefined:
+		return objconv.Nil, nil
+	case Boolean:
+		return objconv.Bool, nil
+	case Int32, Int64:
+		return objconv.Int, nil
+	case Timestamp:
+		return objconv.Uint, nil
+	case Double:
+		return objconv.Float, nil
+	case String, JavaScript, Symbol:
+		return objconv.String, nil
+	case Binary, ObjectId:
+		return objconv.Bytes, nil
+	case DateTime:
+		return objconv.Time, nil
+	case Array:
+		return objconv.Array, nil
+	case Document:
+		return objconv.Map, nil
+	}
+
+	return objconv.Unknown, fmt.Errorf("objconv/bson: unsupported element type: 0x%02X", p.t)
+}
+
+func (p *Parser) ParseNil() (err error) {
+	return
+}
+
+func (p *Parser) ParseBool() (v bool, err error) {
+	v = p.b[p.i] != 0
+	p.i++
+	return
+}
+
+func (p *Parser) ParseInt() (v int64, err error) {
+	switch p.t {
+	case Int32:
+		v = int64(getInt32(p.b[p.i:]))
+		p.i += 4
+	default:
+		v = int64(getUint64(p.b[p.i:]))
+		p.i += 8
+	}
+	return
+}
+
+func (p *Parser) ParseUint() (v uint64, err error) {
+	v = getUint64(p.b[p.i:])
+	p.i += 8
+	return
+}
+
+func (p *Parser) ParseFloat() (v float64, err error) {
+	v = math.Float64frombits(getUint64(p.b[p.i:]))
+	p.i += 8
+	return
+}
+
+func (p *Parser) ParseString() (v []byte, err error) {
+	if p.key {
+		v = p.k
+		return
+	}
+	n := int(getInt32(p.b[p.i:]))
+	v = p.b[p.i+4 : p.i+4+n-1]
+	p.i += 4 + n
+	return
+}
+
+func (p *Parser) ParseBytes() (v []byte, err error) {
+	if p.t == ObjectId {
+		v = p.b[p.i : p.i+12]
+		p.i += 12
+		return
+	}
+
+	n := int(getInt32(p.b[p.i:]))
+	v = p.b[p.i+5 : p.i+5+n]
+	p.i += 5 + n
+
+	// The old binary subtype repeats the length of the data in the payload.
+	if p.b[p.i-n-1] == BinaryOld {
+		if len(v) < 4 || int(getInt32(v)) != len(v)-4 {
+			err = errors.New("objconv/bson: invalid length of old binary subtype")
+			return
+		}
+		v = v[4:]
+	}
+	return
+}
+
+func (p *Parser) ParseTime() (v time.Time, err error) {
+	ms := int64(getUint64(p.b[p.i:]))
+	p.i += 8
+	v = time.Unix(ms/1000, (ms%1000)*int64(time.Millisecond)).UTC()
+	return
+}
+
+func (p *Parser) ParseDuration() (v time.Duration, err error) {
+	err = errors.New("objconv/bson: durations are not supported by the BSON format")
+	return
+}
+
+func (p *Parser) ParseError() (v error, err error) {
+	err = errors.New("objconv/bson: errors are not supported by the BSON format")
+	return
+}
+
+func (p *Parser) ParseArrayBegin() (n int, err error) {
+	return p.begin(true)
+}
+
+func (p *Parser) ParseArrayEnd(n int) (err error) {
+	p.end()
+	return
+}
+
+func (p *Parser) ParseArrayNext(n int) (err error) {
+	return p.element()
+}
+
+func (p *Parser) ParseMapBegin() (n int, err error) {
+	return p.begin(false)
+}
+
+func (p *Parser) ParseMapEnd(n int) (err error) {
+	p.end()
+	return
+}
+
+func (p *Parser) ParseMapValue(n int) (err error) {
+	p.key = false
+	return
+}
+
+func (p *Parser) ParseMapNext(n int) (err error) {
+	return p.element()
+}
+
+// load reads the next top-level document from the input and positions the
+// parser on its value.
+func (p *Parser) load() (err error) {
+	var h [4]byte
+	var n int
+
+	p.o += int64(len(p.b))
+	p.b = p.b[:0]
+	p.i = 0
+	p.j = 0
+
+	if _, err = io.ReadFull(p.r, h[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = errTruncated
+		}
+		return
+	}
+
+	if n = int(getInt32(h[:])); n < 5 {
+		return fmt.Errorf("objconv/bson: invalid document length: %d", n)
+	}
+
+	if cap(p.b) < n {
+		p.b = make([]byte, n)
+	} else {
+		p.b = p.b[:n]
+	}
+	copy(p.b, h[:])
+
+	if _, err = io.ReadFull(p.r, p.b[4:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = errTruncated
+		}
+		return
+	}
+
+	if p.b[n-1] != 0 {
+		return errors.New("objconv/bson: document is not terminated by a null byte")
+	}
+
+	hint := p.hint
+	p.hint = nil
+	p.t = Document
+	p.j = n
+	p.key = false
+
+	// A document made of a single element with an empty name wraps a value
+	// that was not a document, unless it is decoded into a map or a struct.
+	if n > 6 && p.b[5] == 0 && !isDocumentType(hint) {
+		if size, err := valueSize(p.b[4], p.b[6:n-1]); err == nil && size == n-7 {
+			p.t = p.b[4]
+			p.i = 6
+			p.j = n - 1
+		}
+	}
+
+	return nil
+}
+
+// begin positions the parser on the first element of the document or array
+// that it was positioned on, returning the number of elements.
+func (p *Parser) begin(array bool) (n int, err error) {
+	size := int(getInt32(p.b[p.i:]))
+	end := p.i + size - 1
+	b := p.b[p.i+4 : end]
+
+	// Count the elements to report the length of the document, this also
+	// validates the sizes of all the elements.
+	for len(b) != 0 {
+		k := bytes.IndexByte(b[1:], 0)
+		if k < 0 {
+			return 0, errTruncated
+		}
+
+		s, err := valueSize(b[0], b[k+2:])
+		if err != nil {
+			return 0, err
+		}
+
+		b = b[k+2+s:]
+		n++
+	}
+
+	p.stack = append(p.stack, frame{end: end, array: array})
+	p.i += 4
+
+	if n != 0 {
+		err = p.element()
+	}
+	return
+}
+
+func (p *Parser) end() {
+	n := len(p.stack) - 1
+	p.i = p.stack[n].end + 1
+	p.stack = p.stack[:n]
+	p.key = false
+}
+
+// element reads the type and name of the next element in the current document
+// or array.
+func (p *Parser) element() error {
+	f := &p.stack[len(p.stack)-1]
+
+	if p.i >= f.end {
+		return objconv.End
+	}
+
+	k := bytes.IndexByte(p.b[p.i+1:], 0)
+	p.t = p.b[p.i]
+	p.k = p.b[p.i+1 : p.i+1+k]
+	p.i += k + 2
+	p.key = !f.array
+	return nil
+}
+
+// isDocumentType returns true if values of type t are decoded from documents.
+func isDocumentType(t reflect.Type) bool {
+	if t == nil {
+		return false
+	}
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Map:
+		return true
+
+	case reflect.Struct:
+		if _, ok := objconv.AdapterOf(t); ok || t == timeType {
+			return false
+		}
+		p := reflect.PtrTo(t)
+		return !p.Implements(errorType) && !p.Implements(textUnmarshalerType) && !p.Implements(binaryUnmarshalerType)
+	}
+
+	return false
+}
+
+var (
+	timeType              = reflect.TypeOf(time.Time{})
+	errorType             = reflect.TypeOf((*error)(nil)).Elem()
+	textUnmarshalerType   = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+)
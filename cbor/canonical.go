@@ -0,0 +1,170 @@
+package cbor
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sort"
+)
+
+// NewCanonicalEmitter returns an emitter which writes the deterministic
+// encoding of values defined in RFC 8949 (section 4.2), equal values always
+// produce the same bytes:
+//
+// - integers, lengths and tags are written in their shortest form,
+// - floats are written in the shortest form that preserves their value,
+// - arrays and maps are written with definite lengths,
+// - map keys are sorted by the bytewise order of their encoding.
+//
+// Maps, and arrays of unknown lengths, are buffered until they end so they can
+// be written with definite lengths and sorted keys. Raw values are written
+// as-is, they must already be in the canonical form.
+func NewCanonicalEmitter(w io.Writer) *Emitter {
+	e := NewEmitter(w)
+	e.canonical = true
+	return e
+}
+
+// NewCanonicalParser returns a parser which rejects the input that isn't in
+// the deterministic encoding produced by canonical emitters.
+func NewCanonicalParser(r io.Reader) *Parser {
+	p := NewParser(r)
+	p.canonical = true
+	return p
+}
+
+// frame is used by canonical emitters to buffer the content of a map or an
+// array of unknown length.
+type frame struct {
+	buf bytes.Buffer
+	maj byte
+
+	// Number of items of arrays written so far, and offset of the end of the
+	// last one.
+	cnt int
+	end int
+
+	// Offsets of the keys and values of maps.
+	keys   []int
+	values []int
+}
+
+// push starts buffering the content of an array or a map.
+func (e *Emitter) push(maj byte) {
+	var f *frame
+
+	if n := len(e.free); n != 0 {
+		f, e.free = e.free[n-1], e.free[:n-1]
+	} else {
+		f = &frame{}
+	}
+
+	f.buf.Reset()
+	f.maj = maj
+	f.cnt = 0
+	f.end = 0
+	f.keys = append(f.keys[:0], 0)
+	f.values = f.values[:0]
+
+	e.frames = append(e.frames, f)
+	e.w = &f.buf
+}
+
+// pop writes the content of the innermost array or map being buffered to the
+// output of its parent.
+func (e *Emitter) pop() (err error) {
+	n := len(e.frames) - 1
+	f := e.frames[n]
+	e.frames = e.frames[:n]
+	e.free = append(e.free, f)
+
+	if n == 0 {
+		e.w = e.out
+	} else {
+		e.w = &e.frames[n-1].buf
+	}
+
+	if f.maj == majorType4 {
+		f.next()
+
+		if err = e.emitUint(majorType4, uint64(f.cnt)); err != nil {
+			return
+		}
+
+		_, err = e.w.Write(f.buf.Bytes())
+		return
+	}
+
+	b := f.buf.Bytes()
+	items := make([]item, 0, len(f.values))
+
+	for i, k := range f.keys {
+		j := len(b)
+
+		if i+1 < len(f.keys) {
+			j = f.keys[i+1]
+		}
+
+		// The encoder may start an entry before it learns that the map has
+		// no more entries, such entries are empty.
+		if k == j {
+			continue
+		}
+
+		if i >= len(f.values) {
+			return errors.New("objconv/cbor: missing value for map key")
+		}
+
+		items = append(items, item{key: b[k:f.values[i]], value: b[f.values[i]:j]})
+	}
+
+	sort.Sort(itemsByKey(items))
+
+	for i := 1; i < len(items); i++ {
+		if bytes.Equal(items[i-1].key, items[i].key) {
+			return errors.New("objconv/cbor: duplicate map key in canonical mode")
+		}
+	}
+
+	if err = e.emitUint(majorType5, uint64(len(items))); err != nil {
+		return
+	}
+
+	for _, it := range items {
+		if _, err = e.w.Write(it.key); err != nil {
+			return
+		}
+		if _, err = e.w.Write(it.value); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// next accounts for the array item written since the last call, if any.
+func (f *frame) next() {
+	if n := f.buf.Len(); n != f.end {
+		f.cnt++
+		f.end = n
+	}
+}
+
+type item struct {
+	key   []byte
+	value []byte
+}
+
+type itemsByKey []item
+
+func (s itemsByKey) Len() int           { return len(s) }
+func (s itemsByKey) Less(i, j int) bool { return bytes.Compare(s[i].key, s[j].key) < 0 }
+func (s itemsByKey) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// mapKeys is used by canonical parsers to verify that the keys of a map are
+// sorted.
+type mapKeys struct {
+	last []byte // encoding of the previous key
+	off  int    // offset of the current key in the recorded bytes
+	rec  bool   // whether the current key is being recorded
+}
@@ -2,6 +2,10 @@ package cbor
 
 import (
 	"encoding/binary"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
 
 	"github.com/dolab/objconv/objutil"
 )
@@ -37,10 +41,39 @@ const ( // simple values
 )
 
 const ( // tags
-	tagDateTime  = 0
-	tagTimestamp = 1
+	tagDateTime        = 0
+	tagTimestamp       = 1
+	tagPositiveBignum  = 2
+	tagNegativeBignum  = 3
+	tagDecimalFraction = 4
+	tagBase64URL       = 21
+	tagBase64          = 22
+	tagBase16          = 23
+	tagObject          = 27
+	tagURI             = 32
+	tagRegexp          = 35
+	tagUUID            = 37
+	tagDuration        = 1002
+	tagSelfDescribe    = 55799
 )
 
+// errorHead is the beginning of the content of the items with tag 27 which
+// represent errors, an array of two items starting with the type name "error"
+// and followed by the error message.
+var errorHead = [...]byte{0x82, 0x65, 'e', 'r', 'r', 'o', 'r'}
+
+var (
+	bigOne = big.NewInt(1)
+	bigTen = big.NewInt(10)
+
+	// Decimal numbers with mantissas of up to 15 digits are represented
+	// exactly by the float64 closest to them.
+	decimalMax = big.NewInt(1e15)
+)
+
+// adapterScope is the scope of the adapters installed by RegisterTag.
+const adapterScope = "cbor"
+
 const (
 	intMax   = uint64(objutil.IntMax)
 	int64Max = uint64(objutil.Int64Max)
@@ -80,6 +113,39 @@ func getUint64(b []byte) uint64 {
 	return binary.BigEndian.Uint64(b)
 }
 
+// splitDecimal returns the mantissa and the exponent of the decimal number
+// literal s, the trailing zeros of the mantissa are moved to the exponent.
+func splitDecimal(s string) (m *big.Int, exp int64, ok bool) {
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		var err error
+		if exp, err = strconv.ParseInt(s[i+1:], 10, 64); err != nil {
+			return
+		}
+		s = s[:i]
+	}
+
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		exp -= int64(len(s) - (i + 1))
+		s = s[:i] + s[i+1:]
+	}
+
+	if m, ok = new(big.Int).SetString(s, 10); !ok {
+		return
+	}
+
+	q, r := new(big.Int), new(big.Int)
+
+	for m.Sign() != 0 {
+		if q.QuoRem(m, bigTen, r); r.Sign() != 0 {
+			break
+		}
+		m, q = q, m
+		exp++
+	}
+
+	return
+}
+
 func align(n int, a int) int {
 	if (n % a) == 0 {
 		return n
@@ -117,3 +183,53 @@ func f16tof32bits(yy uint16) (d uint32) {
 	m = m << 13
 	return (s << 31) | (e << 23) | m
 }
+
+// f32tof16bits returns the half-precision representation of f, ok is false if
+// f cannot be represented exactly.
+func f32tof16bits(f float32) (h uint16, ok bool) {
+	b := math.Float32bits(f)
+	s := uint16(b>>16) & 0x8000
+	e := int((b >> 23) & 0xff)
+	m := b & 0x007fffff
+
+	switch {
+	case e == 0xff: // Inf or NaN
+		return s | 0x7c00 | uint16(m>>13), (m & 0x1fff) == 0
+
+	case e == 0: // +/- 0, denormalized numbers are too small
+		return s, m == 0
+	}
+
+	switch e -= 127; {
+	case e >= -14 && e <= 15:
+		return s | uint16(e+15)<<10 | uint16(m>>13), (m & 0x1fff) == 0
+
+	case e >= -24 && e < -14: // Denormalized half-precision number
+		m |= 0x00800000
+		shift := uint(-e - 1)
+		return s | uint16(m>>shift), (m & ((1 << shift) - 1)) == 0
+	}
+
+	return
+}
+
+// appendFloat appends to b the shortest encoding of v which preserves its
+// value, NaN is always encoded as 0xf97e00.
+func appendFloat(b []byte, v float64) []byte {
+	if math.IsNaN(v) {
+		return append(b, majorByte(majorType7, svFloat16), 0x7e, 0x00)
+	}
+
+	if f := float32(v); float64(f) == v {
+		if h, ok := f32tof16bits(f); ok {
+			return append(b, majorByte(majorType7, svFloat16), byte(h>>8), byte(h))
+		}
+		b = append(b, majorByte(majorType7, svFloat32), 0, 0, 0, 0)
+		putUint32(b[len(b)-4:], math.Float32bits(f))
+		return b
+	}
+
+	b = append(b, majorByte(majorType7, svFloat64), 0, 0, 0, 0, 0, 0, 0, 0)
+	putUint64(b[len(b)-8:], math.Float64bits(v))
+	return b
+}
@@ -1,8 +1,21 @@
 package cbor
 
 import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/dolab/objconv"
+	"github.com/dolab/objconv/json"
 	"github.com/dolab/objconv/objtests"
 )
 
@@ -10,6 +23,10 @@ func TestCodec(t *testing.T) {
 	objtests.TestCodec(t, Codec)
 }
 
+func TestRawValue(t *testing.T) {
+	objtests.TestRawValue(t, Codec)
+}
+
 func BenchmarkCodec(b *testing.B) {
 	objtests.BenchmarkCodec(b, Codec)
 }
@@ -25,3 +42,637 @@ func TestMajorType(t *testing.T) {
 		t.Error("bad info value:", b)
 	}
 }
+
+func TestBignum(t *testing.T) {
+	tests := []struct {
+		n string
+		b []byte
+	}{
+		{"18446744073709551616", []byte{0xc2, 0x49, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{"-18446744073709551617", []byte{0xc3, 0x49, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{"42", []byte{0x18, 0x2a}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.n, func(t *testing.T) {
+			x, _ := new(big.Int).SetString(test.n, 10)
+
+			b, err := Marshal(x)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(b, test.b) {
+				t.Errorf("bad encoding: %#v", b)
+			}
+
+			y := new(big.Int)
+
+			if err := Unmarshal(b, y); err != nil {
+				t.Fatal(err)
+			}
+
+			if x.Cmp(y) != 0 {
+				t.Error("bad decoding:", y)
+			}
+
+			var n objconv.Number
+			var v interface{}
+
+			if err := Unmarshal(b, &n); err != nil {
+				t.Fatal(err)
+			}
+
+			if string(n) != test.n {
+				t.Error("bad decoding to number:", n)
+			}
+
+			dec := NewDecoder(bytes.NewReader(b))
+			dec.UseNumber = true
+
+			if err := dec.Decode(&v); err != nil {
+				t.Fatal(err)
+			}
+
+			if v != objconv.Number(test.n) {
+				t.Errorf("bad decoding to interface: %#v", v)
+			}
+
+			if err := Unmarshal(b, &v); err != nil {
+				t.Fatal(err)
+			}
+
+			switch z := v.(type) {
+			case *big.Int:
+				if x.Cmp(z) != 0 {
+					t.Error("bad decoding to interface:", z)
+				}
+			case int64, uint64:
+				if fmt.Sprint(z) != test.n {
+					t.Error("bad decoding to interface:", z)
+				}
+			default:
+				t.Errorf("bad decoding to interface: %#v", v)
+			}
+
+			var f float64
+			g, _ := new(big.Float).SetInt(x).Float64()
+
+			if err := Unmarshal(b, &f); err != nil {
+				t.Fatal(err)
+			}
+
+			if f != g {
+				t.Error("bad decoding to float:", f)
+			}
+		})
+	}
+}
+
+func TestBignumFits(t *testing.T) {
+	tests := []struct {
+		b []byte
+		v interface{}
+	}{
+		{[]byte{0xc2, 0x48, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, uint64(math.MaxUint64)},
+		{[]byte{0xc3, 0x48, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, int64(math.MinInt64)},
+		{[]byte{0xc2, 0x4a, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01}, uint64(1)},
+		{[]byte{0xc3, 0x40}, int64(-1)},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.v), func(t *testing.T) {
+			var v interface{}
+
+			if err := Unmarshal(test.b, &v); err != nil {
+				t.Fatal(err)
+			}
+
+			if v != test.v {
+				t.Errorf("bad decoding to interface: %#v", v)
+			}
+		})
+	}
+
+	// One more than math.MaxInt64 is negated to one less than math.MinInt64.
+	var v interface{}
+
+	if err := Unmarshal([]byte{0xc3, 0x48, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if x, ok := v.(*big.Int); !ok || x.String() != "-9223372036854775809" {
+		t.Errorf("bad decoding to interface: %#v", v)
+	}
+}
+
+func TestUnknownTag(t *testing.T) {
+	// 55799(1000(4000(h'0102')))
+	b := []byte{0xd9, 0xd9, 0xf7, 0xd9, 0x03, 0xe8, 0xd9, 0x0f, 0xa0, 0x42, 0x01, 0x02}
+
+	var v interface{}
+
+	if err := Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Tag{Number: 1000, Content: Tag{Number: 4000, Content: []byte{1, 2}}}
+
+	if !reflect.DeepEqual(v, expected) {
+		t.Errorf("bad decoding: %#v", v)
+	}
+
+	// Decoding into a typed destination uses the content of the tags.
+	var c []byte
+
+	if err := Unmarshal(b, &c); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(c, []byte{1, 2}) {
+		t.Errorf("bad decoding: %#v", c)
+	}
+
+	m, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(m, b[3:]) {
+		t.Errorf("bad encoding: %#v", m)
+	}
+
+	if j, _ := json.Marshal(v); string(j) != `"AQI="` {
+		t.Errorf("bad JSON encoding: %s", j)
+	}
+}
+
+func TestBuiltinTags(t *testing.T) {
+	u, _ := url.Parse("http://www.example.com")
+	id, _ := ParseUUID("8f2f4bd2-a5de-4e6c-a3bd-8c1b1a4de2c1")
+
+	tests := []struct {
+		v interface{}
+		b []byte
+	}{
+		{*u, append([]byte{0xd8, 0x20, 0x76}, "http://www.example.com"...)},
+		{regexp.MustCompile("a+b"), append([]byte{0xd8, 0x23, 0x63}, "a+b"...)},
+		{id, append([]byte{0xd8, 0x25, 0x50}, id[:]...)},
+		{Base64URL{0xfb, 0xff}, []byte{0xd5, 0x42, 0xfb, 0xff}},
+		{Base64{0xfb, 0xff}, []byte{0xd6, 0x42, 0xfb, 0xff}},
+		{Base16{0xfb, 0xff}, []byte{0xd7, 0x42, 0xfb, 0xff}},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.v), func(t *testing.T) {
+			b, err := Marshal(test.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(b, test.b) {
+				t.Errorf("bad encoding: %#v", b)
+			}
+
+			var v interface{}
+
+			if err := Unmarshal(b, &v); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(v, test.v) {
+				t.Errorf("bad decoding: %#v", v)
+			}
+
+			p := reflect.New(reflect.TypeOf(test.v))
+
+			if err := Unmarshal(b, p.Interface()); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(p.Elem().Interface(), test.v) {
+				t.Errorf("bad decoding: %#v", p.Elem().Interface())
+			}
+		})
+	}
+}
+
+func TestTagsToJSON(t *testing.T) {
+	id, _ := ParseUUID("8f2f4bd2-a5de-4e6c-a3bd-8c1b1a4de2c1")
+
+	b, err := Marshal(map[string]interface{}{
+		"id":  id,
+		"b64": Base64URL{0xfb, 0xff},
+		"hex": Base16{0xfb, 0xff},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v interface{}
+
+	if err := Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	j, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var m map[string]string
+
+	if err := json.Unmarshal(j, &m); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(m, map[string]string{
+		"id":  "8f2f4bd2-a5de-4e6c-a3bd-8c1b1a4de2c1",
+		"b64": "-_8",
+		"hex": "fbff",
+	}) {
+		t.Errorf("bad JSON conversion: %s", j)
+	}
+}
+
+func TestDecimalFraction(t *testing.T) {
+	// 4([-2, 27315]), the example from RFC 8949.
+	b := []byte{0xc4, 0x82, 0x21, 0x19, 0x6a, 0xb3}
+
+	var f float64
+
+	if err := Unmarshal(b, &f); err != nil {
+		t.Fatal(err)
+	}
+
+	if f != 273.15 {
+		t.Error("bad decoding:", f)
+	}
+
+	var n objconv.Number
+
+	if err := Unmarshal(b, &n); err != nil {
+		t.Fatal(err)
+	}
+
+	if n != "27315e-2" {
+		t.Error("bad decoding to number:", n)
+	}
+
+	var v interface{}
+
+	if err := Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if v != objconv.Number("27315e-2") {
+		t.Errorf("bad decoding to interface: %#v", v)
+	}
+
+	if b, err := Marshal(objconv.Number("273.15")); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(b, []byte{0xfb, 0x40, 0x71, 0x12, 0x66, 0x66, 0x66, 0x66, 0x66}) {
+		t.Errorf("bad encoding of a float: %#v", b)
+	}
+
+	x, _ := new(big.Float).SetPrec(200).SetString("3.14159265358979323846264338327950288")
+
+	if b, err := Marshal(x); err != nil {
+		t.Fatal(err)
+	} else if err := Unmarshal(b, &n); err != nil {
+		t.Fatal(err)
+	}
+
+	if y, _ := new(big.Float).SetPrec(200).SetString(string(n)); y.Cmp(x) != 0 {
+		t.Error("bad decoding of a big.Float:", n)
+	}
+}
+
+type point struct {
+	X, Y int
+}
+
+func TestRegisterTag(t *testing.T) {
+	RegisterTag(100000, reflect.TypeOf(point{}), objconv.Adapter{
+		Encode: func(e objconv.Encoder, v reflect.Value) error {
+			p := v.Interface().(point)
+			return e.Encode([]int{p.X, p.Y})
+		},
+		Decode: func(d objconv.Decoder, to reflect.Value) (err error) {
+			var a [2]int
+			if err = d.Decode(&a); err == nil {
+				to.Set(reflect.ValueOf(point{a[0], a[1]}))
+			}
+			return
+		},
+	})
+
+	b, err := Marshal([]interface{}{point{1, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, []byte{0x81, 0xda, 0x00, 0x01, 0x86, 0xa0, 0x82, 0x01, 0x02}) {
+		t.Errorf("bad encoding: %#v", b)
+	}
+
+	var v []interface{}
+
+	if err := Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(v, []interface{}{point{1, 2}}) {
+		t.Errorf("bad decoding: %#v", v)
+	}
+
+	// The adapter must not change how other formats encode the type.
+	if b, err := json.Marshal(point{1, 2}); err != nil {
+		t.Fatal(err)
+	} else if string(b) != `{"X":1,"Y":2}` {
+		t.Errorf("bad json encoding: %s", b)
+	}
+
+	var p point
+
+	if err := json.Unmarshal([]byte(`{"X":1,"Y":2}`), &p); err != nil {
+		t.Fatal(err)
+	} else if p != (point{1, 2}) {
+		t.Errorf("bad json decoding: %#v", p)
+	}
+}
+
+func TestRegisterTagOtherFormats(t *testing.T) {
+	u, _ := url.Parse("http://example.com/a?b=c")
+
+	b, err := json.Marshal(struct {
+		U *url.URL
+		R *regexp.Regexp
+	}{U: u})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without an adapter installed for URLs, json uses their binary marshaler
+	// and writes them as base64 strings.
+	if s := `{"U":"` + base64.StdEncoding.EncodeToString([]byte(u.String())) + `","R":null}`; string(b) != s {
+		t.Errorf("bad json encoding: %s", b)
+	}
+}
+
+func TestCanonicalCodec(t *testing.T) {
+	objtests.TestCodec(t, CanonicalCodec)
+}
+
+func TestCanonicalEmitter(t *testing.T) {
+	type value struct {
+		Z  int     `objconv:"z"`
+		AA float64 `objconv:"aa"`
+		B  []int   `objconv:"b"`
+	}
+
+	tests := []struct {
+		v interface{}
+		b []byte
+	}{
+		{1.5, []byte{0xf9, 0x3e, 0x00}},
+		{float32(100000), []byte{0xfa, 0x47, 0xc3, 0x50, 0x00}},
+		{1.1, []byte{0xfb, 0x3f, 0xf1, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a}},
+		{5.960464477539063e-08, []byte{0xf9, 0x00, 0x01}},
+		{math.Inf(-1), []byte{0xf9, 0xfc, 0x00}},
+		{math.NaN(), []byte{0xf9, 0x7e, 0x00}},
+		{
+			value{Z: 1, AA: 0, B: []int{1000}},
+			[]byte{0xa3, 0x61, 0x62, 0x81, 0x19, 0x03, 0xe8, 0x61, 0x7a, 0x01, 0x62, 0x61, 0x61, 0xf9, 0x00, 0x00},
+		},
+		{
+			map[interface{}]interface{}{"b": true, 10: nil, -1: false, "aa": 1},
+			[]byte{0xa4, 0x0a, 0xf6, 0x20, 0xf4, 0x61, 0x62, 0xf5, 0x62, 0x61, 0x61, 0x01},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.v), func(t *testing.T) {
+			var b bytes.Buffer
+
+			if err := objconv.NewEncoder(NewCanonicalEmitter(&b)).Encode(test.v); err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(b.Bytes(), test.b) {
+				t.Errorf("bad encoding: %#v", b.Bytes())
+			}
+
+			var v interface{}
+
+			if err := objconv.NewDecoder(NewCanonicalParser(&b)).Decode(&v); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestCanonicalEmitterLengths(t *testing.T) {
+	var b bytes.Buffer
+
+	e := objconv.NewEncoder(NewCanonicalEmitter(&b))
+	i := 0
+
+	if err := e.EncodeArray(-1, func(e objconv.Encoder) error {
+		if i++; i > 2 {
+			return objconv.End
+		}
+		return e.EncodeMap(-1, func(k objconv.Encoder, v objconv.Encoder) error {
+			return objconv.End
+		})
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b.Bytes(), []byte{0x82, 0xa0, 0xa0}) {
+		t.Errorf("bad encoding: %#v", b.Bytes())
+	}
+
+	keys := []string{"a", "a"}
+	i = 0
+
+	err := e.EncodeMap(len(keys), func(k objconv.Encoder, v objconv.Encoder) error {
+		if err := k.Encode(keys[i]); err != nil {
+			return err
+		}
+		i++
+		return v.Encode(i)
+	})
+
+	if err == nil || !strings.Contains(err.Error(), "duplicate map key") {
+		t.Error(err)
+	}
+}
+
+func TestCanonicalParser(t *testing.T) {
+	tests := []struct {
+		name string
+		b    []byte
+	}{
+		{"integer not in the shortest form", []byte{0x18, 0x01}},
+		{"length not in the shortest form", []byte{0x59, 0x00, 0x01, 0x00}},
+		{"indefinite length", []byte{0x9f, 0xff}},
+		{"float not in the shortest form", []byte{0xfa, 0x3f, 0xc0, 0x00, 0x00}},
+		{"NaN not in the shortest form", []byte{0xf9, 0x7e, 0x01}},
+		{"unsorted map keys", []byte{0xa2, 0x61, 0x62, 0x01, 0x61, 0x61, 0x02}},
+		{"duplicate map keys", []byte{0xa2, 0x61, 0x61, 0x01, 0x61, 0x61, 0x02}},
+		{"longer map key first", []byte{0xa2, 0x62, 0x61, 0x61, 0x01, 0x61, 0x62, 0x02}},
+		{"bignum that fits in 64 bits", []byte{0xc2, 0x41, 0x01}},
+		{"bignum in an array that fits in 64 bits", []byte{0x81, 0xc2, 0x41, 0x01}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var v interface{}
+
+			if err := objconv.NewDecoder(NewParser(bytes.NewReader(test.b))).Decode(&v); err != nil {
+				t.Fatal("the default parser must accept the input:", err)
+			}
+
+			if err := objconv.NewDecoder(NewCanonicalParser(bytes.NewReader(test.b))).Decode(&v); err == nil {
+				t.Errorf("the canonical parser accepted the input: %#v", v)
+			}
+
+			if err := objconv.NewDecoder(NewCanonicalParser(bytes.NewReader(test.b))).Decode(nil); err == nil {
+				t.Error("the canonical parser accepted the input when discarding it")
+			}
+		})
+	}
+}
+
+func TestCompactFloats(t *testing.T) {
+	tests := []struct {
+		v float64
+		b []byte
+	}{
+		{0, []byte{0xf9, 0x00, 0x00}},
+		{math.Copysign(0, -1), []byte{0xf9, 0x80, 0x00}},
+		{65504, []byte{0xf9, 0x7b, 0xff}},
+		{0.00006103515625, []byte{0xf9, 0x04, 0x00}},
+		{3.4028234663852886e+38, []byte{0xfa, 0x7f, 0x7f, 0xff, 0xff}},
+		{1.0e+300, []byte{0xfb, 0x7e, 0x37, 0xe4, 0x3c, 0x88, 0x00, 0x75, 0x9c}},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.v), func(t *testing.T) {
+			var b bytes.Buffer
+
+			e := NewEmitter(&b)
+			e.SetCompactFloats(true)
+
+			if err := objconv.NewEncoder(e).Encode(test.v); err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(b.Bytes(), test.b) {
+				t.Errorf("bad encoding: %#v", b.Bytes())
+			}
+
+			var v float64
+
+			if err := Unmarshal(b.Bytes(), &v); err != nil {
+				t.Fatal(err)
+			}
+
+			if math.Float64bits(v) != math.Float64bits(test.v) {
+				t.Error("bad decoding:", v)
+			}
+		})
+	}
+}
+
+func TestFloat16(t *testing.T) {
+	for i := 0; i <= 0xffff; i++ {
+		h := uint16(i)
+		f := math.Float32frombits(f16tof32bits(h))
+
+		if f != f { // NaN
+			continue
+		}
+
+		if x, ok := f32tof16bits(f); !ok || x != h {
+			t.Errorf("%#04x: %g was converted back to %#04x", h, f, x)
+		}
+	}
+
+	if _, ok := f32tof16bits(1.0 / 3); ok {
+		t.Error("1/3 cannot be represented exactly in half precision")
+	}
+}
+
+func TestTypedValues(t *testing.T) {
+	tests := []struct {
+		v interface{}
+		b []byte
+	}{
+		{time.Minute, []byte{0xd9, 0x03, 0xea, 0xa1, 0x01, 0x18, 0x3c}},
+		{1500 * time.Millisecond, []byte{0xd9, 0x03, 0xea, 0xa2, 0x01, 0x01, 0x28, 0x1a, 0x1d, 0xcd, 0x65, 0x00}},
+		{-time.Nanosecond, []byte{0xd9, 0x03, 0xea, 0xa2, 0x01, 0x20, 0x28, 0x1a, 0x3b, 0x9a, 0xc9, 0xff}},
+		{errors.New("oops"), []byte{0xd8, 0x1b, 0x82, 0x65, 'e', 'r', 'r', 'o', 'r', 0x64, 'o', 'o', 'p', 's'}},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.v), func(t *testing.T) {
+			var b bytes.Buffer
+
+			e := NewEmitter(&b)
+			e.SetTypedValues(true)
+
+			if err := objconv.NewEncoder(e).Encode(test.v); err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(b.Bytes(), test.b) {
+				t.Errorf("bad encoding: %#v", b.Bytes())
+			}
+
+			var v interface{}
+
+			if err := Unmarshal(b.Bytes(), &v); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(v, test.v) {
+				t.Errorf("bad decoding: %#v", v)
+			}
+		})
+	}
+}
+
+func TestDurationContent(t *testing.T) {
+	tests := []struct {
+		b []byte
+		d time.Duration
+	}{
+		{[]byte{0xd9, 0x03, 0xea, 0x02}, 2 * time.Second},
+		{[]byte{0xd9, 0x03, 0xea, 0xf9, 0x3e, 0x00}, 1500 * time.Millisecond},
+		{[]byte{0xd9, 0x03, 0xea, 0xa2, 0x01, 0x01, 0x22, 0x19, 0x01, 0xf4}, 1500 * time.Millisecond},
+	}
+
+	for _, test := range tests {
+		var d time.Duration
+
+		if err := Unmarshal(test.b, &d); err != nil {
+			t.Error(err)
+		} else if d != test.d {
+			t.Errorf("%#v: bad duration: %s", test.b, d)
+		}
+	}
+
+	// Objects which aren't errors are decoded into tags.
+	var v interface{}
+
+	if err := Unmarshal([]byte{0xd8, 0x1b, 0x81, 0x61, 'x'}, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(v, Tag{Number: 27, Content: []interface{}{"x"}}) {
+		t.Errorf("bad object: %#v", v)
+	}
+}
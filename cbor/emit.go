@@ -1,9 +1,12 @@
 package cbor
 
 import (
+	"fmt"
 	"io"
 	"math"
-	"reflect"
+	"math/big"
+	"strconv"
+	"strings"
 	"time"
 	"unsafe"
 
@@ -20,17 +23,57 @@ type Emitter struct {
 	// The sback array is the initial backend array for the stack.
 	stack []int
 	sback [16]int
+
+	// In canonical mode the content of maps and arrays of unknown lengths is
+	// buffered in frames, w is the buffer of the innermost frame and out the
+	// writer that the emitter outputs to.
+	canonical bool
+	out       io.Writer
+	frames    []*frame
+	free      []*frame
+
+	// When compact is set, floats are written in the shortest form that
+	// preserves their value.
+	compact bool
+
+	// When typed is set, durations and errors are written as tagged items
+	// instead of strings.
+	typed bool
 }
 
 func NewEmitter(w io.Writer) *Emitter {
-	e := &Emitter{w: w}
+	e := &Emitter{w: w, out: w}
 	e.stack = e.sback[:0]
 	return e
 }
 
 func (e *Emitter) Reset(w io.Writer) {
 	e.w = w
+	e.out = w
 	e.stack = e.stack[:0]
+	e.free = append(e.free, e.frames...)
+	e.frames = e.frames[:0]
+}
+
+// SetCompactFloats configures whether the emitter writes floats in the
+// smallest of the half, single and double precision formats that preserves
+// their value, instead of using the precision of their Go type. NaN values
+// are written as the half-precision quiet NaN.
+//
+// Canonical emitters always write floats in their shortest form.
+func (e *Emitter) SetCompactFloats(compact bool) {
+	e.compact = compact
+}
+
+// SetTypedValues configures whether the emitter writes durations and errors as
+// tagged items which are decoded back into durations and errors, instead of
+// strings.
+//
+// Durations are written with the tag 1002 and a map holding the number of
+// seconds (key 1) and nanoseconds (key -9), errors are written with the tag 27
+// and an array of the type name "error" followed by the error message.
+func (e *Emitter) SetTypedValues(typed bool) {
+	e.typed = typed
 }
 
 func (e *Emitter) EmitNil() (err error) {
@@ -63,6 +106,11 @@ func (e *Emitter) EmitUint(v uint64, _ int) (err error) {
 func (e *Emitter) EmitFloat(v float64, bitSize int) (err error) {
 	n := 0
 
+	if e.canonical || e.compact {
+		_, err = e.w.Write(appendFloat(e.b[:0], v))
+		return
+	}
+
 	if bitSize == 32 {
 		n = 5
 		e.b[0] = majorByte(majorType7, svFloat32)
@@ -77,17 +125,93 @@ func (e *Emitter) EmitFloat(v float64, bitSize int) (err error) {
 	return
 }
 
+// EmitNumber writes the number literal v using the smallest representation,
+// integers that don't fit in 64 bits are written as bignums.
+func (e *Emitter) EmitNumber(v string) (err error) {
+	if strings.ContainsAny(v, ".eE") {
+		var f float64
+
+		// Numbers with more significant digits than a float64 can hold, or
+		// out of its range, are written as decimal fractions.
+		if m, exp, ok := splitDecimal(v); ok && m.CmpAbs(decimalMax) >= 0 {
+			return e.emitDecimal(m, exp)
+		}
+
+		if f, err = strconv.ParseFloat(v, 64); err != nil {
+			if m, exp, ok := splitDecimal(v); ok {
+				return e.emitDecimal(m, exp)
+			}
+			return
+		}
+
+		return e.EmitFloat(f, 64)
+	}
+
+	if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return e.EmitInt(i, 64)
+	}
+
+	if u, err := strconv.ParseUint(v, 10, 64); err == nil {
+		return e.EmitUint(u, 64)
+	}
+
+	x, ok := new(big.Int).SetString(v, 10)
+
+	if !ok {
+		return fmt.Errorf("objconv/cbor: invalid number literal %q", v)
+	}
+
+	return e.emitBignum(x)
+}
+
+func (e *Emitter) emitBignum(x *big.Int) (err error) {
+	tag := uint64(tagPositiveBignum)
+
+	if x.Sign() < 0 { // negative bignums encode -1-x
+		tag = tagNegativeBignum
+		x = new(big.Int).Neg(x)
+		x.Sub(x, bigOne)
+	}
+
+	if err = e.emitUint(majorType6, tag); err != nil {
+		return
+	}
+
+	return e.EmitBytes(x.Bytes())
+}
+
+func (e *Emitter) emitDecimal(m *big.Int, exp int64) (err error) {
+	if err = e.emitUint(majorType6, tagDecimalFraction); err != nil {
+		return
+	}
+
+	if err = e.emitUint(majorType4, 2); err != nil {
+		return
+	}
+
+	if err = e.EmitInt(exp, 64); err != nil {
+		return
+	}
+
+	switch {
+	case m.IsInt64():
+		return e.EmitInt(m.Int64(), 64)
+	case m.IsUint64():
+		return e.EmitUint(m.Uint64(), 64)
+	default:
+		return e.emitBignum(m)
+	}
+}
+
 func (e *Emitter) EmitString(v string) (err error) {
 	if err = e.emitUint(majorType3, uint64(len(v))); err != nil {
 		return
 	}
-	s := *(*reflect.StringHeader)(unsafe.Pointer(&v))
-	b := reflect.SliceHeader{
-		Data: s.Data,
-		Len:  s.Len,
-		Cap:  s.Len,
-	}
-	_, err = e.w.Write(*((*[]byte)(unsafe.Pointer(&b))))
+	b := struct {
+		string
+		int
+	}{v, len(v)}
+	_, err = e.w.Write(*(*[]byte)(unsafe.Pointer(&b)))
 	return
 }
 
@@ -120,10 +244,61 @@ func (e *Emitter) EmitTime(v time.Time) (err error) {
 }
 
 func (e *Emitter) EmitDuration(v time.Duration) (err error) {
-	return e.EmitString(string(objutil.AppendDuration(e.b[:0], v)))
+	if !e.typed {
+		return e.EmitString(string(objutil.AppendDuration(e.b[:0], v)))
+	}
+
+	s, ns := int64(v/time.Second), int64(v%time.Second)
+
+	if ns < 0 {
+		s, ns = s-1, ns+int64(time.Second)
+	}
+
+	n := uint64(1)
+
+	if ns != 0 {
+		n = 2
+	}
+
+	if err = e.EmitTag(tagDuration); err != nil {
+		return
+	}
+
+	if err = e.emitUint(majorType5, n); err != nil {
+		return
+	}
+
+	if err = e.EmitInt(1, 0); err != nil {
+		return
+	}
+
+	if err = e.EmitInt(s, 0); err != nil {
+		return
+	}
+
+	if ns != 0 {
+		if err = e.EmitInt(-9, 0); err != nil {
+			return
+		}
+		err = e.EmitInt(ns, 0)
+	}
+
+	return
 }
 
 func (e *Emitter) EmitError(v error) (err error) {
+	if !e.typed {
+		return e.EmitString(v.Error())
+	}
+
+	if err = e.EmitTag(tagObject); err != nil {
+		return
+	}
+
+	if _, err = e.w.Write(errorHead[:]); err != nil {
+		return
+	}
+
 	return e.EmitString(v.Error())
 }
 
@@ -134,6 +309,11 @@ func (e *Emitter) EmitArrayBegin(n int) (err error) {
 		return e.emitUint(majorType4, uint64(n))
 	}
 
+	if e.canonical {
+		e.push(majorType4)
+		return
+	}
+
 	e.b[0] = majorByte(majorType4, 31)
 	_, err = e.w.Write(e.b[:1])
 	return
@@ -145,6 +325,9 @@ func (e *Emitter) EmitArrayEnd() (err error) {
 	e.stack = e.stack[:i]
 
 	if n < 0 {
+		if e.canonical {
+			return e.pop()
+		}
 		e.b[0] = 0xFF
 		_, err = e.w.Write(e.b[:1])
 	}
@@ -152,12 +335,21 @@ func (e *Emitter) EmitArrayEnd() (err error) {
 }
 
 func (e *Emitter) EmitArrayNext() (err error) {
+	if e.canonical && e.stack[len(e.stack)-1] < 0 {
+		e.frames[len(e.frames)-1].next()
+	}
 	return
 }
 
 func (e *Emitter) EmitMapBegin(n int) (err error) {
 	e.stack = append(e.stack, n)
 
+	if e.canonical {
+		// Maps are always buffered so their keys can be sorted.
+		e.push(majorType5)
+		return
+	}
+
 	if n >= 0 {
 		return e.emitUint(majorType5, uint64(n))
 	}
@@ -172,6 +364,10 @@ func (e *Emitter) EmitMapEnd() (err error) {
 	n := e.stack[i]
 	e.stack = e.stack[:i]
 
+	if e.canonical {
+		return e.pop()
+	}
+
 	if n < 0 {
 		e.b[0] = 0xFF
 		_, err = e.w.Write(e.b[:1])
@@ -180,13 +376,45 @@ func (e *Emitter) EmitMapEnd() (err error) {
 }
 
 func (e *Emitter) EmitMapValue() (err error) {
+	if e.canonical {
+		f := e.frames[len(e.frames)-1]
+		f.values = append(f.values, f.buf.Len())
+	}
 	return
 }
 
 func (e *Emitter) EmitMapNext() (err error) {
+	if e.canonical {
+		f := e.frames[len(e.frames)-1]
+		f.keys = append(f.keys, f.buf.Len())
+	}
 	return
 }
 
+// AdapterScope returns the scope of the adapters installed by RegisterTag,
+// which are only used by CBOR emitters.
+func (e *Emitter) AdapterScope() string {
+	return adapterScope
+}
+
+// EmitTag writes the tag of the next item, it is used by the adapters of the
+// types registered with RegisterTag.
+func (e *Emitter) EmitTag(tag uint64) error {
+	return e.emitUint(majorType6, tag)
+}
+
+// EmitRaw writes b to the output, it must contain a value serialized in the
+// CBOR format.
+func (e *Emitter) EmitRaw(b []byte) (err error) {
+	_, err = e.w.Write(b)
+	return
+}
+
+// RawFormat returns "cbor", the format of the bytes accepted by EmitRaw.
+func (e *Emitter) RawFormat() string {
+	return "cbor"
+}
+
 func (e *Emitter) emitUint(m byte, v uint64) (err error) {
 	var n int
 
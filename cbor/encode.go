@@ -44,5 +44,6 @@ type marshaler struct {
 func newMarshaler() *marshaler {
 	m := &marshaler{}
 	m.w = &m.b
+	m.out = &m.b
 	return m
 }
@@ -2,6 +2,9 @@ package cbor
 
 import (
 	"io"
+	"net/url"
+	"reflect"
+	"regexp"
 
 	"github.com/dolab/objconv"
 )
@@ -12,6 +15,14 @@ var Codec = objconv.Codec{
 	NewParser:  func(r io.Reader) objconv.Parser { return NewParser(r) },
 }
 
+// CanonicalCodec for the deterministic encoding of CBOR, its emitter writes
+// equal values as identical bytes and its parser rejects the input that isn't
+// in the deterministic encoding.
+var CanonicalCodec = objconv.Codec{
+	NewEmitter: func(w io.Writer) objconv.Emitter { return NewCanonicalEmitter(w) },
+	NewParser:  func(r io.Reader) objconv.Parser { return NewCanonicalParser(r) },
+}
+
 func init() {
 	for _, name := range [...]string{
 		"application/cbor",
@@ -19,4 +30,19 @@ func init() {
 	} {
 		objconv.Register(name, Codec)
 	}
+
+	registerTagType(tagBase64URL, reflect.TypeOf(Base64URL(nil)))
+	registerTagType(tagBase64, reflect.TypeOf(Base64(nil)))
+	registerTagType(tagBase16, reflect.TypeOf(Base16(nil)))
+	registerTagType(tagUUID, reflect.TypeOf(UUID{}))
+
+	RegisterTag(tagURI, reflect.TypeOf(url.URL{}), objconv.Adapter{
+		Encode: encodeURL,
+		Decode: decodeURL,
+	})
+
+	RegisterTag(tagRegexp, reflect.TypeOf((*regexp.Regexp)(nil)), objconv.Adapter{
+		Encode: encodeRegexp,
+		Decode: decodeRegexp,
+	})
 }
@@ -6,9 +6,13 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"math/big"
+	"reflect"
+	"strconv"
 	"time"
 
 	"github.com/dolab/objconv"
+	"github.com/dolab/objconv/objutil"
 )
 
 type Parser struct {
@@ -18,19 +22,34 @@ type Parser struct {
 	s []byte    // string buffer
 	b [240]byte // read buffer
 
-	// Last tag loaded while parsing the type of the next available item.
-	tag uint64
-	typ objconv.Type
+	// Tags loaded while parsing the type of the next available item, from the
+	// outermost to the innermost. The tback array is the initial backend array
+	// for the tags.
+	tags  []uint64
+	tback [4]uint64
+	typ   objconv.Type
 
 	// This stack is used to keep track of the array map lengths being parsed.
 	// The sback array is the initial backend array for the stack.
 	stack []int
 	sback [16]int
+
+	raw  bool   // set when the consumed bytes are being recorded
+	recs int    // number of recordings in progress
+	ri   int    // offset in b of the first byte to record
+	rb   []byte // buffer of recorded bytes
+	roff int    // offset in rb of the value recorded by BeginRaw
+
+	// In canonical mode, the parser rejects the input that isn't in the
+	// deterministic encoding, the keys of the maps being parsed are recorded
+	// to verify their order.
+	canonical bool
+	keys      []mapKeys
 }
 
 func NewParser(r io.Reader) *Parser {
 	p := &Parser{r: r}
-	p.tag = noTag
+	p.tags = p.tback[:0]
 	p.stack = p.sback[:0]
 	return p
 }
@@ -39,8 +58,11 @@ func (p *Parser) Reset(r io.Reader) {
 	p.r = r
 	p.i = 0
 	p.j = 0
-	p.tag = noTag
+	p.tags = p.tags[:0]
 	p.stack = p.stack[:0]
+	p.raw = false
+	p.recs = 0
+	p.keys = p.keys[:0]
 }
 
 func (p *Parser) Buffered() io.Reader {
@@ -48,19 +70,18 @@ func (p *Parser) Buffered() io.Reader {
 }
 
 func (p *Parser) ParseType() (typ objconv.Type, err error) {
-	if p.tag != noTag {
+	if len(p.tags) != 0 {
 		typ = p.typ
 		return
 	}
 
-	var t = false
 	var s []byte
 
-	if s, err = p.peek(1); err != nil {
-		return
-	}
-
 	for {
+		if s, err = p.peek(1); err != nil {
+			return
+		}
+
 		switch m, b := majorType(s[0]); m {
 		case majorType0:
 			typ = objconv.Uint
@@ -81,26 +102,21 @@ func (p *Parser) ParseType() (typ objconv.Type, err error) {
 			typ = objconv.Map
 
 		case majorType6:
+			var tag uint64
 			var indef bool
-			if t {
-				err = errors.New("objconv/cbor: multiple tags found for a single item")
-				return
-			}
-			if p.tag, indef, err = p.parseUint(); err != nil {
+			if tag, indef, err = p.parseUint(); err != nil {
 				return
 			}
 			if indef {
 				err = errors.New("objconv/cbor: invalid indefinite length for major type 6")
 				return
 			}
-			switch p.tag {
-			case tagDateTime, tagTimestamp:
-				typ = objconv.Time
-			default: // unsupported tag, just fallback to use the base type
-				t = true
-				continue
+			// The self-describe tag only marks the data as CBOR, it carries
+			// no meaning for the item.
+			if tag != tagSelfDescribe {
+				p.tags = append(p.tags, tag)
 			}
-			p.typ = typ
+			continue
 
 		default:
 			switch b {
@@ -118,16 +134,129 @@ func (p *Parser) ParseType() (typ objconv.Type, err error) {
 
 			default:
 				err = fmt.Errorf("objconv/cbor: unexpected value in major type 7: %d", b)
+				return
+			}
+		}
+
+		if len(p.tags) != 0 {
+			switch p.tag() {
+			case tagDateTime, tagTimestamp:
+				typ = objconv.Time
+			case tagPositiveBignum, tagNegativeBignum:
+				var fits bool
+				if fits, err = p.bignumFits(p.tag() == tagNegativeBignum); err != nil {
+					p.tags = p.tags[:0]
+					return
+				}
+				switch {
+				case !fits:
+					// Bignums wider than 64 bits are loaded as floats,
+					// or as *big.Int values by DynamicType.
+					typ = objconv.Float
+				case p.tag() == tagPositiveBignum:
+					typ = objconv.Uint
+				default:
+					typ = objconv.Int
+				}
+			case tagDecimalFraction:
+				typ = objconv.Float
+			case tagDuration:
+				switch typ {
+				case objconv.Int, objconv.Uint, objconv.Float, objconv.Map:
+					typ = objconv.Duration
+				}
+			case tagObject:
+				// Other objects may be shorter than the head of errors,
+				// failing to peek it isn't an error.
+				if h, e := p.peek(len(errorHead)); e == nil && bytes.Equal(h, errorHead[:]) {
+					typ = objconv.Error
+				}
+			default: // other tags are decoded from the type of their content
 			}
+			p.typ = typ
 		}
 
 		return
 	}
 }
 
+// AdapterScope returns the scope of the adapters installed by RegisterTag,
+// which are only used by CBOR parsers.
+func (p *Parser) AdapterScope() string {
+	return adapterScope
+}
+
+// ParseTag returns the outermost tag of the item found by the last call to
+// ParseType, ok is false if the item has no tags. The following calls to the
+// parser read the content of the tag.
+func (p *Parser) ParseTag() (tag uint64, ok bool, err error) {
+	if _, err = p.ParseType(); err != nil || len(p.tags) == 0 {
+		return
+	}
+	tag, ok = p.tags[0], true
+	p.tags = p.tags[:copy(p.tags, p.tags[1:])]
+	return
+}
+
+// DynamicType returns the Go type that the item found by the last call to
+// ParseType is decoded into when the destination is an empty interface.
+//
+// Items with a tag registered by RegisterTag are decoded into the registered
+// type, items with other tags are decoded into Tag values unless the tag is
+// one of the tags that objconv types represent (times, bignums and decimal
+// fractions), or durations and errors. Bignums that don't fit in 64 bits are
+// decoded into *big.Int values and decimal fractions into objconv.Number
+// values, so they don't lose precision.
+func (p *Parser) DynamicType() reflect.Type {
+	if len(p.tags) == 0 {
+		return nil
+	}
+
+	tag := p.tags[0]
+
+	if t := tagTypeOf(tag); t != nil {
+		// Encoding hints only apply to byte strings.
+		if !isHintTag(tag) || (len(p.tags) == 1 && p.typ == objconv.Bytes) {
+			return t
+		}
+	}
+
+	if len(p.tags) == 1 {
+		switch tag {
+		case tagDateTime, tagTimestamp:
+			return nil
+		case tagPositiveBignum, tagNegativeBignum:
+			if p.typ == objconv.Float {
+				return bigIntPtrType
+			}
+			return nil
+		case tagDecimalFraction:
+			return numberType
+		case tagDuration, tagObject:
+			if p.typ == objconv.Duration || p.typ == objconv.Error {
+				return nil
+			}
+		}
+	}
+
+	return tagType
+}
+
+var (
+	bigIntPtrType = reflect.TypeOf((*big.Int)(nil))
+	numberType    = reflect.TypeOf(objconv.Number(""))
+)
+
+func (p *Parser) tag() uint64 {
+	if n := len(p.tags); n != 0 {
+		return p.tags[n-1]
+	}
+	return noTag
+}
+
 func (p *Parser) ParseNil() (err error) {
 	_, err = p.parseType7()
-	p.tag = noTag
+	p.tags = p.tags[:0]
 	return
 }
 
@@ -139,7 +268,7 @@ func (p *Parser) ParseBool() (v bool, err error) {
 	}
 
 	v = b == svTrue
-	p.tag = noTag
+	p.tags = p.tags[:0]
 	return
 }
 
@@ -147,6 +276,22 @@ func (p *Parser) ParseInt() (v int64, err error) {
 	var u uint64
 	var indef bool
 
+	if p.tag() == tagNegativeBignum {
+		var x *big.Int
+
+		if x, err = p.parseBignum(); err != nil {
+			return
+		}
+
+		if !x.IsInt64() {
+			err = fmt.Errorf("objconv/cbor: bignum %s overflows int64", x)
+			return
+		}
+
+		v = x.Int64()
+		return
+	}
+
 	if u, indef, err = p.parseUint(); err != nil {
 		return
 	}
@@ -157,13 +302,29 @@ func (p *Parser) ParseInt() (v int64, err error) {
 	}
 
 	v = -int64(u + 1)
-	p.tag = noTag
+	p.tags = p.tags[:0]
 	return
 }
 
 func (p *Parser) ParseUint() (v uint64, err error) {
 	var indef bool
 
+	if p.tag() == tagPositiveBignum {
+		var x *big.Int
+
+		if x, err = p.parseBignum(); err != nil {
+			return
+		}
+
+		if !x.IsUint64() {
+			err = fmt.Errorf("objconv/cbor: bignum %s overflows uint64", x)
+			return
+		}
+
+		v = x.Uint64()
+		return
+	}
+
 	if v, indef, err = p.parseUint(); err != nil {
 		return
 	}
@@ -173,7 +334,7 @@ func (p *Parser) ParseUint() (v uint64, err error) {
 		return
 	}
 
-	p.tag = noTag
+	p.tags = p.tags[:0]
 	return
 }
 
@@ -182,6 +343,21 @@ func (p *Parser) ParseFloat() (v float64, err error) {
 	var n int
 	var b byte
 
+	switch p.tag() {
+	case tagDecimalFraction:
+		if s, err = p.parseDecimal(); err != nil {
+			return
+		}
+		return strconv.ParseFloat(string(s), 64)
+
+	case tagPositiveBignum, tagNegativeBignum:
+		var x *big.Int
+		if x, err = p.parseBignum(); err == nil {
+			v, _ = new(big.Float).SetInt(x).Float64()
+		}
+		return
+	}
+
 	if b, err = p.parseType7(); err != nil {
 		return
 	}
@@ -208,8 +384,76 @@ func (p *Parser) ParseFloat() (v float64, err error) {
 		v = math.Float64frombits(getUint64(s))
 	}
 
+	if p.canonical {
+		var a [9]byte
+		if c := appendFloat(a[:0], v); c[0] != majorByte(majorType7, b) || !bytes.Equal(c[1:], s) {
+			err = fmt.Errorf("objconv/cbor: %g is not encoded in the shortest form", v)
+			return
+		}
+	}
+
 	p.i += n
-	p.tag = noTag
+	p.tags = p.tags[:0]
+	return
+}
+
+// ParseNumber returns the decimal representation of the number found by the
+// last call to ParseType, which may be a bignum.
+func (p *Parser) ParseNumber() (v []byte, err error) {
+	switch p.tag() {
+	case tagPositiveBignum, tagNegativeBignum:
+		var x *big.Int
+		if x, err = p.parseBignum(); err == nil {
+			v = x.Append(nil, 10)
+		}
+		return
+
+	case tagDecimalFraction:
+		return p.parseDecimal()
+	}
+
+	var s []byte
+
+	if s, err = p.peek(1); err != nil {
+		return
+	}
+
+	switch m, _ := majorType(s[0]); m {
+	case majorType0:
+		var u uint64
+		if u, err = p.ParseUint(); err == nil {
+			v = strconv.AppendUint(nil, u, 10)
+		}
+
+	case majorType1:
+		var u uint64
+		var indef bool
+
+		if u, indef, err = p.parseUint(); err != nil {
+			return
+		}
+
+		if indef {
+			err = errors.New("objconv/cbor: invalid indefinite length for major type 1")
+			return
+		}
+
+		// The value is -1-u, which may not fit in an int64.
+		if u != objutil.Uint64Max {
+			v = strconv.AppendUint([]byte{'-'}, u+1, 10)
+		} else {
+			v = append(v, "-18446744073709551616"...)
+		}
+
+		p.tags = p.tags[:0]
+
+	default:
+		var f float64
+		if f, err = p.ParseFloat(); err == nil {
+			v = strconv.AppendFloat(nil, f, 'g', -1, 64)
+		}
+	}
+
 	return
 }
 
@@ -217,7 +461,7 @@ func (p *Parser) ParseString() (v []byte, err error) {
 	if v, err = p.parseBytes(majorType3); err != nil {
 		return
 	}
-	p.tag = noTag
+	p.tags = p.tags[:0]
 	return
 }
 
@@ -225,14 +469,14 @@ func (p *Parser) ParseBytes() (v []byte, err error) {
 	if v, err = p.parseBytes(majorType2); err != nil {
 		return
 	}
-	p.tag = noTag
+	p.tags = p.tags[:0]
 	return
 }
 
 func (p *Parser) ParseTime() (v time.Time, err error) {
 	var s []byte
 
-	if p.tag == tagDateTime {
+	if p.tag() == tagDateTime {
 		if s, err = p.ParseString(); err != nil {
 			return
 		}
@@ -278,16 +522,133 @@ func (p *Parser) ParseTime() (v time.Time, err error) {
 		}
 	}
 
-	p.tag = noTag
+	p.tags = p.tags[:0]
 	return
 }
 
+// ParseDuration parses an item with the tag 1002, its content is either a
+// number of seconds or a map of the number of seconds (key 1) and fractions of
+// seconds (keys -3, -6 and -9).
 func (p *Parser) ParseDuration() (v time.Duration, err error) {
-	panic("objconv/cbor: ParseDuration should never be called because CBOR has no duration type, this is likely a bug in the decoder code")
+	var t objconv.Type
+
+	p.tags = p.tags[:0]
+
+	if t, err = p.ParseType(); err != nil {
+		return
+	}
+
+	switch t {
+	case objconv.Int, objconv.Uint:
+		var s int64
+		if s, err = p.parseInt64(); err == nil {
+			v = time.Duration(s) * time.Second
+		}
+		return
+
+	case objconv.Float:
+		var f float64
+		if f, err = p.ParseFloat(); err == nil {
+			v = time.Duration(f * float64(time.Second))
+		}
+		return
+
+	case objconv.Map:
+	default:
+		err = fmt.Errorf("objconv/cbor: cannot decode a duration from %s", t)
+		return
+	}
+
+	var n uint64
+	var indef bool
+
+	if n, indef, err = p.parseUint(); err != nil {
+		return
+	}
+
+	if indef {
+		err = errors.New("objconv/cbor: invalid indefinite length for the content of a duration")
+		return
+	}
+
+	for i := uint64(0); i != n; i++ {
+		var k, x int64
+
+		if k, err = p.parseInt64(); err != nil {
+			return
+		}
+
+		if x, err = p.parseInt64(); err != nil {
+			return
+		}
+
+		switch k {
+		case 1:
+			v += time.Duration(x) * time.Second
+		case -3:
+			v += time.Duration(x) * time.Millisecond
+		case -6:
+			v += time.Duration(x) * time.Microsecond
+		case -9:
+			v += time.Duration(x)
+		default:
+			err = fmt.Errorf("objconv/cbor: unsupported key in the content of a duration: %d", k)
+			return
+		}
+	}
+
+	return
 }
 
+// ParseError parses an item with the tag 27 whose content is the type name
+// "error" followed by the error message.
 func (p *Parser) ParseError() (v error, err error) {
-	panic("objconv/cbor: ParseError should never be called because CBOR has no error type, this is likely a bug in the decoder code")
+	var s []byte
+
+	p.tags = p.tags[:0]
+
+	if _, err = p.peek(len(errorHead)); err != nil {
+		return
+	}
+	p.i += len(errorHead)
+
+	if _, err = p.ParseType(); err != nil {
+		return
+	}
+
+	if s, err = p.ParseString(); err != nil {
+		return
+	}
+
+	v = errors.New(string(s))
+	return
+}
+
+// parseInt64 parses an integer which must be representable by an int64.
+func (p *Parser) parseInt64() (v int64, err error) {
+	var t objconv.Type
+	var u uint64
+
+	if t, err = p.ParseType(); err != nil {
+		return
+	}
+
+	switch t {
+	case objconv.Int:
+		return p.ParseInt()
+
+	case objconv.Uint:
+		if u, err = p.ParseUint(); err == nil {
+			if u > int64Max {
+				err = fmt.Errorf("objconv/cbor: %d cannot be represented by a signed 64 bits integer", u)
+			}
+			v = int64(u)
+		}
+		return
+	}
+
+	err = fmt.Errorf("objconv/cbor: expected an integer but found %s", t)
+	return
 }
 
 func (p *Parser) ParseArrayBegin() (n int, err error) {
@@ -309,7 +670,7 @@ func (p *Parser) ParseArrayBegin() (n int, err error) {
 	}
 
 	p.stack = append(p.stack, n)
-	p.tag = noTag
+	p.tags = p.tags[:0]
 	return
 }
 
@@ -351,21 +712,53 @@ func (p *Parser) ParseMapBegin() (n int, err error) {
 		n = int(u)
 	}
 
+	if p.canonical {
+		k := mapKeys{last: p.lastKey()}
+		if n != 0 {
+			k.off, k.rec = p.record(), true
+		}
+		p.keys = append(p.keys, k)
+	}
+
 	p.stack = append(p.stack, n)
-	p.tag = noTag
+	p.tags = p.tags[:0]
 	return
 }
 
 func (p *Parser) ParseMapEnd(n int) (err error) {
 	p.stack = p.stack[:len(p.stack)-1]
+
+	if p.canonical {
+		i := len(p.keys) - 1
+		if p.keys[i].rec {
+			p.recorded(p.keys[i].off)
+		}
+		p.keys = p.keys[:i]
+	}
 	return
 }
 
 func (p *Parser) ParseMapValue(n int) (err error) {
+	if p.canonical {
+		k := &p.keys[len(p.keys)-1]
+		b := p.recorded(k.off)
+		k.rec = false
+
+		if n != 0 && bytes.Compare(k.last, b) >= 0 {
+			return errors.New("objconv/cbor: map keys are not sorted or not unique")
+		}
+
+		k.last = append(k.last[:0], b...)
+	}
 	return
 }
 
 func (p *Parser) ParseMapNext(n int) (err error) {
+	if p.canonical {
+		k := &p.keys[len(p.keys)-1]
+		k.off, k.rec = p.record(), true
+	}
+
 	if p.stack[len(p.stack)-1] < 0 {
 		var s []byte
 
@@ -380,6 +773,159 @@ func (p *Parser) ParseMapNext(n int) (err error) {
 	return
 }
 
+// Skip consumes the item found by the last call to ParseType without loading
+// it, the parser jumps over the bytes of strings and reads only the heads of
+// arrays, maps and tags.
+//
+// In canonical mode the lengths, floats, bignums and map keys of the skipped
+// items are verified like when they are parsed.
+func (p *Parser) Skip() error {
+	if p.canonical && isBignumTag(p.tag()) {
+		_, err := p.parseBignum()
+		return err
+	}
+	p.tags = p.tags[:0]
+	return p.skipItem()
+}
+
+func (p *Parser) skipItem() (err error) {
+	var s []byte
+	var u uint64
+	var indef bool
+
+	if s, err = p.peek(1); err != nil {
+		return
+	}
+
+	m, b := majorType(s[0])
+
+	switch {
+	case m == majorType5 && p.canonical:
+		return p.skipMap()
+
+	case m == majorType7 && p.canonical && (b == svFloat16 || b == svFloat32 || b == svFloat64):
+		_, err = p.ParseFloat()
+		return
+
+	case m == majorType7 && b > svFloat64:
+		return fmt.Errorf("objconv/cbor: unexpected value in major type 7: %d", b)
+	}
+
+	if u, indef, err = p.parseUint(); err != nil {
+		return
+	}
+
+	if indef {
+		switch m {
+		case majorType2, majorType3, majorType4, majorType5:
+			return p.skipItems(-1)
+		default:
+			return fmt.Errorf("objconv/cbor: invalid indefinite length for major type %d", m)
+		}
+	}
+
+	switch m {
+	case majorType2, majorType3:
+		err = p.skip(u)
+
+	case majorType4, majorType5:
+		if m == majorType5 {
+			u *= 2
+		}
+		if u > intMax {
+			return fmt.Errorf("objconv/cbor: %d items is greater than what an int can represent", u)
+		}
+		err = p.skipItems(int(u))
+
+	case majorType6:
+		if p.canonical && isBignumTag(u) {
+			_, err = p.parseBignum()
+		} else {
+			err = p.skipItem()
+		}
+	}
+
+	return
+}
+
+func isBignumTag(tag uint64) bool {
+	return tag == tagPositiveBignum || tag == tagNegativeBignum
+}
+
+// skipItems consumes the next n items, or the items up to the next break code
+// if n is negative.
+func (p *Parser) skipItems(n int) (err error) {
+	for i := 0; n < 0 || i < n; i++ {
+		if n < 0 {
+			var s []byte
+
+			if s, err = p.peek(1); err != nil {
+				return
+			}
+
+			if s[0] == 0xFF {
+				p.i++
+				return
+			}
+		}
+
+		if err = p.skipItem(); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// skipMap consumes the next map with the methods that verify the order of its
+// keys in canonical mode.
+func (p *Parser) skipMap() (err error) {
+	var n int
+
+	if n, err = p.ParseMapBegin(); err != nil {
+		return
+	}
+
+	for i := 0; i != n; i++ {
+		if i != 0 {
+			if err = p.ParseMapNext(i); err != nil {
+				return
+			}
+		}
+
+		if err = p.skipItem(); err != nil {
+			return
+		}
+
+		if err = p.ParseMapValue(i); err != nil {
+			return
+		}
+
+		if err = p.skipItem(); err != nil {
+			return
+		}
+	}
+
+	return p.ParseMapEnd(n)
+}
+
+// skip consumes the next n bytes of the input.
+func (p *Parser) skip(n uint64) (err error) {
+	for {
+		k := uint64(p.j - p.i)
+
+		if n <= k {
+			p.i += int(n)
+			return
+		}
+
+		p.i, n = p.j, n-k
+
+		if err = p.fill(); err != nil {
+			return
+		}
+	}
+}
+
 func (p *Parser) parseUint() (v uint64, indef bool, err error) {
 	var s []byte
 	var n int
@@ -396,6 +942,10 @@ func (p *Parser) parseUint() (v uint64, indef bool, err error) {
 		p.i++
 		return
 	case b == 31:
+		if p.canonical {
+			err = errors.New("objconv/cbor: indefinite lengths are not allowed in canonical mode")
+			return
+		}
 		indef = true
 		p.i++
 		return
@@ -425,10 +975,158 @@ func (p *Parser) parseUint() (v uint64, indef bool, err error) {
 		v = getUint64(s[1:])
 	}
 
+	if p.canonical && v < minUint[b-iUint8] {
+		err = fmt.Errorf("objconv/cbor: %d is not encoded in the shortest form", v)
+		return
+	}
+
 	p.i += n
 	return
 }
 
+// minUint holds the smallest values which require each of the integer sizes.
+var minUint = [...]uint64{
+	iUint8 - iUint8:  24,
+	iUint16 - iUint8: objutil.Uint8Max + 1,
+	iUint32 - iUint8: objutil.Uint16Max + 1,
+	iUint64 - iUint8: objutil.Uint32Max + 1,
+}
+
+func (p *Parser) parseBignum() (x *big.Int, err error) {
+	var b []byte
+
+	if b, err = p.parseBytes(majorType2); err != nil {
+		return
+	}
+
+	if p.canonical && (len(b) <= 8 || b[0] == 0) {
+		err = errors.New("objconv/cbor: bignums must not fit in 64 bits nor have leading zeros in canonical mode")
+		return
+	}
+
+	x = new(big.Int).SetBytes(b)
+
+	if p.tag() == tagNegativeBignum { // the value is -1-n
+		x.Neg(x)
+		x.Sub(x, bigOne)
+	}
+
+	p.tags = p.tags[:0]
+	return
+}
+
+// bignumFits returns true if the content of the bignum that the parser is
+// positioned on can be represented by an int64 if the bignum is negative, or
+// an uint64 otherwise.
+func (p *Parser) bignumFits(negative bool) (bool, error) {
+	s, err := p.peek(1)
+	if err != nil {
+		return false, err
+	}
+
+	m, b := majorType(s[0])
+
+	if m != majorType2 || b > iUint64 {
+		// Invalid or indefinite lengths are reported when parsing the bignum.
+		return true, nil
+	}
+
+	h := 1
+	if b >= iUint8 {
+		h += 1 << (b - iUint8)
+	}
+
+	if s, err = p.peek(h); err != nil {
+		return false, err
+	}
+
+	var n uint64
+
+	switch b {
+	case iUint8:
+		n = uint64(s[1])
+	case iUint16:
+		n = uint64(getUint16(s[1:]))
+	case iUint32:
+		n = uint64(getUint32(s[1:]))
+	case iUint64:
+		n = getUint64(s[1:])
+	default:
+		n = uint64(b)
+	}
+
+	if n > uint64(len(p.b)-h) {
+		// Too long to be peeked, even with leading zeros the bignum is
+		// unlikely to fit in 64 bits.
+		return false, nil
+	}
+
+	if s, err = p.peek(h + int(n)); err != nil {
+		return false, err
+	}
+
+	s = s[h:]
+
+	for len(s) != 0 && s[0] == 0 {
+		s = s[1:]
+	}
+
+	return len(s) < 8 || (len(s) == 8 && (!negative || s[0] < 0x80)), nil
+}
+
+// parseDecimal parses the content of a decimal fraction, which is an array of
+// an exponent and a mantissa, and returns it as a number literal.
+func (p *Parser) parseDecimal() (v []byte, err error) {
+	var s []byte
+	var u uint64
+	var indef bool
+
+	p.tags = p.tags[:0]
+
+	if s, err = p.peek(1); err != nil {
+		return
+	}
+
+	if m, _ := majorType(s[0]); m == majorType4 {
+		u, indef, err = p.parseUint()
+	}
+
+	if err != nil {
+		return
+	}
+
+	if u != 2 || indef {
+		err = errors.New("objconv/cbor: decimal fractions must be arrays of an exponent and a mantissa")
+		return
+	}
+
+	var exp []byte
+	var man []byte
+
+	for _, x := range [...]*[]byte{&exp, &man} {
+		var t objconv.Type
+
+		if t, err = p.ParseType(); err != nil {
+			return
+		}
+
+		// Wide bignums are reported as floats but are still integers.
+		if t != objconv.Int && t != objconv.Uint && !(t == objconv.Float && isBignumTag(p.tag())) {
+			err = fmt.Errorf("objconv/cbor: decimal fractions must be made of integers, found %s", t)
+			return
+		}
+
+		if s, err = p.ParseNumber(); err != nil {
+			return
+		}
+
+		*x = append(*x, s...)
+	}
+
+	v = append(append(man, 'e'), exp...)
+	return
+}
+
 func (p *Parser) parseBytes(m byte) (v []byte, err error) {
 	var s []byte
 	var u uint64
@@ -512,25 +1210,92 @@ func (p *Parser) load(n int) (b []byte, err error) {
 		}
 
 		copy(p.s[i:], p.b[p.i:p.i+n1])
-
-		if p.i += n1; p.i == p.j {
-			p.i = 0
-			p.j = 0
-		}
-
+		p.i += n1
 		i += n1
 	}
 
+	if p.i == p.j {
+		p.discard()
+		p.i = 0
+		p.j = 0
+	}
+
 	if i != j {
 		if _, err = io.ReadFull(p.r, p.s[i:]); err != nil {
 			return
 		}
+
+		if p.raw {
+			p.rb = append(p.rb, p.s[i:]...)
+		}
 	}
 
 	b = p.s
 	return
 }
 
+// discard accounts for the bytes consumed from the read buffer before they get
+// overwritten, the caller is expected to move the unread bytes to the beginning
+// of the buffer.
+func (p *Parser) discard() {
+	if p.raw {
+		p.rb = append(p.rb, p.b[p.ri:p.i]...)
+		p.ri = 0
+	}
+}
+
+// record starts recording the bytes consumed by the parser, it returns the
+// offset in the recorded bytes where the recording starts.
+func (p *Parser) record() int {
+	if p.raw {
+		p.rb = append(p.rb, p.b[p.ri:p.i]...)
+	} else {
+		p.raw = true
+		p.rb = p.rb[:0]
+	}
+	p.ri = p.i
+	p.recs++
+	return len(p.rb)
+}
+
+// recorded stops the recording that started at off and returns the bytes
+// consumed since then, the returned slice is only valid until the next call to
+// the parser.
+func (p *Parser) recorded(off int) []byte {
+	p.rb = append(p.rb, p.b[p.ri:p.i]...)
+	p.ri = p.i
+	if p.recs--; p.recs == 0 {
+		p.raw = false
+	}
+	return p.rb[off:]
+}
+
+// lastKey returns a buffer that can be reused to hold map keys, it is taken
+// from the keys of a map that was parsed before at the same nesting level.
+func (p *Parser) lastKey() []byte {
+	if n := len(p.keys); n < cap(p.keys) {
+		return p.keys[:n+1][n].last[:0]
+	}
+	return nil
+}
+
+// BeginRaw starts recording the bytes consumed by the parser.
+func (p *Parser) BeginRaw() error {
+	p.roff = p.record()
+	return nil
+}
+
+// EndRaw stops recording and returns the bytes consumed since BeginRaw was
+// called.
+func (p *Parser) EndRaw() []byte {
+	return p.recorded(p.roff)
+}
+
+// RawFormat returns "cbor", the format of the bytes returned by EndRaw.
+func (p *Parser) RawFormat() string {
+	return "cbor"
+}
+
 func (p *Parser) peek(n int) (b []byte, err error) {
 	for (p.i + n) > p.j {
 		if err = p.fill(); err != nil {
@@ -543,6 +1308,7 @@ func (p *Parser) peek(n int) (b []byte, err error) {
 
 func (p *Parser) fill() (err error) {
 	n := p.j - p.i
+	p.discard()
 	copy(p.b[:], p.b[p.i:p.j])
 	p.i = 0
 	p.j = n
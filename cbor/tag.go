@@ -0,0 +1,284 @@
+package cbor
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sync"
+
+	"github.com/dolab/objconv"
+)
+
+// Tag represents a CBOR tagged item whose tag has no registered Go type.
+//
+// Tag values are encoded as tagged items by the CBOR emitter, other emitters
+// only receive the content of the tag.
+type Tag struct {
+	Number  uint64
+	Content interface{}
+}
+
+// Base64URL, Base64 and Base16 are byte slices which are expected to be
+// converted to text with the base64url, base64 and base16 encodings, they
+// represent byte strings carrying the tags 21, 22 and 23.
+//
+// The CBOR emitter writes them as tagged byte strings, emitters of text
+// formats write them as strings in the expected encoding.
+type Base64URL []byte
+type Base64 []byte
+type Base16 []byte
+
+// EncodeValue satisfies the objconv.ValueEncoder interface.
+func (b Base64URL) EncodeValue(e objconv.Encoder) error {
+	return encodeHint(e, tagBase64URL, b, base64.RawURLEncoding.EncodeToString)
+}
+
+// DecodeValue satisfies the objconv.ValueDecoder interface.
+func (b *Base64URL) DecodeValue(d objconv.Decoder) error {
+	return decodeHint(d, (*[]byte)(b), base64.RawURLEncoding.DecodeString)
+}
+
+// EncodeValue satisfies the objconv.ValueEncoder interface.
+func (b Base64) EncodeValue(e objconv.Encoder) error {
+	return encodeHint(e, tagBase64, b, base64.StdEncoding.EncodeToString)
+}
+
+// DecodeValue satisfies the objconv.ValueDecoder interface.
+func (b *Base64) DecodeValue(d objconv.Decoder) error {
+	return decodeHint(d, (*[]byte)(b), base64.StdEncoding.DecodeString)
+}
+
+// EncodeValue satisfies the objconv.ValueEncoder interface.
+func (b Base16) EncodeValue(e objconv.Encoder) error {
+	return encodeHint(e, tagBase16, b, hex.EncodeToString)
+}
+
+// DecodeValue satisfies the objconv.ValueDecoder interface.
+func (b *Base16) DecodeValue(d objconv.Decoder) error {
+	return decodeHint(d, (*[]byte)(b), hex.DecodeString)
+}
+
+// RegisterTag maps the CBOR tag number to typ, using the adapter functions to
+// encode and decode the content of the tag.
+//
+// The CBOR emitter writes values of type typ as items tagged with number,
+// and the CBOR parser decodes items tagged with number into values of type
+// typ when the destination is an empty interface. The adapter is only used by
+// the CBOR emitters and parsers, other formats encode and decode values of
+// typ as usual.
+//
+// A typical use case for this function is to be called during the package
+// initialization phase to support new tags.
+func RegisterTag(number uint64, typ reflect.Type, adapter objconv.Adapter) {
+	encode, decode := adapter.Encode, adapter.Decode
+
+	objconv.InstallScoped(adapterScope, typ, objconv.Adapter{
+		Encode: func(e objconv.Encoder, v reflect.Value) (err error) {
+			if v.Kind() == reflect.Ptr && v.IsNil() {
+				return e.Emitter.EmitNil()
+			}
+			if err = emitTag(e, number); err != nil {
+				return
+			}
+			return encode(e, v)
+		},
+		Decode: func(d objconv.Decoder, to reflect.Value) (err error) {
+			if err = parseTag(d); err != nil {
+				return
+			}
+			return decode(d, to)
+		},
+	})
+
+	registerTagType(number, typ)
+}
+
+// registerTagType maps the CBOR tag number to typ, which encodes and decodes
+// the tag itself.
+func registerTagType(number uint64, typ reflect.Type) {
+	tagMutex.Lock()
+	tagStore[number] = typ
+	tagMutex.Unlock()
+}
+
+func tagTypeOf(number uint64) (t reflect.Type) {
+	tagMutex.RLock()
+	t = tagStore[number]
+	tagMutex.RUnlock()
+	return
+}
+
+func isHintTag(number uint64) bool {
+	return number == tagBase64URL || number == tagBase64 || number == tagBase16
+}
+
+var (
+	tagMutex sync.RWMutex
+	tagStore = make(map[uint64]reflect.Type)
+	tagType  = reflect.TypeOf(Tag{})
+)
+
+// The tagEmitter interface is implemented by the CBOR emitter and the types
+// that embed it.
+type tagEmitter interface {
+	EmitTag(uint64) error
+}
+
+// The tagParser interface is implemented by the CBOR parser and the types that
+// embed it.
+type tagParser interface {
+	ParseTag() (uint64, bool, error)
+}
+
+// EncodeValue satisfies the objconv.ValueEncoder interface, only the CBOR
+// emitter writes the tag number.
+func (t Tag) EncodeValue(e objconv.Encoder) error {
+	if err := emitTag(e, t.Number); err != nil {
+		return err
+	}
+	return e.Encode(t.Content)
+}
+
+// DecodeValue satisfies the objconv.ValueDecoder interface, the tag number is
+// left to zero by parsers other than the CBOR parser.
+func (t *Tag) DecodeValue(d objconv.Decoder) (err error) {
+	var tag Tag
+
+	if x, ok := d.Parser.(tagParser); ok {
+		if tag.Number, _, err = x.ParseTag(); err != nil {
+			return
+		}
+	}
+
+	if err = d.Decode(&tag.Content); err == nil {
+		*t = tag
+	}
+	return
+}
+
+func emitTag(e objconv.Encoder, number uint64) error {
+	if x, ok := e.Emitter.(tagEmitter); ok {
+		return x.EmitTag(number)
+	}
+	return nil
+}
+
+func parseTag(d objconv.Decoder) (err error) {
+	if x, ok := d.Parser.(tagParser); ok {
+		_, _, err = x.ParseTag()
+	}
+	return
+}
+
+// encodeHint writes b as a byte string tagged with number, or as a string in
+// the expected encoding when the emitter produces a text format.
+func encodeHint(e objconv.Encoder, number uint64, b []byte, encode func([]byte) string) error {
+	if isTextEmitter(e.Emitter) {
+		return e.Emitter.EmitString(encode(b))
+	}
+	if err := emitTag(e, number); err != nil {
+		return err
+	}
+	return e.Emitter.EmitBytes(b)
+}
+
+// decodeHint loads a byte string into b, or a string in the expected encoding
+// when the parser returns one.
+func decodeHint(d objconv.Decoder, b *[]byte, decode func(string) ([]byte, error)) (err error) {
+	var t objconv.Type
+	var s []byte
+
+	if err = parseTag(d); err != nil {
+		return
+	}
+
+	if t, err = d.Parser.ParseType(); err != nil {
+		return
+	}
+
+	switch t {
+	case objconv.Nil:
+		err = d.Parser.ParseNil()
+
+	case objconv.Bytes:
+		s, err = d.Parser.ParseBytes()
+		s = append([]byte(nil), s...)
+
+	case objconv.String:
+		if s, err = d.Parser.ParseString(); err == nil {
+			s, err = decode(string(s))
+		}
+
+	default:
+		err = fmt.Errorf("objconv/cbor: cannot decode byte slice from %s", t)
+	}
+
+	if err == nil {
+		*b = s
+	}
+	return
+}
+
+func encodeURL(e objconv.Encoder, v reflect.Value) error {
+	u := v.Interface().(url.URL)
+	return e.Encode(u.String())
+}
+
+func decodeURL(d objconv.Decoder, to reflect.Value) (err error) {
+	var u *url.URL
+	var s string
+
+	if err = d.Decode(&s); err != nil {
+		return
+	}
+
+	if u, err = url.Parse(s); err != nil {
+		err = errors.New("objconv/cbor: bad URL: " + err.Error())
+		return
+	}
+
+	if to.IsValid() {
+		to.Set(reflect.ValueOf(*u))
+	}
+	return
+}
+
+func encodeRegexp(e objconv.Encoder, v reflect.Value) error {
+	if v.IsNil() {
+		return e.Emitter.EmitNil()
+	}
+	return e.Encode(v.Interface().(*regexp.Regexp).String())
+}
+
+func decodeRegexp(d objconv.Decoder, to reflect.Value) (err error) {
+	var r *regexp.Regexp
+	var s *string
+
+	if err = d.Decode(&s); err != nil || s == nil {
+		if err == nil && to.IsValid() {
+			to.Set(reflect.Zero(to.Type()))
+		}
+		return
+	}
+
+	if r, err = regexp.Compile(*s); err != nil {
+		err = errors.New("objconv/cbor: bad regular expression: " + err.Error())
+		return
+	}
+
+	if to.IsValid() {
+		to.Set(reflect.ValueOf(r))
+	}
+	return
+}
+
+func isTextEmitter(emitter objconv.Emitter) bool {
+	e, _ := emitter.(interface {
+		TextEmitter() bool
+	})
+	return e != nil && e.TextEmitter()
+}
@@ -0,0 +1,96 @@
+package cbor
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dolab/objconv"
+)
+
+// UUID represents the 16 bytes of an universally unique identifier.
+//
+// UUID values are encoded as byte strings with the tag 37 by the CBOR emitter,
+// as strings in their canonical form by emitters of text formats, and as byte
+// slices by other emitters.
+type UUID [16]byte
+
+// ParseUUID parses the canonical representation of an UUID, made of groups of
+// 8, 4, 4, 4 and 12 hexadecimal digits separated by dashes.
+func ParseUUID(s string) (id UUID, err error) {
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		err = fmt.Errorf("objconv/cbor: invalid UUID: %q", s)
+		return
+	}
+	if _, err = hex.Decode(id[:], []byte(strings.Replace(s, "-", "", -1))); err != nil {
+		err = fmt.Errorf("objconv/cbor: invalid UUID: %q", s)
+	}
+	return
+}
+
+// String returns the canonical representation of id.
+func (id UUID) String() string {
+	var b [36]byte
+	hex.Encode(b[:8], id[:4])
+	hex.Encode(b[9:13], id[4:6])
+	hex.Encode(b[14:18], id[6:8])
+	hex.Encode(b[19:23], id[8:10])
+	hex.Encode(b[24:], id[10:])
+	b[8], b[13], b[18], b[23] = '-', '-', '-', '-'
+	return string(b[:])
+}
+
+// EncodeValue satisfies the objconv.ValueEncoder interface.
+func (id UUID) EncodeValue(e objconv.Encoder) error {
+	if isTextEmitter(e.Emitter) {
+		return e.Emitter.EmitString(id.String())
+	}
+
+	if err := emitTag(e, tagUUID); err != nil {
+		return err
+	}
+
+	return e.Emitter.EmitBytes(id[:])
+}
+
+// DecodeValue satisfies the objconv.ValueDecoder interface.
+func (id *UUID) DecodeValue(d objconv.Decoder) (err error) {
+	var t objconv.Type
+	var b []byte
+	var v UUID
+
+	if err = parseTag(d); err != nil {
+		return
+	}
+
+	if t, err = d.Parser.ParseType(); err != nil {
+		return
+	}
+
+	switch t {
+	case objconv.Nil:
+		err = d.Parser.ParseNil()
+
+	case objconv.Bytes:
+		if b, err = d.Parser.ParseBytes(); err == nil {
+			if len(b) != len(v) {
+				err = fmt.Errorf("objconv/cbor: invalid UUID length: %d", len(b))
+			}
+			copy(v[:], b)
+		}
+
+	case objconv.String:
+		if b, err = d.Parser.ParseString(); err == nil {
+			v, err = ParseUUID(string(b))
+		}
+
+	default:
+		err = errors.New("objconv/cbor: cannot decode UUID from " + t.String())
+	}
+
+	if err == nil {
+		*id = v
+	}
+	return
+}
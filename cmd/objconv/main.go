@@ -11,49 +11,15 @@ import (
 	"strings"
 
 	"github.com/dolab/objconv"
+	_ "github.com/dolab/objconv/bson"
 	_ "github.com/dolab/objconv/cbor"
 	_ "github.com/dolab/objconv/json"
 	_ "github.com/dolab/objconv/msgpack"
 	_ "github.com/dolab/objconv/resp"
+	_ "github.com/dolab/objconv/toml"
 	_ "github.com/dolab/objconv/yaml"
 )
 
-// document is used to preserve the order of keys in maps.
-type document []item
-
-type item struct {
-	K interface{}
-	V interface{}
-}
-
-func (doc document) EncodeValue(e objconv.Encoder) error {
-	i := 0
-	return e.EncodeMap(len(doc), func(k objconv.Encoder, v objconv.Encoder) (err error) {
-		if err = k.Encode(doc[i].K); err != nil {
-			return
-		}
-		if err = v.Encode(doc[i].V); err != nil {
-			return
-		}
-		i++
-		return
-	})
-}
-
-func (doc *document) DecodeValue(d objconv.Decoder) error {
-	return d.DecodeMap(func(k objconv.Decoder, v objconv.Decoder) (err error) {
-		var item item
-		if err = k.Decode(&item.K); err != nil {
-			return
-		}
-		if err = v.Decode(&item.V); err != nil {
-			return
-		}
-		*doc = append(*doc, item)
-		return
-	})
-}
-
 func main() {
 	var r = bufio.NewReader(os.Stdin)
 	var w = bufio.NewWriter(os.Stdout)
@@ -108,9 +74,8 @@ func conv(w io.Writer, output string, r io.Reader, input string, pretty bool) (e
 		return
 	}
 
-	var d = objconv.NewStreamDecoder(ic.NewParser(r))
+	var d = ic.NewStreamDecoder(r)
 	var e *objconv.StreamEncoder
-	var v interface{}
 	var m = oc.NewEmitter(w)
 
 	if pretty {
@@ -119,26 +84,42 @@ func conv(w io.Writer, output string, r io.Reader, input string, pretty bool) (e
 		}
 	}
 
-	if e, err = d.Encoder(m); err != nil {
+	// Overwrite the type used for decoding maps so we can preserve the order
+	// of the keys.
+	d.MapType = reflect.TypeOf(objconv.OrderedMap{})
+
+	// Values that were read before the encoder was created.
+	var values []interface{}
+
+	if ic.NewStreamParser == nil {
+		e, err = d.Encoder(m)
+	} else {
+		// Formats like YAML represent streams as sequences of documents which
+		// the stream decoder presents as an array, the output is only an array
+		// when there is more than one document.
+		if values, err = peek(d, 2); err == nil {
+			switch len(values) {
+			case 0:
+				err = io.EOF
+			case 1:
+				err = objconv.NewEncoder(m).Encode(values[0])
+			default:
+				e = objconv.NewStreamEncoder(m)
+			}
+		}
+	}
+
+	if err != nil {
 		if err == io.EOF { // empty input
 			err = nil
 		}
 		return
 	}
 
-	// Overwrite the type used for decoding maps so we can preserve the order
-	// of the keys.
-	d.MapType = reflect.TypeOf(document(nil))
-
-	for d.Decode(&v) == nil {
-		if err = e.Encode(v); err != nil {
+	if e != nil {
+		if err = encode(e, values, d); err != nil {
 			return
 		}
-		v = nil
-	}
-
-	if err = e.Close(); err != nil {
-		return
 	}
 
 	// Not ideal but does the job, if the output is JSON we add a newline
@@ -150,3 +131,40 @@ func conv(w io.Writer, output string, r io.Reader, input string, pretty bool) (e
 	err = d.Err()
 	return
 }
+
+// encode writes values and the rest of the values read from d to e.
+func encode(e *objconv.StreamEncoder, values []interface{}, d *objconv.StreamDecoder) (err error) {
+	for _, v := range values {
+		if err = e.Encode(v); err != nil {
+			return
+		}
+	}
+
+	var v interface{}
+
+	for d.Decode(&v) == nil {
+		if err = e.Encode(v); err != nil {
+			return
+		}
+		v = nil
+	}
+
+	return e.Close()
+}
+
+// peek reads up to n values from d.
+func peek(d *objconv.StreamDecoder, n int) (values []interface{}, err error) {
+	for len(values) != n {
+		var v interface{}
+
+		if err = d.Decode(&v); err != nil {
+			if err == objconv.End {
+				err = nil
+			}
+			break
+		}
+
+		values = append(values, v)
+	}
+	return
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestConv(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		output string
+		in     string
+		out    string
+	}{
+		{
+			name:   "json value",
+			input:  "json",
+			output: "json",
+			in:     `{"b":1,"a":2}`,
+			out:    "{\"b\":1,\"a\":2}\n",
+		},
+		{
+			name:   "json array",
+			input:  "json",
+			output: "json",
+			in:     `[1,2,3]`,
+			out:    "[1,2,3]\n",
+		},
+		{
+			name:   "yaml single document",
+			input:  "yaml",
+			output: "json",
+			in:     "a: 1\nb: 2\n",
+			out:    "{\"a\":1,\"b\":2}\n",
+		},
+		{
+			name:   "yaml single document with a sequence",
+			input:  "yaml",
+			output: "json",
+			in:     "- 1\n- 2\n",
+			out:    "[1,2]\n",
+		},
+		{
+			name:   "yaml multiple documents",
+			input:  "yaml",
+			output: "json",
+			in:     "a: 1\n---\nb: 2\n---\nc: 3\n",
+			out:    "[{\"a\":1},{\"b\":2},{\"c\":3}]\n",
+		},
+		{
+			name:   "yaml empty input",
+			input:  "yaml",
+			output: "json",
+			in:     "",
+			out:    "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := &bytes.Buffer{}
+
+			if err := conv(b, test.output, strings.NewReader(test.in), test.input, false); err != nil {
+				t.Fatal(err)
+			}
+
+			if s := b.String(); s != test.out {
+				t.Errorf("\n- expected: %q\n- found:    %q", test.out, s)
+			}
+		})
+	}
+}
+
+func TestConvError(t *testing.T) {
+	b := &bytes.Buffer{}
+
+	if err := conv(b, "json", strings.NewReader("a: 1\n---\nb: [\n"), "yaml", false); err == nil {
+		t.Error("expected an error when converting an invalid document")
+	}
+}
@@ -9,6 +9,13 @@ import (
 type Codec struct {
 	NewEmitter func(io.Writer) Emitter
 	NewParser  func(io.Reader) Parser
+
+	// NewStreamEmitter and NewStreamParser are optional, they are set by
+	// formats where streams of values are not represented as arrays, like
+	// multi-document YAML files. NewEmitter and NewParser are used to create
+	// stream encoders and decoders when they are nil.
+	NewStreamEmitter func(io.Writer) Emitter
+	NewStreamParser  func(io.Reader) Parser
 }
 
 // NewEncoder returns a new encoder that outputs to w.
@@ -23,11 +30,17 @@ func (c Codec) NewDecoder(r io.Reader) *Decoder {
 
 // NewStreamEncoder returns a new stream encoder that outputs to w.
 func (c Codec) NewStreamEncoder(w io.Writer) *StreamEncoder {
+	if c.NewStreamEmitter != nil {
+		return NewStreamEncoder(c.NewStreamEmitter(w))
+	}
 	return NewStreamEncoder(c.NewEmitter(w))
 }
 
 // NewStreamDecoder returns a new stream decoder that takes input from r.
 func (c Codec) NewStreamDecoder(r io.Reader) *StreamDecoder {
+	if c.NewStreamParser != nil {
+		return NewStreamDecoder(c.NewStreamParser(r))
+	}
 	return NewStreamDecoder(c.NewParser(r))
 }
 
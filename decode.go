@@ -4,6 +4,7 @@ import (
 	"encoding"
 	"errors"
 	"fmt"
+	"io"
 	"reflect"
 	"strconv"
 	"time"
@@ -24,9 +25,45 @@ type Decoder struct {
 	// there is not destination type (when decoding to an empty interface).
 	MapType reflect.Type
 
+	// DisallowUnknownFields causes the decoder to return an error when a map
+	// key doesn't match any of the fields of the struct being decoded, instead
+	// of silently discarding the value.
+	DisallowUnknownFields bool
+
+	// FieldMatch configures how map keys are matched against the names of the
+	// fields of the struct being decoded.
+	FieldMatch FieldMatch
+
+	// UseNumber causes the decoder to load numbers as Number values instead of
+	// int64, uint64 or float64 when decoding into an empty interface.
+	UseNumber bool
+
 	off int // offset of the value when decoding a map
+
+	// Optional interfaces of the parser which are used for every value, they
+	// are looked up once by the methods that start decoding.
+	hints   hintParser
+	fields  fieldParser
+	checked bool
 }
 
+// FieldMatch is an enumeration of the strategies that decoders can use to match
+// map keys against the names of struct fields.
+type FieldMatch int
+
+const (
+	// DefaultFieldMatch matches names exactly, falling back to case-insensitive
+	// matching for fields that have no `objconv` tag, the same way that the
+	// standard encoding/json package does.
+	DefaultFieldMatch FieldMatch = iota
+
+	// ExactFieldMatch only matches names that are exactly equal.
+	ExactFieldMatch
+
+	// FoldFieldMatch falls back to case-insensitive matching for all fields.
+	FoldFieldMatch
+)
+
 // NewDecoder returns a decoder object that uses p, will panic if p is nil.
 func NewDecoder(p Parser) *Decoder {
 	if p == nil {
@@ -40,7 +77,14 @@ func NewDecoder(p Parser) *Decoder {
 //
 // The method panics if v is neither a pointer type nor implements the
 // ValueDecoder interface, or if v is a nil pointer.
+//
+// Errors that occur while loading the value are returned as *DecodeError, which
+// carries the path of the value that failed, the underlying error is available
+// from its Err field or by calling Unwrap. Sentinel errors like io.EOF and
+// io.ErrUnexpectedEOF, and errors returned by the DecodeValue method of v are
+// returned unchanged.
 func (d Decoder) Decode(v interface{}) error {
+	d.check()
 	to := reflect.ValueOf(v)
 
 	if d.off != 0 {
@@ -53,7 +97,7 @@ func (d Decoder) Decode(v interface{}) error {
 	if !to.IsValid() {
 		// This special case for a nil value is used to make it possible to
 		// discard decoded values.
-		_, err := d.decodeInterface(to)
+		_, err := d.skip()
 		return err
 	}
 
@@ -61,6 +105,7 @@ func (d Decoder) Decode(v interface{}) error {
 	// methods that are based on reflection.
 	switch x := v.(type) {
 	case ValueDecoder:
+		d.hint(to.Type())
 		return x.DecodeValue(d)
 	}
 
@@ -73,8 +118,69 @@ func (d Decoder) Decode(v interface{}) error {
 		to = to.Elem()
 	}
 
-	_, err := d.decode(to)
-	return err
+	d.hint(to.Type())
+	typ, err := d.decode(to)
+	return d.wrapError(err, "", typ, to.Type())
+}
+
+// wrapError returns err as a *DecodeError, with path prepended to the path
+// already carried by the error if it was already wrapped by a nested call.
+//
+// Sentinel errors like End, io.EOF and io.ErrUnexpectedEOF are returned
+// unchanged so the callers can still compare them.
+func (d Decoder) wrapError(err error, path string, typ Type, to reflect.Type) error {
+	switch e := err.(type) {
+	case nil:
+		return nil
+
+	case *DecodeError:
+		e.Path = path + e.Path
+		return e
+
+	case *UnknownFieldError:
+		e.Path = path + e.Path
+		return e
+	}
+
+	if err == End || err == Shadow || err == io.EOF || err == io.ErrUnexpectedEOF {
+		return err
+	}
+
+	e := &DecodeError{
+		Path:     path,
+		Type:     to,
+		WireType: typ,
+		Offset:   -1,
+		Err:      err,
+	}
+
+	if p, ok := d.Parser.(offsetParser); ok {
+		e.Offset = p.Offset()
+	}
+
+	if p, ok := d.Parser.(positionParser); ok {
+		e.Line, e.Column = p.Position()
+	}
+
+	return e
+}
+
+// check looks up the optional interfaces that the parser implements, unless it
+// was already done by the decoder that d was copied from.
+func (d *Decoder) check() {
+	if !d.checked {
+		d.hints, _ = d.Parser.(hintParser)
+		d.fields, _ = d.Parser.(fieldParser)
+		d.checked = true
+	}
+}
+
+// hint passes the type of the value about to be decoded to the parser if it
+// needs it to tell the types of values apart.
+func (d Decoder) hint(t reflect.Type) {
+	if d.hints != nil {
+		d.hints.HintType(t)
+	}
 }
 
 func (d Decoder) decode(to reflect.Value) (Type, error) {
@@ -388,6 +494,20 @@ func (d Decoder) decodeStringFromType(t Type, to reflect.Value) (err error) {
 	var a [64]byte
 	var b []byte
 
+	if b, err = d.parseStringFromType(t, a[:0]); err != nil {
+		return
+	}
+
+	if to.IsValid() {
+		to.SetString(string(b))
+	}
+	return
+}
+
+// parseStringFromType returns the text representation of the value of type t
+// that the parser is positioned on, a is used as buffer to format values which
+// are not strings.
+func (d Decoder) parseStringFromType(t Type, a []byte) (b []byte, err error) {
 	switch t {
 	case Nil:
 		err = d.Parser.ParseNil()
@@ -401,60 +521,49 @@ func (d Decoder) decodeStringFromType(t Type, to reflect.Value) (err error) {
 	case Bool:
 		var v bool
 		if v, err = d.Parser.ParseBool(); err == nil {
-			if v {
-				b = append(a[:0], "true"...)
-			} else {
-				b = append(a[:0], "false"...)
-			}
+			b = strconv.AppendBool(a, v)
 		}
 
 	case Int:
 		var v int64
 		if v, err = d.Parser.ParseInt(); err == nil {
-			b = strconv.AppendInt(a[:0], v, 10)
+			b = strconv.AppendInt(a, v, 10)
 		}
 
 	case Uint:
 		var v uint64
 		if v, err = d.Parser.ParseUint(); err == nil {
-			b = strconv.AppendUint(a[:0], v, 10)
+			b = strconv.AppendUint(a, v, 10)
 		}
 
 	case Float:
 		var v float64
 		if v, err = d.Parser.ParseFloat(); err == nil {
-			b = strconv.AppendFloat(a[:0], v, 'g', -1, 64)
+			b = strconv.AppendFloat(a, v, 'g', -1, 64)
 		}
 
 	case Time:
 		var v time.Time
 		if v, err = d.Parser.ParseTime(); err == nil {
-			b = v.AppendFormat(a[:0], time.RFC3339Nano)
+			b = v.AppendFormat(a, time.RFC3339Nano)
 		}
 
 	case Duration:
 		var v time.Duration
 		if v, err = d.Parser.ParseDuration(); err == nil {
-			b = objutil.AppendDuration(a[:0], v)
+			b = objutil.AppendDuration(a, v)
 		}
 
 	case Error:
 		var v error
 		if v, err = d.Parser.ParseError(); err == nil {
-			b = append(a[:0], v.Error()...)
+			b = append(a, v.Error()...)
 		}
 
 	default:
 		err = typeConversionError(t, String)
 	}
 
-	if err != nil {
-		return
-	}
-
-	if to.IsValid() {
-		to.SetString(string(b))
-	}
 	return
 }
 
@@ -504,6 +613,29 @@ func (d Decoder) decodeBytesFromType(t Type, to reflect.Value) (err error) {
 	return
 }
 
+func (d Decoder) decodeRawValue(to reflect.Value) (t Type, err error) {
+	r, ok := d.Parser.(rawParser)
+
+	if !ok {
+		err = fmt.Errorf("objconv: raw values cannot be decoded by parsers of type %T", d.Parser)
+		return
+	}
+
+	if err = r.BeginRaw(); err != nil {
+		return
+	}
+
+	t, err = d.skip()
+	b := r.EndRaw()
+
+	if err == nil && to.IsValid() {
+		v := to.Addr().Interface().(*RawValue)
+		v.Format = r.RawFormat()
+		v.Bytes = append(v.Bytes[:0], b...)
+	}
+	return
+}
+
 func (d Decoder) decodeTime(to reflect.Value) (t Type, err error) {
 	if t, err = d.Parser.ParseType(); err == nil {
 		err = d.decodeTimeFromType(t, to)
@@ -538,7 +670,7 @@ func (d Decoder) decodeTimeFromType(t Type, to reflect.Value) (err error) {
 			v, err = time.Parse(time.RFC3339Nano, unsafeString(s))
 			// if an error is received, reparse with a "safe" string in case it is retained in the error
 			if err != nil {
-				_, err = time.Parse(time.RFC3339Nano, string(t))
+				_, err = time.Parse(time.RFC3339Nano, string(s))
 			}
 		}
 		*(to.Addr().Interface().(*time.Time)) = v
@@ -668,8 +800,9 @@ func (d Decoder) decodeSliceFromTypeWith(typ Type, to reflect.Value, f decodeFun
 			reflect.Copy(sc, s)
 			s = sc
 		}
-		if _, err = f(d, s.Index(i)); err != nil {
-			return
+		d.hint(t.Elem())
+		if typ, err := f(d, s.Index(i)); err != nil {
+			return d.wrapError(err, indexPath(i), typ, t.Elem())
 		}
 		i++
 		return
@@ -713,8 +846,9 @@ func (d Decoder) decodeArrayFromTypeWith(typ Type, to reflect.Value, f decodeFun
 
 	if err = d.decodeArrayImpl(typ, func(d Decoder) (err error) {
 		if i < n {
-			if _, err = f(d, to.Index(i)); err != nil {
-				return
+			d.hint(e)
+			if t, err := f(d, to.Index(i)); err != nil {
+				return d.wrapError(err, indexPath(i), t, e)
 			}
 		}
 		i++
@@ -795,14 +929,19 @@ func (d Decoder) decodeMapFromTypeWith(typ Type, to reflect.Value, kf decodeFunc
 	if err = d.decodeMapImpl(typ, func(kd Decoder, vd Decoder) (err error) {
 		kv.Set(kz) // reset the key to its zero-value
 		vv.Set(vz) // reset the value to its zero-value
-		if _, err = kf(d, kv); err != nil {
+		d.hint(kt)
+		if t, err := kf(d, kv); err != nil {
+			return d.wrapError(err, "", t, kt)
+		}
+		if err = checkMapKey(kv); err != nil {
 			return
 		}
 		if err = d.Parser.ParseMapValue(vd.off - 1); err != nil {
 			return
 		}
-		if _, err = vf(d, vv); err != nil {
-			return
+		d.hint(vt)
+		if t, err := vf(d, vv); err != nil {
+			return d.wrapError(err, keyPath(kv.Interface()), t, vt)
 		}
 		m.SetMapIndex(kv, vv)
 		return
@@ -837,15 +976,33 @@ func (d Decoder) decodeMapInterfaceInterface(typ Type, to reflect.Value) error {
 		if err = kd.Decode(&k); err != nil {
 			return
 		}
-		if err = vd.Decode(&v); err != nil {
+		if err = checkMapKey(reflect.ValueOf(k)); err != nil {
 			return
 		}
+		if err = vd.Decode(&v); err != nil {
+			return d.wrapError(err, keyPath(k), Unknown, emptyInterface)
+		}
 
 		m[k] = v
 		return
 	})
 }
 
+// checkMapKey returns an error if k cannot be used as a map key, which happens
+// when an interface key holds a slice, a map, or a function.
+func checkMapKey(k reflect.Value) error {
+	if k.Kind() == reflect.Interface {
+		if k.IsNil() {
+			return nil
+		}
+		k = k.Elem()
+	}
+	if k.IsValid() && !k.Type().Comparable() {
+		return fmt.Errorf("objconv: invalid map key of type %s", k.Type())
+	}
+	return nil
+}
+
 func (d Decoder) decodeMapStringInterface(typ Type, to reflect.Value) (err error) {
 	m := to.Interface().(map[string]interface{})
 
@@ -869,7 +1026,7 @@ func (d Decoder) decodeMapStringInterface(typ Type, to reflect.Value) (err error
 		k = string(b)
 
 		if err = vd.Decode(&v); err != nil {
-			return
+			return d.wrapError(err, keyPath(k), Unknown, emptyInterface)
 		}
 
 		m[k] = v
@@ -893,6 +1050,7 @@ func (d Decoder) decodeMapStringString(typ Type, to reflect.Value) (err error) {
 		var b []byte
 		var k string
 		var v string
+		var t Type
 
 		if _, b, err = d.decodeTypeAndString(); err != nil {
 			return
@@ -903,8 +1061,8 @@ func (d Decoder) decodeMapStringString(typ Type, to reflect.Value) (err error) {
 			return
 		}
 
-		if _, b, err = d.decodeTypeAndString(); err != nil {
-			return
+		if t, b, err = d.decodeTypeAndString(); err != nil {
+			return d.wrapError(err, keyPath(k), t, stringType)
 		}
 		v = string(b)
 
@@ -927,22 +1085,45 @@ func (d Decoder) decodeStructWith(to reflect.Value, s *structType) (t Type, err
 func (d Decoder) decodeStructFromTypeWith(typ Type, to reflect.Value, s *structType) (err error) {
 	if err = d.decodeMapImpl(typ, func(kd Decoder, vd Decoder) (err error) {
 		var b []byte
+		var f *structField
 
-		if _, b, err = d.decodeTypeAndString(); err != nil {
-			return
+		if d.fields != nil {
+			var n int
+			if n, err = d.fields.ParseField(); err != nil {
+				return
+			}
+			b = strconv.AppendInt(b, int64(n), 10)
+			f = s.fieldsByNum[n]
+		} else {
+			if _, b, err = d.decodeTypeAndString(); err != nil {
+				return
+			}
+			f = s.lookup(b, d.FieldMatch)
 		}
-		f := s.fieldsByName[string(b)]
 
 		if err = d.Parser.ParseMapValue(vd.off - 1); err != nil {
 			return
 		}
 
 		if f == nil {
-			_, err = d.decodeInterface(reflect.Value{}) // discard
+			if d.DisallowUnknownFields {
+				err = &UnknownFieldError{Field: string(b), Type: to.Type()}
+				return
+			}
+			_, err = d.skip()
+			return
+		}
+
+		var v reflect.Value
+
+		if v, err = f.valueAlloc(to); err != nil {
 			return
 		}
 
-		_, err = f.decode(d, to.FieldByIndex(f.index))
+		d.hint(v.Type())
+		if t, err := f.decode(d, v); err != nil {
+			return d.wrapError(err, fieldPath(f.name), t, v.Type())
+		}
 		return
 	}); err != nil {
 		to.Set(zeroValueOf(to.Type()))
@@ -1037,6 +1218,22 @@ func (d Decoder) decodeTextUnmarshaler(to reflect.Value) (t Type, err error) {
 	return
 }
 
+// skip discards the next value, the parser jumps over it if it implements the
+// skipParser interface, otherwise the value is parsed and thrown away.
+func (d Decoder) skip() (t Type, err error) {
+	if t, err = d.Parser.ParseType(); err == nil {
+		err = d.skipFromType(t)
+	}
+	return
+}
+
+func (d Decoder) skipFromType(t Type) error {
+	if p, ok := d.Parser.(skipParser); ok {
+		return p.Skip()
+	}
+	return d.decodeInterfaceFromType(t, reflect.Value{})
+}
+
 func (d Decoder) decodeInterface(to reflect.Value) (t Type, err error) {
 	if t, err = d.Parser.ParseType(); err == nil {
 		err = d.decodeInterfaceFromType(t, to)
@@ -1045,6 +1242,22 @@ func (d Decoder) decodeInterface(to reflect.Value) (t Type, err error) {
 }
 
 func (d Decoder) decodeInterfaceFromType(t Type, to reflect.Value) (err error) {
+	if p, ok := d.Parser.(dynamicParser); ok {
+		// UseNumber takes precedence over the numeric types that parsers pick
+		// for numbers that would otherwise lose precision.
+		if typ := p.DynamicType(); typ != nil && !(d.UseNumber && isNumberType(typ)) {
+			v := reflect.New(typ).Elem()
+			if _, err = d.decode(v); err == nil && to.IsValid() {
+				to.Set(v)
+			}
+			return
+		}
+	}
+
+	if d.UseNumber && (t == Int || t == Uint || t == Float) {
+		return d.decodeInterfaceFrom(numberType, t, to, Decoder.decodeNumberFromType)
+	}
+
 	switch t {
 	case Nil:
 		err = d.decodeInterfaceFromNil(to)
@@ -1110,6 +1323,49 @@ func (d Decoder) decodeUnsupported(to reflect.Value) (Type, error) {
 	return Nil, fmt.Errorf("objconv: the decoder doesn't support values of type %s", to.Type())
 }
 
+// decodeFromString loads booleans and numbers from their string representation,
+// it is used to decode struct fields that have the `string` tag option.
+func (d Decoder) decodeFromString(to reflect.Value) (t Type, err error) {
+	var b []byte
+
+	if t, b, err = d.decodeTypeAndString(); err != nil || t == Nil {
+		return
+	}
+
+	switch to.Kind() {
+	case reflect.Bool:
+		var v bool
+		if v, err = strconv.ParseBool(unsafeString(b)); err == nil {
+			to.SetBool(v)
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var v int64
+		if v, err = strconv.ParseInt(unsafeString(b), 10, to.Type().Bits()); err == nil {
+			to.SetInt(v)
+		}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var v uint64
+		if v, err = strconv.ParseUint(unsafeString(b), 10, to.Type().Bits()); err == nil {
+			to.SetUint(v)
+		}
+
+	default:
+		var v float64
+		if v, err = strconv.ParseFloat(unsafeString(b), to.Type().Bits()); err == nil {
+			to.SetFloat(v)
+		}
+	}
+
+	// if an error is received, make a "safe" copy of the string in case it is
+	// retained in the error
+	if e, ok := err.(*strconv.NumError); ok {
+		e.Num = string(b)
+	}
+	return
+}
+
 func (d Decoder) decodeTypeAndString() (t Type, b []byte, err error) {
 	if t, err = d.Parser.ParseType(); err == nil {
 		// This algorithm is the same than the one used in
@@ -1133,6 +1389,8 @@ func (d Decoder) decodeTypeAndString() (t Type, b []byte, err error) {
 func (d Decoder) DecodeArray(f func(Decoder) error) (err error) {
 	var typ Type
 
+	d.check()
+
 	if d.off != 0 {
 		if d.off, err = 0, d.Parser.ParseMapValue(d.off-1); err != nil {
 			return
@@ -1197,6 +1455,8 @@ func (d Decoder) decodeArrayImpl(t Type, f func(Decoder) error) (err error) {
 func (d Decoder) DecodeMap(f func(Decoder, Decoder) error) (err error) {
 	var typ Type
 
+	d.check()
+
 	if d.off != 0 {
 		if d.off, err = 0, d.Parser.ParseMapValue(d.off-1); err != nil {
 			return
@@ -1270,6 +1530,18 @@ type StreamDecoder struct {
 	// there is not destination type (when decoding to an empty interface).
 	MapType reflect.Type
 
+	// DisallowUnknownFields causes the decoder to return an error when a map
+	// key doesn't match any of the fields of the struct being decoded.
+	DisallowUnknownFields bool
+
+	// FieldMatch configures how map keys are matched against the names of the
+	// fields of the struct being decoded.
+	FieldMatch FieldMatch
+
+	// UseNumber causes the decoder to load numbers as Number values instead of
+	// int64, uint64 or float64 when decoding into an empty interface.
+	UseNumber bool
+
 	err error
 	typ Type
 	cnt int
@@ -1331,8 +1603,11 @@ func (d *StreamDecoder) Decode(v interface{}) error {
 	cnt := d.cnt
 	max := d.max
 	dec := Decoder{
-		Parser:  d.Parser,
-		MapType: d.MapType,
+		Parser:                d.Parser,
+		MapType:               d.MapType,
+		DisallowUnknownFields: d.DisallowUnknownFields,
+		FieldMatch:            d.FieldMatch,
+		UseNumber:             d.UseNumber,
 	}
 
 	switch d.typ {
@@ -1448,6 +1723,22 @@ func decodeFuncOf(t reflect.Type) decodeFunc {
 }
 
 func makeDecodeFunc(t reflect.Type, opts decodeFuncOpts) decodeFunc {
+	if hasScopedAdapters(t) {
+		return makeDecodeScopedFunc(t, makeDecodeUnscopedFunc(t, opts))
+	}
+	return makeDecodeUnscopedFunc(t, opts)
+}
+
+func makeDecodeScopedFunc(t reflect.Type, decode decodeFunc) decodeFunc {
+	return func(d Decoder, v reflect.Value) (Type, error) {
+		if a, ok := parserAdapterOf(d.Parser, t); ok {
+			return Unknown /* just needs to not be Nil */, a.Decode(d, v)
+		}
+		return decode(d, v)
+	}
+}
+
+func makeDecodeUnscopedFunc(t reflect.Type, opts decodeFuncOpts) decodeFunc {
 	if a, ok := AdapterOf(t); ok {
 		decode := a.Decode
 		return func(d Decoder, v reflect.Value) (Type, error) {
@@ -1467,6 +1758,24 @@ func makeDecodeFunc(t reflect.Type, opts decodeFuncOpts) decodeFunc {
 	case bytesType:
 		return Decoder.decodeBytes
 
+	case rawValueType:
+		return Decoder.decodeRawValue
+
+	case numberType:
+		return Decoder.decodeNumber
+
+	case bigIntType:
+		return Decoder.decodeBigInt
+
+	case bigFloatType:
+		return Decoder.decodeBigFloat
+
+	case bigRatType:
+		return Decoder.decodeBigRat
+
+	case bigIntPtrType, bigFloatPtrType, bigRatPtrType:
+		return makeDecodePtrFunc(t, decodeFuncOpts{recurse: true})
+
 	case timeType:
 		return Decoder.decodeTime
 
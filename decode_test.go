@@ -3,6 +3,7 @@ package objconv
 import (
 	"errors"
 	"fmt"
+	"io"
 	"reflect"
 	"testing"
 	"time"
@@ -233,6 +234,36 @@ func TestDecoderDecodeType(t *testing.T) {
 		{struct{ A int }{42}, struct{ A int }{42}},
 		{struct{ A, B, C int }{1, 2, 3}, struct{ A, B, C int }{1, 2, 3}},
 
+		// map -> embedded structs
+		{map[string]interface{}{"ID": 1, "Name": "A", "Value": 42}, struct {
+			Metadata
+			Value int
+		}{Metadata{1, "A"}, 42}},
+		{map[string]interface{}{"Created": 1, "updated_at": 2}, struct {
+			*Timestamps
+		}{&Timestamps{1, 2}}},
+		{map[string]interface{}{"Value": 42}, struct {
+			*Timestamps
+			Value int
+		}{nil, 42}},
+		{map[string]interface{}{"metadata": map[string]interface{}{"ID": 1}}, struct {
+			Metadata `objconv:"metadata"`
+		}{Metadata{ID: 1}}},
+
+		// map -> struct with string tag options
+		{map[string]interface{}{"id": "1152921504606846976", "n": "255", "f": "0.5", "b": "true"}, struct {
+			ID int64   `json:"id,string"`
+			N  uint8   `objconv:"n,string"`
+			F  float32 `objconv:"f,string"`
+			B  bool    `objconv:"b,string"`
+		}{1 << 60, 255, 0.5, true}},
+
+		// map -> struct with string tag options on pointers
+		{map[string]interface{}{"id": "42", "nil": nil}, struct {
+			ID  *int64 `json:"id,string"`
+			Nil *int   `objconv:"nil,string"`
+		}{ID: int64Ptr(42)}},
+
 		// struct -> ptr
 		{struct{ A int }{42}, &struct{ A int }{42}},
 	}
@@ -420,3 +451,289 @@ func TestStreamRencode(t *testing.T) {
 		})
 	}
 }
+
+func TestDecoderDisallowUnknownFields(t *testing.T) {
+	type server struct {
+		Host    string `objconv:"host"`
+		Timeout int    `objconv:"timeout"`
+	}
+
+	type config struct {
+		Servers []server `objconv:"servers"`
+	}
+
+	in := map[string]interface{}{
+		"servers": []interface{}{
+			map[string]interface{}{"host": "localhost", "timeout": 1},
+			map[string]interface{}{"host": "localhost", "timout": 1},
+		},
+	}
+
+	t.Run("allowed", func(t *testing.T) {
+		var c config
+
+		if err := NewDecoder(NewValueParser(in)).Decode(&c); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("disallowed", func(t *testing.T) {
+		var c config
+
+		dec := NewDecoder(NewValueParser(in))
+		dec.DisallowUnknownFields = true
+
+		err := dec.Decode(&c)
+		e, ok := err.(*UnknownFieldError)
+
+		if !ok {
+			t.Fatalf("expected *UnknownFieldError but got %#v", err)
+		}
+
+		if e.Field != "timout" {
+			t.Error("bad field:", e.Field)
+		}
+
+		if e.Path != ".servers[1]" {
+			t.Error("bad path:", e.Path)
+		}
+
+		if e.Type != reflect.TypeOf(server{}) {
+			t.Error("bad type:", e.Type)
+		}
+	})
+}
+
+func TestDecoderDecodeError(t *testing.T) {
+	type item struct {
+		Price int8 `objconv:"price"`
+	}
+
+	var v struct {
+		Items []item `objconv:"items"`
+	}
+
+	in := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"price": 1},
+			map[string]interface{}{"price": 1000},
+		},
+	}
+
+	err := NewDecoder(NewValueParser(in)).Decode(&v)
+
+	var e *DecodeError
+
+	if !errors.As(err, &e) {
+		t.Fatalf("expected *DecodeError but got %#v", err)
+	}
+
+	if e.Path != ".items[1].price" {
+		t.Error("bad path:", e.Path)
+	}
+
+	if e.Type != reflect.TypeOf(int8(0)) {
+		t.Error("bad type:", e.Type)
+	}
+
+	if e.WireType != Int {
+		t.Error("bad wire type:", e.WireType)
+	}
+
+	if e.Offset != -1 {
+		t.Error("bad offset:", e.Offset)
+	}
+}
+
+func TestDecodeErrorMessage(t *testing.T) {
+	err := errors.New("oops")
+	typ := reflect.TypeOf(0)
+
+	tests := []struct {
+		e *DecodeError
+		s string
+	}{
+		{&DecodeError{Err: err, Offset: -1}, "oops (at .)"},
+		{&DecodeError{Err: err, Type: typ, Offset: -1}, "oops (decoding into int at .)"},
+		{&DecodeError{Err: err, WireType: String, Offset: -1}, "oops (decoding string at .)"},
+		{&DecodeError{Err: err, WireType: String, Type: typ, Path: ".a", Offset: 4}, "oops (decoding string into int at .a, offset 4)"},
+		{&DecodeError{Err: err, Line: 1, Column: 2, Offset: -1}, "oops (at ., line 1 column 2)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.s, func(t *testing.T) {
+			if s := test.e.Error(); s != test.s {
+				t.Errorf("%q != %q", s, test.s)
+			}
+		})
+	}
+}
+
+type errorDecoder struct{ err error }
+
+func (d *errorDecoder) DecodeValue(Decoder) error { return d.err }
+
+func TestDecoderDecodeErrorUnchanged(t *testing.T) {
+	errOops := errors.New("oops")
+
+	if err := NewDecoder(NewValueParser(1)).Decode(&errorDecoder{errOops}); err != errOops {
+		t.Errorf("the error returned by DecodeValue was changed: %#v", err)
+	}
+
+	var v struct {
+		A errorDecoder
+	}
+	v.A.err = io.ErrUnexpectedEOF
+
+	if err := NewDecoder(NewValueParser(map[string]int{"A": 1})).Decode(&v); err != io.ErrUnexpectedEOF {
+		t.Errorf("io.ErrUnexpectedEOF was changed: %#v", err)
+	}
+}
+
+func TestDecoderFieldMatch(t *testing.T) {
+	type user struct {
+		UserID int    `json:"userID"`
+		Name   string `objconv:"name"`
+		Email  string
+	}
+
+	in := map[string]interface{}{
+		"userid": 42,
+		"NAME":   "Luke",
+		"EMAIL":  "luke@example.com",
+	}
+
+	tests := []struct {
+		match FieldMatch
+		user  user
+	}{
+		{DefaultFieldMatch, user{UserID: 42, Email: "luke@example.com"}},
+		{ExactFieldMatch, user{}},
+		{FoldFieldMatch, user{UserID: 42, Name: "Luke", Email: "luke@example.com"}},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.match), func(t *testing.T) {
+			var u user
+
+			dec := NewDecoder(NewValueParser(in))
+			dec.FieldMatch = test.match
+
+			if err := dec.Decode(&u); err != nil {
+				t.Error(err)
+			}
+
+			if !reflect.DeepEqual(u, test.user) {
+				t.Errorf("%#v != %#v", u, test.user)
+			}
+		})
+	}
+}
+
+func TestDecoderFieldMatchCaseCollision(t *testing.T) {
+	type value struct {
+		Name string
+		NAME string
+	}
+
+	type tagged struct {
+		Name string `objconv:"name"`
+		NAME string
+	}
+
+	tests := []struct {
+		in    map[string]interface{}
+		match FieldMatch
+		out   interface{}
+	}{
+		{map[string]interface{}{"Name": "a", "NAME": "b"}, DefaultFieldMatch, value{Name: "a", NAME: "b"}},
+		{map[string]interface{}{"NAME": "b", "Name": "a"}, FoldFieldMatch, value{Name: "a", NAME: "b"}},
+		{map[string]interface{}{"name": "a"}, DefaultFieldMatch, value{Name: "a"}},
+		{map[string]interface{}{"nAmE": "b"}, DefaultFieldMatch, tagged{NAME: "b"}},
+		{map[string]interface{}{"nAmE": "a"}, FoldFieldMatch, tagged{Name: "a"}},
+		{map[string]interface{}{"name": "a", "Name": "b"}, DefaultFieldMatch, tagged{Name: "a", NAME: "b"}},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.in, test.match), func(t *testing.T) {
+			v := reflect.New(reflect.TypeOf(test.out))
+
+			dec := NewDecoder(NewValueParser(test.in))
+			dec.FieldMatch = test.match
+
+			if err := dec.Decode(v.Interface()); err != nil {
+				t.Error(err)
+			}
+
+			if x := v.Elem().Interface(); !reflect.DeepEqual(x, test.out) {
+				t.Errorf("%#v != %#v", x, test.out)
+			}
+		})
+	}
+}
+
+func TestDecoderStringTagError(t *testing.T) {
+	tests := []interface{}{
+		map[string]interface{}{"n": 42},    // not a string
+		map[string]interface{}{"n": "256"}, // out of range
+		map[string]interface{}{"n": "abc"}, // not a number
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test), func(t *testing.T) {
+			var v struct {
+				N uint8 `objconv:"n,string"`
+			}
+
+			if err := NewDecoder(NewValueParser(test)).Decode(&v); err == nil {
+				t.Error("expected an error when decoding an invalid value into a field with the string tag option")
+			}
+
+			var p struct {
+				N *uint8 `objconv:"n,string"`
+			}
+
+			if err := NewDecoder(NewValueParser(test)).Decode(&p); err == nil {
+				t.Error("expected an error when decoding an invalid value into a pointer field with the string tag option")
+			}
+		})
+	}
+}
+
+func TestDecoderRawValueUnsupported(t *testing.T) {
+	var v RawValue
+
+	if err := NewDecoder(NewValueParser("Hello World!")).Decode(&v); err == nil {
+		t.Error("expected an error when decoding a raw value with a parser that doesn't support it")
+	}
+}
+
+func TestDecoderUseNumber(t *testing.T) {
+	tests := []struct {
+		in  interface{}
+		out Number
+	}{
+		{int64(-42), "-42"},
+		{uint64(42), "42"},
+		{float64(0.5), "0.5"},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.in), func(t *testing.T) {
+			var v interface{}
+
+			dec := NewDecoder(NewValueParser(test.in))
+			dec.UseNumber = true
+
+			if err := dec.Decode(&v); err != nil {
+				t.Error(err)
+			}
+
+			if v != test.out {
+				t.Errorf("%#v != %#v", v, test.out)
+			}
+		})
+	}
+}
+
+func int64Ptr(v int64) *int64 { return &v }
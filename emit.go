@@ -77,6 +77,26 @@ type textEmitter interface {
 	TextEmitter() bool
 }
 
+// The fieldEmitter interface may be implemented by emitters of formats where
+// the fields of structs are identified by numbers instead of names, like
+// Protocol Buffers.
+type fieldEmitter interface {
+	// EmitStruct is called after EmitMapBegin when the map being encoded is a
+	// struct, so empty structs can be told apart from empty maps.
+	EmitStruct() error
+
+	// EmitField is called instead of emitting a map key when encoding a struct,
+	// number is the value of the `proto` tag option, or zero if it wasn't set.
+	EmitField(name string, number int) error
+}
+
+// The scopedEmitter interface may be implemented by emitters of formats that
+// have their own encoding for some types, installed with InstallScoped.
+type scopedEmitter interface {
+	// AdapterScope returns the scope of the adapters used by the emitter.
+	AdapterScope() string
+}
+
 func isTextEmitter(emitter Emitter) bool {
 	e, _ := emitter.(textEmitter)
 	return e != nil && e.TextEmitter()
@@ -101,6 +121,8 @@ func (e discardEmitter) EmitMapBegin(v int) error           { return nil }
 func (e discardEmitter) EmitMapEnd() error                  { return nil }
 func (e discardEmitter) EmitMapNext() error                 { return nil }
 func (e discardEmitter) EmitMapValue() error                { return nil }
+func (e discardEmitter) EmitRaw(v []byte) error             { return nil }
+func (e discardEmitter) RawFormat() string                  { return "" }
 
 var (
 	// Discard is a special emitter that outputs nothing and simply discards
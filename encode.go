@@ -1,10 +1,12 @@
 package objconv
 
 import (
+	"bytes"
 	"encoding"
 	"fmt"
 	"io"
 	"reflect"
+	"strconv"
 	"time"
 	"unsafe"
 )
@@ -296,6 +298,28 @@ func (e Encoder) encodeFloat64(v reflect.Value) error {
 	return e.Emitter.EmitFloat(v.Float(), 64)
 }
 
+// encodeAsString emits booleans and numbers as strings, it is used to encode
+// struct fields that have the `string` tag option.
+func (e Encoder) encodeAsString(v reflect.Value) error {
+	var s string
+
+	switch v.Kind() {
+	case reflect.Bool:
+		s = strconv.FormatBool(v.Bool())
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s = strconv.FormatInt(v.Int(), 10)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		s = strconv.FormatUint(v.Uint(), 10)
+
+	default:
+		s = strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits())
+	}
+
+	return e.Emitter.EmitString(s)
+}
+
 func (e Encoder) encodeString(v reflect.Value) error {
 	return e.Emitter.EmitString(v.String())
 }
@@ -304,6 +328,48 @@ func (e Encoder) encodeBytes(v reflect.Value) error {
 	return e.Emitter.EmitBytes(v.Bytes())
 }
 
+func (e Encoder) encodeRawValue(v reflect.Value) error {
+	raw := v.Interface().(RawValue)
+
+	if raw.Bytes == nil {
+		return e.Emitter.EmitNil()
+	}
+
+	if r, ok := e.Emitter.(rawEmitter); ok {
+		if f := r.RawFormat(); raw.Format == "" || f == "" || f == raw.Format {
+			return r.EmitRaw(raw.Bytes)
+		}
+	}
+
+	if raw.Format == "" {
+		return fmt.Errorf("objconv: raw values cannot be encoded by emitters of type %T", e.Emitter)
+	}
+
+	// The value is in a different format than the one of the emitter, it has
+	// to be decoded and re-encoded. Maps are decoded as OrderedMap values so
+	// their keys are written in the original order.
+	codec, ok := Lookup(raw.Format)
+
+	if !ok {
+		return fmt.Errorf("objconv: no codec registered for the %q format of a raw value", raw.Format)
+	}
+
+	var x interface{}
+
+	d := codec.NewDecoder(bytes.NewReader(raw.Bytes))
+	d.MapType = orderedMapType
+
+	if err := d.Decode(&x); err != nil {
+		return err
+	}
+
+	if x == nil {
+		return e.Emitter.EmitNil()
+	}
+
+	return e.encode(reflect.ValueOf(x))
+}
+
 func (e Encoder) encodeTime(v reflect.Value) error {
 	var t time.Time
 
@@ -525,7 +591,7 @@ func (e Encoder) encodeStructWith(v reflect.Value, s *structType) (err error) {
 
 	for i := range s.fields {
 		f := &s.fields[i]
-		if !f.omit(v.FieldByIndex(f.index)) {
+		if fv := f.value(v); fv.IsValid() && !f.omit(fv) {
 			n++
 		}
 	}
@@ -533,17 +599,22 @@ func (e Encoder) encodeStructWith(v reflect.Value, s *structType) (err error) {
 	if err = e.Emitter.EmitMapBegin(n); err != nil {
 		return
 	}
+	if x, ok := e.Emitter.(fieldEmitter); ok {
+		if err = x.EmitStruct(); err != nil {
+			return
+		}
+	}
 	n = 0
 
 	for i := range s.fields {
 		f := &s.fields[i]
-		if fv := v.FieldByIndex(f.index); !f.omit(fv) {
+		if fv := f.value(v); fv.IsValid() && !f.omit(fv) {
 			if n != 0 {
 				if err = e.Emitter.EmitMapNext(); err != nil {
 					return
 				}
 			}
-			if err = e.Emitter.EmitString(f.name); err != nil {
+			if err = e.emitField(f); err != nil {
 				return
 			}
 			if err = e.Emitter.EmitMapValue(); err != nil {
@@ -559,6 +630,13 @@ func (e Encoder) encodeStructWith(v reflect.Value, s *structType) (err error) {
 	return e.Emitter.EmitMapEnd()
 }
 
+func (e Encoder) emitField(f *structField) error {
+	if x, ok := e.Emitter.(fieldEmitter); ok {
+		return x.EmitField(f.name, f.number)
+	}
+	return e.Emitter.EmitString(f.name)
+}
+
 func (e Encoder) encodePointer(v reflect.Value) error {
 	return e.encodePointerWith(v, encodeFuncOf(v.Type().Elem()))
 }
@@ -589,6 +667,9 @@ func (e Encoder) encodeMarshaler(v reflect.Value) error {
 }
 
 func (e Encoder) encodeBinaryMarshaler(v reflect.Value) error {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return e.Emitter.EmitNil()
+	}
 	b, err := v.Interface().(encoding.BinaryMarshaler).MarshalBinary()
 	if err == nil {
 		err = e.Emitter.EmitBytes(b)
@@ -597,6 +678,9 @@ func (e Encoder) encodeBinaryMarshaler(v reflect.Value) error {
 }
 
 func (e Encoder) encodeTextMarshaler(v reflect.Value) error {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return e.Emitter.EmitNil()
+	}
 	b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
 	if err == nil {
 		err = e.Emitter.EmitString(stringNoCopy(b))
@@ -826,6 +910,22 @@ func encodeFuncOf(t reflect.Type) encodeFunc {
 }
 
 func makeEncodeFunc(t reflect.Type, opts encodeFuncOpts) encodeFunc {
+	if hasScopedAdapters(t) {
+		return makeEncodeScopedFunc(t, makeEncodeUnscopedFunc(t, opts))
+	}
+	return makeEncodeUnscopedFunc(t, opts)
+}
+
+func makeEncodeScopedFunc(t reflect.Type, encode encodeFunc) encodeFunc {
+	return func(e Encoder, v reflect.Value) error {
+		if a, ok := emitterAdapterOf(e.Emitter, t); ok {
+			return a.Encode(e, v)
+		}
+		return encode(e, v)
+	}
+}
+
+func makeEncodeUnscopedFunc(t reflect.Type, opts encodeFuncOpts) encodeFunc {
 	if adapter, ok := AdapterOf(t); ok {
 		return adapter.Encode
 	}
@@ -840,6 +940,24 @@ func makeEncodeFunc(t reflect.Type, opts encodeFuncOpts) encodeFunc {
 	case bytesType:
 		return Encoder.encodeBytes
 
+	case rawValueType:
+		return Encoder.encodeRawValue
+
+	case numberType:
+		return Encoder.encodeNumber
+
+	case bigIntType:
+		return Encoder.encodeBigInt
+
+	case bigFloatType:
+		return Encoder.encodeBigFloat
+
+	case bigRatType:
+		return Encoder.encodeBigRat
+
+	case bigIntPtrType, bigFloatPtrType, bigRatPtrType:
+		return makeEncodePtrFunc(t, encodeFuncOpts{recurse: true})
+
 	case timeType, timePtrType:
 		return Encoder.encodeTime
 
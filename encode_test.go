@@ -3,6 +3,7 @@ package objconv
 import (
 	"errors"
 	"fmt"
+	"math/big"
 	"reflect"
 	"testing"
 	"time"
@@ -135,6 +136,66 @@ func TestEncoder(t *testing.T) {
 			},
 		},
 
+		// embedded structs
+		{
+			in: struct {
+				Metadata
+				*Timestamps
+				Value int
+			}{Metadata: Metadata{ID: 1, Name: "A"}, Value: 42},
+			out: map[interface{}]interface{}{
+				"ID":    int64(1),
+				"Name":  "A",
+				"Value": int64(42),
+			},
+		},
+		{
+			in: struct {
+				*Timestamps
+				Metadata `objconv:"metadata"`
+			}{Timestamps: &Timestamps{Created: 1, Updated: 2}},
+			out: map[interface{}]interface{}{
+				"Created":    int64(1),
+				"updated_at": int64(2),
+				"metadata": map[interface{}]interface{}{
+					"ID":   int64(0),
+					"Name": "",
+				},
+			},
+		},
+
+		// string tag option
+		{
+			in: struct {
+				ID int64         `json:"id,string"`
+				F  float32       `objconv:"f,string"`
+				B  bool          `objconv:"b,string"`
+				S  string        `objconv:"s,string"`
+				D  time.Duration `objconv:"d,string"`
+			}{ID: 1 << 60, F: 0.1, B: true, S: "hello", D: time.Second},
+			out: map[interface{}]interface{}{
+				"id": "1152921504606846976",
+				"f":  "0.1",
+				"b":  "true",
+				"s":  "hello",
+				"d":  time.Second,
+			},
+		},
+
+		// string tag option on pointers
+		{
+			in: struct {
+				ID  *int64 `json:"id,string"`
+				B   *bool  `objconv:"b,string"`
+				Nil *int   `objconv:"nil,string"`
+			}{ID: new(int64), B: new(bool)},
+			out: map[interface{}]interface{}{
+				"id":  "0",
+				"b":   "false",
+				"nil": nil,
+			},
+		},
+
 		// list of complex data structures
 		{
 			in: []map[string]string{
@@ -330,3 +391,60 @@ func TestStreamEncoderVar(t *testing.T) {
 		t.Error(x1, "!=", x2)
 	}
 }
+
+func TestEncoderNumber(t *testing.T) {
+	tests := []struct {
+		in  interface{}
+		out interface{}
+	}{
+		{Number(""), int64(0)},
+		{Number("-42"), int64(-42)},
+		{Number("18446744073709551615"), uint64(18446744073709551615)},
+		{Number("1.5e3"), float64(1500)},
+		{Number("12345678901234567890123"), "12345678901234567890123"},
+		{big.NewInt(1), int64(1)},
+		{big.NewFloat(0.5), float64(0.5)},
+		{big.NewRat(1, 4), float64(0.25)},
+		{big.NewRat(1, 3), "1/3"},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.in), func(t *testing.T) {
+			val := NewValueEmitter()
+
+			if err := NewEncoder(val).Encode(test.in); err != nil {
+				t.Error(err)
+			}
+
+			if v := val.Value(); !reflect.DeepEqual(v, test.out) {
+				t.Errorf("%#v != %#v", v, test.out)
+			}
+		})
+	}
+
+	if err := NewEncoder(Discard).Encode(Number("1.2.3")); err == nil {
+		t.Error("expected an error when encoding an invalid number")
+	}
+}
+
+func TestEncoderRawValue(t *testing.T) {
+	if err := NewEncoder(Discard).Encode(RawValue{Format: "unknown", Bytes: []byte("?")}); err != nil {
+		t.Error(err)
+	}
+
+	if err := NewEncoder(NewValueEmitter()).Encode(RawValue{Bytes: []byte("?")}); err == nil {
+		t.Error("expected an error when encoding a raw value of no format with an emitter that doesn't support raw values")
+	}
+
+	if err := NewEncoder(NewValueEmitter()).Encode(RawValue{Format: "unknown", Bytes: []byte("?")}); err == nil {
+		t.Error("expected an error when encoding a raw value of an unregistered format")
+	}
+
+	val := NewValueEmitter()
+
+	if err := NewEncoder(val).Encode(RawValue{}); err != nil {
+		t.Error(err)
+	} else if v := val.Value(); v != nil {
+		t.Errorf("bad encoding of a raw value with nil bytes: %#v", v)
+	}
+}
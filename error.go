@@ -3,6 +3,8 @@ package objconv
 import (
 	"errors"
 	"fmt"
+	"reflect"
+	"strconv"
 )
 
 var (
@@ -15,6 +17,86 @@ var (
 	Shadow = errors.New("shadow")
 )
 
+// UnknownFieldError is returned by decoders that have DisallowUnknownFields
+// set when a map key doesn't match any field of the destination struct.
+type UnknownFieldError struct {
+	Path  string       // path of the struct in the decoded value
+	Field string       // name of the unknown field
+	Type  reflect.Type // type of the destination struct
+}
+
+// Error satisfies the error interface.
+func (e *UnknownFieldError) Error() string {
+	path := e.Path
+	if len(path) == 0 {
+		path = "."
+	}
+	return fmt.Sprintf("objconv: unknown field %q at %s (decoding into %s)", e.Field, path, e.Type)
+}
+
+// DecodeError is returned by decoders when a value could not be loaded from
+// its serialized form, it records where in the document the error occurred.
+type DecodeError struct {
+	Path     string       // path of the value in the document (e.g. ".items[3].price")
+	Type     reflect.Type // type of the destination value
+	WireType Type         // type of the value in the serialized form
+	Offset   int64        // byte offset in the input, or -1 if unknown
+	Line     int          // line in the input, or zero if unknown
+	Column   int          // column in the input, or zero if unknown
+	Err      error        // the underlying error
+}
+
+// Error satisfies the error interface.
+func (e *DecodeError) Error() string {
+	s := e.Err.Error()
+
+	path := e.Path
+	if len(path) == 0 {
+		path = "."
+	}
+
+	switch {
+	case e.WireType != Unknown && e.Type != nil:
+		s += fmt.Sprintf(" (decoding %s into %s at %s", e.WireType, e.Type, path)
+	case e.WireType != Unknown:
+		s += fmt.Sprintf(" (decoding %s at %s", e.WireType, path)
+	case e.Type != nil:
+		s += fmt.Sprintf(" (decoding into %s at %s", e.Type, path)
+	default:
+		s += fmt.Sprintf(" (at %s", path)
+	}
+
+	if e.Line != 0 {
+		s += fmt.Sprintf(", line %d column %d", e.Line, e.Column)
+	}
+
+	if e.Offset >= 0 {
+		s += fmt.Sprintf(", offset %d", e.Offset)
+	}
+
+	return s + ")"
+}
+
+// Unwrap returns the underlying error.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
 func typeConversionError(from Type, to Type) error {
 	return fmt.Errorf("objconv: cannot convert from %s to %s", from, to)
 }
+
+func fieldPath(name string) string {
+	return "." + name
+}
+
+func indexPath(i int) string {
+	return "[" + strconv.Itoa(i) + "]"
+}
+
+func keyPath(k interface{}) string {
+	if s, ok := k.(string); ok {
+		return fieldPath(s)
+	}
+	return fmt.Sprintf("[%v]", k)
+}
@@ -1,5 +1,3 @@
 module github.com/dolab/objconv
 
 go 1.12
-
-require gopkg.in/yaml.v2 v2.2.2
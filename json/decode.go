@@ -3,6 +3,7 @@ package json
 import (
 	"bytes"
 	"io"
+	"reflect"
 	"sync"
 
 	"github.com/dolab/objconv"
@@ -18,6 +19,24 @@ func NewStreamDecoder(r io.Reader) *objconv.StreamDecoder {
 	return objconv.NewStreamDecoder(NewParser(r))
 }
 
+// NewOrderedDecoder returns a new JSON decoder that parses values from r, and
+// loads objects decoded into empty interfaces as objconv.OrderedMap values so
+// their fields are written in the original order when encoded again.
+func NewOrderedDecoder(r io.Reader) *objconv.Decoder {
+	d := NewDecoder(r)
+	d.MapType = mapType
+	return d
+}
+
+// NewOrderedStreamDecoder is like NewStreamDecoder but decodes maps into empty
+// interfaces as objconv.OrderedMap values, like the decoders returned by
+// NewOrderedDecoder.
+func NewOrderedStreamDecoder(r io.Reader) *objconv.StreamDecoder {
+	d := NewStreamDecoder(r)
+	d.MapType = mapType
+	return d
+}
+
 // Unmarshal decodes a JSON representation of v from b.
 func Unmarshal(b []byte, v interface{}) error {
 	u := unmarshalerPool.Get().(*unmarshaler)
@@ -30,6 +49,8 @@ func Unmarshal(b []byte, v interface{}) error {
 	return err
 }
 
+var mapType = reflect.TypeOf(objconv.OrderedMap{})
+
 var unmarshalerPool = sync.Pool{
 	New: func() interface{} { return newUnmarshaler() },
 }
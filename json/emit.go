@@ -89,6 +89,12 @@ func (e *Emitter) EmitFloat(v float64, bitSize int) (err error) {
 	return
 }
 
+// EmitNumber writes the number literal v to the output as-is.
+func (e *Emitter) EmitNumber(v string) (err error) {
+	_, err = e.w.Write(append(e.s[:0], v...))
+	return
+}
+
 func (e *Emitter) EmitString(v string) (err error) {
 	i := 0
 	j := 0
@@ -216,6 +222,18 @@ func (e *Emitter) EmitMapNext() (err error) {
 	return
 }
 
+// EmitRaw writes b to the output, it must contain a value serialized in the
+// JSON format.
+func (e *Emitter) EmitRaw(b []byte) (err error) {
+	_, err = e.w.Write(b)
+	return
+}
+
+// RawFormat returns "json", the format of the bytes accepted by EmitRaw.
+func (e *Emitter) RawFormat() string {
+	return "json"
+}
+
 func (e *Emitter) TextEmitter() bool {
 	return true
 }
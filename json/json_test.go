@@ -1,11 +1,16 @@
 package json
 
 import (
+	"errors"
 	"fmt"
 	"math"
+	"math/big"
 	"strings"
 	"testing"
+	"testing/iotest"
 
+	"github.com/dolab/objconv"
+	"github.com/dolab/objconv/msgpack"
 	"github.com/dolab/objconv/objtests"
 )
 
@@ -13,6 +18,10 @@ func TestCodec(t *testing.T) {
 	objtests.TestCodec(t, Codec)
 }
 
+func TestRawValue(t *testing.T) {
+	objtests.TestRawValue(t, Codec)
+}
+
 func BenchmarkCodec(b *testing.B) {
 	objtests.BenchmarkCodec(b, Codec)
 }
@@ -25,6 +34,81 @@ func BenchmarkPrettyCodec(b *testing.B) {
 	objtests.BenchmarkCodec(b, PrettyCodec)
 }
 
+func TestSkip(t *testing.T) {
+	valid := []string{
+		`null`,
+		`true`,
+		`-0.5e+10`,
+		`"a\"b\\c\/\b\f\n\r\t\u2022"`,
+		`[]`,
+		`{}`,
+		`[ 1 , [ ] , { } , "]" , {"}":[null,false]} ]`,
+		`{"a":{"b":[1,{"c":"}"}]},"d":[[[]]]}`,
+		`[` + strings.Repeat(`{"a":[`, 100) + strings.Repeat(`]}`, 100) + `]`,
+	}
+
+	invalid := []string{
+		`nul`,
+		`-`,
+		`01`,
+		`1.`,
+		`1e`,
+		`1.2.3`,
+		`"\q"`,
+		`"\u12"`,
+		`[1 2 3}`,
+		`{]`,
+		`[1,]`,
+		`[nul, tru]`,
+		`{"a" 1}`,
+		`{"a":1,}`,
+		`{1:2}`,
+		`[1}`,
+		`[`,
+		`"abc`,
+	}
+
+	for _, junk := range valid {
+		t.Run(junk, func(t *testing.T) {
+			var v struct{ A int }
+			var r struct{ Junk objconv.RawValue }
+
+			s := `{"A":1,"junk":` + junk + `}`
+
+			// The one byte reader forces the parser to refill its buffer in
+			// the middle of the skipped values.
+			if err := NewDecoder(iotest.OneByteReader(strings.NewReader(s))).Decode(&v); err != nil {
+				t.Error(err)
+			} else if v.A != 1 {
+				t.Error("bad value:", v.A)
+			}
+
+			if err := Unmarshal([]byte(s), &r); err != nil {
+				t.Error(err)
+			} else if string(r.Junk.Bytes) != junk {
+				t.Errorf("bad raw value: %s", r.Junk.Bytes)
+			}
+		})
+	}
+
+	for _, junk := range invalid {
+		t.Run(junk, func(t *testing.T) {
+			var v struct{ A int }
+			var r struct{ Junk objconv.RawValue }
+
+			s := `{"A":1,"junk":` + junk + `}`
+
+			if err := NewDecoder(iotest.OneByteReader(strings.NewReader(s))).Decode(&v); err == nil {
+				t.Error("expected an error when skipping a malformed unknown field")
+			}
+
+			if err := Unmarshal([]byte(s), &r); err == nil {
+				t.Errorf("expected an error when decoding a malformed raw value, found %s", r.Junk.Bytes)
+			}
+		})
+	}
+}
+
 func TestUnicode(t *testing.T) {
 	tests := []struct {
 		in  string
@@ -85,3 +169,262 @@ func TestEmitImpossibleFloats(t *testing.T) {
 		})
 	}
 }
+
+func TestDisallowUnknownFields(t *testing.T) {
+	val := struct{ A struct{ B int } }{}
+
+	dec := NewDecoder(strings.NewReader(`{"A":{"B":1,"C":2}}`))
+	dec.DisallowUnknownFields = true
+
+	err := dec.Decode(&val)
+
+	if err == nil {
+		t.Fatal("expected an error when decoding an unknown field")
+	}
+
+	if s := err.Error(); !strings.Contains(s, `"C"`) || !strings.Contains(s, ".A") {
+		t.Error(s)
+	}
+}
+
+func TestDecodeErrorPosition(t *testing.T) {
+	src := "{\n  \"A\": 1,\n  \"B\": [1, \"2\", true]\n}"
+
+	val := struct {
+		A int
+		B []int
+	}{}
+
+	err := Unmarshal([]byte(src), &val)
+
+	var e *objconv.DecodeError
+
+	if !errors.As(err, &e) {
+		t.Fatalf("expected *objconv.DecodeError but got %#v", err)
+	}
+
+	if e.Path != ".B[2]" {
+		t.Error("bad path:", e.Path)
+	}
+
+	if e.WireType != objconv.Bool {
+		t.Error("bad wire type:", e.WireType)
+	}
+
+	if e.Offset != 28 || e.Line != 3 || e.Column != 17 {
+		t.Errorf("bad position: offset=%d line=%d column=%d", e.Offset, e.Line, e.Column)
+	}
+}
+
+func TestRawValueEnvelope(t *testing.T) {
+	src := `{"type": "event", "payload": { "id": 1, "tags": [ "a", "b" ] } }`
+
+	var msg struct {
+		Type    string           `json:"type"`
+		Payload objconv.RawValue `json:"payload"`
+	}
+
+	if err := Unmarshal([]byte(src), &msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := string(msg.Payload.Bytes); s != `{ "id": 1, "tags": [ "a", "b" ] }` {
+		t.Error("bad raw payload:", s)
+	}
+
+	b, err := Marshal(msg)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := string(b); s != `{"type":"event","payload":{ "id": 1, "tags": [ "a", "b" ] }}` {
+		t.Error("bad encoding of the raw payload:", s)
+	}
+}
+
+func TestRawValueOtherFormat(t *testing.T) {
+	type payload struct {
+		ID   int      `objconv:"id"`
+		Tags []string `objconv:"tags"`
+	}
+
+	b, err := msgpack.Marshal(struct {
+		Type    string  `objconv:"type"`
+		Payload payload `objconv:"payload"`
+	}{"event", payload{1, []string{"a", "b"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var msg struct {
+		Type    string           `objconv:"type"`
+		Payload objconv.RawValue `objconv:"payload"`
+	}
+
+	if err := msgpack.Unmarshal(b, &msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.Payload.Format != "msgpack" {
+		t.Error("bad raw payload format:", msg.Payload.Format)
+	}
+
+	// The payload is not in the JSON format so it must be re-encoded instead
+	// of being written as-is.
+	if b, err = Marshal(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := string(b); s != `{"type":"event","payload":{"id":1,"tags":["a","b"]}}` {
+		t.Error("bad encoding of the raw payload:", s)
+	}
+}
+
+func TestUseNumber(t *testing.T) {
+	src := `{"int":12345678901234567890123,"float":0.1000000000000000000000001,"small":42}`
+
+	dec := NewDecoder(strings.NewReader(src))
+	dec.UseNumber = true
+
+	var v map[string]interface{}
+
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	for k, n := range map[string]objconv.Number{
+		"int":   "12345678901234567890123",
+		"float": "0.1000000000000000000000001",
+		"small": "42",
+	} {
+		if v[k] != n {
+			t.Errorf("%s: %#v != %#v", k, v[k], n)
+		}
+	}
+
+	b, err := Marshal(v)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := string(b); !strings.Contains(s, `"int":12345678901234567890123`) || !strings.Contains(s, `"float":0.1000000000000000000000001`) {
+		t.Error("numbers were not encoded verbatim:", s)
+	}
+}
+
+func TestBigNumbers(t *testing.T) {
+	src := `{"i":12345678901234567890123,"f":0.1000000000000000000000001,"r":0.125}`
+
+	var v struct {
+		I *big.Int   `json:"i"`
+		F *big.Float `json:"f"`
+		R *big.Rat   `json:"r"`
+	}
+
+	if err := Unmarshal([]byte(src), &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := v.I.String(); s != "12345678901234567890123" {
+		t.Error("bad big.Int:", s)
+	}
+
+	if s := v.F.Text('f', 25); s != "0.1000000000000000000000001" {
+		t.Error("bad big.Float:", s)
+	}
+
+	if s := v.R.RatString(); s != "1/8" {
+		t.Error("bad big.Rat:", s)
+	}
+
+	b, err := Marshal(v)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := string(b); s != src {
+		t.Error("bad encoding of big numbers:", s)
+	}
+}
+
+func TestOrderedDecoder(t *testing.T) {
+	src := `{"z":1,"a":{"y":[{"c":true,"b":null}],"x":"2"},"m":3.5}`
+
+	var v interface{}
+
+	if err := NewOrderedDecoder(strings.NewReader(src)).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	if m, ok := v.(objconv.OrderedMap); !ok {
+		t.Fatalf("bad value: %#v", v)
+	} else if keys := m.Keys(); len(keys) != 3 || keys[0] != "z" || keys[1] != "a" || keys[2] != "m" {
+		t.Errorf("bad keys: %#v", keys)
+	}
+
+	b, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := string(b); s != src {
+		t.Error("bad round-trip:", s)
+	}
+}
+
+func TestDecodePath(t *testing.T) {
+	src := `{"users":[{"name":"a","roles":["x"]},{"name":"b","roles":["y","z"],"meta":{"k/1":null}}],"count":2} "next"`
+
+	dec := NewDecoder(strings.NewReader(src))
+
+	var role string
+
+	if err := dec.DecodePath("/users/1/roles/1", &role); err != nil {
+		t.Fatal(err)
+	}
+
+	if role != "z" {
+		t.Error("bad value:", role)
+	}
+
+	// The rest of the document must have been skipped.
+	var next string
+
+	if err := dec.Decode(&next); err != nil {
+		t.Fatal(err)
+	}
+
+	if next != "next" {
+		t.Error("bad next value:", next)
+	}
+
+	var count int
+
+	if err := NewDecoder(strings.NewReader(src)).DecodePath("count", &count); err != nil || count != 2 {
+		t.Errorf("bad count: %d (%v)", count, err)
+	}
+
+	var v interface{} = 1
+
+	if err := NewDecoder(strings.NewReader(src)).DecodePath("users[1].meta/k~11", &v); err == nil {
+		t.Error("expected an error")
+	}
+
+	if err := NewDecoder(strings.NewReader(src)).DecodePath("/users/1/meta/k~11", &v); err != nil || v != nil {
+		t.Errorf("bad value: %#v (%v)", v, err)
+	}
+
+	// The empty path designates the whole document.
+	var doc map[string]map[string][]int
+
+	if err := NewDecoder(strings.NewReader(`{"a":{"b":[10,20,30]}}`)).DecodePath("", &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if b := doc["a"]["b"]; len(b) != 3 || b[2] != 30 {
+		t.Errorf("bad document: %#v", doc)
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"encoding/base64"
 	"fmt"
 	"io"
-	"reflect"
 	"strconv"
 	"time"
 	"unicode/utf16"
@@ -23,6 +22,14 @@ type Parser struct {
 	j int       // offset of the last byte in b
 	b [128]byte // buffer where bytes are loaded from the reader
 	c [128]byte // initial backend array for s
+
+	off  int64 // offset of b[0] in the input
+	line int   // number of lines before b[0]
+	bol  int64 // offset of the beginning of the line when it starts before b[0]
+
+	raw bool   // set when the consumed bytes are being recorded
+	ri  int    // offset in b of the first byte to record
+	rb  []byte // buffer of recorded bytes
 }
 
 func NewParser(r io.Reader) *Parser {
@@ -35,6 +42,10 @@ func (p *Parser) Reset(r io.Reader) {
 	p.r = r
 	p.i = 0
 	p.j = 0
+	p.off = 0
+	p.line = 0
+	p.bol = 0
+	p.raw = false
 }
 
 func (p *Parser) Buffered() io.Reader {
@@ -139,6 +150,14 @@ func (p *Parser) ParseFloat() (v float64, err error) {
 	return
 }
 
+// ParseNumber returns the literal text of the number found by the last call
+// to ParseType.
+func (p *Parser) ParseNumber() (v []byte, err error) {
+	v = p.s
+	p.i += len(p.s)
+	return
+}
+
 func (p *Parser) ParseString() (v []byte, err error) {
 	if p.i == p.j {
 		if err = p.fill(); err != nil {
@@ -325,6 +344,262 @@ func (p *Parser) ParseMapNext(n int) (err error) {
 	return
 }
 
+// Skip consumes the value found by the last call to ParseType without loading
+// it. The syntax of the value is still validated, so malformed documents are
+// rejected whether their values are decoded or skipped.
+func (p *Parser) Skip() (err error) {
+	var b byte
+
+	if b, err = p.peekByteAt(0); err != nil {
+		return
+	}
+
+	switch b {
+	case 'n':
+		return p.readToken(nullBytes[:])
+	case 't':
+		return p.readToken(trueBytes[:])
+	case 'f':
+		return p.readToken(falseBytes[:])
+	case '"':
+		return p.skipString()
+	case '[', '{':
+		return p.skipValue()
+	}
+
+	// The number was loaded in p.s by ParseType.
+	if !isValidNumber(p.s) {
+		return fmt.Errorf("objconv/json: invalid number literal %#v", string(p.s))
+	}
+	p.i += len(p.s)
+	return
+}
+
+// skipValue consumes the array or map starting at the current offset, the
+// brackets of the nested arrays and maps are tracked on a stack instead of
+// recursing.
+func (p *Parser) skipValue() (err error) {
+	var b byte
+	var a [32]byte
+	stack := a[:0]
+
+	for {
+		// Skip the next value, which may open an array or a map.
+		if err = p.skipSpaces(); err != nil {
+			return
+		}
+
+		if b, err = p.peekByteAt(0); err != nil {
+			return
+		}
+
+		switch {
+		case b == 'n':
+			err = p.readToken(nullBytes[:])
+
+		case b == 't':
+			err = p.readToken(trueBytes[:])
+
+		case b == 'f':
+			err = p.readToken(falseBytes[:])
+
+		case b == '"':
+			err = p.skipString()
+
+		case b == '-' || (b >= '0' && b <= '9'):
+			err = p.skipNumber()
+
+		case b == '[' || b == '{':
+			p.i++
+			stack = append(stack, b)
+
+			if err = p.skipSpaces(); err != nil {
+				return
+			}
+
+			if b, err = p.peekByteAt(0); err != nil {
+				return
+			}
+
+			if b != closingBracket(stack[len(stack)-1]) {
+				if stack[len(stack)-1] == '{' {
+					err = p.skipMapKey()
+				}
+				if err != nil {
+					return
+				}
+				continue
+			}
+
+			// Empty arrays and maps are closed below.
+
+		default:
+			err = fmt.Errorf("objconv/json: expected token but found '%c'", b)
+		}
+
+		if err != nil {
+			return
+		}
+
+		// Consume the separators and closing brackets that follow the value,
+		// until the next value or the end of the outermost array or map.
+		for {
+			if len(stack) == 0 {
+				return
+			}
+
+			if err = p.skipSpaces(); err != nil {
+				return
+			}
+
+			if b, err = p.peekByteAt(0); err != nil {
+				return
+			}
+
+			top := stack[len(stack)-1]
+
+			if b == closingBracket(top) {
+				p.i++
+				stack = stack[:len(stack)-1]
+				continue
+			}
+
+			if b != ',' {
+				return fmt.Errorf("objconv/json: expected ',' or '%c' but found '%c'", closingBracket(top), b)
+			}
+
+			p.i++
+
+			if top == '{' {
+				if err = p.skipMapKey(); err != nil {
+					return
+				}
+			}
+
+			break
+		}
+	}
+}
+
+// skipMapKey consumes a map key and the colon that follows it.
+func (p *Parser) skipMapKey() (err error) {
+	if err = p.skipSpaces(); err != nil {
+		return
+	}
+	if err = p.skipString(); err != nil {
+		return
+	}
+	return p.ParseMapValue(0)
+}
+
+// skipString consumes the string starting at the current offset, checking that
+// its escape sequences are valid.
+func (p *Parser) skipString() (err error) {
+	if err = p.readByte('"'); err != nil {
+		return
+	}
+
+	for {
+		for p.i != p.j {
+			b := p.b[p.i]
+			p.i++
+
+			switch b {
+			case '"':
+				return
+
+			case '\\':
+				if b, err = p.peekByteAt(0); err != nil {
+					return
+				}
+				p.i++
+
+				switch b {
+				case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+				case 'u':
+					if _, err = p.readUnicode(); err != nil {
+						return
+					}
+				default:
+					return fmt.Errorf("objconv/json: invalid escape sequence '\\%c' in string", b)
+				}
+			}
+		}
+
+		if err = p.fill(); err != nil {
+			return
+		}
+	}
+}
+
+// skipNumber consumes the number starting at the current offset, checking that
+// it is a valid number literal.
+func (p *Parser) skipNumber() error {
+	// Like in ParseType, the error is reported by the next read when the
+	// number ends the input.
+	b, _ := p.peekNumber()
+
+	if !isValidNumber(b) {
+		return fmt.Errorf("objconv/json: invalid number literal %#v", string(b))
+	}
+
+	p.i += len(b)
+	return nil
+}
+
+func closingBracket(b byte) byte {
+	if b == '[' {
+		return ']'
+	}
+	return '}'
+}
+
+// Offset returns the number of bytes consumed from the input.
+func (p *Parser) Offset() int64 {
+	return p.off + int64(p.i)
+}
+
+// Position returns the line and column of the next byte to be read from the
+// input, both starting at 1.
+func (p *Parser) Position() (line int, column int) {
+	b := p.b[:p.i]
+	bol := p.bol
+	line = p.line
+
+	if k := bytes.Count(b, newline[:]); k != 0 {
+		line += k
+		bol = p.off + int64(bytes.LastIndexByte(b, '\n')) + 1
+	}
+
+	line++
+	column = int(p.Offset()-bol) + 1
+	return
+}
+
+// BeginRaw starts recording the bytes consumed by the parser, leading spaces
+// are skipped so the recording starts at the first byte of the next value.
+func (p *Parser) BeginRaw() (err error) {
+	if err = p.skipSpaces(); err == nil {
+		p.raw = true
+		p.ri = p.i
+		p.rb = p.rb[:0]
+	}
+	return
+}
+
+// EndRaw stops recording and returns the bytes consumed since BeginRaw was
+// called.
+func (p *Parser) EndRaw() []byte {
+	p.rb = append(p.rb, p.b[p.ri:p.i]...)
+	p.raw = false
+	return p.rb
+}
+
+// RawFormat returns "json", the format of the bytes returned by EndRaw.
+func (p *Parser) RawFormat() string {
+	return "json"
+}
+
 func (p *Parser) TextParser() bool {
 	return true
 }
@@ -358,6 +633,53 @@ func (p *Parser) peekByteAt(i int) (b byte, err error) {
 	return
 }
 
+// isValidNumber returns true if b is a number literal with the syntax defined
+// by the JSON specification.
+func isValidNumber(b []byte) bool {
+	i := 0
+
+	if i < len(b) && b[i] == '-' {
+		i++
+	}
+
+	switch {
+	case i < len(b) && b[i] == '0':
+		i++
+	case i < len(b) && b[i] >= '1' && b[i] <= '9':
+		i = skipDigits(b, i+1)
+	default:
+		return false
+	}
+
+	if i < len(b) && b[i] == '.' {
+		j := skipDigits(b, i+1)
+		if j == i+1 {
+			return false
+		}
+		i = j
+	}
+
+	if i < len(b) && (b[i] == 'e' || b[i] == 'E') {
+		if i++; i < len(b) && (b[i] == '+' || b[i] == '-') {
+			i++
+		}
+		j := skipDigits(b, i)
+		if j == i {
+			return false
+		}
+		i = j
+	}
+
+	return i == len(b)
+}
+
+func skipDigits(b []byte, i int) int {
+	for i < len(b) && b[i] >= '0' && b[i] <= '9' {
+		i++
+	}
+	return i
+}
+
 func isNumberByte(b byte) bool {
 	return (b >= '0' && b <= '9') || (b == '.') || (b == '+') || (b == '-') || (b == 'e') || (b == 'E')
 }
@@ -462,12 +784,14 @@ func (p *Parser) skipSpaces() (err error) {
 		}
 
 		// all trailing bytes in the read buffer were spaces, clear and refill.
+		p.discard(p.j)
 		p.i = 0
 		p.j = 0
 	}
 }
 
 func (p *Parser) fill() (err error) {
+	p.discard(p.i)
 	n := p.j - p.i
 	copy(p.b[:n], p.b[p.i:p.j])
 	p.i = 0
@@ -486,13 +810,28 @@ func (p *Parser) fill() (err error) {
 	return
 }
 
+// discard accounts for the first n bytes of the read buffer before they get
+// overwritten, so the position in the input can still be computed.
+func (p *Parser) discard(n int) {
+	b := p.b[:n]
+
+	if k := bytes.Count(b, newline[:]); k != 0 {
+		p.line += k
+		p.bol = p.off + int64(bytes.LastIndexByte(b, '\n')) + 1
+	}
+
+	p.off += int64(n)
+
+	if p.raw {
+		p.rb = append(p.rb, p.b[p.ri:n]...)
+		p.ri = 0
+	}
+}
+
 func stringNoCopy(b []byte) string {
 	n := len(b)
 	if n == 0 {
 		return ""
 	}
-	return *(*string)(unsafe.Pointer(&reflect.StringHeader{
-		Data: uintptr(unsafe.Pointer(&b[0])),
-		Len:  n,
-	}))
+	return *(*string)(unsafe.Pointer(&b))
 }
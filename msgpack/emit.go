@@ -25,6 +25,16 @@ type Emitter struct {
 	// sback is used as the initial backing array for the stack slice to avoid
 	// dynamic memory allocations for the most common use cases.
 	sback [8]*context
+
+	// When compact is set, float64 values that can be represented by a
+	// float32 are written as float32.
+	compact bool
+
+	// When typed is set, durations and errors are written as extensions
+	// of type extDuration and extError instead of strings.
+	typed       bool
+	extDuration int8
+	extError    int8
 }
 
 type context struct {
@@ -34,7 +44,7 @@ type context struct {
 }
 
 func NewEmitter(w io.Writer) *Emitter {
-	e := &Emitter{w: w}
+	e := &Emitter{w: w, extDuration: ExtDuration, extError: ExtError}
 	e.stack = e.sback[:0]
 	return e
 }
@@ -42,6 +52,38 @@ func NewEmitter(w io.Writer) *Emitter {
 func (e *Emitter) Reset(w io.Writer) {
 	e.w = w
 	e.stack = e.stack[:0]
+	e.extDuration = ExtDuration
+	e.extError = ExtError
+}
+
+// SetCompactFloats configures whether the emitter writes float64 values as
+// float32 when the conversion is lossless, which makes payloads smaller at the
+// cost of converting the values.
+func (e *Emitter) SetCompactFloats(compact bool) {
+	e.compact = compact
+}
+
+// SetTypedValues configures whether the emitter writes durations and errors as
+// extensions which are decoded back into durations and errors by parsers
+// configured with SetTypedValues, instead of strings.
+//
+// Durations are written as extensions of type ExtDuration holding the number
+// of nanoseconds in a 64 bits big-endian integer, errors are written as
+// extensions of type ExtError holding the error message. The extension types
+// can be changed with SetTypedExtensions.
+func (e *Emitter) SetTypedValues(typed bool) {
+	e.typed = typed
+}
+
+// SetTypedExtensions configures the emitter to write durations and errors as
+// extensions of the given types instead of ExtDuration and ExtError, and
+// enables typed values. Calling SetTypedValues afterwards keeps the types.
+//
+// The method panics if one of the types is reserved by the MessagePack
+// specification (-128 to -1).
+func (e *Emitter) SetTypedExtensions(durationType int8, errorType int8) {
+	checkTypedExtensions(durationType, errorType)
+	e.typed, e.extDuration, e.extError = true, durationType, errorType
 }
 
 func (e *Emitter) EmitNil() (err error) {
@@ -147,6 +189,12 @@ func (e *Emitter) EmitUint(v uint64, _ int) (err error) {
 }
 
 func (e *Emitter) EmitFloat(v float64, bitSize int) (err error) {
+	if e.compact && bitSize != 32 {
+		if f := float32(v); math.Float64bits(float64(f)) == math.Float64bits(v) {
+			bitSize = 32
+		}
+	}
+
 	switch bitSize {
 	case 32:
 		e.b[0] = Float32
@@ -274,11 +322,77 @@ func (e *Emitter) EmitTime(v time.Time) (err error) {
 	return
 }
 
+// AdapterScope returns the scope of the adapters installed by
+// RegisterExtension, which are only used by MessagePack emitters.
+func (e *Emitter) AdapterScope() string {
+	return adapterScope
+}
+
+// EmitExtension writes an extension of type x carrying the data in v, using
+// the fixext formats when the length of v allows it.
+func (e *Emitter) EmitExtension(x int8, v []byte) (err error) {
+	n := len(v)
+
+	switch {
+	case n == 1:
+		e.b[0] = Fixext1
+		n = 1
+	case n == 2:
+		e.b[0] = Fixext2
+		n = 1
+	case n == 4:
+		e.b[0] = Fixext4
+		n = 1
+	case n == 8:
+		e.b[0] = Fixext8
+		n = 1
+	case n == 16:
+		e.b[0] = Fixext16
+		n = 1
+
+	case n <= objutil.Uint8Max:
+		e.b[0] = Ext8
+		e.b[1] = byte(n)
+		n = 2
+
+	case n <= objutil.Uint16Max:
+		e.b[0] = Ext16
+		putUint16(e.b[1:], uint16(n))
+		n = 3
+
+	case n <= objutil.Uint32Max:
+		e.b[0] = Ext32
+		putUint32(e.b[1:], uint32(n))
+		n = 5
+
+	default:
+		err = fmt.Errorf("objconv/msgpack: extension of length %d is too long to be encoded", n)
+		return
+	}
+
+	e.b[n] = byte(x)
+
+	if _, err = e.w.Write(e.b[:n+1]); err != nil {
+		return
+	}
+
+	_, err = e.w.Write(v)
+	return
+}
+
 func (e *Emitter) EmitDuration(v time.Duration) (err error) {
+	if e.typed {
+		var b [8]byte
+		putUint64(b[:], uint64(v))
+		return e.EmitExtension(e.extDuration, b[:])
+	}
 	return e.EmitString(string(objutil.AppendDuration(e.b[:0], v)))
 }
 
 func (e *Emitter) EmitError(v error) (err error) {
+	if e.typed {
+		return e.EmitExtension(e.extError, []byte(v.Error()))
+	}
 	return e.EmitString(v.Error())
 }
 
@@ -370,6 +484,18 @@ func (e *Emitter) EmitMapNext() (err error) {
 	return
 }
 
+// EmitRaw writes b to the output, it must contain a value serialized in the
+// MessagePack format.
+func (e *Emitter) EmitRaw(b []byte) (err error) {
+	_, err = e.w.Write(b)
+	return
+}
+
+// RawFormat returns "msgpack", the format of the bytes accepted by EmitRaw.
+func (e *Emitter) RawFormat() string {
+	return "msgpack"
+}
+
 func (e *Emitter) emitArray(n int) (err error) {
 	switch {
 	case n <= 15:
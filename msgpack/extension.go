@@ -0,0 +1,174 @@
+package msgpack
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/dolab/objconv"
+)
+
+// Extension represents a MessagePack extension whose type has no registered Go
+// type.
+//
+// Extension values are written as extensions by the MessagePack emitter, other
+// emitters only receive the data of the extension as a byte slice.
+type Extension struct {
+	Type int8
+	Data []byte
+}
+
+// RegisterExtension maps the MessagePack extension type x to typ, using the
+// marshal and unmarshal functions to convert values of type typ to and from the
+// data of the extension.
+//
+// The MessagePack emitter writes values of type typ as extensions of type x,
+// and the MessagePack parser decodes extensions of type x into values of type
+// typ when the destination is an empty interface. The marshal and unmarshal
+// functions are only used by the MessagePack emitters and parsers, other
+// formats encode and decode values of typ as usual.
+//
+// The byte slice passed to unmarshal is only valid until the function returns,
+// it must be copied if the value retains it.
+//
+// The function panics if x is one of the types reserved by the MessagePack
+// specification (-128 to -1).
+func RegisterExtension(x int8, typ reflect.Type, marshal func(reflect.Value) ([]byte, error), unmarshal func([]byte, reflect.Value) error) {
+	if x < 0 {
+		panic(fmt.Sprintf("objconv/msgpack: extension type %d is reserved", x))
+	}
+
+	objconv.InstallScoped(adapterScope, typ, objconv.Adapter{
+		Encode: func(e objconv.Encoder, v reflect.Value) (err error) {
+			var b []byte
+
+			if v.Kind() == reflect.Ptr && v.IsNil() {
+				return e.Emitter.EmitNil()
+			}
+
+			if b, err = marshal(v); err != nil {
+				return
+			}
+
+			if p, ok := e.Emitter.(extensionEmitter); ok {
+				return p.EmitExtension(x, b)
+			}
+
+			return e.Emitter.EmitBytes(b)
+		},
+		Decode: func(d objconv.Decoder, to reflect.Value) (err error) {
+			var t objconv.Type
+			var b []byte
+
+			if t, err = d.Parser.ParseType(); err != nil {
+				return
+			}
+
+			switch t {
+			case objconv.Nil:
+				if err = d.Parser.ParseNil(); err == nil && to.IsValid() {
+					to.Set(reflect.Zero(to.Type()))
+				}
+				return
+
+			case objconv.Bytes:
+				if p, ok := d.Parser.(extensionParser); ok {
+					var y int8
+
+					if y, b, err = p.ParseExtension(); err == nil && y != x {
+						err = fmt.Errorf("objconv/msgpack: cannot decode %s from an extension of type %d", typ, y)
+					}
+				} else {
+					b, err = d.Parser.ParseBytes()
+				}
+
+			case objconv.String:
+				b, err = d.Parser.ParseString()
+
+			default:
+				err = fmt.Errorf("objconv/msgpack: cannot decode %s from %s", typ, t)
+			}
+
+			if err == nil && to.IsValid() {
+				err = unmarshal(b, to)
+			}
+			return
+		},
+	})
+
+	extMutex.Lock()
+	extStore[x] = typ
+	extMutex.Unlock()
+}
+
+func checkTypedExtensions(durationType int8, errorType int8) {
+	for _, x := range [...]int8{durationType, errorType} {
+		if x < 0 {
+			panic(fmt.Sprintf("objconv/msgpack: extension type %d is reserved", x))
+		}
+	}
+	if durationType == errorType {
+		panic(fmt.Sprintf("objconv/msgpack: durations and errors cannot share the extension type %d", durationType))
+	}
+}
+
+func extensionTypeOf(x int8) (t reflect.Type) {
+	extMutex.RLock()
+	t = extStore[x]
+	extMutex.RUnlock()
+	return
+}
+
+func isExtension(tag byte) bool {
+	switch tag {
+	case Fixext1, Fixext2, Fixext4, Fixext8, Fixext16, Ext8, Ext16, Ext32:
+		return true
+	}
+	return false
+}
+
+var (
+	extMutex      sync.RWMutex
+	extStore      = make(map[int8]reflect.Type)
+	extensionType = reflect.TypeOf(Extension{})
+)
+
+// The extensionEmitter interface is implemented by the MessagePack emitter and
+// the types that embed it.
+type extensionEmitter interface {
+	EmitExtension(int8, []byte) error
+}
+
+// The extensionParser interface is implemented by the MessagePack parser and
+// the types that embed it.
+type extensionParser interface {
+	ParseExtension() (int8, []byte, error)
+}
+
+// EncodeValue satisfies the objconv.ValueEncoder interface.
+func (ext Extension) EncodeValue(e objconv.Encoder) error {
+	if x, ok := e.Emitter.(extensionEmitter); ok {
+		return x.EmitExtension(ext.Type, ext.Data)
+	}
+
+	return e.Emitter.EmitBytes(ext.Data)
+}
+
+// DecodeValue satisfies the objconv.ValueDecoder interface, the extension type
+// is left to zero by parsers other than the MessagePack parser.
+func (ext *Extension) DecodeValue(d objconv.Decoder) (err error) {
+	var v Extension
+	var b []byte
+
+	if x, ok := d.Parser.(extensionParser); ok {
+		v.Type, b, err = x.ParseExtension()
+	} else {
+		err = d.Decode(&b)
+	}
+
+	if err == nil {
+		v.Data = append([]byte{}, b...)
+		*ext = v
+	}
+	return
+}
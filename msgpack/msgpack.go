@@ -60,8 +60,19 @@ const (
 	NegativeFixintTag  = 0xE0
 
 	ExtTime = int8(-1)
+
+	// ExtDuration and ExtError are the default extension types of durations
+	// and errors written by emitters and read by parsers configured with
+	// SetTypedValues. They are not part of the MessagePack specification,
+	// which reserves the negative types, SetTypedExtensions changes them when
+	// they conflict with the extensions of the application.
+	ExtDuration = int8(126)
+	ExtError    = int8(127)
 )
 
+// adapterScope is the scope of the adapters installed by RegisterExtension.
+const adapterScope = "msgpack"
+
 func putUint16(b []byte, v uint16) {
 	binary.BigEndian.PutUint16(b, v)
 }
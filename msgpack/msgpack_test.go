@@ -1,8 +1,16 @@
 package msgpack
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
 	"testing"
+	"time"
 
+	"github.com/dolab/objconv"
+	"github.com/dolab/objconv/json"
 	"github.com/dolab/objconv/objtests"
 )
 
@@ -10,6 +18,343 @@ func TestCodec(t *testing.T) {
 	objtests.TestCodec(t, Codec)
 }
 
+func TestRawValue(t *testing.T) {
+	objtests.TestRawValue(t, Codec)
+}
+
 func BenchmarkCodec(b *testing.B) {
 	objtests.BenchmarkCodec(b, Codec)
 }
+
+func TestDecodeErrorOffset(t *testing.T) {
+	b, _ := Marshal([]interface{}{1, 2, true, 3})
+
+	var v []int
+	var e *objconv.DecodeError
+
+	if err := Unmarshal(b, &v); !errors.As(err, &e) {
+		t.Fatalf("expected *objconv.DecodeError but got %#v", err)
+	}
+
+	if e.Path != "[2]" {
+		t.Error("bad path:", e.Path)
+	}
+
+	if e.Offset != 3 {
+		t.Error("bad offset:", e.Offset)
+	}
+}
+
+func TestCompactFloats(t *testing.T) {
+	tests := []struct {
+		v float64
+		b []byte
+	}{
+		{0.5, []byte{Float32, 0x3f, 0x00, 0x00, 0x00}},
+		{math.Inf(1), []byte{Float32, 0x7f, 0x80, 0x00, 0x00}},
+		{0.1, []byte{Float64, 0x3f, 0xb9, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a}},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.v), func(t *testing.T) {
+			var b bytes.Buffer
+
+			e := NewEmitter(&b)
+			e.SetCompactFloats(true)
+
+			if err := objconv.NewEncoder(e).Encode(test.v); err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(b.Bytes(), test.b) {
+				t.Errorf("bad encoding: %#v", b.Bytes())
+			}
+
+			var v float64
+
+			if err := Unmarshal(b.Bytes(), &v); err != nil {
+				t.Fatal(err)
+			}
+
+			if v != test.v {
+				t.Error("bad decoding:", v)
+			}
+		})
+	}
+}
+
+func TestUnknownExtension(t *testing.T) {
+	tests := []struct {
+		ext Extension
+		b   []byte
+	}{
+		{Extension{1, []byte{0xA}}, []byte{Fixext1, 1, 0xA}},
+		{Extension{2, []byte{1, 2}}, []byte{Fixext2, 2, 1, 2}},
+		{Extension{3, []byte{1, 2, 3, 4}}, []byte{Fixext4, 3, 1, 2, 3, 4}},
+		{Extension{4, bytes.Repeat([]byte{1}, 8)}, append([]byte{Fixext8, 4}, bytes.Repeat([]byte{1}, 8)...)},
+		{Extension{5, bytes.Repeat([]byte{1}, 16)}, append([]byte{Fixext16, 5}, bytes.Repeat([]byte{1}, 16)...)},
+		{Extension{-100, []byte{}}, []byte{Ext8, 0, 0x9c}},
+		{Extension{6, []byte{1, 2, 3}}, []byte{Ext8, 3, 6, 1, 2, 3}},
+		{Extension{7, bytes.Repeat([]byte{1}, 300)}, append([]byte{Ext16, 0x01, 0x2c, 7}, bytes.Repeat([]byte{1}, 300)...)},
+		{Extension{8, bytes.Repeat([]byte{1}, 70000)}, append([]byte{Ext32, 0x00, 0x01, 0x11, 0x70, 8}, bytes.Repeat([]byte{1}, 70000)...)},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%d:%d", test.ext.Type, len(test.ext.Data)), func(t *testing.T) {
+			b, err := Marshal(test.ext)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(b, test.b) {
+				t.Errorf("bad encoding: %#v", b)
+			}
+
+			var v interface{}
+
+			if err := Unmarshal(b, &v); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(v, test.ext) {
+				t.Errorf("bad decoding: %#v", v)
+			}
+
+			var data []byte
+
+			if err := Unmarshal(b, &data); err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(data, test.ext.Data) {
+				t.Errorf("bad data: %#v", data)
+			}
+		})
+	}
+}
+
+type point struct {
+	X int8
+	Y int8
+}
+
+func TestRegisterExtension(t *testing.T) {
+	RegisterExtension(42, reflect.TypeOf(point{}),
+		func(v reflect.Value) ([]byte, error) {
+			p := v.Interface().(point)
+			return []byte{byte(p.X), byte(p.Y)}, nil
+		},
+		func(b []byte, to reflect.Value) error {
+			if len(b) != 2 {
+				return errors.New("bad point length")
+			}
+			to.Set(reflect.ValueOf(point{int8(b[0]), int8(b[1])}))
+			return nil
+		},
+	)
+
+	b, err := Marshal([]interface{}{point{1, -1}, Extension{43, []byte{1, 2}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, []byte{0x92, Fixext2, 42, 0x01, 0xff, Fixext2, 43, 0x01, 0x02}) {
+		t.Errorf("bad encoding: %#v", b)
+	}
+
+	var v []interface{}
+
+	if err := Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(v, []interface{}{point{1, -1}, Extension{43, []byte{1, 2}}}) {
+		t.Errorf("bad decoding: %#v", v)
+	}
+
+	var p []point
+
+	if err := Unmarshal(b, &p); err == nil {
+		t.Error("expected an error when decoding an extension of another type")
+	}
+
+	// The extension must not change how other formats encode the type.
+	if b, err := json.Marshal(point{1, -1}); err != nil {
+		t.Fatal(err)
+	} else if string(b) != `{"X":1,"Y":-1}` {
+		t.Errorf("bad json encoding: %s", b)
+	}
+
+	var q point
+
+	if err := json.Unmarshal([]byte(`{"X":1,"Y":-1}`), &q); err != nil {
+		t.Fatal(err)
+	} else if q != (point{1, -1}) {
+		t.Errorf("bad json decoding: %#v", q)
+	}
+}
+
+func TestTypedValues(t *testing.T) {
+	tests := []struct {
+		v interface{}
+		b []byte
+	}{
+		{time.Second, []byte{Fixext8, 0x7e, 0x00, 0x00, 0x00, 0x00, 0x3b, 0x9a, 0xca, 0x00}},
+		{-time.Nanosecond, []byte{Fixext8, 0x7e, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{errors.New("oops"), []byte{Fixext4, 0x7f, 'o', 'o', 'p', 's'}},
+		{errors.New("failed"), []byte{Ext8, 6, 0x7f, 'f', 'a', 'i', 'l', 'e', 'd'}},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.v), func(t *testing.T) {
+			var b bytes.Buffer
+
+			e := NewEmitter(&b)
+			e.SetTypedValues(true)
+
+			if err := objconv.NewEncoder(e).Encode(test.v); err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(b.Bytes(), test.b) {
+				t.Errorf("bad encoding: %#v", b.Bytes())
+			}
+
+			var v interface{}
+
+			p := NewParser(bytes.NewReader(b.Bytes()))
+			p.SetTypedValues(true)
+
+			if err := objconv.NewDecoder(p).Decode(&v); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(v, test.v) {
+				t.Errorf("bad decoding: %#v", v)
+			}
+
+			// Parsers that aren't configured read them as other extensions.
+			if err := Unmarshal(b.Bytes(), &v); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, ok := v.(Extension); !ok {
+				t.Errorf("bad decoding without typed values: %#v", v)
+			}
+		})
+	}
+}
+
+func TestTypedExtensions(t *testing.T) {
+	var b bytes.Buffer
+
+	e := NewEmitter(&b)
+	e.SetTypedExtensions(10, 11)
+
+	if err := objconv.NewEncoder(e).Encode([]interface{}{time.Second, errors.New("oops")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b.Bytes(), []byte{
+		0x92,
+		Fixext8, 10, 0x00, 0x00, 0x00, 0x00, 0x3b, 0x9a, 0xca, 0x00,
+		Fixext4, 11, 'o', 'o', 'p', 's',
+	}) {
+		t.Errorf("bad encoding: %#v", b.Bytes())
+	}
+
+	var v []interface{}
+
+	p := NewParser(bytes.NewReader(b.Bytes()))
+	p.SetTypedExtensions(10, 11)
+
+	if err := objconv.NewDecoder(p).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(v, []interface{}{time.Second, errors.New("oops")}) {
+		t.Errorf("bad decoding: %#v", v)
+	}
+
+	for _, x := range [][2]int8{{-2, 11}, {10, -3}, {10, 10}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected a panic when configuring the extension types %d and %d", x[0], x[1])
+				}
+			}()
+			NewEmitter(&b).SetTypedExtensions(x[0], x[1])
+		}()
+	}
+}
+
+func TestTypedExtensionsToggle(t *testing.T) {
+	var b bytes.Buffer
+
+	e := NewEmitter(&b)
+	e.SetTypedExtensions(10, 11)
+	e.SetTypedValues(false)
+
+	if err := objconv.NewEncoder(e).Encode(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if b.Bytes()[0] == Fixext8 {
+		t.Errorf("durations must not be written as extensions when typed values are disabled: %#v", b.Bytes())
+	}
+
+	b.Reset()
+	e.SetTypedValues(true)
+
+	if err := objconv.NewEncoder(e).Encode(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b.Bytes(), []byte{Fixext8, 10, 0x00, 0x00, 0x00, 0x00, 0x3b, 0x9a, 0xca, 0x00}) {
+		t.Errorf("bad encoding: %#v", b.Bytes())
+	}
+
+	var v interface{}
+
+	p := NewParser(bytes.NewReader(b.Bytes()))
+	p.SetTypedExtensions(10, 11)
+	p.SetTypedValues(false)
+	p.SetTypedValues(true)
+
+	if err := objconv.NewDecoder(p).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	if v != time.Second {
+		t.Errorf("bad decoding: %#v", v)
+	}
+}
+
+func TestDecodePath(t *testing.T) {
+	b, err := Marshal(map[string]interface{}{
+		"blob":   bytes.Repeat([]byte("x"), 1000),
+		"points": []map[int]int{{1: 10}, {1: 20, 2: 30}},
+		"when":   time.Unix(1, 0).UTC(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v int
+
+	if err := NewDecoder(bytes.NewReader(b)).DecodePath("points[1][2]", &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if v != 30 {
+		t.Error("bad value:", v)
+	}
+
+	err = NewDecoder(bytes.NewReader(b)).DecodePath("/points/2", &v)
+
+	var e *objconv.PathNotFoundError
+
+	if !errors.As(err, &e) {
+		t.Error("bad error:", err)
+	}
+}
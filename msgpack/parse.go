@@ -2,9 +2,11 @@ package msgpack
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"math"
+	"reflect"
 	"time"
 
 	"github.com/dolab/objconv"
@@ -16,22 +18,60 @@ type Parser struct {
 	j int       // offset + 1 of the last unread byte in b
 	s []byte    // string buffer
 	b [240]byte // read buffer
+	o int64     // offset of b[0] in the input
+
+	raw bool   // set when the consumed bytes are being recorded
+	ri  int    // offset in b of the first byte to record
+	rb  []byte // buffer of recorded bytes
+
+	// When typed is set, extensions of type extDuration and extError are
+	// read as durations and errors.
+	typed       bool
+	extDuration int8
+	extError    int8
 }
 
 func NewParser(r io.Reader) *Parser {
-	return &Parser{r: r}
+	return &Parser{r: r, extDuration: ExtDuration, extError: ExtError}
 }
 
 func (p *Parser) Reset(r io.Reader) {
 	p.r = r
 	p.i = 0
 	p.j = 0
+	p.o = 0
+	p.raw = false
+	p.extDuration = ExtDuration
+	p.extError = ExtError
+}
+
+// SetTypedValues configures whether the parser reads extensions of type
+// ExtDuration and ExtError as durations and errors, which emitters configured
+// with SetTypedValues write. Otherwise they are read as other extensions.
+func (p *Parser) SetTypedValues(typed bool) {
+	p.typed = typed
+}
+
+// SetTypedExtensions configures the parser to read extensions of the given
+// types as durations and errors instead of ExtDuration and ExtError, and
+// enables typed values. Calling SetTypedValues afterwards keeps the types.
+//
+// The method panics if one of the types is reserved by the MessagePack
+// specification (-128 to -1).
+func (p *Parser) SetTypedExtensions(durationType int8, errorType int8) {
+	checkTypedExtensions(durationType, errorType)
+	p.typed, p.extDuration, p.extError = true, durationType, errorType
 }
 
 func (p *Parser) Buffered() io.Reader {
 	return bytes.NewReader(p.b[p.i:p.j])
 }
 
+// Offset returns the number of bytes consumed from the input.
+func (p *Parser) Offset() int64 {
+	return p.o + int64(p.i)
+}
+
 func (p *Parser) ParseType() (objconv.Type, error) {
 	b, err := p.peek(1)
 	if err != nil {
@@ -85,43 +125,113 @@ func (p *Parser) ParseType() (objconv.Type, error) {
 	case Map16, Map32:
 		return objconv.Map, nil
 
-	case Fixext1, Fixext2, Fixext4, Fixext8, Fixext16:
-		if b, err = p.peek(2); err != nil {
+	case Fixext1, Fixext2, Fixext4, Fixext8, Fixext16, Ext8, Ext16, Ext32:
+		x, _, _, err := p.extension()
+		if err != nil {
 			return objconv.Unknown, err
 		}
-
-		switch tag = b[1]; int8(tag) {
-		case ExtTime:
+		switch {
+		case x == ExtTime:
 			return objconv.Time, nil
-
-		default:
-			return objconv.Unknown, fmt.Errorf("objconv/msgpack: unsupported extension '%d'", tag)
+		case p.isTypedDuration(x):
+			return objconv.Duration, nil
+		case p.isTypedError(x):
+			return objconv.Error, nil
 		}
+		return objconv.Bytes, nil
+	}
 
-	case Ext8, Ext16, Ext32: // continue after the switch
-	default:
-		return objconv.Unknown, fmt.Errorf("objconv/msgpack: unknown tag '%#x'", tag)
+	return objconv.Unknown, fmt.Errorf("objconv/msgpack: unknown tag '%#x'", tag)
+}
+
+// DynamicType returns the Go type that the value found by the last call to
+// ParseType is decoded into when the destination is an empty interface.
+//
+// Extensions registered with RegisterExtension are decoded into the registered
+// type, other extensions are decoded into Extension values, timestamps,
+// durations and errors are decoded into time, duration and error values.
+func (p *Parser) DynamicType() reflect.Type {
+	if !isExtension(p.b[p.i]) {
+		return nil
 	}
 
-	switch tag {
+	x, _, _, err := p.extension()
+	if err != nil || x == ExtTime || p.isTypedDuration(x) || p.isTypedError(x) {
+		return nil
+	}
+
+	if t := extensionTypeOf(x); t != nil {
+		return t
+	}
+
+	return extensionType
+}
+
+func (p *Parser) isTypedDuration(x int8) bool {
+	return p.typed && x == p.extDuration
+}
+
+func (p *Parser) isTypedError(x int8) bool {
+	return p.typed && x == p.extError
+}
+
+// AdapterScope returns the scope of the adapters installed by
+// RegisterExtension, which are only used by MessagePack parsers.
+func (p *Parser) AdapterScope() string {
+	return adapterScope
+}
+
+// ParseExtension parses an extension, returning its type and data.
+//
+// The returned byte slice is only valid until the next call to the parser.
+func (p *Parser) ParseExtension() (x int8, v []byte, err error) {
+	var h, n int
+
+	if !isExtension(p.b[p.i]) {
+		err = fmt.Errorf("objconv/msgpack: expected an extension but found tag '%#x'", p.b[p.i])
+		return
+	}
+
+	if x, h, n, err = p.extension(); err != nil {
+		return
+	}
+
+	p.i += h
+	v, err = p.read(n)
+	return
+}
+
+// extension peeks the header of the extension at the current offset, returning
+// the extension type, the length of the header, and the length of the data.
+func (p *Parser) extension() (x int8, h int, n int, err error) {
+	var b []byte
+
+	switch tag := p.b[p.i]; tag {
+	case Fixext1, Fixext2, Fixext4, Fixext8, Fixext16:
+		h, n = 2, 1<<(tag-Fixext1)
 	case Ext8:
-		b, err = p.peek(3)
+		h = 3
 	case Ext16:
-		b, err = p.peek(4)
+		h = 4
 	default:
-		b, err = p.peek(6)
+		h = 6
 	}
 
-	if err != nil {
-		return objconv.Unknown, err
+	if b, err = p.peek(h); err != nil {
+		return
 	}
 
-	switch tag = b[len(b)-1]; int8(tag) {
-	case ExtTime:
-		return objconv.Time, nil
+	switch h {
+	case 3:
+		n = int(b[1])
+	case 4:
+		n = int(getUint16(b[1:]))
+	case 6:
+		n = int(getUint32(b[1:]))
 	}
 
-	return objconv.Unknown, fmt.Errorf("objconv/msgpack: unknown extension '%d'", tag)
+	x = int8(b[h-1])
+	return
 }
 
 func (p *Parser) ParseNil() (err error) {
@@ -284,6 +394,11 @@ func (p *Parser) ParseString() (v []byte, err error) {
 }
 
 func (p *Parser) ParseBytes() (v []byte, err error) {
+	if isExtension(p.b[p.i]) {
+		_, v, err = p.ParseExtension()
+		return
+	}
+
 	tag := p.b[p.i]
 	p.i++
 
@@ -366,12 +481,31 @@ func (p *Parser) ParseTime() (v time.Time, err error) {
 	return
 }
 
+// ParseDuration parses an extension holding a duration, see SetTypedValues.
 func (p *Parser) ParseDuration() (v time.Duration, err error) {
-	panic("objconv/msgpack: ParseDuration should never be called because MessagePack has no duration type, this is likely a bug in the decoder code")
+	var b []byte
+
+	if _, b, err = p.ParseExtension(); err != nil {
+		return
+	}
+
+	if len(b) != 8 {
+		err = fmt.Errorf("objconv/msgpack: invalid duration length, expected 8 but found %d", len(b))
+		return
+	}
+
+	v = time.Duration(getUint64(b))
+	return
 }
 
+// ParseError parses an extension holding an error, see SetTypedValues.
 func (p *Parser) ParseError() (v error, err error) {
-	panic("objconv/msgpack: ParseError should never be called because MessagePack has no error type, this is likely a bug in the decoder code")
+	var b []byte
+
+	if _, b, err = p.ParseExtension(); err == nil {
+		v = errors.New(string(b))
+	}
+	return
 }
 
 func (p *Parser) ParseArrayBegin() (n int, err error) {
@@ -460,6 +594,124 @@ func (p *Parser) ParseMapNext(n int) (err error) {
 	return
 }
 
+// Skip consumes the value found by the last call to ParseType without loading
+// it, the parser jumps over the bytes of strings, binaries and extensions, and
+// counts the elements of arrays and maps that remain to be skipped.
+func (p *Parser) Skip() (err error) {
+	for n := 1; n != 0; n-- {
+		var b []byte
+		var size int
+
+		if b, err = p.peek(1); err != nil {
+			return
+		}
+
+		tag := b[0]
+
+		switch {
+		case (tag & PositiveFixintMask) == PositiveFixintTag, (tag & NegativeFixintMask) == NegativeFixintTag:
+			size = 1
+
+		case (tag & FixstrMask) == FixstrTag:
+			size = 1 + int(tag & ^byte(FixstrMask))
+
+		case (tag & FixarrayMask) == FixarrayTag:
+			size, n = 1, n+int(tag & ^byte(FixarrayMask))
+
+		case (tag & FixmapMask) == FixmapTag:
+			size, n = 1, n+2*int(tag & ^byte(FixmapMask))
+
+		default:
+			switch tag {
+			case Nil, False, True:
+				size = 1
+
+			case Int8, Uint8:
+				size = 2
+
+			case Int16, Uint16:
+				size = 3
+
+			case Int32, Uint32, Float32:
+				size = 5
+
+			case Int64, Uint64, Float64:
+				size = 9
+
+			case Str8, Bin8, Str16, Bin16, Str32, Bin32, Array16, Array32, Map16, Map32:
+				var h, m int
+
+				switch tag {
+				case Str8, Bin8:
+					h = 2
+				case Str16, Bin16, Array16, Map16:
+					h = 3
+				default:
+					h = 5
+				}
+
+				if b, err = p.peek(h); err != nil {
+					return
+				}
+
+				switch h {
+				case 2:
+					m = int(b[1])
+				case 3:
+					m = int(getUint16(b[1:]))
+				default:
+					m = int(getUint32(b[1:]))
+				}
+
+				switch tag {
+				case Array16, Array32:
+					size, n = h, n+m
+				case Map16, Map32:
+					size, n = h, n+2*m
+				default:
+					size = h + m
+				}
+
+			case Fixext1, Fixext2, Fixext4, Fixext8, Fixext16, Ext8, Ext16, Ext32:
+				var h, m int
+
+				if _, h, m, err = p.extension(); err != nil {
+					return
+				}
+
+				size = h + m
+
+			default:
+				return fmt.Errorf("objconv/msgpack: unknown tag '%#x'", tag)
+			}
+		}
+
+		if err = p.skip(size); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// skip consumes the next n bytes of the input.
+func (p *Parser) skip(n int) (err error) {
+	for {
+		k := p.j - p.i
+
+		if n <= k {
+			p.i += n
+			return
+		}
+
+		p.i, n = p.j, n-k
+
+		if err = p.fill(); err != nil {
+			return
+		}
+	}
+}
+
 func (p *Parser) read(n int) (b []byte, err error) {
 	if n <= (p.j - p.i) { // check if the string is already buffered
 		b = p.b[p.i : p.i+n]
@@ -483,6 +735,8 @@ func (p *Parser) read(n int) (b []byte, err error) {
 
 	copy(p.s, p.b[p.i:p.j])
 	n = p.j - p.i
+	p.i = p.j
+	p.discard()
 	p.i = 0
 	p.j = 0
 
@@ -490,10 +744,49 @@ func (p *Parser) read(n int) (b []byte, err error) {
 		return
 	}
 
+	p.o += int64(len(p.s) - n)
+
+	if p.raw {
+		p.rb = append(p.rb, p.s[n:]...)
+	}
+
 	b = p.s
 	return
 }
 
+// discard accounts for the bytes consumed from the read buffer before they get
+// overwritten, the caller is expected to move the unread bytes to the beginning
+// of the buffer.
+func (p *Parser) discard() {
+	p.o += int64(p.i)
+
+	if p.raw {
+		p.rb = append(p.rb, p.b[p.ri:p.i]...)
+		p.ri = 0
+	}
+}
+
+// BeginRaw starts recording the bytes consumed by the parser.
+func (p *Parser) BeginRaw() error {
+	p.raw = true
+	p.ri = p.i
+	p.rb = p.rb[:0]
+	return nil
+}
+
+// EndRaw stops recording and returns the bytes consumed since BeginRaw was
+// called.
+func (p *Parser) EndRaw() []byte {
+	p.rb = append(p.rb, p.b[p.ri:p.i]...)
+	p.raw = false
+	return p.rb
+}
+
+// RawFormat returns "msgpack", the format of the bytes returned by EndRaw.
+func (p *Parser) RawFormat() string {
+	return "msgpack"
+}
+
 func (p *Parser) peek(n int) (b []byte, err error) {
 	for (p.i + n) > p.j {
 		if err = p.fill(); err != nil {
@@ -506,6 +799,7 @@ func (p *Parser) peek(n int) (b []byte, err error) {
 
 func (p *Parser) fill() (err error) {
 	n := p.j - p.i
+	p.discard()
 	copy(p.b[:], p.b[p.i:p.j])
 	p.i = 0
 	p.j = n
@@ -0,0 +1,414 @@
+package objconv
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+)
+
+// Number represents a number in its literal text form, it is used to decode
+// numbers without losing precision when the destination is an empty interface
+// and the decoder has UseNumber set, or when a program needs to carry numbers
+// without interpreting them.
+//
+// When encoded, a Number is written as a number by emitters that support it
+// (like JSON), other emitters receive an integer or a floating point value if
+// it can be represented without overflowing, or a string otherwise.
+type Number string
+
+// String returns the literal text of the number.
+func (n Number) String() string {
+	return string(n)
+}
+
+// Int64 returns the number as an int64.
+func (n Number) Int64() (int64, error) {
+	return strconv.ParseInt(string(n), 10, 64)
+}
+
+// Uint64 returns the number as an uint64.
+func (n Number) Uint64() (uint64, error) {
+	return strconv.ParseUint(string(n), 10, 64)
+}
+
+// Float64 returns the number as a float64.
+func (n Number) Float64() (float64, error) {
+	return strconv.ParseFloat(string(n), 64)
+}
+
+// The numberParser interface may be implemented by parsers that can expose the
+// literal text of numbers, or represent numbers that don't fit in 64 bits.
+type numberParser interface {
+	// ParseNumber is called after ParseType returned Int, Uint or Float and
+	// returns the number in the syntax of Go's strconv package. The returned
+	// slice is only valid until the next call to the parser.
+	ParseNumber() ([]byte, error)
+}
+
+// The numberEmitter interface may be implemented by emitters that can output
+// numbers of arbitrary precision.
+type numberEmitter interface {
+	// EmitNumber is called with a valid decimal number literal, as reported
+	// by Number values or the String method of math/big types.
+	EmitNumber(string) error
+}
+
+func (e Encoder) encodeNumber(v reflect.Value) error {
+	return e.emitNumber(v.String())
+}
+
+func (e Encoder) encodeBigInt(v reflect.Value) error {
+	var x *big.Int
+
+	if v.CanAddr() {
+		x = v.Addr().Interface().(*big.Int)
+	} else {
+		i := v.Interface().(big.Int)
+		x = &i
+	}
+
+	return e.emitNumber(x.String())
+}
+
+func (e Encoder) encodeBigFloat(v reflect.Value) error {
+	var x *big.Float
+
+	if v.CanAddr() {
+		x = v.Addr().Interface().(*big.Float)
+	} else {
+		f := v.Interface().(big.Float)
+		x = &f
+	}
+
+	if x.IsInf() {
+		return fmt.Errorf("objconv: cannot encode infinite big.Float %s", x)
+	}
+
+	return e.emitNumber(x.Text('g', -1))
+}
+
+func (e Encoder) encodeBigRat(v reflect.Value) error {
+	var x *big.Rat
+
+	if v.CanAddr() {
+		x = v.Addr().Interface().(*big.Rat)
+	} else {
+		r := v.Interface().(big.Rat)
+		x = &r
+	}
+
+	if s, ok := ratDecimalString(x); ok {
+		return e.emitNumber(s)
+	}
+
+	// The rational number has no finite decimal representation, the only way
+	// to encode it without losing precision is to use the a/b notation.
+	return e.Emitter.EmitString(x.RatString())
+}
+
+func (e Encoder) emitNumber(s string) error {
+	if len(s) == 0 {
+		s = "0"
+	}
+
+	if !isValidNumber(s) {
+		return fmt.Errorf("objconv: invalid number literal %q", s)
+	}
+
+	if n, ok := e.Emitter.(numberEmitter); ok {
+		return n.EmitNumber(s)
+	}
+
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return e.Emitter.EmitInt(i, 64)
+	}
+
+	if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return e.Emitter.EmitUint(u, 64)
+	}
+
+	if !isIntegerNumber(s) {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return e.Emitter.EmitFloat(f, 64)
+		}
+	}
+
+	// The number cannot be represented by the emitter without overflowing or
+	// losing precision, fallback to using a string.
+	return e.Emitter.EmitString(s)
+}
+
+func (d Decoder) decodeNumber(to reflect.Value) (t Type, err error) {
+	var b []byte
+
+	if t, b, err = d.decodeTypeAndNumber(); err == nil && to.IsValid() {
+		to.SetString(string(b))
+	}
+
+	return
+}
+
+func (d Decoder) decodeNumberFromType(t Type, to reflect.Value) (err error) {
+	var b []byte
+
+	if b, err = d.decodeNumberFrom(t); err == nil && to.IsValid() {
+		to.SetString(string(b))
+	}
+
+	return
+}
+
+func (d Decoder) decodeBigInt(to reflect.Value) (t Type, err error) {
+	var b []byte
+
+	if t, b, err = d.decodeTypeAndNumber(); err != nil || t == Nil || !to.IsValid() {
+		return
+	}
+
+	x := to.Addr().Interface().(*big.Int)
+
+	if _, ok := x.SetString(string(b), 10); !ok {
+		// The number may still be an integer written with an exponent or a
+		// fractional part, like 1e3 or 1.0.
+		if r, ok := new(big.Rat).SetString(string(b)); ok && r.IsInt() {
+			x.Set(r.Num())
+		} else {
+			err = fmt.Errorf("objconv: cannot decode %q into a big.Int", b)
+		}
+	}
+
+	return
+}
+
+func (d Decoder) decodeBigFloat(to reflect.Value) (t Type, err error) {
+	var b []byte
+
+	if t, b, err = d.decodeTypeAndNumber(); err != nil || t == Nil || !to.IsValid() {
+		return
+	}
+
+	x := to.Addr().Interface().(*big.Float)
+
+	// Unless the program already configured the precision of the destination
+	// we pick one that is large enough to represent all the decimal digits.
+	if x.Prec() == 0 {
+		if prec := uint(4 * len(b)); prec > 64 {
+			x.SetPrec(prec)
+		} else {
+			x.SetPrec(64)
+		}
+	}
+
+	if _, ok := x.SetString(string(b)); !ok {
+		err = fmt.Errorf("objconv: cannot decode %q into a big.Float", b)
+	}
+
+	return
+}
+
+func (d Decoder) decodeBigRat(to reflect.Value) (t Type, err error) {
+	var b []byte
+
+	if t, err = d.Parser.ParseType(); err != nil {
+		return
+	}
+
+	switch t {
+	case String:
+		b, err = d.Parser.ParseString()
+	case Bytes:
+		b, err = d.Parser.ParseBytes()
+	default:
+		b, err = d.decodeNumberFrom(t)
+	}
+
+	if err != nil || t == Nil || !to.IsValid() {
+		return
+	}
+
+	x := to.Addr().Interface().(*big.Rat)
+
+	if _, ok := x.SetString(string(b)); !ok {
+		err = fmt.Errorf("objconv: cannot decode %q into a big.Rat", b)
+	}
+
+	return
+}
+
+func (d Decoder) decodeTypeAndNumber() (t Type, b []byte, err error) {
+	if t, err = d.Parser.ParseType(); err == nil {
+		b, err = d.decodeNumberFrom(t)
+	}
+	return
+}
+
+func (d Decoder) decodeNumberFrom(t Type) (b []byte, err error) {
+	switch t {
+	case Nil:
+		err = d.Parser.ParseNil()
+		return
+
+	case Int, Uint, Float:
+		b, err = d.parseNumber(t)
+
+	case String:
+		b, err = d.Parser.ParseString()
+
+	case Bytes:
+		b, err = d.Parser.ParseBytes()
+
+	default:
+		err = fmt.Errorf("objconv: cannot convert from %s to number", t)
+		return
+	}
+
+	if err == nil && !isValidNumber(unsafeString(b)) {
+		err = fmt.Errorf("objconv: invalid number literal %q", b)
+	}
+
+	return
+}
+
+// parseNumber returns the text representation of the number of type t that the
+// parser is positioned on.
+func (d Decoder) parseNumber(t Type) (b []byte, err error) {
+	if p, ok := d.Parser.(numberParser); ok {
+		return p.ParseNumber()
+	}
+
+	switch t {
+	case Int:
+		var i int64
+		if i, err = d.Parser.ParseInt(); err == nil {
+			b = strconv.AppendInt(nil, i, 10)
+		}
+
+	case Uint:
+		var u uint64
+		if u, err = d.Parser.ParseUint(); err == nil {
+			b = strconv.AppendUint(nil, u, 10)
+		}
+
+	default:
+		var f float64
+		if f, err = d.Parser.ParseFloat(); err == nil {
+			b = strconv.AppendFloat(nil, f, 'g', -1, 64)
+		}
+	}
+
+	return
+}
+
+// ratDecimalString returns the decimal representation of x, which exists only
+// if the prime factors of the denominator are 2 and 5.
+func ratDecimalString(x *big.Rat) (string, bool) {
+	if x.IsInt() {
+		return x.Num().String(), true
+	}
+
+	d := new(big.Int).Set(x.Denom())
+	n2 := 0
+	n5 := 0
+
+	for d.Bit(0) == 0 {
+		d.Rsh(d, 1)
+		n2++
+	}
+
+	five := big.NewInt(5)
+	q, r := new(big.Int), new(big.Int)
+
+	for {
+		if q.QuoRem(d, five, r); r.Sign() != 0 {
+			break
+		}
+		d.Set(q)
+		n5++
+	}
+
+	if d.Cmp(big.NewInt(1)) != 0 {
+		return "", false
+	}
+
+	if n5 > n2 {
+		n2 = n5
+	}
+
+	return x.FloatString(n2), true
+}
+
+// isNumberType returns true if t is one of the types that represent numbers of
+// arbitrary precision.
+func isNumberType(t reflect.Type) bool {
+	switch t {
+	case numberType, bigIntPtrType, bigFloatPtrType, bigRatPtrType:
+		return true
+	}
+	return false
+}
+
+// isIntegerNumber returns true if s, which must be a valid number, has no
+// fractional part nor exponent.
+func isIntegerNumber(s string) bool {
+	for i := 0; i != len(s); i++ {
+		switch s[i] {
+		case '.', 'e', 'E':
+			return false
+		}
+	}
+	return true
+}
+
+// isValidNumber returns true if s is a valid number literal, using the same
+// syntax as JSON numbers.
+func isValidNumber(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+
+	// optional sign
+	if s[0] == '-' {
+		if s = s[1:]; len(s) == 0 {
+			return false
+		}
+	}
+
+	// digits
+	switch {
+	case s[0] == '0':
+		s = s[1:]
+
+	case '1' <= s[0] && s[0] <= '9':
+		s = s[1:]
+		for len(s) != 0 && '0' <= s[0] && s[0] <= '9' {
+			s = s[1:]
+		}
+
+	default:
+		return false
+	}
+
+	// . followed by 1 or more digits
+	if len(s) >= 2 && s[0] == '.' && '0' <= s[1] && s[1] <= '9' {
+		s = s[2:]
+		for len(s) != 0 && '0' <= s[0] && s[0] <= '9' {
+			s = s[1:]
+		}
+	}
+
+	// e or E followed by an optional - or + and 1 or more digits
+	if len(s) >= 2 && (s[0] == 'e' || s[0] == 'E') {
+		if s = s[1:]; s[0] == '+' || s[0] == '-' {
+			if s = s[1:]; len(s) == 0 {
+				return false
+			}
+		}
+		for len(s) != 0 && '0' <= s[0] && s[0] <= '9' {
+			s = s[1:]
+		}
+	}
+
+	// make sure we are at the end
+	return len(s) == 0
+}
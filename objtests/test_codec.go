@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"net"
 	"net/mail"
 	"net/url"
@@ -13,6 +14,7 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	"github.com/dolab/objconv"
@@ -132,6 +134,26 @@ var TestValues = [...]interface{}{
 		T time.Time
 		S string
 	}{42, time.Date(2016, 12, 20, 0, 20, 1, 0, time.UTC), "Hello World!"},
+	struct {
+		Embedded
+		*EmbeddedPtr
+		C int
+	}{Embedded{1}, &EmbeddedPtr{2}, 3},
+	struct {
+		ID    int64   `objconv:"id,string"`
+		N     uint8   `objconv:"n,string"`
+		F     float64 `objconv:"f,string"`
+		B     bool    `objconv:"b,string"`
+		Other string  `objconv:"other,string"`
+		P     *int64  `objconv:"p,string"`
+	}{1 << 60, 255, 0.5, true, "hello", new(int64)},
+
+	// numbers
+	objconv.Number("42"),
+	objconv.Number("-1.5"),
+	objconv.Number("12345678901234567890123"),
+	big.NewInt(42),
+	parseBigInt("-123456789012345678901234567890"),
 
 	// net
 	net.TCPAddr{
@@ -163,6 +185,17 @@ var TestValues = [...]interface{}{
 	&point{1, 2},
 }
 
+// Embedded is used to test the support of embedded structs in codecs.
+type Embedded struct {
+	A int
+}
+
+// EmbeddedPtr is used to test the support of embedded pointers to structs in
+// codecs.
+type EmbeddedPtr struct {
+	B int
+}
+
 func makeMap(n int) map[string]string {
 	m := make(map[string]string, n)
 	for i := 0; i != n; i++ {
@@ -184,8 +217,25 @@ func testName(v interface{}) string {
 // tests that the codec behaves properly when used with stream encoders and
 // decoders.
 func TestCodec(t *testing.T, codec objconv.Codec) {
-	t.Run("Values", func(t *testing.T) { testCodecValues(t, codec) })
-	t.Run("Stream", func(t *testing.T) { testCodecStream(t, codec) })
+	TestCodecWithTimePrecision(t, codec, 0)
+}
+
+// TestCodecWithTimePrecision is like TestCodec but for codecs that serialize
+// times with a lower precision, like BSON which only retains milliseconds, the
+// decoded times are expected to be truncated to a multiple of precision.
+func TestCodecWithTimePrecision(t *testing.T, codec objconv.Codec, precision time.Duration) {
+	t.Run("Values", func(t *testing.T) { testCodecValues(t, codec, precision) })
+	t.Run("Discard", func(t *testing.T) { testCodecDiscard(t, codec) })
+	t.Run("Stream", func(t *testing.T) { testCodecStream(t, codec, precision) })
+	t.Run("Strict", func(t *testing.T) { TestDisallowUnknownFields(t, codec) })
+}
+
+// expected returns the value that v is expected to be decoded as.
+func expected(v interface{}, precision time.Duration) interface{} {
+	if t, ok := v.(time.Time); ok && precision > 0 {
+		return t.Truncate(precision)
+	}
+	return v
 }
 
 func newValue(model interface{}) reflect.Value {
@@ -196,7 +246,7 @@ func newValue(model interface{}) reflect.Value {
 	}
 }
 
-func testCodecValues(t *testing.T, codec objconv.Codec) {
+func testCodecValues(t *testing.T, codec objconv.Codec, precision time.Duration) {
 	b := &bytes.Buffer{}
 	b.Grow(1024)
 
@@ -217,7 +267,7 @@ func testCodecValues(t *testing.T, codec objconv.Codec) {
 				return
 			}
 
-			x1 := v1
+			x1 := expected(v1, precision)
 			x2 := v2.Elem().Interface()
 
 			if !reflect.DeepEqual(x1, x2) {
@@ -227,12 +277,51 @@ func testCodecValues(t *testing.T, codec objconv.Codec) {
 	}
 }
 
-func testCodecStream(t *testing.T, codec objconv.Codec) {
-	t.Run("Values", func(t *testing.T) { testCodecStreamValues(t, codec) })
+// testCodecDiscard verifies that discarding a value consumes exactly the bytes
+// of the value, by decoding the value that follows it in an array.
+func testCodecDiscard(t *testing.T, codec objconv.Codec) {
+	b := &bytes.Buffer{}
+	b.Grow(1024)
+
+	for _, v := range TestValues {
+		t.Run(testName(v), func(t *testing.T) {
+			b.Reset()
+			e := objconv.NewEncoder(codec.NewEmitter(b))
+			d := objconv.NewDecoder(codec.NewParser(b))
+
+			if err := e.Encode([]interface{}{v, "next"}); err != nil {
+				t.Error(err)
+				return
+			}
+
+			var next string
+			i := 0
+
+			if err := d.DecodeArray(func(d objconv.Decoder) (err error) {
+				if i++; i == 1 {
+					err = d.Decode(nil)
+				} else {
+					err = d.Decode(&next)
+				}
+				return
+			}); err != nil {
+				t.Error(err)
+				return
+			}
+
+			if next != "next" {
+				t.Errorf("bad value after the discarded one: %#v", next)
+			}
+		})
+	}
+}
+
+func testCodecStream(t *testing.T, codec objconv.Codec, precision time.Duration) {
+	t.Run("Values", func(t *testing.T) { testCodecStreamValues(t, codec, precision) })
 	t.Run("Empty", func(t *testing.T) { testCodecStreamEmpty(t, codec) })
 }
 
-func testCodecStreamValues(t *testing.T, codec objconv.Codec) {
+func testCodecStreamValues(t *testing.T, codec objconv.Codec, precision time.Duration) {
 	r, w := io.Pipe()
 	defer r.Close()
 
@@ -260,7 +349,7 @@ func testCodecStreamValues(t *testing.T, codec objconv.Codec) {
 			return
 		}
 
-		x1 := v1
+		x1 := expected(v1, precision)
 		x2 := v2.Elem().Interface()
 
 		if !reflect.DeepEqual(x1, x2) {
@@ -315,6 +404,8 @@ func (c *counter) Write(b []byte) (n int, err error) {
 func BenchmarkCodec(b *testing.B, codec objconv.Codec) {
 	b.Run("Encoder", func(b *testing.B) { benchmarkEncoder(b, codec) })
 	b.Run("Decoder", func(b *testing.B) { benchmarkDecoder(b, codec) })
+	b.Run("Discard", func(b *testing.B) { benchmarkDiscard(b, codec, false) })
+	b.Run("DiscardNoSkip", func(b *testing.B) { benchmarkDiscard(b, codec, true) })
 	b.Run("StreamEncoder", func(b *testing.B) { benchmarkStreamEncoder(b, codec) })
 	b.Run("StreamDecoder", func(b *testing.B) { benchmarkStreamDecoder(b, codec) })
 }
@@ -361,6 +452,41 @@ func benchmarkDecoder(b *testing.B, codec objconv.Codec) {
 	}
 }
 
+// benchmarkDiscard measures the time it takes to discard values, when noSkip is
+// true the parser is wrapped to hide the optional Skip method it may have, so
+// the values are parsed and thrown away.
+func benchmarkDiscard(b *testing.B, codec objconv.Codec, noSkip bool) {
+	a := &bytes.Buffer{}
+	a.Grow(1024)
+
+	for _, v := range TestValues {
+		e := objconv.NewEncoder(codec.NewEmitter(a))
+		e.Encode(v)
+
+		s := a.Bytes()
+		r := bytes.NewReader(s)
+
+		b.Run(testName(v), func(b *testing.B) {
+			p := codec.NewParser(r)
+
+			if noSkip {
+				p = struct{ objconv.Parser }{p}
+			}
+
+			d := objconv.NewDecoder(p)
+
+			for i := 0; i != b.N; i++ {
+				d.Decode(nil)
+				r.Reset(s)
+			}
+
+			b.SetBytes(int64(len(s)))
+		})
+
+		a.Reset()
+	}
+}
+
 func benchmarkStreamEncoder(b *testing.B, codec objconv.Codec) {
 	for _, v := range TestValues {
 		b.Run(testName(v), func(b *testing.B) {
@@ -405,6 +531,11 @@ func benchmarkStreamDecoder(b *testing.B, codec objconv.Codec) {
 	}
 }
 
+func parseBigInt(s string) *big.Int {
+	x, _ := new(big.Int).SetString(s, 10)
+	return x
+}
+
 func parseURL(s string) url.URL {
 	u, _ := url.Parse(s)
 	return *u
@@ -455,3 +586,141 @@ func (p *point) UnmarshalText(b []byte) error {
 	fmt.Sscanf(string(b), "(%d,%d)", &p.x, &p.y)
 	return nil
 }
+
+// TestDisallowUnknownFields implements a test suite for validating that
+// decoders of a codec report the map keys that don't match any field of the
+// destination struct when DisallowUnknownFields is set, and ignore them
+// otherwise.
+func TestDisallowUnknownFields(t *testing.T, codec objconv.Codec) {
+	type server struct {
+		Host    string `objconv:"host"`
+		Timeout int    `objconv:"timeout"`
+	}
+
+	type config struct {
+		Servers []server `objconv:"servers"`
+	}
+
+	b := &bytes.Buffer{}
+
+	if err := objconv.NewEncoder(codec.NewEmitter(b)).Encode(map[string]interface{}{
+		"servers": []interface{}{
+			map[string]interface{}{"host": "localhost", "timeout": 1},
+			map[string]interface{}{"host": "localhost", "timout": 1},
+		},
+	}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	t.Run("allowed", func(t *testing.T) {
+		var c config
+
+		if err := objconv.NewDecoder(codec.NewParser(bytes.NewReader(b.Bytes()))).Decode(&c); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if !reflect.DeepEqual(c.Servers, []server{{"localhost", 1}, {"localhost", 0}}) {
+			t.Errorf("bad value: %#v", c)
+		}
+	})
+
+	t.Run("disallowed", func(t *testing.T) {
+		var c config
+		var e *objconv.UnknownFieldError
+
+		d := objconv.NewDecoder(codec.NewParser(bytes.NewReader(b.Bytes())))
+		d.DisallowUnknownFields = true
+
+		if err := d.Decode(&c); !errors.As(err, &e) {
+			t.Errorf("expected *objconv.UnknownFieldError but got %#v", err)
+			return
+		}
+
+		if e.Field != "timout" {
+			t.Error("bad field:", e.Field)
+		}
+
+		if e.Path != ".servers[1]" {
+			t.Error("bad path:", e.Path)
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		var c config
+		var e *objconv.UnknownFieldError
+
+		d := objconv.NewStreamDecoder(codec.NewParser(bytes.NewReader(b.Bytes())))
+		d.DisallowUnknownFields = true
+
+		if err := d.Decode(&c); !errors.As(err, &e) {
+			t.Errorf("expected *objconv.UnknownFieldError but got %#v", err)
+		}
+	})
+}
+
+// TestRawValue implements a test suite for validating that a codec captures
+// the exact bytes of values decoded into objconv.RawValue, and writes them
+// back verbatim when encoding.
+func TestRawValue(t *testing.T, codec objconv.Codec) {
+	values := []interface{}{
+		nil,
+		true,
+		42,
+		"Hello World!",
+		strings.Repeat("A", 1000),
+		[]interface{}{1, "2", []interface{}{3}, nil},
+		[]string{strings.Repeat("B", 300), strings.Repeat("C", 300)},
+	}
+
+	b1 := &bytes.Buffer{}
+	b2 := &bytes.Buffer{}
+
+	if err := objconv.NewEncoder(codec.NewEmitter(b1)).Encode(values); err != nil {
+		t.Error(err)
+		return
+	}
+
+	var raw []objconv.RawValue
+
+	// Reading one byte at a time ensures that the recording works across the
+	// refills of the parser's buffer.
+	if err := objconv.NewDecoder(codec.NewParser(iotest.OneByteReader(bytes.NewReader(b1.Bytes())))).Decode(&raw); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(raw) != len(values) {
+		t.Errorf("bad number of raw values: %d != %d", len(raw), len(values))
+		return
+	}
+
+	for i, r := range raw {
+		b2.Reset()
+
+		if err := objconv.NewEncoder(codec.NewEmitter(b2)).Encode(values[i]); err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if !bytes.Equal(r.Bytes, b2.Bytes()) {
+			t.Errorf("bad raw value at index %d: %q != %q", i, r.Bytes, b2.Bytes())
+		}
+
+		if _, ok := objconv.Lookup(r.Format); !ok {
+			t.Errorf("bad raw value format at index %d: %q", i, r.Format)
+		}
+	}
+
+	b2.Reset()
+
+	if err := objconv.NewEncoder(codec.NewEmitter(b2)).Encode(raw); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !bytes.Equal(b1.Bytes(), b2.Bytes()) {
+		t.Errorf("re-encoding raw values produced different bytes: %q != %q", b2.Bytes(), b1.Bytes())
+	}
+}
@@ -1,6 +1,9 @@
 package objutil
 
-import "strings"
+import (
+	"strconv"
+	"strings"
+)
 
 // Tag represents the result of parsing the tag of a struct field.
 type Tag struct {
@@ -12,6 +15,14 @@ type Tag struct {
 
 	// Omitzero is true if the tag had `omitzero` set.
 	Omitzero bool
+
+	// AsString is true if the tag had `string` set, it indicates that boolean
+	// and numeric values should be serialized as strings.
+	AsString bool
+
+	// Proto is the field number set with the `proto=N` option, it is used by
+	// formats that identify fields by number like Protocol Buffers.
+	Proto int
 }
 
 // ParseTag parses a raw tag obtained from a struct field, returning the results
@@ -20,6 +31,8 @@ func ParseTag(s string) Tag {
 	var name string
 	var omitzero bool
 	var omitempty bool
+	var asString bool
+	var proto int
 
 	name, s = parseNextTagToken(s)
 
@@ -30,6 +43,14 @@ func ParseTag(s string) Tag {
 			omitempty = true
 		case "omitzero":
 			omitzero = true
+		case "string":
+			asString = true
+		default:
+			if strings.HasPrefix(token, "proto=") {
+				if n, err := strconv.Atoi(token[6:]); err == nil && n > 0 {
+					proto = n
+				}
+			}
 		}
 	}
 
@@ -37,6 +58,8 @@ func ParseTag(s string) Tag {
 		Name:      name,
 		Omitempty: omitempty,
 		Omitzero:  omitzero,
+		AsString:  asString,
+		Proto:     proto,
 	}
 }
 
@@ -45,6 +68,7 @@ func ParseTag(s string) Tag {
 func ParseTagJSON(s string) Tag {
 	var name string
 	var omitempty bool
+	var asString bool
 
 	name, s = parseNextTagToken(s)
 
@@ -53,12 +77,15 @@ func ParseTagJSON(s string) Tag {
 		switch token, s = parseNextTagToken(s); token {
 		case "omitempty":
 			omitempty = true
+		case "string":
+			asString = true
 		}
 	}
 
 	return Tag{
 		Name:      name,
 		Omitempty: omitempty,
+		AsString:  asString,
 	}
 }
 
@@ -39,6 +39,26 @@ func TestParseTag(t *testing.T) {
 			tag: "-,omitempty,omitzero",
 			res: Tag{Name: "-", Omitempty: true, Omitzero: true},
 		},
+		{
+			tag: "id,string",
+			res: Tag{Name: "id", AsString: true},
+		},
+		{
+			tag: ",omitzero,string",
+			res: Tag{Omitzero: true, AsString: true},
+		},
+		{
+			tag: "name,proto=3",
+			res: Tag{Name: "name", Proto: 3},
+		},
+		{
+			tag: "name,omitempty,proto=12",
+			res: Tag{Name: "name", Omitempty: true, Proto: 12},
+		},
+		{
+			tag: "name,proto=-1",
+			res: Tag{Name: "name"},
+		},
 	}
 
 	for _, test := range tests {
@@ -79,11 +99,19 @@ func TestParseTagJSON(t *testing.T) {
 			tag: "-,omitempty",
 			res: Tag{Name: "-", Omitempty: true},
 		},
+		{
+			tag: "id,string",
+			res: Tag{Name: "id", AsString: true},
+		},
+		{
+			tag: "id,omitzero,string",
+			res: Tag{Name: "id", AsString: true},
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.tag, func(t *testing.T) {
-			if res := ParseTag(test.tag); res != test.res {
+			if res := ParseTagJSON(test.tag); res != test.res {
 				t.Errorf("%s: %#v != %#v", test.tag, test.res, res)
 			}
 		})
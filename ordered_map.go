@@ -0,0 +1,205 @@
+package objconv
+
+import (
+	"reflect"
+)
+
+// OrderedMap is a map which remembers the order in which keys were inserted,
+// and encodes its entries in that order.
+//
+// Setting the MapType field of a decoder to reflect.TypeOf(OrderedMap{}) makes
+// it produce OrderedMap values when decoding maps into empty interfaces, so
+// documents keep the order of their keys when they are decoded and encoded
+// again.
+//
+// Comparable keys are looked up in an index, keys that are not comparable, like
+// byte slices, are compared with reflect.DeepEqual which requires a linear
+// scan of the entries.
+//
+// Like slices, copies of an OrderedMap share their entries, a map must not be
+// modified through one of its copies once another copy was modified.
+//
+// The zero-value is an empty map ready to use.
+type OrderedMap struct {
+	entries []orderedMapEntry
+	index   map[interface{}]int // positions of the comparable keys in entries
+	deleted int                 // number of deleted entries
+}
+
+// OrderedMapItem is a key and value pair of an OrderedMap.
+type OrderedMapItem struct {
+	Key   interface{}
+	Value interface{}
+}
+
+type orderedMapEntry struct {
+	OrderedMapItem
+	deleted bool
+}
+
+// Len returns the number of entries in m.
+func (m OrderedMap) Len() int {
+	return len(m.entries) - m.deleted
+}
+
+// Get returns the value associated with key in m, and a boolean indicating
+// whether the key was found.
+func (m OrderedMap) Get(key interface{}) (value interface{}, ok bool) {
+	if i := m.lookup(key); i >= 0 {
+		value, ok = m.entries[i].Value, true
+	}
+	return
+}
+
+// Set associates value with key in m. The key keeps its position if it was
+// already in the map, otherwise it is added after all the other keys.
+func (m *OrderedMap) Set(key interface{}, value interface{}) {
+	if i := m.lookup(key); i >= 0 {
+		m.entries[i].Value = value
+		return
+	}
+
+	if isComparableKey(key) {
+		if m.index == nil {
+			m.index = make(map[interface{}]int)
+		}
+		m.index[key] = len(m.entries)
+	}
+
+	m.entries = append(m.entries, orderedMapEntry{
+		OrderedMapItem: OrderedMapItem{Key: key, Value: value},
+	})
+}
+
+// Delete removes key from m, the other keys keep their order.
+func (m *OrderedMap) Delete(key interface{}) {
+	i := m.lookup(key)
+	if i < 0 {
+		return
+	}
+
+	if isComparableKey(key) {
+		delete(m.index, key)
+	}
+
+	// The entry is only marked as deleted so the positions of the following
+	// entries don't have to be updated, they are compacted when more than
+	// half of the entries were deleted.
+	m.entries[i] = orderedMapEntry{deleted: true}
+	m.deleted++
+
+	if m.deleted > len(m.entries)/2 {
+		m.compact()
+	}
+}
+
+// Keys returns the keys of m in their order.
+func (m OrderedMap) Keys() []interface{} {
+	keys := make([]interface{}, 0, m.Len())
+
+	for _, e := range m.entries {
+		if !e.deleted {
+			keys = append(keys, e.Key)
+		}
+	}
+
+	return keys
+}
+
+// Items returns the entries of m in their order.
+func (m OrderedMap) Items() []OrderedMapItem {
+	var items []OrderedMapItem
+
+	if n := m.Len(); n != 0 {
+		items = make([]OrderedMapItem, 0, n)
+	}
+
+	for _, e := range m.entries {
+		if !e.deleted {
+			items = append(items, e.OrderedMapItem)
+		}
+	}
+
+	return items
+}
+
+// Range calls f for each entry of m in their order, until f returns false.
+func (m OrderedMap) Range(f func(key interface{}, value interface{}) bool) {
+	for _, e := range m.entries {
+		if !e.deleted && !f(e.Key, e.Value) {
+			break
+		}
+	}
+}
+
+// EncodeValue satisfies the ValueEncoder interface.
+func (m OrderedMap) EncodeValue(e Encoder) error {
+	i := 0
+	return e.EncodeMap(m.Len(), func(k Encoder, v Encoder) (err error) {
+		for m.entries[i].deleted {
+			i++
+		}
+		if err = k.Encode(m.entries[i].Key); err != nil {
+			return
+		}
+		if err = v.Encode(m.entries[i].Value); err != nil {
+			return
+		}
+		i++
+		return
+	})
+}
+
+// DecodeValue satisfies the ValueDecoder interface, the entries are added to
+// the map in the order they are decoded.
+func (m *OrderedMap) DecodeValue(d Decoder) error {
+	return d.DecodeMap(func(k Decoder, v Decoder) (err error) {
+		var item OrderedMapItem
+		if err = k.Decode(&item.Key); err != nil {
+			return
+		}
+		if err = v.Decode(&item.Value); err != nil {
+			return
+		}
+		m.Set(item.Key, item.Value)
+		return
+	})
+}
+
+// lookup returns the position of key in the entries of m, or -1 if the key is
+// not in the map.
+func (m OrderedMap) lookup(key interface{}) int {
+	if !isComparableKey(key) {
+		for i, e := range m.entries {
+			if !e.deleted && reflect.DeepEqual(e.Key, key) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	if i, ok := m.index[key]; ok {
+		return i
+	}
+	return -1
+}
+
+func (m *OrderedMap) compact() {
+	entries := make([]orderedMapEntry, 0, len(m.entries)-m.deleted)
+	index := make(map[interface{}]int, len(m.index))
+
+	for _, e := range m.entries {
+		if !e.deleted {
+			if isComparableKey(e.Key) {
+				index[e.Key] = len(entries)
+			}
+			entries = append(entries, e)
+		}
+	}
+
+	m.entries, m.index, m.deleted = entries, index, 0
+}
+
+func isComparableKey(key interface{}) bool {
+	return key == nil || reflect.TypeOf(key).Comparable()
+}
@@ -0,0 +1,153 @@
+package objconv
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOrderedMap(t *testing.T) {
+	var m OrderedMap
+
+	m.Set("c", 1)
+	m.Set("a", 2)
+	m.Set([]byte("b"), 3)
+	m.Set("a", 4)
+
+	if n := m.Len(); n != 3 {
+		t.Error("bad length:", n)
+	}
+
+	if v, ok := m.Get("a"); !ok || v != 4 {
+		t.Errorf("bad value: %#v (%t)", v, ok)
+	}
+
+	if v, ok := m.Get([]byte("b")); !ok || v != 3 {
+		t.Errorf("bad value: %#v (%t)", v, ok)
+	}
+
+	if _, ok := m.Get("b"); ok {
+		t.Error("unexpected key found")
+	}
+
+	if keys := m.Keys(); !reflect.DeepEqual(keys, []interface{}{"c", "a", []byte("b")}) {
+		t.Errorf("bad keys: %#v", keys)
+	}
+
+	m.Delete("c")
+	m.Delete("nope")
+
+	var items []OrderedMapItem
+
+	m.Range(func(k interface{}, v interface{}) bool {
+		items = append(items, OrderedMapItem{Key: k, Value: v})
+		return true
+	})
+
+	if !reflect.DeepEqual(items, []OrderedMapItem{{"a", 4}, {[]byte("b"), 3}}) {
+		t.Errorf("bad items: %#v", items)
+	}
+
+	if !reflect.DeepEqual(items, m.Items()) {
+		t.Errorf("bad items: %#v", m.Items())
+	}
+}
+
+func TestOrderedMapType(t *testing.T) {
+	var v interface{}
+
+	d := NewDecoder(NewValueParser(map[string]interface{}{
+		"a": map[string]int{"b": 1},
+	}))
+	d.MapType = reflect.TypeOf(OrderedMap{})
+
+	if err := d.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	m, ok := v.(OrderedMap)
+	if !ok {
+		t.Fatalf("bad value: %#v", v)
+	}
+
+	a, _ := m.Get("a")
+
+	if b, _ := a.(OrderedMap).Get("b"); b != int64(1) {
+		t.Errorf("bad nested value: %#v", a)
+	}
+
+	e := NewValueEmitter()
+
+	if err := NewEncoder(e).Encode(m); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(e.Value(), map[interface{}]interface{}{
+		"a": map[interface{}]interface{}{"b": int64(1)},
+	}) {
+		t.Errorf("bad encoded value: %#v", e.Value())
+	}
+}
+
+func TestOrderedMapDelete(t *testing.T) {
+	var m OrderedMap
+
+	for i := 0; i != 10; i++ {
+		m.Set(i, i*i)
+	}
+
+	// Deleting more than half of the entries compacts the map, the remaining
+	// entries must still be found at their new positions.
+	for i := 0; i != 10; i += 2 {
+		m.Delete(i)
+	}
+	m.Delete(1)
+
+	if n := m.Len(); n != 4 {
+		t.Error("bad length:", n)
+	}
+
+	if keys := m.Keys(); !reflect.DeepEqual(keys, []interface{}{3, 5, 7, 9}) {
+		t.Errorf("bad keys: %#v", keys)
+	}
+
+	for _, k := range []int{3, 5, 7, 9} {
+		if v, ok := m.Get(k); !ok || v != k*k {
+			t.Errorf("bad value for %d: %#v (%t)", k, v, ok)
+		}
+	}
+
+	m.Set(1, 0)
+	m.Set(3, 0)
+
+	if !reflect.DeepEqual(m.Items(), []OrderedMapItem{{3, 0}, {5, 25}, {7, 49}, {9, 81}, {1, 0}}) {
+		t.Errorf("bad items: %#v", m.Items())
+	}
+
+	e := NewValueEmitter()
+
+	if err := NewEncoder(e).Encode(m); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(e.Value(), map[interface{}]interface{}{
+		int64(3): int64(0), int64(5): int64(25), int64(7): int64(49), int64(9): int64(81), int64(1): int64(0),
+	}) {
+		t.Errorf("bad encoded value: %#v", e.Value())
+	}
+}
+
+func BenchmarkOrderedMapDecode(b *testing.B) {
+	m := make(map[int]int, 10000)
+
+	for i := 0; i != 10000; i++ {
+		m[i] = i
+	}
+
+	for i := 0; i != b.N; i++ {
+		var v OrderedMap
+
+		if err := NewDecoder(NewValueParser(m)).Decode(&v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
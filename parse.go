@@ -2,6 +2,7 @@ package objconv
 
 import (
 	"io"
+	"reflect"
 	"time"
 )
 
@@ -137,6 +138,66 @@ type textParser interface {
 	TextParser() bool
 }
 
+// The offsetParser interface may be implemented by parsers reading from a byte
+// stream to report where in the input an error occurred.
+type offsetParser interface {
+	// Offset returns the number of bytes consumed from the input.
+	Offset() int64
+}
+
+// The positionParser interface may be implemented by parsers of text formats
+// to report the line and column where an error occurred.
+type positionParser interface {
+	// Position returns the line and column (both starting at 1) of the next
+	// byte to be read from the input.
+	Position() (line int, column int)
+}
+
+// The fieldParser interface may be implemented by parsers of formats where the
+// fields of structs are identified by numbers instead of names, like Protocol
+// Buffers.
+type fieldParser interface {
+	// ParseField is called instead of parsing a map key when decoding a struct
+	// and returns the number of the field, as set by the `proto` tag option.
+	ParseField() (int, error)
+}
+
+// The hintParser interface may be implemented by parsers of formats that don't
+// carry enough information to tell the types of the values apart, like
+// Protocol Buffers where integers and booleans share the same encoding.
+type hintParser interface {
+	// HintType is called before decoding a value with the type of the
+	// destination, or nil if the value is discarded. The parser may use it to
+	// pick the type that it returns from the next call to ParseType.
+	HintType(reflect.Type)
+}
+
+// The dynamicParser interface may be implemented by parsers of formats that
+// carry values which objconv types cannot represent, like CBOR tags.
+type dynamicParser interface {
+	// DynamicType is called after ParseType when the value is decoded into an
+	// empty interface, it returns the Go type that the value must be decoded
+	// into, or nil to use the default type for the objconv type.
+	DynamicType() reflect.Type
+}
+
+// The scopedParser interface may be implemented by parsers of formats that
+// have their own encoding for some types, installed with InstallScoped.
+type scopedParser interface {
+	// AdapterScope returns the scope of the adapters used by the parser.
+	AdapterScope() string
+}
+
+// The skipParser interface may be implemented by parsers that can jump over
+// values without loading them, which is faster than parsing the values that a
+// decoder discards.
+type skipParser interface {
+	// Skip is called after ParseType when the value is discarded, instead of
+	// the method that parses a value of the type. The parser must consume the
+	// whole value, including the elements of arrays and maps.
+	Skip() error
+}
+
 func isTextParser(parser Parser) bool {
 	p, _ := parser.(textParser)
 	return p != nil && p.TextParser()
@@ -0,0 +1,203 @@
+package objconv
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PathNotFoundError is the underlying error of the *DecodeError returned by
+// DecodePath when the document has no value at the requested path.
+type PathNotFoundError struct {
+	Path string // the path passed to DecodePath
+}
+
+// Error satisfies the error interface.
+func (e *PathNotFoundError) Error() string {
+	return fmt.Sprintf("objconv: no value found at path %q", e.Path)
+}
+
+// DecodePath decodes into v the value found at path in the next value parsed
+// by the decoder, the rest of the document is skipped without being loaded in
+// memory.
+//
+// The path is either a JSON Pointer (RFC 6901), like "/items/3/price", or a
+// sequence of map keys and array indexes, like "items[3].price" or
+// "items.3.price". Map keys which are not strings are matched by their text
+// representation.
+//
+// Like in RFC 6901, the empty path "" designates the whole document, which is
+// then decoded into v the same way that Decode does. Note that "/" is not the
+// empty path, it designates the value of the empty key in a map.
+//
+// Like the other decoding errors, the error returned when no value exists at
+// path is a *DecodeError, its Err field holds a *PathNotFoundError. The whole
+// document is still consumed from the parser in that case.
+func (d Decoder) DecodePath(path string, v interface{}) error {
+	d.check()
+
+	keys, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+
+	found, err := d.decodePath(keys, v)
+
+	if err == nil && !found {
+		err = d.wrapError(&PathNotFoundError{Path: path}, "", Unknown, nil)
+	}
+
+	return err
+}
+
+func (d Decoder) decodePath(keys []string, v interface{}) (found bool, err error) {
+	if len(keys) == 0 {
+		return true, d.Decode(v)
+	}
+
+	var typ Type
+
+	if d.off != 0 {
+		if d.off, err = 0, d.Parser.ParseMapValue(d.off-1); err != nil {
+			return
+		}
+	}
+
+	if typ, err = d.Parser.ParseType(); err != nil {
+		return
+	}
+
+	key, next := keys[0], keys[1:]
+
+	switch typ {
+	case Map:
+		err = d.decodeMapImpl(typ, func(kd Decoder, vd Decoder) (err error) {
+			var match bool
+
+			if match, err = kd.matchPathKey(key); err != nil {
+				return
+			}
+
+			if !found && match {
+				if found, err = vd.decodePath(next, v); err != nil {
+					err = vd.wrapError(err, fieldPath(key), Unknown, nil)
+				}
+				return
+			}
+
+			return vd.Decode(nil)
+		})
+
+	case Array:
+		i := 0
+		err = d.decodeArrayImpl(typ, func(ed Decoder) (err error) {
+			if !found && strconv.Itoa(i) == key {
+				if found, err = ed.decodePath(next, v); err != nil {
+					err = ed.wrapError(err, indexPath(i), Unknown, nil)
+				}
+			} else {
+				err = ed.Decode(nil)
+			}
+			i++
+			return
+		})
+
+	default:
+		err = d.skipFromType(typ)
+	}
+
+	return
+}
+
+// matchPathKey parses the next map key and compares it to the key of a path,
+// keys which are not strings are compared by their text representation.
+func (d Decoder) matchPathKey(key string) (match bool, err error) {
+	var a [64]byte
+	var b []byte
+	var t Type
+
+	if t, err = d.Parser.ParseType(); err != nil {
+		return
+	}
+
+	switch t {
+	case Nil, Array, Map:
+		// Keys that have no text representation can't be matched.
+		return false, d.skipFromType(t)
+	}
+
+	if b, err = d.parseStringFromType(t, a[:0]); err == nil {
+		match = string(b) == key
+	}
+	return
+}
+
+// parsePath splits path into the sequence of map keys and array indexes that
+// it is made of.
+func parsePath(path string) (keys []string, err error) {
+	if len(path) == 0 {
+		return
+	}
+
+	if path[0] == '/' {
+		return parseJSONPointer(path)
+	}
+
+	s := path
+
+	if s[0] != '.' && s[0] != '[' {
+		s = "." + s
+	}
+
+	for len(s) != 0 {
+		var key string
+
+		switch s[0] {
+		case '.':
+			i := strings.IndexAny(s[1:], ".[")
+			if i < 0 {
+				i = len(s) - 1
+			}
+			key, s = s[1:i+1], s[i+1:]
+
+		case '[':
+			i := strings.IndexByte(s, ']')
+			if i < 0 {
+				return nil, fmt.Errorf("objconv: invalid path %q: missing closing bracket", path)
+			}
+			key, s = s[1:i], s[i+1:]
+
+		default:
+			return nil, fmt.Errorf("objconv: invalid path %q: expected '.' or '[' before %q", path, s)
+		}
+
+		if len(key) == 0 {
+			return nil, fmt.Errorf("objconv: invalid path %q: empty key", path)
+		}
+
+		keys = append(keys, key)
+	}
+
+	return
+}
+
+// parseJSONPointer splits the JSON pointer path into its reference tokens,
+// unescaping the "~0" and "~1" sequences.
+func parseJSONPointer(path string) (keys []string, err error) {
+	keys = strings.Split(path[1:], "/")
+
+	for i, key := range keys {
+		for j := 0; j != len(key); j++ {
+			if key[j] == '~' {
+				if j++; j == len(key) || (key[j] != '0' && key[j] != '1') {
+					return nil, fmt.Errorf("objconv: invalid JSON pointer %q: bad escape sequence in %q", path, key)
+				}
+			}
+		}
+		keys[i] = jsonPointerUnescaper.Replace(key)
+	}
+
+	return
+}
+
+var jsonPointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
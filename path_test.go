@@ -0,0 +1,135 @@
+package objconv
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		path string
+		keys []string
+	}{
+		{"", nil},
+		{"/", []string{""}},
+		{"/a/0/b", []string{"a", "0", "b"}},
+		{"/a~1b/m~0n", []string{"a/b", "m~n"}},
+		{"a", []string{"a"}},
+		{".a.b", []string{"a", "b"}},
+		{"items[3].price", []string{"items", "3", "price"}},
+		{"[0][1]", []string{"0", "1"}},
+		{"items.3.price", []string{"items", "3", "price"}},
+	}
+
+	for _, test := range tests {
+		keys, err := parsePath(test.path)
+		if err != nil {
+			t.Errorf("%q: %s", test.path, err)
+			continue
+		}
+		if !reflect.DeepEqual(keys, test.keys) {
+			t.Errorf("%q: bad keys: %#v", test.path, keys)
+		}
+	}
+
+	for _, path := range []string{"a..b", "a[0", "a[]", "a[0]b", "/a~2", "/a~"} {
+		if _, err := parsePath(path); err == nil {
+			t.Errorf("%q: expected an error", path)
+		}
+	}
+}
+
+func TestDecodePath(t *testing.T) {
+	doc := map[string]interface{}{
+		"name": "objconv",
+		"items": []interface{}{
+			map[string]interface{}{"price": 1.5},
+			map[string]interface{}{"price": 2.5, "tags": []string{"a", "b"}},
+		},
+		"meta": map[int]string{42: "answer"},
+		"a/b":  true,
+	}
+
+	tests := []struct {
+		path  string
+		value interface{}
+	}{
+		{"name", "objconv"},
+		{"/items/1/price", 2.5},
+		{"items[0].price", 1.5},
+		{"items[1].tags[1]", "b"},
+		{"meta[42]", "answer"},
+		{"/a~1b", true},
+	}
+
+	for _, test := range tests {
+		v := reflect.New(reflect.TypeOf(test.value))
+
+		if err := NewDecoder(NewValueParser(doc)).DecodePath(test.path, v.Interface()); err != nil {
+			t.Errorf("%q: %s", test.path, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(v.Elem().Interface(), test.value) {
+			t.Errorf("%q: bad value: %#v", test.path, v.Elem().Interface())
+		}
+	}
+
+	for _, path := range []string{"nope", "items[2]", "name.length", "/items/01", "items[1].tags[-1]"} {
+		var v interface{}
+
+		err := NewDecoder(NewValueParser(doc)).DecodePath(path, &v)
+
+		if _, ok := err.(*DecodeError); !ok {
+			t.Errorf("%q: expected a *DecodeError but got %#v", path, err)
+		}
+
+		var e *PathNotFoundError
+
+		if !errors.As(err, &e) || e.Path != path {
+			t.Errorf("%q: bad error: %v", path, err)
+		}
+	}
+}
+
+func TestDecodePathWholeDocument(t *testing.T) {
+	doc := map[string]interface{}{
+		"a": map[string]interface{}{"b": []interface{}{10, 20, 30}},
+	}
+
+	var v map[string]map[string][]int
+
+	if err := NewDecoder(NewValueParser(doc)).DecodePath("", &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(v, map[string]map[string][]int{"a": {"b": {10, 20, 30}}}) {
+		t.Errorf("bad value: %#v", v)
+	}
+
+	// The whole document is decoded like Decode does, so decoding it into a
+	// type that it cannot be converted to fails.
+	var n int
+
+	if err := NewDecoder(NewValueParser(doc)).DecodePath("", &n); err == nil {
+		t.Error("expected an error when decoding a map into an int")
+	}
+}
+
+func TestDecodePathError(t *testing.T) {
+	doc := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"price": 1.5},
+			map[string]interface{}{"price": "free"},
+		},
+	}
+
+	var v float64
+
+	err := NewDecoder(NewValueParser(doc)).DecodePath("items[1].price", &v)
+
+	if e, ok := err.(*DecodeError); !ok || e.Path != ".items[1].price" || e.WireType != String {
+		t.Errorf("bad error: %#v", err)
+	}
+}
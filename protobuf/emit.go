@@ -0,0 +1,395 @@
+package protobuf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Emitter implements a Protocol Buffers emitter that satisfies the
+// objconv.Emitter interface.
+//
+// The emitter can only encode messages at the top level, which are either
+// structs with numbered fields or maps of field numbers to values. Messages
+// are buffered in memory until they're complete because their length has to be
+// written before their content.
+type Emitter struct {
+	w io.Writer
+
+	// The stack of messages, maps and arrays being encoded, the first element
+	// is the top-level message.
+	stack []context
+
+	// sback is used as the initial backing array for the stack slice to avoid
+	// dynamic memory allocations for the most common use cases.
+	sback [8]context
+}
+
+type context struct {
+	kind  int    // what the context is encoding
+	num   int    // field number of the context in its parent
+	field int    // field number of the next value written to a message
+	wire  int    // wire type of the packed elements of an array
+	key   bool   // set when the next value is a map key
+	open  bool   // set when the current map entry wasn't flushed yet
+	b     []byte // message, map entry or repeated elements being encoded
+	out   []byte // encoded map entries or packed elements of an array
+}
+
+const (
+	message = iota // struct fields or top-level map of field numbers
+	object         // nested map of unknown kind, which becomes a message or entries
+	entries        // map entries, each is a message with the key and value
+	array          // repeated field
+)
+
+var errTopLevel = errors.New("objconv/protobuf: only messages can be encoded at the top level")
+
+func NewEmitter(w io.Writer) *Emitter {
+	e := &Emitter{w: w}
+	e.stack = e.sback[:0]
+	return e
+}
+
+func (e *Emitter) Reset(w io.Writer) {
+	e.w = w
+	e.stack = e.stack[:0]
+}
+
+func (e *Emitter) EmitNil() error {
+	c := e.top()
+
+	if c == nil {
+		return errTopLevel
+	}
+
+	if c.key {
+		return errors.New("objconv/protobuf: map keys cannot be nil")
+	}
+
+	// Protocol Buffers have no representation for null values, fields that
+	// are not set are simply omitted.
+	return nil
+}
+
+func (e *Emitter) EmitBool(v bool) error {
+	var u uint64
+	if v {
+		u = 1
+	}
+	return e.emit(Varint, u)
+}
+
+func (e *Emitter) EmitInt(v int64, _ int) error {
+	if c := e.top(); c != nil && c.kind == message && c.key {
+		return e.emitField(c, "", v)
+	}
+	return e.emit(Varint, uint64(v))
+}
+
+func (e *Emitter) EmitUint(v uint64, _ int) error {
+	if c := e.top(); c != nil && c.kind == message && c.key {
+		if v > MaxField {
+			return fmt.Errorf("objconv/protobuf: invalid field number %d", v)
+		}
+		return e.emitField(c, "", int64(v))
+	}
+	return e.emit(Varint, v)
+}
+
+func (e *Emitter) EmitFloat(v float64, bitSize int) error {
+	if bitSize == 32 {
+		return e.emit(Fixed32, uint64(math.Float32bits(float32(v))))
+	}
+	return e.emit(Fixed64, math.Float64bits(v))
+}
+
+func (e *Emitter) EmitString(v string) error {
+	c, num, err := e.next()
+	if err == nil {
+		c.b = appendKey(c.b, num, LengthDelimited)
+		c.b = appendVarint(c.b, uint64(len(v)))
+		c.b = append(c.b, v...)
+	}
+	return err
+}
+
+func (e *Emitter) EmitBytes(v []byte) error {
+	c, num, err := e.next()
+	if err == nil {
+		c.b = appendLengthDelimited(c.b, num, v)
+	}
+	return err
+}
+
+func (e *Emitter) EmitTime(v time.Time) error {
+	return e.emitTimestamp(v.Unix(), int64(v.Nanosecond()))
+}
+
+func (e *Emitter) EmitDuration(v time.Duration) error {
+	return e.emitTimestamp(int64(v/time.Second), int64(v%time.Second))
+}
+
+func (e *Emitter) EmitError(v error) error {
+	return e.EmitString(v.Error())
+}
+
+func (e *Emitter) EmitArrayBegin(_ int) error {
+	c, num, err := e.next()
+
+	if err != nil {
+		return err
+	}
+
+	if c.kind == array {
+		return errors.New("objconv/protobuf: nested arrays are not supported")
+	}
+
+	e.push(array, num)
+	return nil
+}
+
+func (e *Emitter) EmitArrayEnd() error {
+	c := e.pop()
+	p := e.top()
+
+	if len(c.out) != 0 {
+		p.b = appendLengthDelimited(p.b, c.num, c.out)
+	}
+
+	p.b = append(p.b, c.b...)
+	return nil
+}
+
+func (e *Emitter) EmitArrayNext() error {
+	return nil
+}
+
+func (e *Emitter) EmitMapBegin(_ int) error {
+	if len(e.stack) == 0 {
+		e.push(message, 0)
+		return nil
+	}
+
+	_, num, err := e.next()
+
+	if err == nil {
+		e.push(object, num)
+	}
+
+	return err
+}
+
+func (e *Emitter) EmitMapEnd() (err error) {
+	c := e.pop()
+	p := e.top()
+
+	if p == nil {
+		_, err = e.w.Write(c.b)
+		return
+	}
+
+	switch c.kind {
+	case message:
+		p.b = appendLengthDelimited(p.b, c.num, c.b)
+
+	case entries:
+		if c.open {
+			c.out = appendLengthDelimited(c.out, c.num, c.b)
+		}
+		p.b = append(p.b, c.out...)
+
+	case object:
+		// Empty maps have no entries, which is the same as being absent.
+	}
+
+	return
+}
+
+func (e *Emitter) EmitMapValue() error {
+	e.top().key = false
+	return nil
+}
+
+func (e *Emitter) EmitMapNext() error {
+	c := e.top()
+
+	if c.kind == entries && c.open {
+		c.out = appendLengthDelimited(c.out, c.num, c.b)
+		c.b = c.b[:0]
+		c.open = false
+	}
+
+	c.key = true
+	return nil
+}
+
+// EmitStruct is called by the encoder after EmitMapBegin when encoding a
+// struct, which is always written as a message, even when it has no fields.
+func (e *Emitter) EmitStruct() error {
+	if c := e.top(); c != nil && c.kind == object {
+		c.kind = message
+	}
+	return nil
+}
+
+// EmitField is called by the encoder instead of emitting the name of struct
+// fields, the field number is taken from the `proto` option of the field tag.
+func (e *Emitter) EmitField(name string, number int) error {
+	c := e.top()
+
+	if c == nil || !c.key || (c.kind != message && c.kind != object) {
+		return fmt.Errorf("objconv/protobuf: unexpected struct field %q", name)
+	}
+
+	c.kind = message
+	return e.emitField(c, name, int64(number))
+}
+
+func (e *Emitter) emitField(c *context, name string, num int64) error {
+	if num <= 0 || num > MaxField {
+		if len(name) != 0 {
+			return fmt.Errorf("objconv/protobuf: missing or invalid field number for %q, it must be set with the proto tag option", name)
+		}
+		return fmt.Errorf("objconv/protobuf: invalid field number %d", num)
+	}
+	c.field = int(num)
+	return nil
+}
+
+func (e *Emitter) emit(wire int, u uint64) error {
+	c, num, err := e.next()
+
+	if err != nil {
+		return err
+	}
+
+	b := c.b
+
+	if c.kind == array {
+		// Repeated scalar values are packed in a single length-delimited
+		// field, which requires all the elements to have the same wire type.
+		if len(c.out) == 0 {
+			c.wire = wire
+		} else if c.wire != wire {
+			return fmt.Errorf("objconv/protobuf: cannot mix %s and %s elements in a packed repeated field", wireTypeString(c.wire), wireTypeString(wire))
+		}
+		b = c.out
+	} else {
+		b = appendKey(b, num, wire)
+	}
+
+	switch wire {
+	case Varint:
+		b = appendVarint(b, u)
+	case Fixed32:
+		b = appendFixed32(b, uint32(u))
+	default:
+		b = appendFixed64(b, u)
+	}
+
+	if c.kind == array {
+		c.out = b
+	} else {
+		c.b = b
+	}
+
+	return nil
+}
+
+func (e *Emitter) emitTimestamp(sec int64, nsec int64) error {
+	c, num, err := e.next()
+
+	if err != nil {
+		return err
+	}
+
+	var a [24]byte
+	var b = a[:0]
+
+	if sec != 0 {
+		b = appendKey(b, 1, Varint)
+		b = appendVarint(b, uint64(sec))
+	}
+
+	if nsec != 0 {
+		b = appendKey(b, 2, Varint)
+		b = appendVarint(b, uint64(nsec))
+	}
+
+	c.b = appendLengthDelimited(c.b, num, b)
+	return nil
+}
+
+// next returns the context that the next value must be written to and the
+// number of the field that it must be written as.
+func (e *Emitter) next() (c *context, num int, err error) {
+	if c = e.top(); c == nil {
+		err = errTopLevel
+		return
+	}
+
+	switch c.kind {
+	case message:
+		if c.key {
+			if len(e.stack) == 1 {
+				err = errors.New("objconv/protobuf: the keys of top-level maps must be field numbers")
+			} else {
+				err = errors.New("objconv/protobuf: struct fields and map keys cannot be mixed")
+			}
+			return
+		}
+		num = c.field
+
+	case object, entries:
+		c.kind = entries
+		if c.key {
+			c.open = true
+			num = 1
+		} else {
+			num = 2
+		}
+
+	case array:
+		num = c.num
+	}
+
+	return
+}
+
+func (e *Emitter) top() *context {
+	if n := len(e.stack); n != 0 {
+		return &e.stack[n-1]
+	}
+	return nil
+}
+
+func (e *Emitter) push(kind int, num int) {
+	n := len(e.stack)
+
+	if n == cap(e.stack) {
+		e.stack = append(e.stack, context{})
+	} else {
+		e.stack = e.stack[:n+1]
+	}
+
+	c := &e.stack[n]
+	*c = context{
+		kind: kind,
+		num:  num,
+		key:  kind != array,
+		b:    c.b[:0],
+		out:  c.out[:0],
+	}
+}
+
+// pop removes the context at the top of the stack and returns it, the buffers
+// of the context remain valid until the next call to push.
+func (e *Emitter) pop() context {
+	n := len(e.stack) - 1
+	c := e.stack[n]
+	e.stack = e.stack[:n]
+	return c
+}
@@ -0,0 +1,22 @@
+package protobuf
+
+import (
+	"io"
+
+	"github.com/dolab/objconv"
+)
+
+// Codec for the Protocol Buffers wire format.
+var Codec = objconv.Codec{
+	NewEmitter: func(w io.Writer) objconv.Emitter { return NewEmitter(w) },
+	NewParser:  func(r io.Reader) objconv.Parser { return NewParser(r) },
+}
+
+func init() {
+	for _, name := range [...]string{
+		"application/x-protobuf",
+		"protobuf",
+	} {
+		objconv.Register(name, Codec)
+	}
+}
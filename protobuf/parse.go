@@ -0,0 +1,605 @@
+package protobuf
+
+import (
+	"bytes"
+	"encoding"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/dolab/objconv"
+)
+
+// Parser implements a Protocol Buffers parser that satisfies the
+// objconv.Parser interface.
+//
+// Messages are not delimited in the wire format so the parser reads the whole
+// input before decoding the top-level message.
+type Parser struct {
+	r    io.Reader
+	b    []byte       // the input, loaded on the first call to ParseType
+	load bool         // set when the input was loaded
+	done bool         // set when the top-level message was parsed
+	hint reflect.Type // type of the destination of the next value
+
+	// The stack of messages, maps and arrays being parsed.
+	stack []frame
+
+	// Holds the top-level message as a length-delimited value.
+	root [1]value
+}
+
+type frame struct {
+	kind   int     // message, entries or array
+	fields []field // fields of a message
+	values []value // elements of an array or entries of a map
+	entry  []field // key and value of the current map entry
+	i      int     // index of the current field, entry or element
+	key    bool    // set when positioned on a key
+}
+
+func NewParser(r io.Reader) *Parser {
+	return &Parser{r: r}
+}
+
+func (p *Parser) Reset(r io.Reader) {
+	p.r = r
+	p.b = nil
+	p.load = false
+	p.done = false
+	p.hint = nil
+	p.stack = p.stack[:0]
+}
+
+func (p *Parser) Buffered() io.Reader {
+	return bytes.NewReader(nil)
+}
+
+// HintType is called by the decoder with the type of the next value to decode,
+// the parser needs it to tell apart the types that share the same encoding.
+func (p *Parser) HintType(t reflect.Type) {
+	p.hint = t
+}
+
+// ParseField is called by the decoder instead of parsing map keys when decoding
+// structs, it returns the number of the field that the parser is positioned on.
+func (p *Parser) ParseField() (int, error) {
+	if num, ok := p.fieldNumber(); ok {
+		return num, nil
+	}
+	return 0, errors.New("objconv/protobuf: struct fields can only be decoded from messages")
+}
+
+func (p *Parser) ParseType() (objconv.Type, error) {
+	if _, ok := p.fieldNumber(); ok {
+		return objconv.Int, nil
+	}
+
+	if len(p.stack) == 0 {
+		// The top-level value is always a message, whatever the type of the
+		// destination is.
+		if _, err := p.values(); err != nil {
+			return objconv.Unknown, err
+		}
+		return objconv.Map, nil
+	}
+
+	v, err := p.values()
+	if err != nil {
+		return objconv.Unknown, err
+	}
+
+	return p.typeOf(v), nil
+}
+
+func (p *Parser) ParseNil() (err error) {
+	p.hint = nil
+	return
+}
+
+func (p *Parser) ParseBool() (v bool, err error) {
+	var x value
+	if x, err = p.value(Varint); err == nil {
+		v = x.u != 0
+	}
+	return
+}
+
+func (p *Parser) ParseInt() (v int64, err error) {
+	if num, ok := p.fieldNumber(); ok {
+		v, p.hint = int64(num), nil
+		return
+	}
+
+	var x value
+	if x, err = p.value(Varint, Fixed32, Fixed64); err == nil {
+		if x.wire == Fixed32 {
+			v = int64(int32(x.u))
+		} else {
+			v = int64(x.u)
+		}
+	}
+	return
+}
+
+func (p *Parser) ParseUint() (v uint64, err error) {
+	if num, ok := p.fieldNumber(); ok {
+		v, p.hint = uint64(num), nil
+		return
+	}
+
+	var x value
+	if x, err = p.value(Varint, Fixed32, Fixed64); err == nil {
+		v = x.u
+	}
+	return
+}
+
+func (p *Parser) ParseFloat() (v float64, err error) {
+	var x value
+	if x, err = p.value(Fixed32, Fixed64, Varint); err == nil {
+		switch x.wire {
+		case Fixed32:
+			v = float64(math.Float32frombits(uint32(x.u)))
+		case Fixed64:
+			v = math.Float64frombits(x.u)
+		default:
+			v = float64(int64(x.u))
+		}
+	}
+	return
+}
+
+func (p *Parser) ParseString() (v []byte, err error) {
+	var x value
+	if x, err = p.value(LengthDelimited); err == nil {
+		v = x.b
+	}
+	return
+}
+
+func (p *Parser) ParseBytes() (v []byte, err error) {
+	return p.ParseString()
+}
+
+func (p *Parser) ParseTime() (v time.Time, err error) {
+	var sec, nsec int64
+	if sec, nsec, err = p.parseTimestamp(); err == nil {
+		v = time.Unix(sec, nsec).UTC()
+	}
+	return
+}
+
+func (p *Parser) ParseDuration() (v time.Duration, err error) {
+	var sec, nsec int64
+	if sec, nsec, err = p.parseTimestamp(); err == nil {
+		v = time.Duration(sec)*time.Second + time.Duration(nsec)
+	}
+	return
+}
+
+func (p *Parser) ParseError() (v error, err error) {
+	var b []byte
+	if b, err = p.ParseString(); err == nil {
+		v = errors.New(string(b))
+	}
+	return
+}
+
+func (p *Parser) ParseArrayBegin() (n int, err error) {
+	var v []value
+
+	if v, err = p.values(); err != nil {
+		return
+	}
+
+	var elem reflect.Type
+
+	if t := p.hintType(); t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		elem = indirect(t.Elem())
+	}
+
+	f := p.push(array)
+
+	for _, x := range v {
+		if x.wire != LengthDelimited || elem == nil || packedWireType(elem) < 0 {
+			f.values = append(f.values, x)
+			continue
+		}
+		if f.values, err = readPacked(x.b, packedWireType(elem), f.values); err != nil {
+			return
+		}
+	}
+
+	n = len(f.values)
+	return
+}
+
+func (p *Parser) ParseArrayEnd(n int) (err error) {
+	p.pop()
+	return
+}
+
+func (p *Parser) ParseArrayNext(n int) (err error) {
+	p.top().i = n
+	return
+}
+
+func (p *Parser) ParseMapBegin() (n int, err error) {
+	var v []value
+	var root = len(p.stack) == 0
+
+	if v, err = p.values(); err != nil {
+		return
+	}
+
+	if t := p.hintType(); !root && t != nil && t.Kind() == reflect.Map {
+		f := p.push(entries)
+		f.values = append(f.values, v...)
+		n = len(f.values)
+		return
+	}
+
+	x := v[len(v)-1]
+
+	if x.wire != LengthDelimited {
+		err = fmt.Errorf("objconv/protobuf: cannot decode a message from a %s field", wireTypeString(x.wire))
+		return
+	}
+
+	f := p.push(message)
+
+	if f.fields, err = readMessage(x.b, f.fields); err == nil {
+		n = len(f.fields)
+	}
+	return
+}
+
+func (p *Parser) ParseMapEnd(n int) (err error) {
+	if p.pop(); len(p.stack) == 0 {
+		p.done = true
+	}
+	return
+}
+
+func (p *Parser) ParseMapValue(n int) (err error) {
+	f := p.top()
+	f.i, f.key = n, false
+	return
+}
+
+func (p *Parser) ParseMapNext(n int) (err error) {
+	f := p.top()
+	f.i, f.key = n, true
+	return
+}
+
+// fieldNumber returns the field number that the parser is positioned on, if it
+// is positioned on the key of a message.
+func (p *Parser) fieldNumber() (int, bool) {
+	if f := p.top(); f != nil && f.kind == message && f.key && f.i < len(f.fields) {
+		return f.fields[f.i].num, true
+	}
+	return 0, false
+}
+
+// values returns all the occurrences of the value that the parser is
+// positioned on, the slice is empty if the value wasn't set.
+func (p *Parser) values() ([]value, error) {
+	f := p.top()
+
+	if f == nil {
+		if p.done {
+			return nil, io.EOF
+		}
+		if !p.load {
+			b, err := ioutil.ReadAll(p.r)
+			if err != nil {
+				return nil, err
+			}
+			p.b, p.load = b, true
+		}
+		p.root[0] = value{wire: LengthDelimited, b: p.b}
+		return p.root[:], nil
+	}
+
+	switch f.kind {
+	case message:
+		if f.i < len(f.fields) {
+			return f.fields[f.i].values, nil
+		}
+
+	case entries:
+		if f.i < len(f.values) {
+			if err := f.loadEntry(); err != nil {
+				return nil, err
+			}
+			num := 2
+			if f.key {
+				num = 1
+			}
+			for _, x := range f.entry {
+				if x.num == num {
+					return x.values, nil
+				}
+			}
+			return nil, nil
+		}
+
+	case array:
+		if f.i < len(f.values) {
+			return f.values[f.i : f.i+1], nil
+		}
+	}
+
+	return nil, errors.New("objconv/protobuf: no value to parse")
+}
+
+// value consumes the value that the parser is positioned on, expecting it to
+// have one of the given wire types.
+func (p *Parser) value(wire ...int) (x value, err error) {
+	var v []value
+
+	if v, err = p.values(); err != nil {
+		return
+	}
+
+	p.hint = nil
+
+	if len(v) == 0 {
+		err = errors.New("objconv/protobuf: no value to parse")
+		return
+	}
+
+	// Like protoc, the last occurrence of fields that are not repeated wins.
+	x = v[len(v)-1]
+
+	for _, w := range wire {
+		if x.wire == w {
+			return
+		}
+	}
+
+	err = fmt.Errorf("objconv/protobuf: unexpected %s field", wireTypeString(x.wire))
+	return
+}
+
+func (p *Parser) parseTimestamp() (sec int64, nsec int64, err error) {
+	var x value
+	var f [2]field
+
+	if x, err = p.value(LengthDelimited); err != nil {
+		return
+	}
+
+	fields, err := readMessage(x.b, f[:0])
+	if err != nil {
+		return
+	}
+
+	for _, f := range fields {
+		v := f.values[len(f.values)-1]
+
+		if v.wire != Varint {
+			err = fmt.Errorf("objconv/protobuf: unexpected %s field in timestamp", wireTypeString(v.wire))
+			return
+		}
+
+		switch f.num {
+		case 1:
+			sec = int64(v.u)
+		case 2:
+			nsec = int64(int32(v.u))
+		}
+	}
+
+	return
+}
+
+// typeOf returns the type of the value made of the occurrences in v, using the
+// type hint to tell apart the types that share the same encoding.
+func (p *Parser) typeOf(v []value) objconv.Type {
+	if len(v) == 0 {
+		return objconv.Nil
+	}
+
+	x := v[len(v)-1]
+
+	if t := p.hintType(); t != nil {
+		if typ := hintedType(t, x.wire); typ != objconv.Unknown {
+			return typ
+		}
+	}
+
+	if len(v) > 1 {
+		return objconv.Array
+	}
+
+	switch x.wire {
+	case Varint:
+		return objconv.Int
+	case Fixed32, Fixed64:
+		return objconv.Float
+	default:
+		return objconv.Bytes
+	}
+}
+
+// hintType returns the type hint with pointers removed.
+func (p *Parser) hintType() reflect.Type {
+	if p.hint == nil {
+		return nil
+	}
+	return indirect(p.hint)
+}
+
+func (p *Parser) top() *frame {
+	if n := len(p.stack); n != 0 {
+		return &p.stack[n-1]
+	}
+	return nil
+}
+
+func (p *Parser) push(kind int) *frame {
+	n := len(p.stack)
+
+	if n == cap(p.stack) {
+		p.stack = append(p.stack, frame{})
+	} else {
+		p.stack = p.stack[:n+1]
+	}
+
+	f := &p.stack[n]
+	*f = frame{
+		kind:   kind,
+		fields: f.fields[:0],
+		values: f.values[:0],
+		entry:  f.entry[:0],
+		key:    kind != array,
+	}
+
+	p.hint = nil
+	return f
+}
+
+func (p *Parser) pop() {
+	p.stack = p.stack[:len(p.stack)-1]
+}
+
+// loadEntry decodes the key and value of the current map entry.
+func (f *frame) loadEntry() (err error) {
+	x := f.values[f.i]
+
+	if x.wire != LengthDelimited {
+		return fmt.Errorf("objconv/protobuf: cannot decode a map entry from a %s field", wireTypeString(x.wire))
+	}
+
+	f.entry, err = readMessage(x.b, f.entry[:0])
+	return
+}
+
+// hintedType returns the type of a value of wire type w decoded into a value of
+// type t, or Unknown if the encoding doesn't match what values of type t are
+// encoded as.
+func hintedType(t reflect.Type, w int) objconv.Type {
+	switch t {
+	case timeType:
+		if w == LengthDelimited {
+			return objconv.Time
+		}
+		return objconv.Unknown
+
+	case durationType:
+		if w == LengthDelimited {
+			return objconv.Duration
+		}
+		return objconv.Unknown
+
+	case errorType:
+		if w == LengthDelimited {
+			return objconv.Error
+		}
+		return objconv.Unknown
+	}
+
+	if _, ok := objconv.AdapterOf(t); ok {
+		return objconv.Unknown
+	}
+
+	if p := reflect.PtrTo(t); p.Implements(textUnmarshalerType) || p.Implements(binaryUnmarshalerType) {
+		return objconv.Unknown
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		if w == Varint {
+			return objconv.Bool
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if w != LengthDelimited {
+			return objconv.Int
+		}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if w != LengthDelimited {
+			return objconv.Uint
+		}
+
+	case reflect.Float32, reflect.Float64:
+		if w == Fixed32 || w == Fixed64 {
+			return objconv.Float
+		}
+
+	case reflect.String:
+		if w == LengthDelimited {
+			return objconv.String
+		}
+
+	case reflect.Slice:
+		if t.Elem().Kind() != reflect.Uint8 {
+			return objconv.Array
+		}
+		if w == LengthDelimited {
+			return objconv.Bytes
+		}
+
+	case reflect.Array:
+		return objconv.Array
+
+	case reflect.Map, reflect.Struct:
+		if w == LengthDelimited {
+			return objconv.Map
+		}
+	}
+
+	return objconv.Unknown
+}
+
+// packedWireType returns the wire type of the elements of packed repeated
+// fields decoded into values of type t, or -1 if t cannot be packed.
+func packedWireType(t reflect.Type) int {
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return Varint
+	case reflect.Float32:
+		return Fixed32
+	case reflect.Float64:
+		return Fixed64
+	}
+	return -1
+}
+
+// readPacked appends the elements of wire type w packed in b to v.
+func readPacked(b []byte, w int, v []value) ([]value, error) {
+	for len(b) != 0 {
+		x, n, err := readValue(b, w)
+		if err != nil {
+			return v, err
+		}
+		v = append(v, x)
+		b = b[n:]
+	}
+	return v, nil
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+var (
+	timeType              = reflect.TypeOf(time.Time{})
+	durationType          = reflect.TypeOf(time.Duration(0))
+	errorType             = reflect.TypeOf((*error)(nil)).Elem()
+	textUnmarshalerType   = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+)
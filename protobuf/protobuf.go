@@ -0,0 +1,212 @@
+// Package protobuf implements an emitter and a parser of the Protocol Buffers
+// binary wire format.
+//
+// Protocol Buffers identify the fields of messages by numbers, which are set
+// with the `proto` option of the struct tags:
+//
+//	type Point struct {
+//		X int64 `objconv:"x,proto=1"`
+//		Y int64 `objconv:"y,proto=2"`
+//	}
+//
+// Values are encoded the way protoc would for the equivalent proto3 schema,
+// integers and booleans are varints, floats are fixed32 or fixed64, strings,
+// byte slices and nested structs are length-delimited, time.Time and
+// time.Duration values are google.protobuf.Timestamp and Duration messages,
+// slices are repeated fields (packed for numeric types), and maps are repeated
+// entries with the key in field 1 and the value in field 2.
+//
+// The wire format doesn't carry enough information to tell all the types apart
+// so the parser relies on the types of the destination values, decoding into
+// empty interfaces produces maps of field numbers to int64, float64 and []byte
+// values.
+package protobuf
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Wire types of the Protocol Buffers encoding.
+const (
+	Varint          = 0
+	Fixed64         = 1
+	LengthDelimited = 2
+	StartGroup      = 3
+	EndGroup        = 4
+	Fixed32         = 5
+)
+
+// MaxField is the largest field number supported by Protocol Buffers.
+const MaxField = 1<<29 - 1
+
+var (
+	errTruncated = errors.New("objconv/protobuf: unexpected end of input")
+	errOverflow  = errors.New("objconv/protobuf: varint overflows 64 bits")
+)
+
+func appendVarint(b []byte, u uint64) []byte {
+	for u >= 0x80 {
+		b = append(b, byte(u)|0x80)
+		u >>= 7
+	}
+	return append(b, byte(u))
+}
+
+func appendKey(b []byte, num int, wire int) []byte {
+	return appendVarint(b, uint64(num)<<3|uint64(wire))
+}
+
+func appendFixed32(b []byte, u uint32) []byte {
+	return append(b, byte(u), byte(u>>8), byte(u>>16), byte(u>>24))
+}
+
+func appendFixed64(b []byte, u uint64) []byte {
+	return append(b,
+		byte(u), byte(u>>8), byte(u>>16), byte(u>>24),
+		byte(u>>32), byte(u>>40), byte(u>>48), byte(u>>56),
+	)
+}
+
+func appendLengthDelimited(b []byte, num int, v []byte) []byte {
+	b = appendKey(b, num, LengthDelimited)
+	b = appendVarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func readVarint(b []byte) (u uint64, n int, err error) {
+	for s := uint(0); n != len(b); s += 7 {
+		c := b[n]
+		n++
+
+		if s == 63 && c > 1 {
+			err = errOverflow
+			return
+		}
+
+		if u |= uint64(c&0x7F) << s; c < 0x80 {
+			return
+		}
+	}
+	err = errTruncated
+	return
+}
+
+func readFixed32(b []byte) (uint32, error) {
+	if len(b) < 4 {
+		return 0, errTruncated
+	}
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24, nil
+}
+
+func readFixed64(b []byte) (uint64, error) {
+	if len(b) < 8 {
+		return 0, errTruncated
+	}
+	lo, _ := readFixed32(b)
+	hi, _ := readFixed32(b[4:])
+	return uint64(lo) | uint64(hi)<<32, nil
+}
+
+// readValue reads the value of wire type w at the beginning of b, returning
+// the value and the number of bytes that it used.
+func readValue(b []byte, w int) (v value, n int, err error) {
+	v.wire = w
+
+	switch w {
+	case Varint:
+		v.u, n, err = readVarint(b)
+
+	case Fixed32:
+		var u uint32
+		u, err = readFixed32(b)
+		v.u, n = uint64(u), 4
+
+	case Fixed64:
+		v.u, err = readFixed64(b)
+		n = 8
+
+	case LengthDelimited:
+		var u uint64
+		if u, n, err = readVarint(b); err != nil {
+			return
+		}
+		if u > uint64(len(b)-n) {
+			err = errTruncated
+			return
+		}
+		v.b = b[n : n+int(u)]
+		n += int(u)
+
+	case StartGroup, EndGroup:
+		err = errors.New("objconv/protobuf: groups are not supported")
+
+	default:
+		err = fmt.Errorf("objconv/protobuf: invalid wire type %d", w)
+	}
+
+	return
+}
+
+// readMessage appends the fields of the message encoded in b to fields, the
+// occurrences of repeated fields are grouped in the order they were found.
+func readMessage(b []byte, fields []field) ([]field, error) {
+	for len(b) != 0 {
+		k, n, err := readVarint(b)
+		if err != nil {
+			return fields, err
+		}
+		b = b[n:]
+
+		num := k >> 3
+		if num == 0 || num > MaxField {
+			return fields, fmt.Errorf("objconv/protobuf: invalid field number %d", num)
+		}
+
+		v, n, err := readValue(b, int(k&7))
+		if err != nil {
+			return fields, err
+		}
+		b = b[n:]
+
+		i := 0
+		for i != len(fields) && fields[i].num != int(num) {
+			i++
+		}
+
+		if i == len(fields) {
+			fields = append(fields, field{num: int(num)})
+		}
+
+		fields[i].values = append(fields[i].values, v)
+	}
+	return fields, nil
+}
+
+// value is the representation of a field value in the wire format.
+type value struct {
+	wire int    // wire type of the value
+	u    uint64 // value of varint, fixed32 and fixed64 fields
+	b    []byte // payload of length-delimited fields
+}
+
+// field is the list of occurrences of a field in a message.
+type field struct {
+	num    int
+	values []value
+}
+
+func wireTypeString(w int) string {
+	switch w {
+	case Varint:
+		return "varint"
+	case Fixed64:
+		return "fixed64"
+	case LengthDelimited:
+		return "length-delimited"
+	case Fixed32:
+		return "fixed32"
+	default:
+		return fmt.Sprintf("<wire type %d>", w)
+	}
+}
@@ -0,0 +1,287 @@
+package protobuf
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dolab/objconv"
+)
+
+type test1 struct {
+	A int32 `objconv:"a,proto=1"`
+}
+
+type test2 struct {
+	A int32    `objconv:"a,proto=1,omitzero"`
+	B string   `objconv:"b,proto=2,omitzero"`
+	C *test1   `objconv:"c,proto=3,omitempty"`
+	D []int32  `objconv:"d,proto=4,omitempty"`
+	E []string `objconv:"e,proto=5,omitempty"`
+}
+
+type test3 struct {
+	C *test2   `objconv:"c,proto=3"`
+	S struct{} `objconv:"s,proto=4"`
+}
+
+func TestWireFormat(t *testing.T) {
+	// These are the examples from the encoding guide of Protocol Buffers, the
+	// output must be byte for byte what protoc generates.
+	tests := []struct {
+		v interface{}
+		b []byte
+	}{
+		{
+			v: test1{A: 150},
+			b: []byte{0x08, 0x96, 0x01},
+		},
+		{
+			v: test2{B: "testing"},
+			b: []byte{0x12, 0x07, 't', 'e', 's', 't', 'i', 'n', 'g'},
+		},
+		{
+			v: test2{C: &test1{A: 150}},
+			b: []byte{0x1a, 0x03, 0x08, 0x96, 0x01},
+		},
+		{
+			v: test2{D: []int32{3, 270, 86942}},
+			b: []byte{0x22, 0x06, 0x03, 0x8e, 0x02, 0x9e, 0xa7, 0x05},
+		},
+		{
+			v: test2{E: []string{"a", "b"}},
+			b: []byte{0x2a, 0x01, 'a', 0x2a, 0x01, 'b'},
+		},
+		{ // empty nested messages are present with a zero length
+			v: test3{C: &test2{}},
+			b: []byte{0x1a, 0x00, 0x22, 0x00},
+		},
+		{
+			v: test1{A: -1},
+			b: []byte{0x08, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01},
+		},
+	}
+
+	for _, test := range tests {
+		b, err := Marshal(test.v)
+
+		if err != nil {
+			t.Errorf("%#v: %s", test.v, err)
+			continue
+		}
+
+		if !bytes.Equal(b, test.b) {
+			t.Errorf("%#v:\n- expected: %#v\n- found:    %#v", test.v, test.b, b)
+		}
+
+		v := reflect.New(reflect.TypeOf(test.v))
+
+		if err := Unmarshal(test.b, v.Interface()); err != nil {
+			t.Errorf("%#v: %s", test.v, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(v.Elem().Interface(), test.v) {
+			t.Errorf("%#v: bad value decoded: %#v", test.v, v.Elem().Interface())
+		}
+	}
+}
+
+func TestEmptyMap(t *testing.T) {
+	b, err := Marshal(struct {
+		Tags map[string]string `objconv:"tags,proto=1"`
+	}{Tags: map[string]string{}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(b) != 0 {
+		t.Errorf("empty maps must not be written: %#v", b)
+	}
+}
+
+type point struct {
+	X float32 `objconv:"x,proto=1"`
+	Y float32 `objconv:"y,proto=2"`
+}
+
+type testMessage struct {
+	Bool     bool                `objconv:"bool,proto=1"`
+	Int      int                 `objconv:"int,proto=2"`
+	Uint     uint64              `objconv:"uint,proto=3"`
+	Float    float64             `objconv:"float,proto=4"`
+	String   string              `objconv:"string,proto=5"`
+	Bytes    []byte              `objconv:"bytes,proto=6"`
+	Time     time.Time           `objconv:"time,proto=7"`
+	Duration time.Duration       `objconv:"duration,proto=8"`
+	Point    point               `objconv:"point,proto=9"`
+	Pointer  *point              `objconv:"pointer,proto=10"`
+	Points   []point             `objconv:"points,proto=11"`
+	Floats   []float64           `objconv:"floats,proto=12"`
+	Bools    []bool              `objconv:"bools,proto=13"`
+	Tags     map[string]string   `objconv:"tags,proto=14"`
+	Counts   map[string]int      `objconv:"counts,proto=15"`
+	Index    map[int32]point     `objconv:"index,proto=16"`
+	Lists    map[string][]uint32 `objconv:"lists,proto=17"`
+	Error    error               `objconv:"error,proto=18,omitempty"`
+}
+
+func TestRoundTrip(t *testing.T) {
+	m1 := testMessage{
+		Bool:     true,
+		Int:      -42,
+		Uint:     1 << 63,
+		Float:    0.5,
+		String:   "Hello World!",
+		Bytes:    []byte{0, 1, 2},
+		Time:     time.Date(2016, 12, 20, 1, 2, 3, 4, time.UTC),
+		Duration: -1500 * time.Millisecond,
+		Point:    point{X: 1, Y: 2},
+		Pointer:  &point{X: 3},
+		Points:   []point{{X: 1}, {}, {Y: 2}},
+		Floats:   []float64{1, 2, 3},
+		Bools:    []bool{true, false, true},
+		Tags:     map[string]string{"A": "1", "B": "2", "": ""},
+		Counts:   map[string]int{"A": 1, "B": -2},
+		Index:    map[int32]point{1: {X: 1, Y: 1}, 2: {}},
+		Lists:    map[string][]uint32{"A": {1, 2}},
+		Error:    errors.New("oops"),
+	}
+
+	b, err := Marshal(m1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m2 := testMessage{}
+
+	if err := Unmarshal(b, &m2); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(m1, m2) {
+		t.Errorf("bad value decoded:\n- expected: %#v\n- found:    %#v", m1, m2)
+	}
+}
+
+func TestUnknownFields(t *testing.T) {
+	b, _ := Marshal(testMessage{
+		Int:    1,
+		String: "A",
+		Points: []point{{X: 1}, {X: 2}},
+		Tags:   map[string]string{"A": "B"},
+	})
+
+	var v struct {
+		String string `objconv:"string,proto=5"`
+	}
+
+	if err := Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if v.String != "A" {
+		t.Error("bad value decoded:", v.String)
+	}
+
+	var e *objconv.UnknownFieldError
+
+	if err := (objconv.Decoder{
+		Parser:                NewParser(bytes.NewReader(b)),
+		DisallowUnknownFields: true,
+	}).Decode(&v); !errors.As(err, &e) {
+		t.Fatalf("expected *objconv.UnknownFieldError but got %#v", err)
+	}
+
+	if e.Field != "1" {
+		t.Error("bad field:", e.Field)
+	}
+}
+
+func TestDecodeInterface(t *testing.T) {
+	b, _ := Marshal(test2{A: 1, B: "A", C: &test1{A: 2}, D: []int32{1}, E: []string{"A", "B"}})
+
+	var v interface{}
+
+	if err := Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a destination type the parser can only rely on the wire types.
+	if !reflect.DeepEqual(v, map[interface{}]interface{}{
+		int64(1): int64(1),
+		int64(2): []byte("A"),
+		int64(3): []byte{0x08, 0x02},
+		int64(4): []byte{0x01},
+		int64(5): []interface{}{[]byte("A"), []byte("B")},
+	}) {
+		t.Errorf("bad value decoded: %#v", v)
+	}
+}
+
+func TestValueEncoder(t *testing.T) {
+	// Maps of field numbers at the top level are encoded as messages.
+	fields := []struct {
+		num   int
+		value interface{}
+	}{
+		{1, 150},
+		{2, "testing"},
+	}
+
+	v := objconv.ValueEncoderFunc(func(e objconv.Encoder) error {
+		i := 0
+		return e.EncodeMap(len(fields), func(k objconv.Encoder, v objconv.Encoder) (err error) {
+			if err = k.Encode(fields[i].num); err != nil {
+				return
+			}
+			err = v.Encode(fields[i].value)
+			i++
+			return
+		})
+	})
+
+	b, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var x test2
+
+	if err := Unmarshal(b, &x); err != nil {
+		t.Fatal(err)
+	}
+
+	if x.A != 150 || x.B != "testing" {
+		t.Errorf("bad value decoded: %#v", x)
+	}
+}
+
+func TestEncodeError(t *testing.T) {
+	tests := []interface{}{
+		nil,
+		42,
+		"Hello World!",
+		[]test1{},
+		map[string]int{"A": 1},
+		struct{ A int }{1},
+		struct {
+			A [][]int `objconv:"a,proto=1"`
+		}{[][]int{{1}}},
+	}
+
+	for _, test := range tests {
+		if _, err := Marshal(test); err == nil {
+			t.Errorf("%#v: expected an error", test)
+		}
+	}
+}
+
+func TestCodecRegistered(t *testing.T) {
+	if _, ok := objconv.Lookup("application/x-protobuf"); !ok {
+		t.Error("protobuf codec not registered")
+	}
+}
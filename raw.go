@@ -0,0 +1,51 @@
+package objconv
+
+// RawValue is a serialized value, it can be used to delay decoding part of a
+// document or to avoid paying the cost of decoding and re-encoding values that
+// a program only needs to route.
+//
+// When decoding, a RawValue receives a copy of the exact bytes of the value and
+// the name of their format, which is only supported by parsers of byte-based
+// formats.
+//
+// When encoding, the bytes of a RawValue are written to the output as-is if
+// they are in the format of the emitter, or if the format is empty. Otherwise
+// they are decoded with the codec registered for the format and re-encoded. A
+// RawValue with nil bytes is encoded as a nil value.
+type RawValue struct {
+	// Format is the name of the codec that the value is serialized with, as
+	// registered in the global registry (for example "json" or "msgpack").
+	Format string
+
+	// Bytes holds the serialized value.
+	Bytes []byte
+}
+
+// The rawParser interface may be implemented by parsers of byte-based formats
+// to support decoding values into RawValue.
+type rawParser interface {
+	// BeginRaw is called right before parsing a value, the parser must record
+	// all bytes it consumes from this point on.
+	BeginRaw() error
+
+	// EndRaw is called right after parsing the value, it stops the recording
+	// and returns the bytes consumed since BeginRaw was called. The returned
+	// slice is only valid until the next call to the parser.
+	EndRaw() []byte
+
+	// RawFormat returns the name of the format of the recorded bytes, which
+	// must be the name of a codec in the global registry.
+	RawFormat() string
+}
+
+// The rawEmitter interface may be implemented by emitters of byte-based
+// formats to support encoding values of type RawValue.
+type rawEmitter interface {
+	// EmitRaw writes b to the output without any transformation.
+	EmitRaw(b []byte) error
+
+	// RawFormat returns the name of the format that EmitRaw accepts, which
+	// must be the name of a codec in the global registry, or an empty string
+	// if EmitRaw accepts values of any format.
+	RawFormat() string
+}
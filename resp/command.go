@@ -0,0 +1,324 @@
+package resp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/dolab/objconv"
+	"github.com/dolab/objconv/objutil"
+)
+
+// Command represents a command sent by a redis client.
+type Command struct {
+	// Name of the command, as sent by the client.
+	Name string
+
+	// Arguments of the command, following its name.
+	Args [][]byte
+}
+
+// Decode decodes the arguments of the command into v, which must be a pointer
+// to a struct.
+//
+// The arguments are assigned to the exported fields of the struct in the order
+// of their declaration, fields with the `objconv:"-"` tag are ignored. Fields
+// with the `omitempty` tag option are optional, the last field may be a slice
+// which receives all the remaining arguments. Each argument is decoded with an
+// objconv.Decoder, text is converted to the type of the field, so numbers and
+// booleans are parsed, and types implementing encoding.TextUnmarshaler or
+// having adapters installed are supported. Arguments which cannot be decoded
+// are reported with an *ArgumentError.
+func (c *Command) Decode(v interface{}) error {
+	to := reflect.ValueOf(v)
+
+	if to.Kind() != reflect.Ptr || to.IsNil() || to.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("objconv/resp: cannot decode the arguments of %s into a value of type %T", c.Name, v)
+	}
+
+	to = to.Elem()
+	args := c.Args
+	p := &argParser{}
+	d := objconv.Decoder{Parser: p}
+
+	for i, n := 0, to.NumField(); i != n; i++ {
+		f := to.Type().Field(i)
+
+		if f.PkgPath != "" { // unexported
+			continue
+		}
+
+		tag := objutil.ParseTag(f.Tag.Get("objconv"))
+
+		if tag.Name == "-" {
+			continue
+		}
+
+		field := to.Field(i)
+
+		if i == n-1 && field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.Uint8 {
+			s := reflect.MakeSlice(field.Type(), len(args), len(args))
+
+			for j, a := range args {
+				p.arg = a
+				if err := d.Decode(s.Index(j).Addr().Interface()); err != nil {
+					return argError(c.Name, f.Name, field.Type().Elem(), err)
+				}
+			}
+
+			field.Set(s)
+			args = nil
+			break
+		}
+
+		if len(args) == 0 {
+			if tag.Omitempty {
+				continue
+			}
+			return fmt.Errorf("ERR wrong number of arguments for '%s' command", c.Name)
+		}
+
+		p.arg, args = args[0], args[1:]
+
+		if err := d.Decode(field.Addr().Interface()); err != nil {
+			return argError(c.Name, f.Name, field.Type(), err)
+		}
+	}
+
+	if len(args) != 0 {
+		return fmt.Errorf("ERR wrong number of arguments for '%s' command", c.Name)
+	}
+
+	return nil
+}
+
+func argError(cmd string, field string, t reflect.Type, err error) error {
+	return &ArgumentError{Command: cmd, Field: field, Type: t, Err: err}
+}
+
+// CommandReader reads commands sent by redis clients, in the multibulk format
+// (arrays of bulk strings) or inline (space-separated arguments on one line).
+type CommandReader struct {
+	p Parser
+}
+
+// NewCommandReader returns a new command reader which reads from r.
+func NewCommandReader(r io.Reader) *CommandReader {
+	return &CommandReader{p: Parser{r: r}}
+}
+
+// Buffered returns the number of bytes that were loaded from the input but not
+// read yet. A non-zero value indicates that the client pipelined commands.
+func (r *CommandReader) Buffered() int {
+	return len(r.p.s) - r.p.n
+}
+
+// ReadCommand reads the next command, empty commands are skipped. The method
+// returns io.EOF when the input reached its end between two commands.
+func (r *CommandReader) ReadCommand() (cmd Command, err error) {
+	var line []byte
+
+	// The arguments reference the buffer of the parser, which is reused by
+	// the next reads, so they are copied to a memory area which belongs to
+	// the command, ends holds the offsets of the end of each argument.
+	var b []byte
+	var ends []int
+
+	for len(ends) == 0 {
+		if line, err = r.p.peekLine(); err != nil {
+			if err == io.EOF && len(r.p.s) != r.p.n {
+				err = io.ErrUnexpectedEOF
+			}
+			return
+		}
+
+		if len(line) != 0 && line[0] == '*' {
+			b, ends, err = r.readMultibulk(b, ends)
+		} else {
+			b, ends, err = splitInline(b, ends, line)
+			r.p.skipLine()
+		}
+
+		if err != nil {
+			return
+		}
+	}
+
+	args := make([][]byte, len(ends))
+
+	for i, j := range ends {
+		k := 0
+		if i != 0 {
+			k = ends[i-1]
+		}
+		args[i] = b[k:j:j]
+	}
+
+	cmd.Name, cmd.Args = string(args[0]), args[1:]
+	return
+}
+
+func (r *CommandReader) readMultibulk(b []byte, ends []int) ([]byte, []int, error) {
+	n, err := r.p.ParseArrayBegin()
+
+	for i := 0; i != n && err == nil; i++ {
+		var a []byte
+
+		if a, err = r.p.ParseBytes(); err == nil {
+			b = append(b, a...)
+			ends = append(ends, len(b))
+		}
+	}
+
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return b, ends, err
+}
+
+// splitInline splits an inline command into its arguments, which are separated
+// by spaces and may be quoted with single or double quotes.
+func splitInline(b []byte, ends []int, line []byte) ([]byte, []int, error) {
+	for {
+		line = bytes.TrimLeft(line, " \t")
+
+		if len(line) == 0 {
+			return b, ends, nil
+		}
+
+		switch q := line[0]; q {
+		case '"', '\'':
+			i := 1
+
+			for ; i < len(line) && line[i] != q; i++ {
+				if line[i] == '\\' && q == '"' && i+1 < len(line) {
+					i++
+				}
+				b = append(b, line[i])
+			}
+
+			if i == len(line) {
+				return b, ends, errors.New("ERR Protocol error: unbalanced quotes in request")
+			}
+
+			line = line[i+1:]
+
+		default:
+			i := bytes.IndexAny(line, " \t")
+
+			if i < 0 {
+				i = len(line)
+			}
+
+			b, line = append(b, line[:i]...), line[i:]
+		}
+
+		ends = append(ends, len(b))
+	}
+}
+
+// argParser is an implementation of objconv.Parser used to decode the text of
+// command arguments into values of the type hinted by the decoder.
+type argParser struct {
+	arg  []byte
+	hint reflect.Type
+}
+
+func (p *argParser) HintType(t reflect.Type) {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	p.hint = t
+}
+
+func (p *argParser) Buffered() io.Reader {
+	return bytes.NewReader(nil)
+}
+
+func (p *argParser) ParseType() (objconv.Type, error) {
+	if p.hint == nil || p.hint == durationType {
+		return objconv.String, nil
+	}
+
+	switch p.hint.Kind() {
+	case reflect.Bool:
+		return objconv.Bool, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return objconv.Int, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return objconv.Uint, nil
+	case reflect.Float32, reflect.Float64:
+		return objconv.Float, nil
+	case reflect.Slice:
+		if p.hint.Elem().Kind() == reflect.Uint8 {
+			return objconv.Bytes, nil
+		}
+	}
+
+	return objconv.String, nil
+}
+
+func (p *argParser) ParseNil() error {
+	return errors.New("objconv/resp: command arguments cannot be null")
+}
+
+func (p *argParser) ParseBool() (bool, error) {
+	return strconv.ParseBool(string(p.arg))
+}
+
+func (p *argParser) ParseInt() (int64, error) {
+	return strconv.ParseInt(string(p.arg), 10, 64)
+}
+
+func (p *argParser) ParseUint() (uint64, error) {
+	return strconv.ParseUint(string(p.arg), 10, 64)
+}
+
+func (p *argParser) ParseFloat() (float64, error) {
+	return strconv.ParseFloat(string(p.arg), 64)
+}
+
+func (p *argParser) ParseString() ([]byte, error) {
+	return p.arg, nil
+}
+
+func (p *argParser) ParseBytes() ([]byte, error) {
+	return p.arg, nil
+}
+
+func (p *argParser) ParseTime() (time.Time, error) {
+	return time.Parse(time.RFC3339Nano, string(p.arg))
+}
+
+func (p *argParser) ParseDuration() (time.Duration, error) {
+	return time.ParseDuration(string(p.arg))
+}
+
+func (p *argParser) ParseError() (error, error) {
+	return errors.New(string(p.arg)), nil
+}
+
+func (p *argParser) ParseArrayBegin() (int, error) {
+	return 0, errors.New("objconv/resp: command arguments cannot be decoded into arrays")
+}
+
+func (p *argParser) ParseArrayEnd(int) error { return nil }
+
+func (p *argParser) ParseArrayNext(int) error { return nil }
+
+func (p *argParser) ParseMapBegin() (int, error) {
+	return 0, errors.New("objconv/resp: command arguments cannot be decoded into maps")
+}
+
+func (p *argParser) ParseMapEnd(int) error { return nil }
+
+func (p *argParser) ParseMapValue(int) error { return nil }
+
+func (p *argParser) ParseMapNext(int) error { return nil }
+
+var durationType = reflect.TypeOf(time.Duration(0))
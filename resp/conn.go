@@ -0,0 +1,275 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/dolab/objconv"
+)
+
+// Conn is a client connection to a server speaking the redis protocol.
+//
+// Commands are written with Send, which buffers them until Flush is called,
+// and their replies are read in order with Receive, which makes it possible
+// to pipeline commands. Do combines the three methods to run a single command.
+//
+// Error replies are returned as *Error values by Do and Receive, the
+// connection remains usable after receiving them. Other errors leave the
+// connection in an unknown state, they are returned by all the following calls
+// to the methods of the connection.
+//
+// Instances of Conn are not safe for use by multiple goroutines.
+type Conn struct {
+	rw  io.ReadWriter
+	w   *bufio.Writer
+	e   objconv.Encoder
+	p   *Parser
+	d   *objconv.StreamDecoder
+	err error
+}
+
+// NewConn returns a new client connection which writes commands to rw and
+// reads their replies from it.
+func NewConn(rw io.ReadWriter) *Conn {
+	w := bufio.NewWriter(rw)
+	p := NewParser(rw)
+	return &Conn{
+		rw: rw,
+		w:  w,
+		e:  objconv.Encoder{Emitter: NewClientEmitter(w)},
+		p:  p,
+		d:  objconv.NewStreamDecoder(p),
+	}
+}
+
+// Close closes the underlying reader and writer if it implements io.Closer.
+func (c *Conn) Close() error {
+	if x, ok := c.rw.(io.Closer); ok {
+		return x.Close()
+	}
+	return nil
+}
+
+// Do sends the command made of cmd, flushes it, and returns its reply decoded
+// into an empty interface.
+func (c *Conn) Do(cmd ...interface{}) (reply interface{}, err error) {
+	if err = c.Send(cmd...); err != nil {
+		return
+	}
+	if err = c.Flush(); err != nil {
+		return
+	}
+	err = c.Receive(&reply)
+	return
+}
+
+// Hello negotiates the version of the protocol used by the connection with the
+// HELLO command, and returns the information about the server found in its
+// reply.
+//
+// The parser of the connection switches to the new version before reading the
+// reply, and switches back to RESP2 if the server rejects the command.
+func (c *Conn) Hello(version int) (info map[interface{}]interface{}, err error) {
+	if err = c.Send("HELLO", version); err != nil {
+		return
+	}
+
+	if err = c.Flush(); err != nil {
+		return
+	}
+
+	c.p.SetProtocol(version)
+
+	if err = c.Receive(&info); err != nil {
+		c.p.SetProtocol(2)
+	}
+
+	return
+}
+
+// Send writes the command made of cmd to the output buffer of the connection.
+//
+// Each element of cmd is written as a bulk string, slices are flattened.
+func (c *Conn) Send(cmd ...interface{}) (err error) {
+	if c.err != nil {
+		return c.err
+	}
+
+	if len(cmd) == 0 {
+		return errors.New("objconv/resp: cannot send an empty command")
+	}
+
+	if err = c.e.Encode(cmd); err != nil {
+		c.err = err
+	}
+	return
+}
+
+// Flush writes the commands buffered by calls to Send.
+func (c *Conn) Flush() (err error) {
+	if c.err != nil {
+		return c.err
+	}
+
+	if err = c.w.Flush(); err != nil {
+		c.err = err
+	}
+	return
+}
+
+// Receive reads the next reply and decodes it into v, which may be nil to
+// discard the reply.
+//
+// Array replies can be decoded into slices, arrays, maps (from arrays of keys
+// and values) and empty interfaces, other replies can be decoded into any
+// value accepted by objconv.Decoder.
+// Error replies are returned as *Error values.
+func (c *Conn) Receive(v interface{}) (err error) {
+	if c.err != nil {
+		return c.err
+	}
+
+	// The stream decoder reads one reply at a time, Next moves it to the
+	// reply following the one that it reached the end of.
+	if err = c.d.Next(); err == nil {
+		switch c.d.RootType() {
+		case objconv.Error:
+			err = c.receiveError()
+		case objconv.Array:
+			err = c.receiveArray(v)
+		default:
+			err = c.receiveValue(v)
+		}
+	}
+
+	if err == nil {
+		err = c.d.Err()
+	}
+
+	if _, ok := err.(*Error); !ok && err != nil {
+		c.err = err
+	}
+
+	return
+}
+
+func (c *Conn) receiveError() (err error) {
+	var x error
+
+	if err = c.receiveValue(&x); err == nil {
+		err = x
+	}
+
+	return
+}
+
+func (c *Conn) receiveValue(v interface{}) (err error) {
+	if err = c.d.Decode(v); err != nil {
+		return
+	}
+
+	if err = c.d.Decode(nil); err == objconv.End {
+		err = nil
+	}
+
+	return
+}
+
+func (c *Conn) receiveArray(v interface{}) (err error) {
+	var to reflect.Value
+
+	if v != nil {
+		if to = reflect.ValueOf(v); to.Kind() != reflect.Ptr || to.IsNil() {
+			return fmt.Errorf("objconv/resp: cannot decode a reply into a value of type %T", v)
+		}
+		to = to.Elem()
+	}
+
+	switch {
+	case !to.IsValid():
+		for err == nil {
+			err = c.d.Decode(nil)
+		}
+
+	case to.Kind() == reflect.Interface && to.NumMethod() == 0:
+		var a []interface{}
+
+		if err = c.receiveArray(&a); err == nil {
+			to.Set(reflect.ValueOf(a))
+		}
+		return
+
+	case to.Kind() == reflect.Slice:
+		s := to.Slice(0, 0)
+
+		for err == nil {
+			e := reflect.New(s.Type().Elem())
+
+			if err = c.d.Decode(e.Interface()); err == nil {
+				s = reflect.Append(s, e.Elem())
+			}
+		}
+
+		if err == objconv.End {
+			to.Set(s)
+		}
+
+	case to.Kind() == reflect.Array:
+		if n := c.d.Len(); n > to.Len() {
+			return fmt.Errorf("objconv/resp: array reply of length %d cannot be decoded into %s", n, to.Type())
+		}
+
+		for i := 0; err == nil; i++ {
+			if i < to.Len() {
+				err = c.d.Decode(to.Index(i).Addr().Interface())
+			} else {
+				err = c.d.Decode(nil)
+			}
+		}
+
+	case to.Kind() == reflect.Map:
+		// RESP2 has no map type, maps are represented by arrays of keys and
+		// values.
+		m := reflect.MakeMap(to.Type())
+
+		for err == nil {
+			k := reflect.New(to.Type().Key())
+			e := reflect.New(to.Type().Elem())
+
+			if err = c.d.Decode(k.Interface()); err != nil {
+				break
+			}
+
+			if err = c.d.Decode(e.Interface()); err != nil {
+				if err == objconv.End {
+					err = errors.New("objconv/resp: array reply of odd length cannot be decoded into a map")
+				}
+				break
+			}
+
+			// Bulk strings are decoded into byte slices, which cannot be used
+			// as map keys.
+			if b, ok := k.Elem().Interface().([]byte); ok && k.Elem().Kind() == reflect.Interface {
+				k.Elem().Set(reflect.ValueOf(string(b)))
+			}
+
+			m.SetMapIndex(k.Elem(), e.Elem())
+		}
+
+		if err == objconv.End {
+			to.Set(m)
+		}
+
+	default:
+		return fmt.Errorf("objconv/resp: cannot decode an array reply into a value of type %s", to.Type())
+	}
+
+	if err == objconv.End {
+		err = nil
+	}
+
+	return
+}
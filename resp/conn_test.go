@@ -0,0 +1,339 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dolab/objconv"
+)
+
+// fakeServer is a minimal in-memory redis server used to test client
+// connections.
+type fakeServer struct {
+	l     net.Listener
+	mutex sync.Mutex
+	data  map[string]string
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &fakeServer{l: l, data: make(map[string]string)}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *fakeServer) dial(t *testing.T) *Conn {
+	conn, err := net.Dial("tcp", s.l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewConn(conn)
+}
+
+func (s *fakeServer) Close() error {
+	return s.l.Close()
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	w := bufio.NewWriter(conn)
+	e := NewEmitter(w)
+	d := objconv.NewStreamDecoder(NewParser(conn))
+	enc := objconv.NewEncoder(e)
+
+	for d.Next() == nil {
+		var cmd []string
+		var arg string
+
+		for d.Decode(&arg) == nil {
+			cmd = append(cmd, arg)
+		}
+
+		if d.Err() != nil {
+			return
+		}
+
+		if enc.Encode(s.handle(cmd, e)) != nil || w.Flush() != nil {
+			return
+		}
+	}
+}
+
+func (s *fakeServer) handle(cmd []string, e *Emitter) interface{} {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch strings.ToUpper(cmd[0]) {
+	case "PING":
+		return "PONG"
+
+	case "HELLO":
+		if cmd[1] != "2" && cmd[1] != "3" {
+			return errors.New("NOPROTO unsupported protocol version")
+		}
+		// The reply is written with the negotiated version.
+		e.SetProtocol(int(cmd[1][0] - '0'))
+		return map[string]interface{}{"server": "fake", "proto": int(cmd[1][0] - '0')}
+
+	case "SET":
+		s.data[cmd[1]] = cmd[2]
+		return "OK"
+
+	case "GET":
+		if v, ok := s.data[cmd[1]]; ok {
+			return []byte(v)
+		}
+		return nil
+
+	case "INCR":
+		n, _ := strconv.Atoi(s.data[cmd[1]])
+		n++
+		s.data[cmd[1]] = strconv.Itoa(n)
+		return n
+
+	case "KEYS":
+		keys := make([]string, 0, len(s.data))
+		for k := range s.data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return keys
+
+	case "GETALL":
+		if e.resp3 {
+			return s.data
+		}
+		var kv []string
+		for k, v := range s.data {
+			kv = append(kv, k, v)
+		}
+		return kv
+	}
+
+	return errors.New("ERR unknown command '" + cmd[0] + "'")
+}
+
+func TestConnDo(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+
+	c := s.dial(t)
+	defer c.Close()
+
+	tests := []struct {
+		cmd   []interface{}
+		reply interface{}
+	}{
+		{[]interface{}{"PING"}, "PONG"},
+		{[]interface{}{"SET", "key", 42}, "OK"},
+		{[]interface{}{"GET", "key"}, []byte("42")},
+		{[]interface{}{"GET", "nope"}, nil},
+		{[]interface{}{"INCR", "key"}, int64(43)},
+	}
+
+	for _, test := range tests {
+		reply, err := c.Do(test.cmd...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(reply, test.reply) {
+			t.Errorf("%v: bad reply: %#v", test.cmd, reply)
+		}
+	}
+}
+
+func TestConnErrorReply(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+
+	c := s.dial(t)
+	defer c.Close()
+
+	_, err := c.Do("NOPE")
+
+	e, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("expected a *resp.Error but got %#v", err)
+	}
+
+	if e.Type() != "ERR" {
+		t.Error("bad error type:", e.Type())
+	}
+
+	// The connection must remain usable after an error reply.
+	if reply, err := c.Do("PING"); err != nil || reply != "PONG" {
+		t.Errorf("bad reply after error: %#v (%v)", reply, err)
+	}
+}
+
+func TestConnPipeline(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+
+	c := s.dial(t)
+	defer c.Close()
+
+	for i := 0; i != 100; i++ {
+		if err := c.Send("INCR", "counter"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := c.Send("NOPE"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Send("SET", "other", "value"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i != 100; i++ {
+		var n int
+
+		if err := c.Receive(&n); err != nil {
+			t.Fatal(err)
+		}
+
+		if n != i+1 {
+			t.Errorf("bad reply #%d: %d", i, n)
+		}
+	}
+
+	if err := c.Receive(nil); err == nil {
+		t.Error("expected an error reply")
+	}
+
+	if err := c.Receive(nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConnTypedReplies(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+
+	c := s.dial(t)
+	defer c.Close()
+
+	c.Send("SET", "a", "1")
+	c.Send("SET", "b", "2")
+	c.Send("KEYS")
+	c.Send("KEYS")
+	c.Send("GETALL")
+	c.Send("KEYS")
+
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Receive(nil)
+	c.Receive(nil)
+
+	var keys []string
+
+	if err := c.Receive(&keys); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(keys, []string{"a", "b"}) {
+		t.Errorf("bad keys: %#v", keys)
+	}
+
+	var array [2]string
+
+	if err := c.Receive(&array); err != nil {
+		t.Fatal(err)
+	}
+
+	if array != [2]string{"a", "b"} {
+		t.Errorf("bad array: %#v", array)
+	}
+
+	var m map[string]int
+
+	if err := c.Receive(&m); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(m, map[string]int{"a": 1, "b": 2}) {
+		t.Errorf("bad map: %#v", m)
+	}
+
+	var v interface{}
+
+	if err := c.Receive(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(v, []interface{}{"a", "b"}) {
+		t.Errorf("bad value: %#v", v)
+	}
+}
+
+func TestConnHello(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+
+	c := s.dial(t)
+	defer c.Close()
+
+	if _, err := c.Hello(4); err == nil {
+		t.Error("expected an error when negotiating an unsupported version")
+	}
+
+	info, err := c.Hello(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(info, map[interface{}]interface{}{"server": "fake", "proto": int64(3)}) {
+		t.Errorf("bad server info: %#v", info)
+	}
+
+	if _, err := c.Do("SET", "k", "v"); err != nil {
+		t.Fatal(err)
+	}
+
+	var m map[string]string
+
+	c.Send("GETALL")
+	c.Flush()
+
+	if err := c.Receive(&m); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(m, map[string]string{"k": "v"}) {
+		t.Errorf("bad map: %#v", m)
+	}
+
+	if reply, err := c.Do("GET", "k"); err != nil || reply != "v" {
+		t.Errorf("bad reply: %#v (%v)", reply, err)
+	}
+}
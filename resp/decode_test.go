@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/dolab/objconv"
+	"github.com/dolab/objconv/objtests"
 )
 
 var respDecodeTests = []struct {
@@ -117,3 +118,13 @@ func BenchmarkDecoder(b *testing.B) {
 		})
 	}
 }
+
+func TestDisallowUnknownFields(t *testing.T) {
+	// RESP2 has no map type, maps are written as arrays of keys and values
+	// which cannot be decoded into structs.
+	objtests.TestDisallowUnknownFields(t, RESP3Codec)
+}
+
+func TestRawValue(t *testing.T) {
+	objtests.TestRawValue(t, Codec)
+}
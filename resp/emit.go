@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"math"
 	"strconv"
 	"strings"
 	"sync"
@@ -17,6 +18,10 @@ var (
 	nullBytes  = [...]byte{'$', '-', '1', '\r', '\n'}
 	trueBytes  = [...]byte{'+', 't', 'r', 'u', 'e', '\r', '\n'}
 	falseBytes = [...]byte{'+', 'f', 'a', 'l', 's', 'e', '\r', '\n'}
+
+	null3Bytes  = [...]byte{'_', '\r', '\n'}
+	true3Bytes  = [...]byte{'#', 't', '\r', '\n'}
+	false3Bytes = [...]byte{'#', 'f', '\r', '\n'}
 )
 
 // Emitter implements a RESP emitter that satisfies the objconv.Emitter
@@ -40,6 +45,10 @@ type Emitter struct {
 	// sback is used as the initial backing array for the stack slice to avoid
 	// dynamic memory allocations for the most common use cases.
 	sback [8]*context
+
+	// When resp3 is set the emitter uses the types introduced by RESP3, maps
+	// are written with the map type instead of being flattened into arrays.
+	resp3 bool
 }
 
 type context struct {
@@ -73,11 +82,24 @@ func (e *Emitter) Reset(w io.Writer) {
 }
 
 func (e *Emitter) EmitNil() (err error) {
-	_, err = e.w.Write(nullBytes[:])
+	if e.resp3 {
+		_, err = e.w.Write(null3Bytes[:])
+	} else {
+		_, err = e.w.Write(nullBytes[:])
+	}
 	return
 }
 
 func (e *Emitter) EmitBool(v bool) (err error) {
+	if e.resp3 {
+		if v {
+			_, err = e.w.Write(true3Bytes[:])
+		} else {
+			_, err = e.w.Write(false3Bytes[:])
+		}
+		return
+	}
+
 	if v {
 		_, err = e.w.Write(trueBytes[:])
 	} else {
@@ -100,6 +122,9 @@ func (e *Emitter) EmitInt(v int64, _ int) (err error) {
 
 func (e *Emitter) EmitUint(v uint64, _ int) (err error) {
 	if v > objutil.Int64Max {
+		if e.resp3 {
+			return e.emitBigNumber(strconv.FormatUint(v, 10))
+		}
 		return fmt.Errorf("objconv/resp: %d overflows the maximum integer value of %d", v, objutil.Int64Max)
 	}
 
@@ -117,8 +142,46 @@ func (e *Emitter) EmitUint(v uint64, _ int) (err error) {
 func (e *Emitter) EmitFloat(v float64, bitSize int) (err error) {
 	s := e.s[:0]
 
-	s = append(s, '+')
-	s = appendFloat(s, v, bitSize)
+	if e.resp3 {
+		s = append(s, ',')
+		s = appendDouble(s, v, bitSize)
+	} else {
+		s = append(s, '+')
+		s = appendFloat(s, v, bitSize)
+	}
+	s = appendCRLF(s)
+
+	e.s = s[:0]
+	_, err = e.w.Write(s)
+	return
+}
+
+// EmitNumber writes the number literal v, RESP3 emitters write integers which
+// don't fit in 64 bits as big numbers.
+func (e *Emitter) EmitNumber(v string) (err error) {
+	if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return e.EmitInt(i, 64)
+	}
+
+	if isInteger(v) {
+		if e.resp3 {
+			return e.emitBigNumber(v)
+		}
+		if u, err := strconv.ParseUint(v, 10, 64); err == nil {
+			return e.EmitUint(u, 64)
+		}
+	} else if f, err := strconv.ParseFloat(v, 64); err == nil {
+		return e.EmitFloat(f, 64)
+	}
+
+	return e.EmitString(v)
+}
+
+func (e *Emitter) emitBigNumber(v string) (err error) {
+	s := e.s[:0]
+
+	s = append(s, '(')
+	s = append(s, v...)
 	s = appendCRLF(s)
 
 	e.s = s[:0]
@@ -205,6 +268,17 @@ func (e *Emitter) EmitError(v error) (err error) {
 	s := e.s[:0]
 
 	if i := indexCRLF(x); i >= 0 {
+		if e.resp3 { // blob errors can carry multiple lines
+			s = append(s, '!')
+			s = appendUint(s, uint64(len(x)))
+			s = appendCRLF(s)
+			s = append(s, x...)
+			s = appendCRLF(s)
+
+			e.s = s[:0]
+			_, err = e.w.Write(s)
+			return
+		}
 		x = x[:i] // only keep the first line
 	}
 
@@ -227,7 +301,7 @@ func (e *Emitter) EmitArrayBegin(n int) (err error) {
 		c.w = e.w
 		e.w = &c.b
 	} else {
-		err = e.emitArray(n)
+		err = e.emitArray('*', n)
 	}
 
 	e.stack = append(e.stack, c)
@@ -246,7 +320,7 @@ func (e *Emitter) EmitArrayEnd() (err error) {
 			c.n++
 		}
 
-		if err = e.emitArray(c.n); err == nil {
+		if err = e.emitArray('*', c.n); err == nil {
 			_, err = c.b.WriteTo(c.w)
 		}
 
@@ -264,14 +338,54 @@ func (e *Emitter) EmitArrayNext() (err error) {
 }
 
 func (e *Emitter) EmitMapBegin(n int) (err error) {
-	return e.emitArray(n + n)
+	if !e.resp3 {
+		return e.emitArray('*', n+n)
+	}
+
+	var c *context
+
+	if n < 0 {
+		c = contextPool.Get().(*context)
+		c.b.Truncate(0)
+		c.n = 0
+		c.w = e.w
+		e.w = &c.b
+	} else {
+		err = e.emitArray('%', n)
+	}
+
+	e.stack = append(e.stack, c)
+	return
 }
 
 func (e *Emitter) EmitMapEnd() (err error) {
+	if !e.resp3 {
+		return
+	}
+
+	i := len(e.stack) - 1
+	c := e.stack[i]
+	e.stack = e.stack[:i]
+
+	if c != nil {
+		e.w = c.w
+
+		if err = e.emitArray('%', c.n); err == nil {
+			_, err = c.b.WriteTo(c.w)
+		}
+
+		contextPool.Put(c)
+	}
+
 	return
 }
 
 func (e *Emitter) EmitMapValue() (err error) {
+	if e.resp3 {
+		if c := e.stack[len(e.stack)-1]; c != nil {
+			c.n++
+		}
+	}
 	return
 }
 
@@ -279,10 +393,26 @@ func (e *Emitter) EmitMapNext() (err error) {
 	return
 }
 
-func (e *Emitter) emitArray(n int) (err error) {
+// EmitRaw writes b to the output, it must contain a value serialized in the
+// RESP format.
+func (e *Emitter) EmitRaw(b []byte) (err error) {
+	_, err = e.w.Write(b)
+	return
+}
+
+// RawFormat returns "resp3" if the emitter uses the types introduced by RESP3,
+// or "resp" otherwise.
+func (e *Emitter) RawFormat() string {
+	if e.resp3 {
+		return "resp3"
+	}
+	return "resp"
+}
+
+func (e *Emitter) emitArray(t byte, n int) (err error) {
 	s := e.s[:0]
 
-	s = append(s, '*')
+	s = append(s, t)
 	s = appendUint(s, uint64(n))
 	s = appendCRLF(s)
 
@@ -303,6 +433,35 @@ func appendFloat(b []byte, v float64, bitSize int) []byte {
 	return strconv.AppendFloat(b, v, 'g', -1, bitSize)
 }
 
+// appendDouble formats v as a RESP3 double, which spells infinities and NaN
+// in lower case.
+func appendDouble(b []byte, v float64, bitSize int) []byte {
+	switch {
+	case math.IsInf(v, 1):
+		return append(b, "inf"...)
+	case math.IsInf(v, -1):
+		return append(b, "-inf"...)
+	case math.IsNaN(v):
+		return append(b, "nan"...)
+	}
+	return appendFloat(b, v, bitSize)
+}
+
+func isInteger(s string) bool {
+	if len(s) != 0 && s[0] == '-' {
+		s = s[1:]
+	}
+	if len(s) == 0 {
+		return false
+	}
+	for i := 0; i != len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
 func appendCRLF(b []byte) []byte {
 	return append(b, '\r', '\n')
 }
@@ -372,6 +531,10 @@ func (e *ClientEmitter) EmitFloat(v float64, bitSize int) error {
 	return e.EmitBytes(b)
 }
 
+func (e *ClientEmitter) EmitNumber(v string) error {
+	return e.EmitString(v)
+}
+
 func (e *ClientEmitter) EmitString(v string) (err error) {
 	s := e.s[:0]
 
@@ -1,7 +1,10 @@
 package resp
 
 import (
+	"fmt"
+	"reflect"
 	"strings"
+	"time"
 	"unicode"
 )
 
@@ -39,3 +42,42 @@ func (e *Error) Type() string {
 
 	return s
 }
+
+// ArgumentError is returned by Command.Decode when an argument of a command
+// cannot be decoded into the struct field that receives it.
+//
+// The error message is a short error reply which is safe to send to clients,
+// the detailed error returned by the decoder is kept in Err so servers can log
+// it.
+type ArgumentError struct {
+	Command string       // name of the command
+	Field   string       // name of the struct field receiving the argument
+	Type    reflect.Type // type that the argument was decoded into
+	Err     error        // error returned by the decoder
+}
+
+// Error satisfies the error interface.
+func (e *ArgumentError) Error() string {
+	if e.Type == reflect.TypeOf(time.Duration(0)) {
+		return "ERR value is not a valid duration"
+	}
+
+	switch e.Type.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "ERR value is not an integer or out of range"
+
+	case reflect.Float32, reflect.Float64:
+		return "ERR value is not a valid float"
+
+	case reflect.Bool:
+		return "ERR value is not a valid boolean"
+	}
+
+	return fmt.Sprintf("ERR invalid %s argument for '%s' command", e.Field, e.Command)
+}
+
+// Unwrap returns the error returned by the decoder.
+func (e *ArgumentError) Unwrap() error {
+	return e.Err
+}
@@ -12,6 +12,12 @@ var Codec = objconv.Codec{
 	NewParser:  func(r io.Reader) objconv.Parser { return NewParser(r) },
 }
 
+// RESP3Codec for the RESP3 format.
+var RESP3Codec = objconv.Codec{
+	NewEmitter: func(w io.Writer) objconv.Emitter { return NewRESP3Emitter(w) },
+	NewParser:  func(r io.Reader) objconv.Parser { return NewRESP3Parser(r) },
+}
+
 func init() {
 	for _, name := range [...]string{
 		"application/resp",
@@ -20,4 +26,11 @@ func init() {
 	} {
 		objconv.Register(name, Codec)
 	}
+
+	for _, name := range [...]string{
+		"application/resp3",
+		"resp3",
+	} {
+		objconv.Register(name, RESP3Codec)
+	}
 }
@@ -5,6 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math/big"
+	"reflect"
+	"strconv"
 	"time"
 
 	"github.com/dolab/objconv"
@@ -13,6 +16,8 @@ import (
 
 var (
 	null = [...]byte{'-', '1'}
+
+	bigIntPtrType = reflect.TypeOf((*big.Int)(nil))
 )
 
 type Parser struct {
@@ -22,6 +27,16 @@ type Parser struct {
 	s []byte    // buffer used for building strings
 	a [128]byte // initial backend array for s
 	b [128]byte // buffer where bytes are loaded from the reader
+
+	raw bool   // set when the consumed bytes are being recorded
+	ri  int    // offset in s of the first byte to record
+	rb  []byte // buffer of recorded bytes
+
+	// When resp3 is set the parser accepts the types introduced by RESP3,
+	// attrs holds the attributes of the value found by the last call to
+	// ParseType.
+	resp3 bool
+	attrs map[interface{}]interface{}
 }
 
 func NewParser(r io.Reader) *Parser {
@@ -32,6 +47,8 @@ func (p *Parser) Reset(r io.Reader) {
 	p.r = r
 	p.n = 0
 	p.s = nil
+	p.raw = false
+	p.attrs = nil
 }
 
 func (p *Parser) Buffered() io.Reader {
@@ -41,44 +58,122 @@ func (p *Parser) Buffered() io.Reader {
 func (p *Parser) ParseType() (t objconv.Type, err error) {
 	var line []byte
 
-	if line, err = p.peekLine(); err != nil {
-		return
+	if p.i == 0 { // a new value starts, forget the attributes of the previous one
+		p.attrs = nil
 	}
 
-	if len(line) == 0 {
-		err = errors.New("objconv/resp: invalid empty line at the beginning of the stream")
+	for {
+		if line, err = p.peekLine(); err != nil {
+			return
+		}
+
+		if len(line) == 0 {
+			err = errors.New("objconv/resp: invalid empty line at the beginning of the stream")
+			return
+		}
+
+		switch line[0] {
+		case '+':
+			t = objconv.String
+
+		case '-':
+			t = objconv.Error
+
+		case ':':
+			t = objconv.Int
+
+		case '$':
+			if bytes.Equal(line[1:], null[:]) {
+				t = objconv.Nil
+			} else if p.resp3 {
+				// Bulk strings are the regular strings of RESP3, they are
+				// used as the keys of maps.
+				t = objconv.String
+			} else {
+				t = objconv.Bytes
+			}
+
+		case '*':
+			if bytes.Equal(line[1:], null[:]) {
+				t = objconv.Nil
+			} else {
+				t = objconv.Array
+			}
+
+		default:
+			if !p.resp3 {
+				err = fmt.Errorf("objconv/resp: expected type token but found %#v", string(line))
+				return
+			}
+
+			switch line[0] {
+			case '_':
+				t = objconv.Nil
+
+			case '#':
+				t = objconv.Bool
+
+			case ',':
+				t = objconv.Float
+
+			case '(':
+				if len(line) > 1 && line[1] == '-' {
+					t = objconv.Int
+				} else {
+					t = objconv.Uint
+				}
+
+			case '=':
+				t = objconv.String
+
+			case '!':
+				t = objconv.Error
+
+			case '%':
+				t = objconv.Map
+
+			case '~', '>':
+				t = objconv.Array
+
+			case '|':
+				if err = p.parseAttributes(); err != nil {
+					return
+				}
+				continue
+
+			default:
+				err = fmt.Errorf("objconv/resp: expected type token but found %#v", string(line))
+			}
+		}
+
 		return
 	}
+}
 
-	switch line[0] {
-	case '+':
-		t = objconv.String
+// DynamicType returns the type of *big.Int for RESP3 big numbers that don't fit
+// in 64 bits integers, nil is returned for all other values.
+func (p *Parser) DynamicType() reflect.Type {
+	if !p.resp3 {
+		return nil
+	}
 
-	case '-':
-		t = objconv.Error
+	line, err := p.peekLine()
 
-	case ':':
-		t = objconv.Int
+	if err != nil || len(line) < 2 || line[0] != '(' {
+		return nil
+	}
 
-	case '$':
-		if bytes.Equal(line[1:], null[:]) {
-			t = objconv.Nil
-		} else {
-			t = objconv.Bytes
+	if line[1] == '-' {
+		if _, err = objutil.ParseInt(line[1:]); err != nil {
+			return bigIntPtrType
 		}
-
-	case '*':
-		if bytes.Equal(line[1:], null[:]) {
-			t = objconv.Nil
-		} else {
-			t = objconv.Array
+	} else {
+		if _, err = strconv.ParseUint(string(line[1:]), 10, 64); err != nil {
+			return bigIntPtrType
 		}
-
-	default:
-		err = fmt.Errorf("objconv/resp: expected type token but found %#v", string(line))
 	}
 
-	return
+	return nil
 }
 
 func (p *Parser) ParseNil() (err error) {
@@ -95,11 +190,16 @@ func (p *Parser) ParseNil() (err error) {
 
 	switch line[0] {
 	case '$', '*':
-	default:
-		goto failure
-	}
+		if !bytes.Equal(line[1:], null[:]) {
+			goto failure
+		}
+
+	case '_':
+		if !p.resp3 || len(line) != 1 {
+			goto failure
+		}
 
-	if !bytes.Equal(line[1:], null[:]) {
+	default:
 		goto failure
 	}
 
@@ -111,7 +211,24 @@ failure:
 }
 
 func (p *Parser) ParseBool() (v bool, err error) {
-	panic("objconv/resp: ParseBool should never be called because RESP has no boolean type, this is likely a bug in the decoder code")
+	var line []byte
+
+	if line, err = p.peekLine(); err != nil {
+		return
+	}
+
+	switch {
+	case p.resp3 && string(line) == "#t":
+		v = true
+	case p.resp3 && string(line) == "#f":
+		v = false
+	default:
+		err = fmt.Errorf("objconv/resp: expected boolean value but found %#v", string(line))
+		return
+	}
+
+	p.skipLine()
+	return
 }
 
 func (p *Parser) ParseInt() (v int64, err error) {
@@ -126,7 +243,7 @@ func (p *Parser) ParseInt() (v int64, err error) {
 		return
 	}
 
-	if line[0] != ':' {
+	if line[0] != ':' && (line[0] != '(' || !p.resp3) {
 		goto failure
 	}
 
@@ -142,11 +259,78 @@ failure:
 }
 
 func (p *Parser) ParseUint() (v uint64, err error) {
-	panic("objconv/resp: ParseBool should never be called because RESP has no unsigned integer type, this is likely a bug in the decoder code")
+	var line []byte
+
+	if line, err = p.peekLine(); err != nil {
+		return
+	}
+
+	if len(line) == 0 || (line[0] != ':' && (line[0] != '(' || !p.resp3)) {
+		err = fmt.Errorf("objconv/resp: expected integer value but found %#v", string(line))
+		return
+	}
+
+	if v, err = strconv.ParseUint(string(line[1:]), 10, 64); err != nil {
+		err = fmt.Errorf("objconv/resp: invalid unsigned integer value %#v", string(line))
+		return
+	}
+
+	p.skipLine()
+	return
 }
 
 func (p *Parser) ParseFloat() (v float64, err error) {
-	panic("objconv/resp: ParseFloat should never be called because RESP has no floating point type, this is likely a bug in the decoder code")
+	var line []byte
+
+	if line, err = p.peekLine(); err != nil {
+		return
+	}
+
+	if !p.resp3 || len(line) == 0 || line[0] != ',' {
+		err = fmt.Errorf("objconv/resp: expected double value but found %#v", string(line))
+		return
+	}
+
+	// strconv.ParseFloat accepts the inf, -inf and nan spellings of RESP3.
+	if v, err = strconv.ParseFloat(string(line[1:]), 64); err != nil {
+		err = fmt.Errorf("objconv/resp: invalid double value %#v", string(line))
+		return
+	}
+
+	p.skipLine()
+	return
+}
+
+// ParseNumber returns the literal text of the integer, big number or double
+// found by the last call to ParseType.
+func (p *Parser) ParseNumber() (v []byte, err error) {
+	var line []byte
+
+	if line, err = p.peekLine(); err != nil {
+		return
+	}
+
+	if len(line) == 0 {
+		err = errors.New("objconv/resp: invalid empty line at the beginning of a number value")
+		return
+	}
+
+	switch line[0] {
+	case ':':
+	case '(', ',':
+		if !p.resp3 {
+			goto failure
+		}
+	default:
+		goto failure
+	}
+
+	v = line[1:]
+	p.skipLine()
+	return
+failure:
+	err = fmt.Errorf("objconv/resp: expected number value but found %#v", string(line))
+	return
 }
 
 func (p *Parser) ParseString() (v []byte, err error) {
@@ -161,6 +345,21 @@ func (p *Parser) ParseString() (v []byte, err error) {
 		return
 	}
 
+	if p.resp3 {
+		switch line[0] {
+		case '$':
+			return p.parseChunk(line)
+
+		case '=':
+			// Verbatim strings start with the three letters of their
+			// format followed by a colon.
+			if v, err = p.parseChunk(line); err == nil && len(v) >= 4 && v[3] == ':' {
+				v = v[4:]
+			}
+			return
+		}
+	}
+
 	if line[0] != '+' {
 		goto failure
 	}
@@ -175,7 +374,6 @@ failure:
 
 func (p *Parser) ParseBytes() (v []byte, err error) {
 	var line []byte
-	var size int64
 
 	if line, err = p.peekLine(); err != nil {
 		return
@@ -187,11 +385,21 @@ func (p *Parser) ParseBytes() (v []byte, err error) {
 	}
 
 	if line[0] != '$' {
-		goto failure
+		err = fmt.Errorf("objconv/resp: expected bulk string value but found %#v", string(line))
+		return
 	}
 
+	return p.parseChunk(line)
+}
+
+// parseChunk parses the length-prefixed value starting with line, which must
+// be the line found by the last call to peekLine.
+func (p *Parser) parseChunk(line []byte) (v []byte, err error) {
+	var size int64
+
 	if size, err = objutil.ParseInt(line[1:]); err != nil || size < 0 || size > int64(objutil.IntMax) {
-		goto failure
+		err = fmt.Errorf("objconv/resp: expected bulk string value but found %#v", string(line))
+		return
 	}
 	p.skipLine()
 
@@ -200,9 +408,6 @@ func (p *Parser) ParseBytes() (v []byte, err error) {
 	}
 	p.n += len(v) + 2
 	return
-failure:
-	err = fmt.Errorf("objconv/resp: expected bulk string value but found %#v", string(line))
-	return
 }
 
 func (p *Parser) ParseTime() (v time.Time, err error) {
@@ -225,6 +430,14 @@ func (p *Parser) ParseError() (v error, err error) {
 		return
 	}
 
+	if p.resp3 && line[0] == '!' {
+		var b []byte
+		if b, err = p.parseChunk(line); err == nil {
+			v = NewError(string(b))
+		}
+		return
+	}
+
 	if line[0] != '-' {
 		goto failure
 	}
@@ -250,7 +463,13 @@ func (p *Parser) ParseArrayBegin() (n int, err error) {
 		return
 	}
 
-	if line[0] != '*' {
+	switch line[0] {
+	case '*':
+	case '~', '>':
+		if !p.resp3 {
+			goto failure
+		}
+	default:
 		goto failure
 	}
 
@@ -275,19 +494,133 @@ func (p *Parser) ParseArrayNext(n int) (err error) {
 }
 
 func (p *Parser) ParseMapBegin() (n int, err error) {
-	panic("objconv/resp: ParseMapBegin should never be called because RESP has no map type, this is likely a bug in the decoder code")
+	var line []byte
+	var size int64
+
+	if line, err = p.peekLine(); err != nil {
+		return
+	}
+
+	if !p.resp3 || len(line) == 0 || (line[0] != '%' && line[0] != '|') {
+		goto failure
+	}
+
+	if size, err = objutil.ParseInt(line[1:]); err != nil || size < 0 || size > int64(objutil.IntMax) {
+		goto failure
+	}
+
+	p.skipLine()
+	n = int(size)
+	return
+failure:
+	err = fmt.Errorf("objconv/resp: expected map value but found %#v", string(line))
+	return
 }
 
 func (p *Parser) ParseMapEnd(n int) (err error) {
-	panic("objconv/resp: ParseMapEnd should never be called because RESP has no map type, this is likely a bug in the decoder code")
+	return
 }
 
 func (p *Parser) ParseMapValue(n int) (err error) {
-	panic("objconv/resp: ParseMapValue should never be called because RESP has no map type, this is likely a bug in the decoder code")
+	return
 }
 
 func (p *Parser) ParseMapNext(n int) (err error) {
-	panic("objconv/resp: ParseMapNext should never be called because RESP has no map type, this is likely a bug in the decoder code")
+	return
+}
+
+// Skip consumes the value found by the last call to ParseType without loading
+// it, the parser jumps over the lines of simple values and the chunks of bulk
+// values, and counts the elements of aggregates that remain to be skipped.
+func (p *Parser) Skip() (err error) {
+	for n := 1; n != 0; n-- {
+		var line []byte
+		var size int64
+
+		if line, err = p.peekLine(); err != nil {
+			return
+		}
+
+		if len(line) == 0 {
+			return errors.New("objconv/resp: invalid empty line at the beginning of a value")
+		}
+
+		c := line[0]
+
+		switch c {
+		case '+', '-', ':':
+		case '_', '#', ',', '(':
+			if !p.resp3 {
+				return fmt.Errorf("objconv/resp: expected type token but found %#v", string(line))
+			}
+		case '$', '*', '=', '!', '%', '~', '>', '|':
+			if (c == '$' || c == '*') && bytes.Equal(line[1:], null[:]) {
+				size = -1
+			} else if size, err = objutil.ParseInt(line[1:]); err != nil || size < 0 || size > int64(objutil.IntMax/2) {
+				return fmt.Errorf("objconv/resp: invalid length in %#v", string(line))
+			}
+			if c != '$' && c != '*' && !p.resp3 {
+				return fmt.Errorf("objconv/resp: expected type token but found %#v", string(line))
+			}
+		default:
+			return fmt.Errorf("objconv/resp: expected type token but found %#v", string(line))
+		}
+
+		p.skipLine()
+
+		switch c {
+		case '$', '=', '!':
+			if size >= 0 {
+				if _, err = p.peekChunk(int(size)); err != nil {
+					return
+				}
+				p.n += int(size) + 2
+			}
+		case '*', '~', '>':
+			if size >= 0 {
+				n += int(size)
+			}
+		case '%':
+			n += 2 * int(size)
+		case '|': // attributes are followed by the value they describe
+			n += 2*int(size) + 1
+		}
+	}
+	return
+}
+
+// parseAttributes loads the attributes that precede a value in RESP3.
+func (p *Parser) parseAttributes() (err error) {
+	var n int
+
+	if n, err = p.ParseMapBegin(); err != nil {
+		return
+	}
+
+	d := objconv.Decoder{Parser: p}
+	a := make(map[interface{}]interface{}, n)
+
+	for i := 0; i != n; i++ {
+		var k, v interface{}
+
+		if err = d.Decode(&k); err != nil {
+			return
+		}
+
+		if k != nil && !reflect.TypeOf(k).Comparable() {
+			err = fmt.Errorf("objconv/resp: unsupported attribute key of type %T", k)
+			return
+		}
+
+		if err = d.Decode(&v); err != nil {
+			return
+		}
+
+		a[k] = v
+	}
+
+	p.attrs = a
+	return
 }
 
 func (p *Parser) peekLine() (line []byte, err error) {
@@ -307,6 +640,10 @@ func (p *Parser) peekLine() (line []byte, err error) {
 		}
 
 		if p.n != 0 { // pack
+			if p.raw {
+				p.rb = append(p.rb, p.s[p.ri:p.n]...)
+				p.ri = 0
+			}
 			copy(p.s, p.s[p.n:])
 			p.s = p.s[:len(p.s)-p.n]
 			p.n = 0
@@ -357,6 +694,31 @@ func (p *Parser) peekChunk(size int) (chunk []byte, err error) {
 	return
 }
 
+// BeginRaw starts recording the bytes consumed by the parser.
+func (p *Parser) BeginRaw() error {
+	p.raw = true
+	p.ri = p.n
+	p.rb = p.rb[:0]
+	return nil
+}
+
+// EndRaw stops recording and returns the bytes consumed since BeginRaw was
+// called.
+func (p *Parser) EndRaw() []byte {
+	p.rb = append(p.rb, p.s[p.ri:p.n]...)
+	p.raw = false
+	return p.rb
+}
+
+// RawFormat returns "resp3" if the parser accepts the types introduced by
+// RESP3, or "resp" otherwise.
+func (p *Parser) RawFormat() string {
+	if p.resp3 {
+		return "resp3"
+	}
+	return "resp"
+}
+
 func (p *Parser) skipLine() {
 	p.n, p.i = p.i, 0
 }
@@ -0,0 +1,53 @@
+package resp
+
+import "io"
+
+// NewRESP3Emitter returns an emitter which writes values with the types
+// introduced by RESP3: nulls, booleans, doubles and big numbers are written
+// with their own types instead of strings, maps are written as maps instead
+// of arrays of keys and values, and multi-line errors as blob errors.
+func NewRESP3Emitter(w io.Writer) *Emitter {
+	e := NewEmitter(w)
+	e.resp3 = true
+	return e
+}
+
+// NewRESP3Parser returns a parser which accepts the types introduced by RESP3,
+// in addition to the RESP2 types.
+//
+// Bulk strings are parsed as strings, sets and pushes as arrays, verbatim
+// strings as strings (without their format prefix), blob errors as errors, and big numbers as integers
+// which can be decoded into math/big values. The attributes which precede a
+// value are not decoded with the value, they are exposed by the Attributes
+// method.
+func NewRESP3Parser(r io.Reader) *Parser {
+	p := NewParser(r)
+	p.resp3 = true
+	return p
+}
+
+// SetProtocol configures the version of the protocol used by the emitter, 3
+// for RESP3 or 2 for RESP2.
+//
+// Connections start with RESP2 and switch to RESP3 after a successful HELLO 3
+// command, the emitter of the server must switch right after writing the
+// reply to the command.
+func (e *Emitter) SetProtocol(version int) {
+	e.resp3 = version >= 3
+}
+
+// SetProtocol configures the version of the protocol used by the parser, 3 for
+// RESP3 or 2 for RESP2.
+//
+// Connections start with RESP2 and switch to RESP3 after a successful HELLO 3
+// command, the parser of the client must switch before reading the reply to
+// the command, which is a RESP3 map.
+func (p *Parser) SetProtocol(version int) {
+	p.resp3 = version >= 3
+}
+
+// Attributes returns the RESP3 attributes that preceded the value found by the
+// last call to ParseType, or nil if the value had no attributes.
+func (p *Parser) Attributes() map[interface{}]interface{} {
+	return p.attrs
+}
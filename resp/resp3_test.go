@@ -0,0 +1,295 @@
+package resp
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dolab/objconv"
+)
+
+var resp3EncodeTests = []struct {
+	v interface{}
+	s string
+}{
+	{nil, "_\r\n"},
+
+	{true, "#t\r\n"},
+	{false, "#f\r\n"},
+
+	{42, ":42\r\n"},
+	{uint64(math.MaxUint64), "(18446744073709551615\r\n"},
+	{objconv.Number("-123456789012345678901234567890"), "(-123456789012345678901234567890\r\n"},
+
+	{0.5, ",0.5\r\n"},
+	{math.Inf(1), ",inf\r\n"},
+	{math.Inf(-1), ",-inf\r\n"},
+	{math.NaN(), ",nan\r\n"},
+
+	{"Hello World!", "+Hello World!\r\n"},
+	{[]byte("Hello World!"), "$12\r\nHello World!\r\n"},
+
+	{errors.New("ERR oops"), "-ERR oops\r\n"},
+	{errors.New("A\r\nB"), "!4\r\nA\r\nB\r\n"},
+
+	{time.Second, "+1s\r\n"},
+
+	{[]int{1, 2}, "*2\r\n:1\r\n:2\r\n"},
+	{map[string]int{"A": 1}, "%1\r\n+A\r\n:1\r\n"},
+	{struct{ A, B int }{1, 2}, "%2\r\n+A\r\n:1\r\n+B\r\n:2\r\n"},
+}
+
+func TestRESP3Emitter(t *testing.T) {
+	for _, test := range resp3EncodeTests {
+		t.Run(testName(test.s), func(t *testing.T) {
+			var b bytes.Buffer
+
+			if err := objconv.NewEncoder(NewRESP3Emitter(&b)).Encode(test.v); err != nil {
+				t.Fatal(err)
+			}
+
+			if s := b.String(); s != test.s {
+				t.Errorf("%#v", s)
+			}
+		})
+	}
+}
+
+func TestRESP3StreamMap(t *testing.T) {
+	var b bytes.Buffer
+
+	e := NewRESP3Emitter(&b)
+	m := objconv.NewEncoder(e)
+
+	i := 0
+
+	if err := m.EncodeMap(-1, func(k objconv.Encoder, v objconv.Encoder) (err error) {
+		if i++; i > 2 {
+			return objconv.End
+		}
+		if err = k.Encode([]string{"A", "B"}[i-1]); err == nil {
+			err = v.Encode(i)
+		}
+		return
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := b.String(); s != "%2\r\n+A\r\n:1\r\n+B\r\n:2\r\n" {
+		t.Errorf("%#v", s)
+	}
+}
+
+var resp3DecodeTests = []struct {
+	v interface{}
+	s string
+	t objconv.Type
+}{
+	{nil, "_\r\n", objconv.Nil},
+	{nil, "$-1\r\n", objconv.Nil},
+
+	{true, "#t\r\n", objconv.Bool},
+	{false, "#f\r\n", objconv.Bool},
+
+	{int64(42), ":42\r\n", objconv.Int},
+	{int64(-42), "(-42\r\n", objconv.Int},
+	{uint64(math.MaxUint64), "(18446744073709551615\r\n", objconv.Uint},
+
+	{0.5, ",0.5\r\n", objconv.Float},
+	{1e10, ",1e10\r\n", objconv.Float},
+	{math.Inf(1), ",inf\r\n", objconv.Float},
+	{math.Inf(-1), ",-inf\r\n", objconv.Float},
+
+	{"Hello", "+Hello\r\n", objconv.String},
+	{"Some string", "=15\r\ntxt:Some string\r\n", objconv.String},
+	{"Hello", "$5\r\nHello\r\n", objconv.String},
+
+	{NewError("ERR oops"), "-ERR oops\r\n", objconv.Error},
+	{NewError("SYNTAX invalid\r\nsyntax"), "!22\r\nSYNTAX invalid\r\nsyntax\r\n", objconv.Error},
+
+	{[]interface{}{int64(1), "a"}, "*2\r\n:1\r\n+a\r\n", objconv.Array},
+	{[]interface{}{int64(1), int64(2)}, "~2\r\n:1\r\n:2\r\n", objconv.Array},
+	{[]interface{}{"message", "chan", "hi"}, ">3\r\n+message\r\n+chan\r\n+hi\r\n", objconv.Array},
+	{map[interface{}]interface{}{"a": int64(1), int64(2): true}, "%2\r\n+a\r\n:1\r\n:2\r\n#t\r\n", objconv.Map},
+
+	{int64(42), "|1\r\n+ttl\r\n:3600\r\n:42\r\n", objconv.Int},
+}
+
+func TestRESP3Parser(t *testing.T) {
+	for _, test := range resp3DecodeTests {
+		t.Run(testName(test.s), func(t *testing.T) {
+			typ, err := NewRESP3Parser(strings.NewReader(test.s)).ParseType()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if typ != test.t {
+				t.Errorf("expected %s but got %s", test.t, typ)
+			}
+
+			var v interface{}
+
+			if err := objconv.NewDecoder(NewRESP3Parser(strings.NewReader(test.s))).Decode(&v); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(v, test.v) && !(test.t == objconv.Float && v == test.v) {
+				t.Errorf("%#v", v)
+			}
+		})
+	}
+}
+
+func TestRESP3Skip(t *testing.T) {
+	for _, test := range resp3DecodeTests {
+		t.Run(testName(test.s), func(t *testing.T) {
+			var s string
+
+			d := objconv.NewDecoder(NewRESP3Parser(strings.NewReader(test.s + "+next\r\n")))
+
+			if err := d.Decode(nil); err != nil {
+				t.Error(err)
+				return
+			}
+
+			if err := d.Decode(&s); err != nil {
+				t.Error(err)
+				return
+			}
+
+			if s != "next" {
+				t.Errorf("bad value after the skipped one: %#v", s)
+			}
+		})
+	}
+}
+
+func TestRESP3BigNumber(t *testing.T) {
+	var x big.Int
+
+	p := NewRESP3Parser(strings.NewReader("(-123456789012345678901234567890\r\n"))
+
+	if err := objconv.NewDecoder(p).Decode(&x); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := x.String(); s != "-123456789012345678901234567890" {
+		t.Error(s)
+	}
+}
+
+func TestRESP3BigNumberInterface(t *testing.T) {
+	for _, s := range []string{
+		"3492890328409238509324850943850943825024385",
+		"-3492890328409238509324850943850943825024385",
+		"18446744073709551616",
+		"-9223372036854775809",
+	} {
+		t.Run(s, func(t *testing.T) {
+			var v interface{}
+
+			d := objconv.NewDecoder(NewRESP3Parser(strings.NewReader("(" + s + "\r\n+next\r\n")))
+
+			if err := d.Decode(&v); err != nil {
+				t.Fatal(err)
+			}
+
+			if x, ok := v.(*big.Int); !ok {
+				t.Errorf("expected a *big.Int but got %#v", v)
+			} else if x.String() != s {
+				t.Errorf("bad big number: %s", x)
+			}
+
+			// The parser must be left at the next value.
+			if err := d.Decode(&v); err != nil {
+				t.Fatal(err)
+			}
+
+			if v != "next" {
+				t.Errorf("bad value after the big number: %#v", v)
+			}
+		})
+	}
+}
+
+func TestRESP3Attributes(t *testing.T) {
+	p := NewRESP3Parser(strings.NewReader("|1\r\n+key-popularity\r\n%1\r\n$1\r\na\r\n,0.1923\r\n*2\r\n:1\r\n:2\r\n"))
+
+	if _, err := p.ParseType(); err != nil {
+		t.Fatal(err)
+	}
+
+	attrs := p.Attributes()
+
+	if !reflect.DeepEqual(attrs, map[interface{}]interface{}{
+		"key-popularity": map[interface{}]interface{}{"a": 0.1923},
+	}) {
+		t.Errorf("bad attributes: %#v", attrs)
+	}
+
+	var v []int
+
+	if err := objconv.NewDecoder(p).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(v, []int{1, 2}) {
+		t.Errorf("bad value: %#v", v)
+	}
+
+	if p.Attributes() != nil {
+		t.Error("attributes must not be carried to the next values")
+	}
+}
+
+func TestSetProtocol(t *testing.T) {
+	var b bytes.Buffer
+
+	e := NewEmitter(&b)
+	p := NewParser(&b)
+
+	if err := e.EmitBool(true); err != nil {
+		t.Fatal(err)
+	}
+
+	e.SetProtocol(3)
+
+	if err := e.EmitBool(true); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := b.String(); s != "+true\r\n#t\r\n" {
+		t.Fatalf("%#v", s)
+	}
+
+	if _, err := p.ParseString(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.ParseType(); err == nil {
+		t.Error("expected an error when parsing a RESP3 boolean in RESP2 mode")
+	}
+
+	p.SetProtocol(3)
+
+	if v, err := p.ParseBool(); err != nil || !v {
+		t.Errorf("bad boolean: %v (%v)", v, err)
+	}
+}
+
+func TestRESP3BulkStringToBytes(t *testing.T) {
+	var b []byte
+
+	if err := objconv.NewDecoder(NewRESP3Parser(strings.NewReader("$5\r\nHello\r\n"))).Decode(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "Hello" {
+		t.Errorf("%#v", b)
+	}
+}
@@ -0,0 +1,174 @@
+package resp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/dolab/objconv"
+)
+
+// A Handler responds to the commands received by a Server.
+//
+// ServeRESP returns the reply to the command, which is encoded with a RESP
+// emitter. A non-nil error is written as an error reply instead, errors which
+// aren't *Error values get the generic ERR type.
+type Handler interface {
+	ServeRESP(cmd *Command) (interface{}, error)
+}
+
+// The HandlerFunc type is an adapter to allow the use of ordinary functions as
+// command handlers.
+type HandlerFunc func(cmd *Command) (interface{}, error)
+
+// ServeRESP calls f(cmd).
+func (f HandlerFunc) ServeRESP(cmd *Command) (interface{}, error) {
+	return f(cmd)
+}
+
+// ServeMux is a command multiplexer, it dispatches commands to the handlers
+// registered for their names, which are case insensitive.
+type ServeMux struct {
+	mutex    sync.RWMutex
+	handlers map[string]Handler
+}
+
+// NewServeMux allocates and returns a new ServeMux.
+func NewServeMux() *ServeMux {
+	return &ServeMux{handlers: make(map[string]Handler)}
+}
+
+// Handle registers the handler for the command name.
+func (mux *ServeMux) Handle(name string, handler Handler) {
+	mux.mutex.Lock()
+	mux.handlers[strings.ToUpper(name)] = handler
+	mux.mutex.Unlock()
+}
+
+// HandleFunc registers the handler function for the command name.
+func (mux *ServeMux) HandleFunc(name string, handler func(*Command) (interface{}, error)) {
+	mux.Handle(name, HandlerFunc(handler))
+}
+
+// ServeRESP dispatches cmd to the handler registered for its name, or replies
+// with an error if there are none.
+func (mux *ServeMux) ServeRESP(cmd *Command) (interface{}, error) {
+	mux.mutex.RLock()
+	h := mux.handlers[strings.ToUpper(cmd.Name)]
+	mux.mutex.RUnlock()
+
+	if h == nil {
+		return nil, fmt.Errorf("ERR unknown command '%s'", cmd.Name)
+	}
+
+	return h.ServeRESP(cmd)
+}
+
+// Server serves redis clients, reading their commands with a CommandReader and
+// writing the replies of its handler with a RESP emitter.
+//
+// Commands of a connection are served one at a time, in order. The replies are
+// buffered while the client has more pipelined commands waiting to be read, and
+// flushed when the input buffer is empty or the reply buffer is full. A client
+// which doesn't read its replies blocks the server, which stops reading its
+// commands, so the memory used by a connection is bounded whatever the size of
+// the pipelines.
+//
+// When the handler accepts a HELLO command whose first argument is the version
+// 2 or 3 of the protocol, the server switches the connection to the version
+// before writing the reply.
+type Server struct {
+	// Handler to invoke on the commands.
+	Handler Handler
+
+	// WriteBufferSize is the size of the buffer where the replies are written
+	// before being flushed to the connection, 4096 bytes when zero.
+	WriteBufferSize int
+}
+
+// Serve accepts connections on l and serves them in new goroutines, it returns
+// the error returned by l when accepting a connection fails.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			defer conn.Close()
+			s.ServeConn(conn)
+		}()
+	}
+}
+
+// ServeConn serves the commands read from conn until the input reaches its end,
+// which is reported as a nil error, or until an error occurs.
+//
+// Protocol errors are reported to the client with an error reply before the
+// method returns.
+func (s *Server) ServeConn(conn io.ReadWriter) (err error) {
+	size := s.WriteBufferSize
+
+	if size == 0 {
+		size = 4096
+	}
+
+	r := NewCommandReader(conn)
+	w := bufio.NewWriterSize(conn, size)
+	e := NewEmitter(w)
+	enc := objconv.NewEncoder(e)
+
+	for {
+		var cmd Command
+		var reply interface{}
+
+		if cmd, err = r.ReadCommand(); err != nil {
+			if err == io.EOF {
+				err = nil
+			} else if _, ok := err.(*net.OpError); !ok {
+				enc.Encode(replyError(err))
+			}
+			w.Flush()
+			return
+		}
+
+		if reply, err = s.Handler.ServeRESP(&cmd); err != nil {
+			reply = replyError(err)
+		} else if strings.EqualFold(cmd.Name, "HELLO") && len(cmd.Args) != 0 {
+			switch string(cmd.Args[0]) {
+			case "2":
+				e.SetProtocol(2)
+			case "3":
+				e.SetProtocol(3)
+			}
+		}
+
+		if err = enc.Encode(reply); err != nil {
+			return
+		}
+
+		if r.Buffered() == 0 {
+			if err = w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func replyError(err error) *Error {
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+
+	e := NewError(err.Error())
+
+	if e.Type() == "" {
+		e = NewError("ERR " + err.Error())
+	}
+
+	return e
+}
@@ -0,0 +1,417 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCommandReader(t *testing.T) {
+	tests := []struct {
+		in   string
+		cmds []Command
+	}{
+		{
+			in:   "*1\r\n$4\r\nPING\r\n",
+			cmds: []Command{{Name: "PING", Args: [][]byte{}}},
+		},
+		{
+			in: "*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$5\r\nhello\r\n*2\r\n$3\r\nGET\r\n$1\r\nk\r\n",
+			cmds: []Command{
+				{Name: "SET", Args: [][]byte{[]byte("k"), []byte("hello")}},
+				{Name: "GET", Args: [][]byte{[]byte("k")}},
+			},
+		},
+		{
+			in:   "PING\r\n",
+			cmds: []Command{{Name: "PING", Args: [][]byte{}}},
+		},
+		{
+			in: "\r\n  SET k  \"a b\\\"c\" 'd e'\r\n*0\r\nGET k\r\n",
+			cmds: []Command{
+				{Name: "SET", Args: [][]byte{[]byte("k"), []byte("a b\"c"), []byte("d e")}},
+				{Name: "GET", Args: [][]byte{[]byte("k")}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(strings.Fields(test.in)[0], func(t *testing.T) {
+			r := NewCommandReader(strings.NewReader(test.in))
+
+			for _, cmd := range test.cmds {
+				c, err := r.ReadCommand()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(c, cmd) {
+					t.Errorf("bad command:\n- expected: %#v\n- found:    %#v", cmd, c)
+				}
+			}
+
+			if _, err := r.ReadCommand(); err != io.EOF {
+				t.Error("expected io.EOF but got", err)
+			}
+		})
+	}
+}
+
+func TestCommandReaderErrors(t *testing.T) {
+	tests := []struct {
+		in  string
+		err string
+	}{
+		{"*2\r\n$3\r\nGET\r\n", io.ErrUnexpectedEOF.Error()},
+		{"SET k \"value\r\n", "ERR Protocol error: unbalanced quotes in request"},
+	}
+
+	for _, test := range tests {
+		r := NewCommandReader(strings.NewReader(test.in))
+
+		if _, err := r.ReadCommand(); err == nil || err.Error() != test.err {
+			t.Errorf("%q: bad error: %v", test.in, err)
+		}
+	}
+}
+
+func TestCommandDecode(t *testing.T) {
+	type set struct {
+		Key   string
+		Value []byte
+		TTL   time.Duration `objconv:",omitempty"`
+	}
+
+	type mset struct {
+		Count  int
+		secret int
+		Skip   string `objconv:"-"`
+		Values []float64
+	}
+
+	args := func(s ...string) [][]byte {
+		a := make([][]byte, len(s))
+		for i := range s {
+			a[i] = []byte(s[i])
+		}
+		return a
+	}
+
+	var s set
+
+	if err := (&Command{Name: "SET", Args: args("k", "v", "1s")}).Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(s, set{Key: "k", Value: []byte("v"), TTL: time.Second}) {
+		t.Errorf("bad arguments: %#v", s)
+	}
+
+	s = set{}
+
+	if err := (&Command{Name: "SET", Args: args("k", "v")}).Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(s, set{Key: "k", Value: []byte("v")}) {
+		t.Errorf("bad arguments: %#v", s)
+	}
+
+	var m mset
+
+	if err := (&Command{Name: "MSET", Args: args("3", "1", "2.5", "-3")}).Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(m, mset{Count: 3, Values: []float64{1, 2.5, -3}}) {
+		t.Errorf("bad arguments: %#v", m)
+	}
+
+	errs := []struct {
+		cmd Command
+		v   interface{}
+		err string
+	}{
+		{Command{Name: "set", Args: args("k")}, &set{}, "ERR wrong number of arguments for 'set' command"},
+		{Command{Name: "set", Args: args("k", "v", "1s", "x")}, &set{}, "ERR wrong number of arguments for 'set' command"},
+		{Command{Name: "set"}, set{}, "objconv/resp: cannot decode the arguments of set into a value of type resp.set"},
+	}
+
+	for _, test := range errs {
+		if err := test.cmd.Decode(test.v); err == nil || !strings.HasPrefix(err.Error(), test.err) {
+			t.Errorf("%s %q: bad error: %v", test.cmd.Name, test.cmd.Args, err)
+		}
+	}
+
+	argErrs := []struct {
+		cmd   Command
+		v     interface{}
+		field string
+		err   string
+	}{
+		{Command{Name: "mset", Args: args("x")}, &mset{}, "Count", "ERR value is not an integer or out of range"},
+		{Command{Name: "mset", Args: args("1", "x")}, &mset{}, "Values", "ERR value is not a valid float"},
+		{Command{Name: "set", Args: args("k", "v", "x")}, &set{}, "TTL", "ERR value is not a valid duration"},
+		{Command{Name: "ping", Args: args("x")}, &struct{ Addr net.IP }{}, "Addr", "ERR invalid Addr argument for 'ping' command"},
+	}
+
+	for _, test := range argErrs {
+		err := test.cmd.Decode(test.v)
+
+		// The reply doesn't carry the details of the error, which are only
+		// available to the server.
+		if e, ok := err.(*ArgumentError); !ok || e.Error() != test.err || e.Field != test.field || e.Err == nil {
+			t.Errorf("%s %q: bad error: %#v", test.cmd.Name, test.cmd.Args, err)
+		}
+	}
+}
+
+func newTestServer(t *testing.T) (*Server, net.Listener) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mutex := sync.Mutex{}
+	data := map[string][]byte{}
+	mux := NewServeMux()
+
+	mux.HandleFunc("PING", func(cmd *Command) (interface{}, error) {
+		return "PONG", nil
+	})
+
+	mux.HandleFunc("HELLO", func(cmd *Command) (interface{}, error) {
+		var args struct{ Version int }
+
+		if err := cmd.Decode(&args); err != nil {
+			return nil, err
+		}
+
+		if args.Version != 2 && args.Version != 3 {
+			return nil, NewError("NOPROTO unsupported protocol version")
+		}
+
+		return map[string]interface{}{"server": "test", "proto": args.Version}, nil
+	})
+
+	mux.HandleFunc("SET", func(cmd *Command) (interface{}, error) {
+		var args struct {
+			Key   string
+			Value []byte
+		}
+
+		if err := cmd.Decode(&args); err != nil {
+			return nil, err
+		}
+
+		mutex.Lock()
+		data[args.Key] = args.Value
+		mutex.Unlock()
+		return "OK", nil
+	})
+
+	mux.HandleFunc("GET", func(cmd *Command) (interface{}, error) {
+		var args struct{ Key string }
+
+		if err := cmd.Decode(&args); err != nil {
+			return nil, err
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if v, ok := data[args.Key]; ok {
+			return v, nil
+		}
+		return nil, nil
+	})
+
+	mux.HandleFunc("SUM", func(cmd *Command) (interface{}, error) {
+		var args struct{ Values []int }
+		var sum int
+
+		if err := cmd.Decode(&args); err != nil {
+			return nil, err
+		}
+
+		for _, v := range args.Values {
+			sum += v
+		}
+		return sum, nil
+	})
+
+	s := &Server{Handler: mux}
+	go s.Serve(l)
+	return s, l
+}
+
+func dialTestServer(t *testing.T, l net.Listener) *Conn {
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewConn(conn)
+}
+
+func TestServer(t *testing.T) {
+	_, l := newTestServer(t)
+	defer l.Close()
+
+	c := dialTestServer(t, l)
+	defer c.Close()
+
+	tests := []struct {
+		cmd   []interface{}
+		reply interface{}
+	}{
+		{[]interface{}{"ping"}, "PONG"},
+		{[]interface{}{"SET", "key", "value"}, "OK"},
+		{[]interface{}{"GET", "key"}, []byte("value")},
+		{[]interface{}{"GET", "nope"}, nil},
+		{[]interface{}{"SUM", 1, 2, 3, 4}, int64(10)},
+		{[]interface{}{"SUM"}, int64(0)},
+	}
+
+	for _, test := range tests {
+		reply, err := c.Do(test.cmd...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(reply, test.reply) {
+			t.Errorf("%v: bad reply: %#v", test.cmd, reply)
+		}
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	_, l := newTestServer(t)
+	defer l.Close()
+
+	c := dialTestServer(t, l)
+	defer c.Close()
+
+	tests := []struct {
+		cmd []interface{}
+		err string
+	}{
+		{[]interface{}{"NOPE"}, "ERR unknown command 'NOPE'"},
+		{[]interface{}{"GET"}, "ERR wrong number of arguments for 'GET' command"},
+		{[]interface{}{"SUM", 1, "x"}, "ERR value is not an integer or out of range"},
+		{[]interface{}{"HELLO", 4}, "NOPROTO unsupported protocol version"},
+	}
+
+	for _, test := range tests {
+		_, err := c.Do(test.cmd...)
+
+		if e, ok := err.(*Error); !ok || !strings.HasPrefix(e.Error(), test.err) {
+			t.Errorf("%v: bad error: %#v", test.cmd, err)
+		}
+	}
+
+	if reply, err := c.Do("PING"); err != nil || reply != "PONG" {
+		t.Errorf("bad reply after errors: %#v (%v)", reply, err)
+	}
+}
+
+func TestServerPipeline(t *testing.T) {
+	_, l := newTestServer(t)
+	defer l.Close()
+
+	c := dialTestServer(t, l)
+	defer c.Close()
+
+	const n = 10000
+
+	// The pipeline is larger than the buffers of the connection, commands are
+	// sent concurrently so the server blocks on writing replies until they are
+	// received.
+	go func() {
+		for i := 0; i != n; i++ {
+			c.Send("SUM", i, 1)
+		}
+		c.Flush()
+	}()
+
+	for i := 0; i != n; i++ {
+		var v int
+
+		if err := c.Receive(&v); err != nil {
+			t.Fatal(err)
+		}
+
+		if v != i+1 {
+			t.Fatalf("bad reply #%d: %d", i, v)
+		}
+	}
+}
+
+func TestServerInline(t *testing.T) {
+	_, l := newTestServer(t)
+	defer l.Close()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := io.WriteString(conn, "PING\r\nSET k \"hello world\"\r\nGET k\r\nSUM 1 2\r\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	r := bufio.NewReader(conn)
+	expect := "+PONG\r\n+OK\r\n$11\r\nhello world\r\n:3\r\n"
+	found := make([]byte, len(expect))
+
+	if _, err := io.ReadFull(r, found); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(found) != expect {
+		t.Errorf("bad replies: %q", found)
+	}
+}
+
+func TestServerHello(t *testing.T) {
+	_, l := newTestServer(t)
+	defer l.Close()
+
+	c := dialTestServer(t, l)
+	defer c.Close()
+
+	info, err := c.Hello(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(info, map[interface{}]interface{}{"server": "test", "proto": int64(3)}) {
+		t.Errorf("bad server info: %#v", info)
+	}
+
+	if reply, err := c.Do("GET", "nope"); err != nil || reply != nil {
+		t.Errorf("bad reply: %#v (%v)", reply, err)
+	}
+}
+
+func TestServeConnProtocolError(t *testing.T) {
+	b := &bytes.Buffer{}
+	s := &Server{Handler: NewServeMux()}
+
+	err := s.ServeConn(struct {
+		io.Reader
+		io.Writer
+	}{strings.NewReader("GET \"k\r\n"), b})
+
+	if err == nil {
+		t.Error("expected a protocol error")
+	}
+
+	if b.String() != "-ERR Protocol error: unbalanced quotes in request\r\n" {
+		t.Errorf("bad reply: %q", b.String())
+	}
+}
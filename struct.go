@@ -1,8 +1,12 @@
 package objconv
 
 import (
+	"fmt"
 	"reflect"
+	"sort"
 	"sync"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/dolab/objconv/objutil"
 )
@@ -10,12 +14,21 @@ import (
 // structField represents a single field of a struct and carries information
 // useful to the algorithms of the objconv package.
 type structField struct {
-	// The index of the field in the structure.
+	// The index of the field in the structure, fields promoted from embedded
+	// structs have an index made of multiple values.
 	index []int
 
 	// The name of the field in the structure.
 	name string
 
+	// Tagged is set to true when the field name was set from a struct tag.
+	tagged bool
+
+	// Fold is set to true when the field name can be matched case-insensitively
+	// by default, which is the case of fields without an `objconv` tag to stay
+	// compatible with the standard encoding/json package.
+	fold bool
+
 	// Omitempty is set to true when the field should be omitted if it has an
 	// empty value.
 	omitempty bool
@@ -24,32 +37,26 @@ type structField struct {
 	// value.
 	omitzero bool
 
+	// Number of the field set by the `proto` tag option, zero if the field has
+	// no number.
+	number int
+
 	// cache for the encoder and decoder methods
 	encode encodeFunc
 	decode decodeFunc
 }
 
 func makeStructField(f reflect.StructField, c map[reflect.Type]*structType) structField {
-	var t objutil.Tag
-
-	if tag := f.Tag.Get("objconv"); len(tag) != 0 {
-		t = objutil.ParseTag(tag)
-	} else {
-		// To maximize compatibility with existing code we fallback to checking
-		// if the field has a `json` tag.
-		//
-		// This tag doesn't support any of the extra features that are supported
-		// by the `objconv` tag, and it should stay this way. It has to match
-		// the behavior of the standard encoding/json package to avoid any
-		// implicit changes in what would be intuitively expected.
-		t = objutil.ParseTagJSON(f.Tag.Get("json"))
-	}
+	t := parseStructTag(f)
 
 	s := structField{
 		index:     f.Index,
 		name:      f.Name,
+		tagged:    len(t.Name) != 0,
+		fold:      len(f.Tag.Get("objconv")) == 0,
 		omitempty: t.Omitempty,
 		omitzero:  t.Omitzero,
+		number:    t.Proto,
 
 		encode: makeEncodeFunc(f.Type, encodeFuncOpts{
 			recurse: true,
@@ -66,19 +73,122 @@ func makeStructField(f reflect.StructField, c map[reflect.Type]*structType) stru
 		s.name = t.Name
 	}
 
+	if t.AsString {
+		switch {
+		case canEncodeAsString(f.Type):
+			s.encode = Encoder.encodeAsString
+			s.decode = Decoder.decodeFromString
+
+		case f.Type.Kind() == reflect.Ptr && !f.Type.Implements(valueEncoderInterface) && canEncodeAsString(f.Type.Elem()):
+			// Like encoding/json, the option also applies to pointers to
+			// booleans and numbers, which are used for optional values.
+			s.encode = func(e Encoder, v reflect.Value) error {
+				return e.encodePointerWith(v, Encoder.encodeAsString)
+			}
+			s.decode = func(d Decoder, v reflect.Value) (Type, error) {
+				return d.decodePointerWith(v, Decoder.decodeFromString)
+			}
+		}
+	}
+
 	return s
 }
 
+// canEncodeAsString returns true if values of type t can be serialized as
+// strings when the field has the `string` tag option, which only applies to
+// booleans and numbers like in the standard encoding/json package.
+func canEncodeAsString(t reflect.Type) bool {
+	if _, ok := AdapterOf(t); ok || hasScopedAdapters(t) {
+		return false
+	}
+
+	if t == durationType || t.Implements(valueEncoderInterface) || reflect.PtrTo(t).Implements(valueDecoderInterface) {
+		return false
+	}
+
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return false
+}
+
+func parseStructTag(f reflect.StructField) objutil.Tag {
+	if tag := f.Tag.Get("objconv"); len(tag) != 0 {
+		return objutil.ParseTag(tag)
+	}
+
+	// To maximize compatibility with existing code we fallback to checking if
+	// the field has a `json` tag.
+	//
+	// This tag doesn't support any of the extra features that are supported by
+	// the `objconv` tag, and it should stay this way. It has to match the
+	// behavior of the standard encoding/json package to avoid any implicit
+	// changes in what would be intuitively expected.
+	return objutil.ParseTagJSON(f.Tag.Get("json"))
+}
+
 func (f *structField) omit(v reflect.Value) bool {
 	return (f.omitempty && objutil.IsEmptyValue(v)) || (f.omitzero && objutil.IsZeroValue(v))
 }
 
+// value returns the value of the field in the struct value v.
+//
+// Fields promoted from embedded pointers may not be reachable if one of the
+// pointers is nil, in which case the method returns an invalid value.
+func (f *structField) value(v reflect.Value) reflect.Value {
+	if len(f.index) == 1 {
+		return v.Field(f.index[0])
+	}
+
+	for i, x := range f.index {
+		if i != 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+
+	return v
+}
+
+// valueAlloc is similar to value but allocates the nil embedded pointers that
+// it goes through, it is used when decoding values into the struct v.
+func (f *structField) valueAlloc(v reflect.Value) (reflect.Value, error) {
+	if len(f.index) == 1 {
+		return v.Field(f.index[0]), nil
+	}
+
+	for i, x := range f.index {
+		if i != 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, fmt.Errorf("objconv: cannot set embedded pointer to unexported struct %s", v.Type().Elem())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+
+	return v, nil
+}
+
 // structType is used to represent a Go structure in internal data structures
 // that cache meta information to make field lookups faster and avoid having to
 // use reflection to lookup the same type information over and over again.
 type structType struct {
-	fields       []structField           // the serializable fields of the struct
-	fieldsByName map[string]*structField // cache of fields by name
+	fields       []structField             // the serializable fields of the struct
+	fieldsByName map[string]*structField   // cache of fields by name
+	fieldsByFold map[string][]*structField // cache of fields by case-folded name
+	fieldsByNum  map[int]*structField      // cache of fields by number
 }
 
 // newStructType takes a Go type as argument and extract information to make a
@@ -89,31 +199,248 @@ func newStructType(t reflect.Type, c map[reflect.Type]*structType) *structType {
 		return s
 	}
 
-	n := t.NumField()
 	s := &structType{
-		fields:       make([]structField, 0, n),
 		fieldsByName: make(map[string]*structField),
+		fieldsByFold: make(map[string][]*structField),
+		fieldsByNum:  make(map[int]*structField),
 	}
 	c[t] = s
 
-	for i := 0; i != n; i++ {
-		ft := t.Field(i)
+	fields := structFieldsOf(t)
+	s.fields = make([]structField, 0, len(fields))
 
-		if ft.Anonymous || len(ft.PkgPath) != 0 { // anonymous or non-exported
-			continue
+	for _, f := range fields {
+		sf := makeStructField(f, c)
+		s.fields = append(s.fields, sf)
+	}
+
+	for i := range s.fields {
+		f := &s.fields[i]
+		s.fieldsByName[f.name] = f
+
+		// Fields are kept in order so when multiple names fold to the same key
+		// the first one that can be matched wins, like in encoding/json.
+		k := string(appendFold(nil, []byte(f.name)))
+		s.fieldsByFold[k] = append(s.fieldsByFold[k], f)
+
+		if f.number != 0 && s.fieldsByNum[f.number] == nil {
+			s.fieldsByNum[f.number] = f
 		}
+	}
 
-		sf := makeStructField(ft, c)
+	return s
+}
 
-		if sf.name == "-" { // skip
+// lookup returns the field matching name according to m, or nil if there is
+// no such field in the struct. A field whose name is exactly equal always
+// takes priority over fields that only match after case-folding.
+func (s *structType) lookup(name []byte, m FieldMatch) *structField {
+	if f := s.fieldsByName[string(name)]; f != nil {
+		return f
+	}
+
+	if m == ExactFieldMatch {
+		return nil
+	}
+
+	var a [64]byte
+
+	for _, f := range s.fieldsByFold[string(appendFold(a[:0], name))] {
+		if f.fold || m == FoldFieldMatch {
+			return f
+		}
+	}
+
+	return nil
+}
+
+// appendFold appends the case-folded version of s to b, two names are equal
+// under simple unicode case-folding if their folded versions are equal.
+func appendFold(b []byte, s []byte) []byte {
+	for i := 0; i != len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if 'A' <= c && c <= 'Z' {
+				c += 'a' - 'A'
+			}
+			b = append(b, c)
+			i++
 			continue
 		}
 
-		s.fields = append(s.fields, sf)
-		s.fieldsByName[sf.name] = &s.fields[len(s.fields)-1]
+		r, n := utf8.DecodeRune(s[i:])
+		r = unicode.ToLower(unicode.ToUpper(r))
+		i += n
+
+		var a [utf8.UTFMax]byte
+		b = append(b, a[:utf8.EncodeRune(a[:], r)]...)
 	}
+	return b
+}
 
-	return s
+// structFieldsOf returns the list of serializable fields of the struct type t,
+// applying the Go rules of embedding to promote the fields of anonymous structs
+// the same way that the standard encoding/json package does.
+//
+// Embedded structs are walked breadth first, the name conflicts are resolved
+// by keeping the shallowest field, or the one that was tagged if there are
+// multiple fields at the same depth. Conflicts that cannot be resolved cause
+// all the fields with the conflicting name to be dropped.
+//
+// The Index of each field returned by the function is the full path to the
+// field from t.
+func structFieldsOf(t reflect.Type) []reflect.StructField {
+	type embedded struct {
+		typ   reflect.Type
+		index []int
+	}
+
+	var fields []reflect.StructField
+	var current []embedded
+	var next = []embedded{{typ: t}}
+
+	// Count of the number of times a type was queued at the current and next
+	// depths, this is used to detect ambiguous fields that must be dropped.
+	count := map[reflect.Type]int{}
+	nextCount := map[reflect.Type]int{}
+	visited := map[reflect.Type]bool{}
+
+	for len(next) != 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, map[reflect.Type]int{}
+
+		for _, e := range current {
+			if visited[e.typ] {
+				continue
+			}
+			visited[e.typ] = true
+
+			for i, n := 0, e.typ.NumField(); i != n; i++ {
+				f := e.typ.Field(i)
+				ft := f.Type
+
+				if ft.Name() == "" && ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+
+				if f.Anonymous {
+					// Unexported embedded structs are still walked because their
+					// exported fields get promoted.
+					if len(f.PkgPath) != 0 && ft.Kind() != reflect.Struct {
+						continue
+					}
+				} else if len(f.PkgPath) != 0 { // non-exported
+					continue
+				}
+
+				tag := parseStructTag(f)
+
+				if tag.Name == "-" { // skip
+					continue
+				}
+
+				index := make([]int, len(e.index)+1)
+				copy(index, e.index)
+				index[len(e.index)] = i
+
+				if len(tag.Name) != 0 || !f.Anonymous || ft.Kind() != reflect.Struct {
+					if f.Anonymous && len(f.PkgPath) != 0 { // unexported, untagged non-struct
+						continue
+					}
+
+					f.Index = index
+					fields = append(fields, f)
+
+					if count[e.typ] > 1 {
+						// The embedded type appeared multiple times at the same
+						// depth, adding the field twice guarantees that it gets
+						// annihilated by the conflict resolution.
+						fields = append(fields, f)
+					}
+					continue
+				}
+
+				if nextCount[ft]++; nextCount[ft] == 1 {
+					next = append(next, embedded{typ: ft, index: index})
+				}
+			}
+		}
+	}
+
+	sort.Slice(fields, func(i int, j int) bool {
+		n1, n2 := structFieldName(fields[i]), structFieldName(fields[j])
+		if n1 != n2 {
+			return n1 < n2
+		}
+		if d1, d2 := len(fields[i].Index), len(fields[j].Index); d1 != d2 {
+			return d1 < d2
+		}
+		if t1, t2 := structFieldTagged(fields[i]), structFieldTagged(fields[j]); t1 != t2 {
+			return t1
+		}
+		return lessIndex(fields[i].Index, fields[j].Index)
+	})
+
+	// Remove the fields that are hidden by Go's embedding rules, the list is
+	// sorted by name so fields with the same name are next to each others.
+	out := fields[:0]
+
+	for i, n := 0, 0; i < len(fields); i += n {
+		name := structFieldName(fields[i])
+
+		for n = 1; i+n < len(fields); n++ {
+			if structFieldName(fields[i+n]) != name {
+				break
+			}
+		}
+
+		if f, ok := dominantStructField(fields[i : i+n]); ok {
+			out = append(out, f)
+		}
+	}
+
+	fields = out
+	sort.Slice(fields, func(i int, j int) bool {
+		return lessIndex(fields[i].Index, fields[j].Index)
+	})
+	return fields
+}
+
+// dominantStructField looks through the fields, all of which are known to have
+// the same name, to find the single field that dominates the others using Go's
+// embedding rules, modified by the presence of struct tags.
+//
+// The fields are expected to be sorted by depth and then by tag presence.
+func dominantStructField(fields []reflect.StructField) (reflect.StructField, bool) {
+	if len(fields) > 1 {
+		f1, f2 := fields[0], fields[1]
+		if len(f1.Index) == len(f2.Index) && structFieldTagged(f1) == structFieldTagged(f2) {
+			return reflect.StructField{}, false
+		}
+	}
+	return fields[0], true
+}
+
+func structFieldName(f reflect.StructField) string {
+	if tag := parseStructTag(f); len(tag.Name) != 0 {
+		return tag.Name
+	}
+	return f.Name
+}
+
+func structFieldTagged(f reflect.StructField) bool {
+	return len(parseStructTag(f).Name) != 0
+}
+
+func lessIndex(i1 []int, i2 []int) bool {
+	for k, x := range i1 {
+		if k >= len(i2) {
+			return false
+		}
+		if x != i2[k] {
+			return x < i2[k]
+		}
+	}
+	return len(i1) < len(i2)
 }
 
 // structTypeCache is a simple cache for mapping Go types to Struct values.
@@ -20,6 +20,7 @@ func TestMakeStructField(t *testing.T) {
 			f: structField{
 				index: []int{0},
 				name:  "A",
+				fold:  true,
 			},
 		},
 
@@ -28,6 +29,7 @@ func TestMakeStructField(t *testing.T) {
 			f: structField{
 				index: []int{0},
 				name:  "a",
+				fold:  true,
 			},
 		},
 
@@ -36,6 +38,7 @@ func TestMakeStructField(t *testing.T) {
 			f: structField{
 				index: []int{0},
 				name:  "A",
+				fold:  true,
 			},
 		},
 
@@ -44,6 +47,7 @@ func TestMakeStructField(t *testing.T) {
 			f: structField{
 				index: []int{0},
 				name:  "a",
+				fold:  true,
 			},
 		},
 	}
@@ -60,3 +64,137 @@ func TestMakeStructField(t *testing.T) {
 		})
 	}
 }
+
+type Metadata struct {
+	ID   int
+	Name string
+}
+
+type Timestamps struct {
+	Created int
+	Updated int `objconv:"updated_at"`
+}
+
+type embedded struct {
+	Hidden int
+}
+
+type ambiguousA struct{ X int }
+type ambiguousB struct{ X int }
+
+type taggedX struct {
+	Y int `objconv:"X"`
+}
+
+func TestNewStructTypeEmbedded(t *testing.T) {
+	tests := []struct {
+		v     interface{}
+		names []string
+		index [][]int
+	}{
+		{
+			v: struct {
+				Metadata
+				*Timestamps
+				Value int
+			}{},
+			names: []string{"ID", "Name", "Created", "updated_at", "Value"},
+		},
+
+		{ // shallower fields hide the embedded ones
+			v: struct {
+				Metadata
+				Name string
+			}{},
+			names: []string{"ID", "Name"},
+		},
+
+		{ // exported fields of unexported embedded structs are promoted
+			v: struct {
+				embedded
+			}{},
+			names: []string{"Hidden"},
+		},
+
+		{ // tagged embedded structs are nested under their tag name
+			v: struct {
+				Metadata `objconv:"metadata"`
+			}{},
+			names: []string{"metadata"},
+		},
+
+		{ // ambiguous fields are dropped
+			v: struct {
+				ambiguousA
+				ambiguousB
+			}{},
+			names: []string{},
+		},
+
+		{ // ambiguous fields are dropped without affecting the other fields
+			v: struct {
+				ambiguousA
+				B struct {
+					X int `objconv:"X"`
+				}
+				ambiguousB
+			}{},
+			names: []string{"B"},
+		},
+
+		{ // tagged fields win over untagged fields at the same depth
+			v: struct {
+				ambiguousA
+				taggedX
+			}{},
+			names: []string{"X"},
+			index: [][]int{{1, 0}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			s := newStructType(reflect.TypeOf(test.v), map[reflect.Type]*structType{})
+			names := make([]string, 0, len(s.fields))
+
+			for _, f := range s.fields {
+				names = append(names, f.name)
+
+				if s.fieldsByName[f.name] == nil {
+					t.Errorf("missing field %s in the index by name", f.name)
+				}
+			}
+
+			if !reflect.DeepEqual(names, test.names) {
+				t.Errorf("%#v != %#v", names, test.names)
+			}
+
+			for i, index := range test.index {
+				if !reflect.DeepEqual(s.fields[i].index, index) {
+					t.Errorf("%s: %#v != %#v", s.fields[i].name, s.fields[i].index, index)
+				}
+			}
+		})
+	}
+}
+
+func TestAppendFold(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{"", ""},
+		{"userID", "userid"},
+		{"UserId", "userid"},
+		{"ÉTÉ", "été"},
+		{"K", "k"}, // Kelvin sign
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			if s := string(appendFold(nil, []byte(test.in))); s != test.out {
+				t.Errorf("%q != %q", s, test.out)
+			}
+		})
+	}
+}
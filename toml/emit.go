@@ -0,0 +1,405 @@
+package toml
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/dolab/objconv/objutil"
+)
+
+// Emitter implements a TOML emitter that satisfies the objconv.Emitter
+// interface.
+//
+// The document is built in memory and written when the top-level table is
+// complete, because the keys of a table must be written before its sub-tables.
+type Emitter struct {
+	w io.Writer
+	b []byte // buffer used to format the document
+	// The stack is used to keep track of the container being built by the
+	// emitter, which may be an arrayEmitter or tableEmitter.
+	stack []emitter
+}
+
+func NewEmitter(w io.Writer) *Emitter {
+	return &Emitter{w: w}
+}
+
+func (e *Emitter) Reset(w io.Writer) {
+	e.w = w
+	e.stack = e.stack[:0]
+}
+
+func (e *Emitter) EmitNil() error {
+	return e.emit(nil)
+}
+
+func (e *Emitter) EmitBool(v bool) error {
+	return e.emit(v)
+}
+
+func (e *Emitter) EmitInt(v int64, _ int) error {
+	return e.emit(v)
+}
+
+func (e *Emitter) EmitUint(v uint64, _ int) error {
+	if v > objutil.Int64Max {
+		return fmt.Errorf("objconv/toml: unsigned integer %d overflows the TOML integer type", v)
+	}
+	return e.emit(int64(v))
+}
+
+func (e *Emitter) EmitFloat(v float64, bitSize int) error {
+	if bitSize == 32 {
+		return e.emit(float32(v))
+	}
+	return e.emit(v)
+}
+
+func (e *Emitter) EmitString(v string) error {
+	return e.emit(v)
+}
+
+func (e *Emitter) EmitBytes(v []byte) error {
+	return e.emit(base64.StdEncoding.EncodeToString(v))
+}
+
+func (e *Emitter) EmitTime(v time.Time) error {
+	return e.emit(v)
+}
+
+func (e *Emitter) EmitDuration(v time.Duration) error {
+	return e.emit(v.String())
+}
+
+func (e *Emitter) EmitError(v error) error {
+	return e.emit(v.Error())
+}
+
+func (e *Emitter) EmitArrayBegin(_ int) (err error) {
+	e.push(&arrayEmitter{self: []interface{}{}})
+	return
+}
+
+func (e *Emitter) EmitArrayEnd() (err error) {
+	return e.emit(e.pop().value())
+}
+
+func (e *Emitter) EmitArrayNext() (err error) {
+	return
+}
+
+func (e *Emitter) EmitMapBegin(_ int) (err error) {
+	e.push(&tableEmitter{self: newTable(header)})
+	return
+}
+
+func (e *Emitter) EmitMapEnd() (err error) {
+	return e.emit(e.pop().value())
+}
+
+func (e *Emitter) EmitMapValue() (err error) {
+	return
+}
+
+func (e *Emitter) EmitMapNext() (err error) {
+	return
+}
+
+func (e *Emitter) TextEmitter() bool {
+	return true
+}
+
+func (e *Emitter) emit(v interface{}) (err error) {
+	if n := len(e.stack); n != 0 {
+		return e.stack[n-1].emit(v)
+	}
+
+	t, ok := v.(*table)
+	if !ok {
+		return errTopLevel
+	}
+
+	e.b = appendTable(e.b[:0], nil, t)
+	_, err = e.w.Write(e.b)
+	return
+}
+
+func (e *Emitter) push(v emitter) {
+	e.stack = append(e.stack, v)
+}
+
+func (e *Emitter) pop() emitter {
+	i := len(e.stack) - 1
+	v := e.stack[i]
+	e.stack = e.stack[:i]
+	return v
+}
+
+type emitter interface {
+	emit(interface{}) error
+	value() interface{}
+}
+
+type arrayEmitter struct {
+	self []interface{}
+}
+
+func (e *arrayEmitter) emit(v interface{}) error {
+	if v == nil {
+		return fmt.Errorf("objconv/toml: arrays cannot contain null values")
+	}
+	e.self = append(e.self, v)
+	return nil
+}
+
+func (e *arrayEmitter) value() interface{} {
+	return e.self
+}
+
+type tableEmitter struct {
+	self *table
+	key  string
+	val  bool
+}
+
+func (e *tableEmitter) emit(v interface{}) error {
+	if !e.val {
+		switch k := v.(type) {
+		case string:
+			e.key = k
+		case int64:
+			e.key = strconv.FormatInt(k, 10)
+		case bool:
+			e.key = strconv.FormatBool(k)
+		default:
+			return fmt.Errorf("objconv/toml: keys must be strings, found %T", v)
+		}
+		e.val = true
+		return nil
+	}
+
+	e.val = false
+
+	// TOML has no null values, keys that have no value are simply omitted.
+	if v == nil {
+		return nil
+	}
+
+	if _, exists := e.self.get(e.key); exists {
+		return fmt.Errorf("objconv/toml: duplicate key %q", e.key)
+	}
+
+	e.self.set(e.key, v)
+	return nil
+}
+
+func (e *tableEmitter) value() interface{} {
+	return e.self
+}
+
+// appendTable writes the keys of t that are neither tables nor arrays of tables
+// first, because all keys that follow a table header belong to that table.
+func appendTable(b []byte, path []string, t *table) []byte {
+	for _, it := range t.items {
+		if !isSection(it.value) {
+			b = appendKey(b, it.key)
+			b = append(b, " = "...)
+			b = appendValue(b, it.value)
+			b = append(b, '\n')
+		}
+	}
+
+	for _, it := range t.items {
+		if !isSection(it.value) {
+			continue
+		}
+
+		p := append(path[:len(path):len(path)], it.key)
+
+		switch v := it.value.(type) {
+		case *table:
+			// Headers of tables that only contain other tables are omitted,
+			// they are implicitly defined by the headers of their sub-tables.
+			if !onlySections(v) {
+				b = appendHeader(b, "[", p, "]")
+			}
+			b = appendTable(b, p, v)
+
+		case []interface{}:
+			for _, x := range v {
+				b = appendHeader(b, "[[", p, "]]")
+				b = appendTable(b, p, x.(*table))
+			}
+		}
+	}
+
+	return b
+}
+
+func appendHeader(b []byte, open string, path []string, close string) []byte {
+	if len(b) != 0 {
+		b = append(b, '\n')
+	}
+	b = append(b, open...)
+	b = append(b, keyString(path)...)
+	b = append(b, close...)
+	return append(b, '\n')
+}
+
+func appendValue(b []byte, v interface{}) []byte {
+	switch x := v.(type) {
+	case bool:
+		return strconv.AppendBool(b, x)
+
+	case int64:
+		return strconv.AppendInt(b, x, 10)
+
+	case float32:
+		return appendFloat(b, float64(x), 32)
+
+	case float64:
+		return appendFloat(b, x, 64)
+
+	case string:
+		return appendString(b, x)
+
+	case time.Time:
+		return appendTime(b, x)
+
+	case []interface{}:
+		b = append(b, '[')
+		for i, y := range x {
+			if i != 0 {
+				b = append(b, ", "...)
+			}
+			b = appendValue(b, y)
+		}
+		return append(b, ']')
+
+	case *table:
+		b = append(b, '{')
+		for i, it := range x.items {
+			if i != 0 {
+				b = append(b, ',')
+			}
+			b = append(b, ' ')
+			b = appendKey(b, it.key)
+			b = append(b, " = "...)
+			b = appendValue(b, it.value)
+		}
+		if len(x.items) != 0 {
+			b = append(b, ' ')
+		}
+		return append(b, '}')
+	}
+
+	panic(fmt.Sprintf("objconv/toml: unsupported value of type %T", v))
+}
+
+func appendFloat(b []byte, v float64, bitSize int) []byte {
+	switch {
+	case math.IsNaN(v):
+		return append(b, "nan"...)
+	case math.IsInf(v, +1):
+		return append(b, "inf"...)
+	case math.IsInf(v, -1):
+		return append(b, "-inf"...)
+	}
+
+	i := len(b)
+	b = strconv.AppendFloat(b, v, 'g', -1, bitSize)
+
+	// Floats must have a fractional part or an exponent to not be parsed as
+	// integers.
+	if bytes.IndexAny(b[i:], ".e") < 0 {
+		b = append(b, ".0"...)
+	}
+
+	return b
+}
+
+func appendTime(b []byte, v time.Time) []byte {
+	// Times on the first day of year 0 are local times, which is how they are
+	// represented when parsed.
+	if v.Year() == 0 && v.YearDay() == 1 {
+		return v.AppendFormat(b, "15:04:05.999999999")
+	}
+	return v.AppendFormat(b, time.RFC3339Nano)
+}
+
+func appendKey(b []byte, k string) []byte {
+	if len(k) == 0 {
+		return append(b, `""`...)
+	}
+
+	for i := 0; i < len(k); i++ {
+		if !isBare(k[i]) {
+			return appendString(b, k)
+		}
+	}
+
+	return append(b, k...)
+}
+
+func appendString(b []byte, s string) []byte {
+	const hex = "0123456789ABCDEF"
+
+	b = append(b, '"')
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\\':
+			b = append(b, '\\', c)
+		case '\b':
+			b = append(b, '\\', 'b')
+		case '\t':
+			b = append(b, '\\', 't')
+		case '\n':
+			b = append(b, '\\', 'n')
+		case '\f':
+			b = append(b, '\\', 'f')
+		case '\r':
+			b = append(b, '\\', 'r')
+		default:
+			if isControl(c) {
+				b = append(b, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xF])
+			} else {
+				b = append(b, c)
+			}
+		}
+	}
+
+	return append(b, '"')
+}
+
+// isSection returns true if v is written as a table or an array of tables
+// after the keys of its parent table.
+func isSection(v interface{}) bool {
+	switch x := v.(type) {
+	case *table:
+		return true
+
+	case []interface{}:
+		for _, y := range x {
+			if _, ok := y.(*table); !ok {
+				return false
+			}
+		}
+		return len(x) != 0
+	}
+	return false
+}
+
+func onlySections(t *table) bool {
+	for _, it := range t.items {
+		if !isSection(it.value) {
+			return false
+		}
+	}
+	return len(t.items) != 0
+}
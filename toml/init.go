@@ -0,0 +1,22 @@
+package toml
+
+import (
+	"io"
+
+	"github.com/dolab/objconv"
+)
+
+// Codec for the TOML format.
+var Codec = objconv.Codec{
+	NewEmitter: func(w io.Writer) objconv.Emitter { return NewEmitter(w) },
+	NewParser:  func(r io.Reader) objconv.Parser { return NewParser(r) },
+}
+
+func init() {
+	for _, name := range [...]string{
+		"application/toml",
+		"toml",
+	} {
+		objconv.Register(name, Codec)
+	}
+}
@@ -0,0 +1,1178 @@
+package toml
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dolab/objconv"
+)
+
+// Parser implements a TOML parser that satisfies the objconv.Parser interface.
+//
+// The whole document is loaded in memory before being parsed, because keys of
+// a table may be defined anywhere in the document.
+type Parser struct {
+	r io.Reader // reader to load bytes from
+	s []byte    // string buffer
+	// This stack is used to iterate over the arrays and tables that get loaded
+	// from the document.
+	stack []parser
+}
+
+func NewParser(r io.Reader) *Parser {
+	return &Parser{r: r}
+}
+
+func (p *Parser) Reset(r io.Reader) {
+	p.r = r
+	p.s = nil
+	p.stack = nil
+}
+
+func (p *Parser) Buffered() io.Reader {
+	return bytes.NewReader(nil)
+}
+
+func (p *Parser) ParseType() (typ objconv.Type, err error) {
+	if p.stack == nil {
+		var b []byte
+		var t *table
+
+		if b, err = ioutil.ReadAll(p.r); err != nil {
+			return
+		}
+		if t, err = parseDocument(b); err != nil {
+			return
+		}
+		p.push(newParser(t))
+	}
+
+	switch v := p.value(); v.(type) {
+	case bool:
+		typ = objconv.Bool
+
+	case int64:
+		typ = objconv.Int
+
+	case float64:
+		typ = objconv.Float
+
+	case string:
+		typ = objconv.String
+
+	case time.Time:
+		typ = objconv.Time
+
+	case *table:
+		typ = objconv.Map
+
+	case []interface{}, *tableArray:
+		typ = objconv.Array
+
+	case eof:
+		err = io.EOF
+
+	default:
+		err = fmt.Errorf("objconv/toml: unsupported value of type %T", v)
+	}
+
+	return
+}
+
+func (p *Parser) ParseNil() (err error) {
+	panic("objconv/toml: ParseNil should never be called because TOML has no null type, this is likely a bug in the decoder code")
+}
+
+func (p *Parser) ParseBool() (v bool, err error) {
+	v = p.pop().value().(bool)
+	return
+}
+
+func (p *Parser) ParseInt() (v int64, err error) {
+	v = p.pop().value().(int64)
+	return
+}
+
+func (p *Parser) ParseUint() (v uint64, err error) {
+	panic("objconv/toml: ParseUint should never be called because TOML has no unsigned integer type, this is likely a bug in the decoder code")
+}
+
+func (p *Parser) ParseFloat() (v float64, err error) {
+	v = p.pop().value().(float64)
+	return
+}
+
+func (p *Parser) ParseString() (v []byte, err error) {
+	s := p.pop().value().(string)
+	n := len(s)
+
+	if cap(p.s) < n {
+		p.s = make([]byte, 0, ((n/1024)+1)*1024)
+	}
+
+	v = p.s[:n]
+	copy(v, s)
+	return
+}
+
+func (p *Parser) ParseBytes() (v []byte, err error) {
+	panic("objconv/toml: ParseBytes should never be called because TOML has no bytes type, this is likely a bug in the decoder code")
+}
+
+func (p *Parser) ParseTime() (v time.Time, err error) {
+	v = p.pop().value().(time.Time)
+	return
+}
+
+func (p *Parser) ParseDuration() (v time.Duration, err error) {
+	panic("objconv/toml: ParseDuration should never be called because TOML has no duration type, this is likely a bug in the decoder code")
+}
+
+func (p *Parser) ParseError() (v error, err error) {
+	panic("objconv/toml: ParseError should never be called because TOML has no error type, this is likely a bug in the decoder code")
+}
+
+func (p *Parser) ParseArrayBegin() (n int, err error) {
+	if n = p.top().len(); n != 0 {
+		p.push(newParser(p.top().next()))
+	}
+	return
+}
+
+func (p *Parser) ParseArrayEnd(n int) (err error) {
+	p.pop()
+	return
+}
+
+func (p *Parser) ParseArrayNext(n int) (err error) {
+	p.push(newParser(p.top().next()))
+	return
+}
+
+func (p *Parser) ParseMapBegin() (n int, err error) {
+	if n = p.top().len(); n != 0 {
+		p.push(newParser(p.top().next()))
+	}
+	return
+}
+
+func (p *Parser) ParseMapEnd(n int) (err error) {
+	p.pop()
+	return
+}
+
+func (p *Parser) ParseMapValue(n int) (err error) {
+	p.push(newParser(p.top().next()))
+	return
+}
+
+func (p *Parser) ParseMapNext(n int) (err error) {
+	p.push(newParser(p.top().next()))
+	return
+}
+
+func (p *Parser) TextParser() bool {
+	return true
+}
+
+func (p *Parser) DecodeBytes(b []byte) (v []byte, err error) {
+	var n int
+	if n, err = base64.StdEncoding.Decode(b, b); err != nil {
+		return
+	}
+	v = b[:n]
+	return
+}
+
+func (p *Parser) push(v parser) {
+	p.stack = append(p.stack, v)
+}
+
+func (p *Parser) pop() parser {
+	i := len(p.stack) - 1
+	v := p.stack[i]
+	p.stack = p.stack[:i]
+	return v
+}
+
+func (p *Parser) top() parser {
+	return p.stack[len(p.stack)-1]
+}
+
+func (p *Parser) value() interface{} {
+	n := len(p.stack)
+	if n == 0 {
+		return eof{}
+	}
+	return p.stack[n-1].value()
+}
+
+type parser interface {
+	value() interface{}
+	next() interface{}
+	len() int
+}
+
+type valueParser struct {
+	self interface{}
+}
+
+func (p *valueParser) value() interface{} {
+	return p.self
+}
+
+func (p *valueParser) next() interface{} {
+	panic("objconv/toml: invalid call of next method on simple value parser")
+}
+
+func (p *valueParser) len() int {
+	panic("objconv/toml: invalid call of len method on simple value parser")
+}
+
+type arrayParser struct {
+	self []interface{}
+	off  int
+}
+
+func (p *arrayParser) value() interface{} {
+	return p.self
+}
+
+func (p *arrayParser) next() interface{} {
+	v := p.self[p.off]
+	p.off++
+	return v
+}
+
+func (p *arrayParser) len() int {
+	return len(p.self)
+}
+
+type tableParser struct {
+	self *table
+	off  int
+	val  bool
+}
+
+func (p *tableParser) value() interface{} {
+	return p.self
+}
+
+func (p *tableParser) next() (v interface{}) {
+	if p.val {
+		v = p.self.items[p.off].value
+		p.val = false
+		p.off++
+	} else {
+		v = p.self.items[p.off].key
+		p.val = true
+	}
+	return
+}
+
+func (p *tableParser) len() int {
+	return len(p.self.items)
+}
+
+func newParser(v interface{}) parser {
+	switch x := v.(type) {
+	case *table:
+		return &tableParser{self: x}
+
+	case *tableArray:
+		a := make([]interface{}, len(x.tables))
+		for i, t := range x.tables {
+			a[i] = t
+		}
+		return &arrayParser{self: a}
+
+	case []interface{}:
+		return &arrayParser{self: x}
+
+	default:
+		return &valueParser{self: x}
+	}
+}
+
+// eof values are returned by the top method to indicate that all values have
+// already been consumed.
+type eof struct{}
+
+// reader loads TOML documents into tables, keeping track of the position in
+// the document to report it in errors.
+type reader struct {
+	b    []byte
+	i    int // offset of the next byte to read in b
+	line int // line number of the next byte to read
+	head int // offset in b where the current line starts
+}
+
+func parseDocument(b []byte) (*table, error) {
+	r := reader{b: b, line: 1}
+	return r.document()
+}
+
+func (r *reader) document() (root *table, err error) {
+	root = newTable(header)
+	cur := root
+
+	// Skip the byte order mark, which is the only one allowed in UTF-8.
+	if bytes.HasPrefix(r.b, []byte("\xEF\xBB\xBF")) {
+		r.i, r.head = 3, 3
+	}
+
+	for {
+		if r.skipBlank(); r.i == len(r.b) {
+			return
+		}
+
+		if r.b[r.i] == '[' {
+			cur, err = r.tableHeader(root)
+		} else {
+			err = r.keyValue(cur)
+		}
+
+		if err != nil {
+			return
+		}
+
+		if err = r.endOfLine(); err != nil {
+			return
+		}
+	}
+}
+
+func (r *reader) tableHeader(root *table) (t *table, err error) {
+	var keys []string
+	var array = r.hasPrefix("[[")
+
+	if array {
+		r.i += 2
+	} else {
+		r.i++
+	}
+
+	r.skipSpace()
+
+	if keys, err = r.keys(); err != nil {
+		return
+	}
+
+	r.skipSpace()
+
+	if array && !r.hasPrefix("]]") {
+		err = r.errorf("expected ']]' after the name of the array of tables %s", keyString(keys))
+		return
+	}
+
+	if !array && !r.hasPrefix("]") {
+		err = r.errorf("expected ']' after the name of the table %s", keyString(keys))
+		return
+	}
+
+	parent := root
+	last := len(keys) - 1
+
+	for _, k := range keys[:last] {
+		if parent, err = r.descend(parent, k, implicit); err != nil {
+			return
+		}
+	}
+
+	v, exists := parent.get(keys[last])
+
+	if array {
+		if !exists {
+			v = &tableArray{}
+			parent.set(keys[last], v)
+		}
+
+		a, ok := v.(*tableArray)
+		if !ok {
+			err = r.errorf("cannot define the array of tables %s because the key is already defined", keyString(keys))
+			return
+		}
+
+		t = newTable(header)
+		a.tables = append(a.tables, t)
+		r.i += 2
+		return
+	}
+
+	if !exists {
+		t = newTable(header)
+		parent.set(keys[last], t)
+		r.i++
+		return
+	}
+
+	if x, ok := v.(*table); ok && x.kind == implicit {
+		t, x.kind = x, header
+		r.i++
+		return
+	}
+
+	err = r.errorf("table %s is already defined", keyString(keys))
+	return
+}
+
+func (r *reader) keyValue(t *table) (err error) {
+	var keys []string
+	var v interface{}
+
+	if keys, err = r.keys(); err != nil {
+		return
+	}
+
+	r.skipSpace()
+
+	if !r.hasPrefix("=") {
+		return r.errorf("expected '=' after the key %s", keyString(keys))
+	}
+
+	r.i++
+	r.skipSpace()
+
+	last := len(keys) - 1
+
+	for _, k := range keys[:last] {
+		if t, err = r.descend(t, k, dotted); err != nil {
+			return
+		}
+	}
+
+	if _, exists := t.get(keys[last]); exists {
+		return r.errorf("key %s is already defined", keyString(keys))
+	}
+
+	if v, err = r.value(); err != nil {
+		return
+	}
+
+	t.set(keys[last], v)
+	return
+}
+
+// descend returns the table at key k in t, creating it with the given kind if
+// it doesn't exist yet.
+func (r *reader) descend(t *table, k string, kind int) (*table, error) {
+	v, ok := t.get(k)
+
+	if !ok {
+		x := newTable(kind)
+		t.set(k, x)
+		return x, nil
+	}
+
+	switch x := v.(type) {
+	case *table:
+		if x.kind == inline || (kind == dotted && x.kind == header) {
+			return nil, r.errorf("cannot extend the table %q", k)
+		}
+		return x, nil
+
+	case *tableArray:
+		if kind == implicit {
+			return x.tables[len(x.tables)-1], nil
+		}
+	}
+
+	return nil, r.errorf("key %q is already defined and is not a table", k)
+}
+
+func (r *reader) keys() (keys []string, err error) {
+	for {
+		var k string
+
+		if k, err = r.key(); err != nil {
+			return
+		}
+
+		keys = append(keys, k)
+		r.skipSpace()
+
+		if !r.hasPrefix(".") {
+			return
+		}
+
+		r.i++
+		r.skipSpace()
+	}
+}
+
+func (r *reader) key() (k string, err error) {
+	if r.i == len(r.b) {
+		err = r.errorf("expected a key but found the end of the document")
+		return
+	}
+
+	switch r.b[r.i] {
+	case '"':
+		return r.basicString()
+	case '\'':
+		return r.literalString()
+	}
+
+	i := r.i
+
+	for r.i < len(r.b) && isBare(r.b[r.i]) {
+		r.i++
+	}
+
+	if i == r.i {
+		err = r.errorf("expected a key but found %q", r.b[r.i])
+		return
+	}
+
+	k = string(r.b[i:r.i])
+	return
+}
+
+func (r *reader) value() (v interface{}, err error) {
+	if r.i == len(r.b) {
+		err = r.errorf("expected a value but found the end of the document")
+		return
+	}
+
+	switch c := r.b[r.i]; {
+	case c == '"':
+		if r.hasPrefix(`"""`) {
+			return r.multilineString(true)
+		}
+		return r.basicString()
+
+	case c == '\'':
+		if r.hasPrefix(`'''`) {
+			return r.multilineString(false)
+		}
+		return r.literalString()
+
+	case c == '[':
+		return r.array()
+
+	case c == '{':
+		return r.inlineTable()
+
+	case c == 't':
+		return r.literal("true", true)
+
+	case c == 'f':
+		return r.literal("false", false)
+
+	case r.isDate(r.i) || r.isTime(r.i):
+		return r.datetime()
+
+	default:
+		return r.number()
+	}
+}
+
+func (r *reader) literal(s string, v interface{}) (interface{}, error) {
+	if !r.hasPrefix(s) {
+		return nil, r.errorf("expected a value but found %q", r.token())
+	}
+	r.i += len(s)
+	return v, nil
+}
+
+func (r *reader) basicString() (s string, err error) {
+	var b []byte
+	r.i++
+
+	for {
+		if r.i == len(r.b) {
+			err = r.errorf("unterminated string")
+			return
+		}
+
+		switch c := r.b[r.i]; {
+		case c == '"':
+			r.i++
+			s = string(b)
+			return
+
+		case c == '\\':
+			if b, err = r.escape(b); err != nil {
+				return
+			}
+
+		case isControl(c):
+			err = r.errorf("invalid character %q in string", c)
+			return
+
+		default:
+			b = append(b, c)
+			r.i++
+		}
+	}
+}
+
+func (r *reader) literalString() (s string, err error) {
+	r.i++
+	i := r.i
+
+	for {
+		if r.i == len(r.b) {
+			err = r.errorf("unterminated string")
+			return
+		}
+
+		switch c := r.b[r.i]; {
+		case c == '\'':
+			s = string(r.b[i:r.i])
+			r.i++
+			return
+
+		case isControl(c):
+			err = r.errorf("invalid character %q in string", c)
+			return
+		}
+
+		r.i++
+	}
+}
+
+// multilineString parses basic multi-line strings if escape is true, or
+// literal multi-line strings otherwise.
+func (r *reader) multilineString(escape bool) (s string, err error) {
+	var b []byte
+	var q = r.b[r.i]
+
+	r.i += 3
+
+	// A newline immediately following the opening delimiter is trimmed.
+	r.skipNewline()
+
+	for {
+		if r.i == len(r.b) {
+			err = r.errorf("unterminated multi-line string")
+			return
+		}
+
+		switch c := r.b[r.i]; {
+		case c == q && r.hasPrefix(string([]byte{q, q, q})):
+			r.i += 3
+			// Up to two quotes may be placed right before the closing
+			// delimiter.
+			for n := 0; n < 2 && r.i < len(r.b) && r.b[r.i] == q; n++ {
+				b = append(b, q)
+				r.i++
+			}
+			s = string(b)
+			return
+
+		case c == '\\' && escape:
+			if r.isLineEndingBackslash() {
+				// Trim the whitespaces and newlines up to the next
+				// non-whitespace character.
+				for r.i++; r.i < len(r.b); {
+					if isSpace(r.b[r.i]) {
+						r.i++
+					} else if !r.skipNewline() {
+						break
+					}
+				}
+			} else if b, err = r.escape(b); err != nil {
+				return
+			}
+
+		case c == '\n' || c == '\r':
+			if !r.skipNewline() {
+				err = r.errorf("invalid character %q in string", c)
+				return
+			}
+			b = append(b, '\n')
+
+		case isControl(c):
+			err = r.errorf("invalid character %q in string", c)
+			return
+
+		default:
+			b = append(b, c)
+			r.i++
+		}
+	}
+}
+
+// isLineEndingBackslash returns true if the backslash at the current position
+// is only followed by whitespaces until the end of the line.
+func (r *reader) isLineEndingBackslash() bool {
+	i := r.i + 1
+
+	for i < len(r.b) && isSpace(r.b[i]) {
+		i++
+	}
+
+	return i < len(r.b) && (r.b[i] == '\n' || (r.b[i] == '\r' && i+1 < len(r.b) && r.b[i+1] == '\n'))
+}
+
+func (r *reader) escape(b []byte) ([]byte, error) {
+	if r.i++; r.i == len(r.b) {
+		return b, r.errorf("unterminated string")
+	}
+
+	c := r.b[r.i]
+	r.i++
+
+	switch c {
+	case 'b':
+		return append(b, '\b'), nil
+	case 't':
+		return append(b, '\t'), nil
+	case 'n':
+		return append(b, '\n'), nil
+	case 'f':
+		return append(b, '\f'), nil
+	case 'r':
+		return append(b, '\r'), nil
+	case '"':
+		return append(b, '"'), nil
+	case '\\':
+		return append(b, '\\'), nil
+	case 'u', 'U':
+		n := 4
+		if c == 'U' {
+			n = 8
+		}
+
+		if r.i+n > len(r.b) {
+			return b, r.errorf("unterminated unicode escape sequence")
+		}
+
+		u, err := strconv.ParseUint(string(r.b[r.i:r.i+n]), 16, 32)
+
+		if err != nil || !utf8.ValidRune(rune(u)) {
+			return b, r.errorf("invalid unicode escape sequence %q", r.b[r.i-2:r.i+n])
+		}
+
+		var a [utf8.UTFMax]byte
+		r.i += n
+		return append(b, a[:utf8.EncodeRune(a[:], rune(u))]...), nil
+	}
+
+	return b, r.errorf("invalid escape sequence '\\%c'", c)
+}
+
+func (r *reader) array() (a []interface{}, err error) {
+	a = []interface{}{}
+	r.i++
+
+	for {
+		var v interface{}
+
+		if r.skipBlank(); r.i == len(r.b) {
+			err = r.errorf("unterminated array")
+			return
+		}
+
+		if r.b[r.i] == ']' {
+			r.i++
+			return
+		}
+
+		if v, err = r.value(); err != nil {
+			return
+		}
+
+		a = append(a, v)
+
+		if r.skipBlank(); r.i == len(r.b) {
+			err = r.errorf("unterminated array")
+			return
+		}
+
+		switch c := r.b[r.i]; c {
+		case ',':
+			r.i++
+		case ']':
+			r.i++
+			return
+		default:
+			err = r.errorf("expected ',' or ']' in array but found %q", c)
+			return
+		}
+	}
+}
+
+func (r *reader) inlineTable() (t *table, err error) {
+	t = newTable(inline)
+	r.i++
+
+	if r.skipSpace(); r.hasPrefix("}") {
+		r.i++
+		return
+	}
+
+	for {
+		if err = r.keyValue(t); err != nil {
+			return
+		}
+
+		if r.skipSpace(); r.i == len(r.b) {
+			err = r.errorf("unterminated inline table")
+			return
+		}
+
+		switch c := r.b[r.i]; c {
+		case ',':
+			r.i++
+			r.skipSpace()
+		case '}':
+			r.i++
+			freeze(t)
+			return
+		default:
+			err = r.errorf("expected ',' or '}' in inline table but found %q", c)
+			return
+		}
+	}
+}
+
+// freeze marks the tables created by dotted keys in an inline table as inline
+// tables as well, so they cannot be extended later on.
+func freeze(t *table) {
+	for _, it := range t.items {
+		if x, ok := it.value.(*table); ok {
+			x.kind = inline
+			freeze(x)
+		}
+	}
+}
+
+func (r *reader) datetime() (v time.Time, err error) {
+	var i = r.i
+	var date = r.isDate(r.i)
+	var clock = !date
+	var offset = false
+
+	if date {
+		r.i += 10
+
+		// The date and time may be separated by a space instead of a 'T', but
+		// only if a time follows.
+		if r.i+1 < len(r.b) && (r.b[r.i] == 'T' || r.b[r.i] == 't' || r.b[r.i] == ' ') && r.isTime(r.i+1) {
+			r.i++
+			clock = true
+		}
+	}
+
+	if clock {
+		if r.i+8 > len(r.b) || r.b[r.i+5] != ':' || !isDigit(r.b[r.i+6]) || !isDigit(r.b[r.i+7]) {
+			r.i = i
+			err = r.errorf("invalid time %q", r.token())
+			return
+		}
+
+		if r.i += 8; r.hasPrefix(".") && r.i+1 < len(r.b) && isDigit(r.b[r.i+1]) {
+			for r.i++; r.i < len(r.b) && isDigit(r.b[r.i]); r.i++ {
+			}
+		}
+
+		if date && r.i < len(r.b) {
+			switch r.b[r.i] {
+			case 'Z', 'z':
+				r.i++
+				offset = true
+			case '+', '-':
+				r.i += 6
+				offset = r.i <= len(r.b)
+			}
+		}
+	}
+
+	if r.i > len(r.b) {
+		r.i = len(r.b)
+	}
+
+	s := []byte(string(r.b[i:r.i]))
+	layout := "15:04:05"
+
+	switch {
+	case date && clock:
+		s[10] = 'T'
+		layout = "2006-01-02T15:04:05"
+		if offset {
+			layout = time.RFC3339Nano
+			if c := &s[len(s)-1]; *c == 'z' {
+				*c = 'Z'
+			}
+		}
+	case date:
+		layout = "2006-01-02"
+	}
+
+	if v, err = time.ParseInLocation(layout, string(s), time.UTC); err != nil {
+		r.i = i
+		err = r.errorf("invalid date-time %q", s)
+	}
+	return
+}
+
+func (r *reader) number() (v interface{}, err error) {
+	i := r.i
+
+	for r.i < len(r.b) && isNumber(r.b[r.i]) {
+		r.i++
+	}
+
+	if i == r.i {
+		err = r.errorf("expected a value but found %q", r.token())
+		return
+	}
+
+	var ok bool
+
+	if v, ok = parseNumber(string(r.b[i:r.i])); !ok {
+		r.i = i
+		err = r.errorf("invalid number %q", r.token())
+	}
+	return
+}
+
+func parseNumber(s string) (v interface{}, ok bool) {
+	switch s {
+	case "inf", "+inf":
+		return math.Inf(1), true
+	case "-inf":
+		return math.Inf(-1), true
+	case "nan", "+nan", "-nan":
+		return math.NaN(), true
+	}
+
+	if len(s) > 2 && s[0] == '0' {
+		base := 0
+
+		switch s[1] {
+		case 'x':
+			base = 16
+		case 'o':
+			base = 8
+		case 'b':
+			base = 2
+		}
+
+		if base != 0 {
+			var d string
+			var n int64
+
+			if d, ok = stripUnderscores(s[2:], base); !ok {
+				return
+			}
+			if n, ok = parseInt(d, base); ok {
+				v = n
+			}
+			return
+		}
+	}
+
+	d, ok := stripUnderscores(s, 10)
+	if !ok {
+		return
+	}
+
+	// Leading zeros are not allowed in the integer part.
+	if n := strings.TrimLeft(d, "+-"); len(n) > 1 && n[0] == '0' && isDigit(n[1]) {
+		return nil, false
+	}
+
+	if !strings.ContainsAny(d, ".eE") {
+		var n int64
+		if n, ok = parseInt(d, 10); ok {
+			v = n
+		}
+		return
+	}
+
+	for j := 0; j < len(d); j++ {
+		switch c := d[j]; {
+		case c == '.':
+			// The decimal point must be surrounded by digits.
+			if j == 0 || !isDigit(d[j-1]) || j+1 == len(d) || !isDigit(d[j+1]) {
+				return nil, false
+			}
+		case !isDigit(c) && c != 'e' && c != 'E' && c != '+' && c != '-':
+			return nil, false
+		}
+	}
+
+	f, err := strconv.ParseFloat(d, 64)
+	if err != nil {
+		return nil, false
+	}
+
+	return f, true
+}
+
+func parseInt(s string, base int) (v int64, ok bool) {
+	if len(s) != 0 && (s[0] == '+' || s[0] == '-') && base != 10 {
+		return
+	}
+	v, err := strconv.ParseInt(s, base, 64)
+	return v, err == nil
+}
+
+// stripUnderscores removes the underscores used to separate digits in s, each
+// underscore must be surrounded by digits of the base.
+func stripUnderscores(s string, base int) (string, bool) {
+	if strings.IndexByte(s, '_') < 0 {
+		return s, true
+	}
+
+	b := make([]byte, 0, len(s))
+
+	for i := 0; i < len(s); i++ {
+		if s[i] == '_' {
+			if i == 0 || i+1 == len(s) || !isDigitOf(s[i-1], base) || !isDigitOf(s[i+1], base) {
+				return "", false
+			}
+			continue
+		}
+		b = append(b, s[i])
+	}
+
+	return string(b), true
+}
+
+func (r *reader) isDate(i int) bool {
+	return i+10 <= len(r.b) &&
+		isDigit(r.b[i]) && isDigit(r.b[i+1]) && isDigit(r.b[i+2]) && isDigit(r.b[i+3]) && r.b[i+4] == '-' &&
+		isDigit(r.b[i+5]) && isDigit(r.b[i+6]) && r.b[i+7] == '-' &&
+		isDigit(r.b[i+8]) && isDigit(r.b[i+9])
+}
+
+func (r *reader) isTime(i int) bool {
+	return i+5 <= len(r.b) &&
+		isDigit(r.b[i]) && isDigit(r.b[i+1]) && r.b[i+2] == ':' &&
+		isDigit(r.b[i+3]) && isDigit(r.b[i+4])
+}
+
+// skipBlank skips whitespaces, newlines and comments.
+func (r *reader) skipBlank() {
+	for r.i < len(r.b) {
+		switch r.b[r.i] {
+		case ' ', '\t':
+			r.i++
+		case '#':
+			r.skipComment()
+		default:
+			if !r.skipNewline() {
+				return
+			}
+		}
+	}
+}
+
+// skipSpace skips whitespaces, but not newlines.
+func (r *reader) skipSpace() {
+	for r.i < len(r.b) && isSpace(r.b[r.i]) {
+		r.i++
+	}
+}
+
+func (r *reader) skipComment() {
+	for r.i < len(r.b) && r.b[r.i] != '\n' && !r.hasPrefix("\r\n") {
+		r.i++
+	}
+}
+
+// skipNewline skips the newline at the current position, if any.
+func (r *reader) skipNewline() bool {
+	switch {
+	case r.hasPrefix("\n"):
+		r.i++
+	case r.hasPrefix("\r\n"):
+		r.i += 2
+	default:
+		return false
+	}
+	r.line++
+	r.head = r.i
+	return true
+}
+
+func (r *reader) endOfLine() error {
+	if r.skipSpace(); r.hasPrefix("#") {
+		r.skipComment()
+	}
+
+	if r.i != len(r.b) && !r.skipNewline() {
+		return r.errorf("expected a new line but found %q", r.token())
+	}
+
+	return nil
+}
+
+func (r *reader) hasPrefix(s string) bool {
+	return len(r.b)-r.i >= len(s) && string(r.b[r.i:r.i+len(s)]) == s
+}
+
+// token returns the sequence of bytes at the current position, it is used to
+// report errors.
+func (r *reader) token() string {
+	i := r.i
+
+	for i < len(r.b) && !isSpace(r.b[i]) && r.b[i] != '\n' && r.b[i] != '\r' && r.b[i] != ',' {
+		i++
+	}
+
+	if i == r.i && i < len(r.b) {
+		i++
+	}
+
+	return string(r.b[r.i:i])
+}
+
+func (r *reader) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("objconv/toml: %s (line %d, column %d)", fmt.Sprintf(format, args...), r.line, r.i-r.head+1)
+}
+
+func keyString(keys []string) string {
+	var b []byte
+	for i, k := range keys {
+		if i != 0 {
+			b = append(b, '.')
+		}
+		b = appendKey(b, k)
+	}
+	return string(b)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isDigitOf(c byte, base int) bool {
+	switch base {
+	case 2:
+		return c == '0' || c == '1'
+	case 8:
+		return c >= '0' && c <= '7'
+	case 16:
+		return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+	}
+	return isDigit(c)
+}
+
+func isBare(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || isDigit(c) || c == '_' || c == '-'
+}
+
+func isNumber(c byte) bool {
+	return isBare(c) || c == '+' || c == '.'
+}
+
+func isControl(c byte) bool {
+	return (c < 0x20 && c != '\t') || c == 0x7F
+}
@@ -0,0 +1,61 @@
+// Package toml implements an emitter and a parser of the TOML format, as
+// described by the specification at https://toml.io/en/v1.0.0.
+//
+// TOML documents are tables, so only maps and structs can be encoded at the top
+// level. TOML has no null values, map entries and struct fields that are nil
+// are omitted from the output. Offset date-times are parsed as objconv.Time
+// values, local date-times and local dates are parsed as times in UTC, and local
+// times are parsed as times on January 1st of year 0 in UTC.
+package toml
+
+import "errors"
+
+// table is the in-memory representation of TOML tables, it preserves the
+// order in which the keys were defined.
+type table struct {
+	items []item
+	index map[string]int
+	kind  int
+}
+
+type item struct {
+	key   string
+	value interface{}
+}
+
+// The kinds of tables, they are used to enforce the rules of the specification
+// about which tables can be defined or extended.
+const (
+	implicit = iota // created as parent of a table header
+	header          // defined by a table header, or the root table
+	dotted          // created by a dotted key
+	inline          // inline table, cannot be extended
+)
+
+// tableArray is the in-memory representation of arrays of tables, which are
+// defined by [[...]] headers and are distinct from static arrays.
+type tableArray struct {
+	tables []*table
+}
+
+var errTopLevel = errors.New("objconv/toml: only tables can be encoded at the top level")
+
+func newTable(kind int) *table {
+	return &table{kind: kind}
+}
+
+func (t *table) get(k string) (v interface{}, ok bool) {
+	var i int
+	if i, ok = t.index[k]; ok {
+		v = t.items[i].value
+	}
+	return
+}
+
+func (t *table) set(k string, v interface{}) {
+	if t.index == nil {
+		t.index = make(map[string]int)
+	}
+	t.index[k] = len(t.items)
+	t.items = append(t.items, item{key: k, value: v})
+}
@@ -0,0 +1,412 @@
+package toml
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dolab/objconv"
+)
+
+type config struct {
+	Title    string            `objconv:"title"`
+	Owner    owner             `objconv:"owner"`
+	Database database          `objconv:"database"`
+	Servers  map[string]server `objconv:"servers"`
+	Products []product         `objconv:"products"`
+}
+
+type owner struct {
+	Name string    `objconv:"name"`
+	DOB  time.Time `objconv:"dob"`
+}
+
+type database struct {
+	Enabled     bool               `objconv:"enabled"`
+	Ports       []int              `objconv:"ports"`
+	Timeout     time.Duration      `objconv:"timeout"`
+	TempTargets map[string]float64 `objconv:"temp_targets"`
+}
+
+type server struct {
+	IP   string `objconv:"ip"`
+	Role string `objconv:"role"`
+}
+
+type product struct {
+	Name  string `objconv:"name,omitempty"`
+	SKU   int    `objconv:"sku,omitempty"`
+	Color string `objconv:"color,omitempty"`
+}
+
+const document = `# This is a TOML document
+
+title = "TOML Example"
+
+[owner]
+name = "Tom Preston-Werner"
+dob = 1979-05-27T07:32:00-08:00
+
+[database]
+enabled = true
+ports = [ 8000, 8001, 8002 ]
+timeout = "1m30s"
+temp_targets = { cpu = 79.5, case = 72.0 }
+
+[servers]
+
+[servers.alpha]
+ip = "10.0.0.1"
+role = "frontend"
+
+[servers.beta]
+ip = "10.0.0.2"
+role = "backend"
+
+[[products]]
+name = "Hammer"
+sku = 738594937
+
+[[products]]  # empty table within the array
+
+[[products]]
+name = "Nail"
+sku = 284758393
+color = "gray"
+`
+
+var expected = config{
+	Title: "TOML Example",
+	Owner: owner{
+		Name: "Tom Preston-Werner",
+		DOB:  time.Date(1979, 5, 27, 15, 32, 0, 0, time.UTC),
+	},
+	Database: database{
+		Enabled:     true,
+		Ports:       []int{8000, 8001, 8002},
+		Timeout:     90 * time.Second,
+		TempTargets: map[string]float64{"cpu": 79.5, "case": 72},
+	},
+	Servers: map[string]server{
+		"alpha": {IP: "10.0.0.1", Role: "frontend"},
+		"beta":  {IP: "10.0.0.2", Role: "backend"},
+	},
+	Products: []product{
+		{Name: "Hammer", SKU: 738594937},
+		{},
+		{Name: "Nail", SKU: 284758393, Color: "gray"},
+	},
+}
+
+func TestUnmarshal(t *testing.T) {
+	var c config
+
+	if err := Unmarshal([]byte(document), &c); err != nil {
+		t.Fatal(err)
+	}
+
+	if !c.Owner.DOB.Equal(expected.Owner.DOB) {
+		t.Errorf("bad time decoded: %s", c.Owner.DOB)
+	}
+
+	c.Owner.DOB = expected.Owner.DOB
+
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("bad value decoded:\n- expected: %#v\n- found:    %#v", expected, c)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf)
+	enc.SortMapKeys = true
+
+	if err := enc.Encode(expected); err != nil {
+		t.Fatal(err)
+	}
+
+	b := buf.Bytes()
+
+	const output = `title = "TOML Example"
+
+[owner]
+name = "Tom Preston-Werner"
+dob = 1979-05-27T15:32:00Z
+
+[database]
+enabled = true
+ports = [8000, 8001, 8002]
+timeout = "1m30s"
+
+[database.temp_targets]
+case = 72.0
+cpu = 79.5
+
+[servers.alpha]
+ip = "10.0.0.1"
+role = "frontend"
+
+[servers.beta]
+ip = "10.0.0.2"
+role = "backend"
+
+[[products]]
+name = "Hammer"
+sku = 738594937
+
+[[products]]
+
+[[products]]
+name = "Nail"
+sku = 284758393
+color = "gray"
+`
+
+	if s := string(b); s != output {
+		t.Errorf("bad document encoded:\n%s", s)
+	}
+
+	var c config
+
+	if err := Unmarshal(b, &c); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("bad value decoded:\n- expected: %#v\n- found:    %#v", expected, c)
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	tests := []struct {
+		s string
+		v interface{}
+	}{
+		{`true`, true},
+		{`false`, false},
+		{`+99`, int64(99)},
+		{`-17`, int64(-17)},
+		{`1_000`, int64(1000)},
+		{`0xDEAD_beef`, int64(0xdeadbeef)},
+		{`0o755`, int64(0755)},
+		{`0b1101`, int64(13)},
+		{`+1.0`, 1.0},
+		{`-0.01`, -0.01},
+		{`5e+22`, 5e+22},
+		{`6.626e-34`, 6.626e-34},
+		{`224_617.445_991`, 224617.445991},
+		{`-inf`, math.Inf(-1)},
+		{`"A\tB\u00E9\U0001F600\""`, "A\tBé😀\""},
+		{`'C:\Users\nodejs'`, `C:\Users\nodejs`},
+		{"\"\"\"\nRoses are red\nViolets are blue\"\"\"", "Roses are red\nViolets are blue"},
+		{"\"\"\"\nThe quick \\\n\n   brown fox\"\"\"", "The quick brown fox"},
+		{`""""quoted"""""`, `"quoted""`},
+		{"'''\nline\n'''", "line\n"},
+		{`1979-05-27T07:32:00Z`, time.Date(1979, 5, 27, 7, 32, 0, 0, time.UTC)},
+		{`1979-05-27 00:32:00.999999-07:00`, time.Date(1979, 5, 27, 7, 32, 0, 999999000, time.UTC)},
+		{`1979-05-27T07:32:00`, time.Date(1979, 5, 27, 7, 32, 0, 0, time.UTC)},
+		{`1979-05-27`, time.Date(1979, 5, 27, 0, 0, 0, 0, time.UTC)},
+		{`07:32:00`, time.Date(0, 1, 1, 7, 32, 0, 0, time.UTC)},
+		{"[ 1, 2, # comment\n  3, ]", []interface{}{int64(1), int64(2), int64(3)}},
+		{`[ [ 1 ], [ "a", "b" ] ]`, []interface{}{[]interface{}{int64(1)}, []interface{}{"a", "b"}}},
+		{`{ x = 1, y.z = "A" }`, map[interface{}]interface{}{"x": int64(1), "y": map[interface{}]interface{}{"z": "A"}}},
+		{`[ { a = 1 }, { } ]`, []interface{}{map[interface{}]interface{}{"a": int64(1)}, map[interface{}]interface{}{}}},
+	}
+
+	for _, test := range tests {
+		var v map[string]interface{}
+
+		if err := Unmarshal([]byte("v = "+test.s), &v); err != nil {
+			t.Errorf("%s: %s", test.s, err)
+			continue
+		}
+
+		if x, ok := v["v"].(time.Time); ok {
+			if !x.Equal(test.v.(time.Time)) {
+				t.Errorf("%s: bad time decoded: %s", test.s, x)
+			}
+			continue
+		}
+
+		if !reflect.DeepEqual(v["v"], test.v) {
+			t.Errorf("%s: bad value decoded: %#v", test.s, v["v"])
+		}
+	}
+}
+
+func TestParseTables(t *testing.T) {
+	var v map[string]interface{}
+
+	if err := Unmarshal([]byte(`
+a.b = 1
+"a".c = 2
+[x.y.z]
+w = 3
+[x]
+'y'.u = 4 # x.y was implicitly defined by [x.y.z]
+[[f]]
+g = 5
+[f.h]
+i = 6
+[[f]]
+[[f.j]]
+k = 7
+`), &v); err != nil {
+		t.Fatal(err)
+	}
+
+	type m = map[interface{}]interface{}
+	type a = []interface{}
+
+	if !reflect.DeepEqual(v, map[string]interface{}{
+		"a": m{"b": int64(1), "c": int64(2)},
+		"x": m{"y": m{"z": m{"w": int64(3)}, "u": int64(4)}},
+		"f": a{
+			m{"g": int64(5), "h": m{"i": int64(6)}},
+			m{"j": a{m{"k": int64(7)}}},
+		},
+	}) {
+		t.Errorf("bad value decoded: %#v", v)
+	}
+}
+
+func TestParseKeyOrder(t *testing.T) {
+	var v interface{}
+
+	d := objconv.NewDecoder(NewParser(strings.NewReader("c = 1\nb = 2\na = 3\n")))
+	k := []string{}
+
+	if err := d.DecodeMap(func(kd objconv.Decoder, vd objconv.Decoder) (err error) {
+		var s string
+		if err = kd.Decode(&s); err == nil {
+			k = append(k, s)
+			err = vd.Decode(&v)
+		}
+		return
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(k, []string{"c", "b", "a"}) {
+		t.Errorf("bad key order: %v", k)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		s   string
+		err string
+	}{
+		{"a = 1\na = 2", "key a is already defined (line 2, column 5)"},
+		{"[a]\n[a]", "table a is already defined (line 2, column 3)"},
+		{"a = 1\n[a]", "table a is already defined"},
+		{"a = 1\n[a.b]", `key "a" is already defined and is not a table`},
+		{"a = {}\n[a]", "table a is already defined"},
+		{"a = { b = 1 }\na.c = 2", `cannot extend the table "a"`},
+		{"[a]\nb.c = 1\n[a.b]", "table a.b is already defined"},
+		{"a = []\n[[a]]", "cannot define the array of tables a"},
+		{"a = 1 b = 2", "expected a new line"},
+		{"a = ", "expected a value but found the end of the document"},
+		{"a = 01", `invalid number "01"`},
+		{"a = 1__0", `invalid number "1__0"`},
+		{"a = 1.", `invalid number "1."`},
+		{"a = .5", `invalid number ".5"`},
+		{"a = -0x10", `invalid number "-0x10"`},
+		{"a = 0xFFFFFFFFFFFFFFFF", "invalid number"},
+		{"a = tru", "expected a value"},
+		{`a = "\x"`, `invalid escape sequence '\x'`},
+		{"a = \"A\nB\"", "invalid character"},
+		{`a = "A`, "unterminated string"},
+		{"a = [1, 2", "unterminated array"},
+		{"a = [1 2]", "expected ',' or ']' in array"},
+		{"a = { b = 1, }", "expected a key"},
+		{"a = { b = 1\n}", "expected ',' or '}' in inline table"},
+		{"a = 1979-13-27", "invalid date-time"},
+		{"[a", "expected ']'"},
+		{"= 1", "expected a key"},
+	}
+
+	for _, test := range tests {
+		var v interface{}
+
+		err := Unmarshal([]byte(test.s), &v)
+
+		if err == nil {
+			t.Errorf("%q: expected an error", test.s)
+			continue
+		}
+
+		if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: bad error: %s", test.s, err)
+		}
+	}
+}
+
+func TestMarshalValues(t *testing.T) {
+	type m = map[string]interface{}
+
+	tests := []struct {
+		v interface{}
+		s string
+	}{
+		{m{}, ``},
+		{m{"": 1}, "\"\" = 1\n"},
+		{m{"a b": "A\"\n\x01"}, "\"a b\" = \"A\\\"\\n\\u0001\"\n"},
+		{m{"a": 1.0}, "a = 1.0\n"},
+		{m{"a": float32(0.1)}, "a = 0.1\n"},
+		{m{"a": 1e21}, "a = 1e+21\n"},
+		{m{"a": math.NaN()}, "a = nan\n"},
+		{m{"a": []byte("A")}, "a = \"QQ==\"\n"},
+		{m{"a": nil}, ``},
+		{m{"a": []interface{}{}}, "a = []\n"},
+		{m{"a": []interface{}{1, "A", []int{}}}, "a = [1, \"A\", []]\n"},
+		{m{"a": []interface{}{m{"b": 1}, 2}}, "a = [{ b = 1 }, 2]\n"},
+		{m{"a": []m{{"b": m{"c": 1}}}}, "[[a]]\n\n[a.b]\nc = 1\n"},
+		{m{"a": m{}}, "[a]\n"},
+		{m{"a": m{"b": m{"c": m{}}}}, "[a.b.c]\n"},
+		{map[int]int{1: 2}, "1 = 2\n"},
+		{m{"t": time.Date(0, 1, 1, 7, 32, 0, 500000000, time.UTC)}, "t = 07:32:00.5\n"},
+	}
+
+	for _, test := range tests {
+		b, err := Marshal(test.v)
+
+		if err != nil {
+			t.Errorf("%#v: %s", test.v, err)
+			continue
+		}
+
+		if s := string(b); s != test.s {
+			t.Errorf("%#v:\n- expected: %q\n- found:    %q", test.v, test.s, s)
+		}
+	}
+}
+
+func TestMarshalError(t *testing.T) {
+	tests := []interface{}{
+		nil,
+		42,
+		"Hello World!",
+		[]map[string]int{},
+		map[string]interface{}{"a": []interface{}{nil}},
+		map[string]uint64{"a": math.MaxUint64},
+		map[float64]int{1.5: 1},
+	}
+
+	for _, test := range tests {
+		if _, err := Marshal(test); err == nil {
+			t.Errorf("%#v: expected an error", test)
+		}
+	}
+}
+
+func TestCodecRegistered(t *testing.T) {
+	for _, name := range []string{"application/toml", "toml"} {
+		if _, ok := objconv.Lookup(name); !ok {
+			t.Errorf("toml codec not registered as %s", name)
+		}
+	}
+}
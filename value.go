@@ -5,6 +5,7 @@ import (
 	"encoding"
 	"errors"
 	"io"
+	"math/big"
 	"reflect"
 	"sync"
 	"time"
@@ -103,6 +104,15 @@ var (
 	float64Type        = reflect.TypeOf(float64(0))
 	stringType         = reflect.TypeOf("")
 	bytesType          = reflect.TypeOf([]byte(nil))
+	rawValueType       = reflect.TypeOf(RawValue{})
+	orderedMapType     = reflect.TypeOf(OrderedMap{})
+	numberType         = reflect.TypeOf(Number(""))
+	bigIntType         = reflect.TypeOf(big.Int{})
+	bigFloatType       = reflect.TypeOf(big.Float{})
+	bigRatType         = reflect.TypeOf(big.Rat{})
+	bigIntPtrType      = reflect.PtrTo(bigIntType)
+	bigFloatPtrType    = reflect.PtrTo(bigFloatType)
+	bigRatPtrType      = reflect.PtrTo(bigRatType)
 	timeType           = reflect.TypeOf(time.Time{})
 	durationType       = reflect.TypeOf(time.Duration(0))
 	sliceInterfaceType = reflect.TypeOf(([]interface{})(nil))
@@ -133,10 +143,7 @@ func stringNoCopy(b []byte) string {
 	if n == 0 {
 		return ""
 	}
-	return *(*string)(unsafe.Pointer(&reflect.StringHeader{
-		Data: uintptr(unsafe.Pointer(&b[0])),
-		Len:  n,
-	}))
+	return *(*string)(unsafe.Pointer(&b))
 }
 
 // ValueParser is parser that uses "natural" in-memory representation of data
@@ -314,7 +321,7 @@ func (p *ValueParser) ParseMapBegin() (n int, err error) {
 		s := structCache.lookup(v.Type())
 
 		for _, f := range s.fields {
-			if !f.omit(v.FieldByIndex(f.index)) {
+			if fv := f.value(v); fv.IsValid() && !f.omit(fv) {
 				c.fields = append(c.fields, f)
 				n++
 			}
@@ -344,7 +351,7 @@ func (p *ValueParser) ParseMapValue(n int) (err error) {
 	if ctx.keys != nil {
 		p.push(ctx.value.MapIndex(ctx.keys[n]))
 	} else {
-		p.push(ctx.value.FieldByIndex(ctx.fields[n].index))
+		p.push(ctx.fields[n].value(ctx.value))
 	}
 
 	return
@@ -3,6 +3,7 @@ package yaml
 import (
 	"bytes"
 	"io"
+	"reflect"
 	"sync"
 
 	"github.com/dolab/objconv"
@@ -13,9 +14,28 @@ func NewDecoder(r io.Reader) *objconv.Decoder {
 	return objconv.NewDecoder(NewParser(r))
 }
 
-// NewStreamDecoder returns a new YAML stream decoder that parses values from r.
+// NewStreamDecoder returns a new YAML stream decoder that parses values from r,
+// each document of the stream is decoded as a separate value.
 func NewStreamDecoder(r io.Reader) *objconv.StreamDecoder {
-	return objconv.NewStreamDecoder(NewParser(r))
+	return objconv.NewStreamDecoder(NewStreamParser(r))
+}
+
+// NewOrderedDecoder is like NewDecoder but mappings decoded into empty
+// interfaces become objconv.OrderedMap values, which keep the keys in the
+// order they appear in the document.
+func NewOrderedDecoder(r io.Reader) *objconv.Decoder {
+	d := NewDecoder(r)
+	d.MapType = mapType
+	return d
+}
+
+// NewOrderedStreamDecoder is like NewStreamDecoder but decodes maps into empty
+// interfaces as objconv.OrderedMap values, like the decoders returned by
+// NewOrderedDecoder.
+func NewOrderedStreamDecoder(r io.Reader) *objconv.StreamDecoder {
+	d := NewStreamDecoder(r)
+	d.MapType = mapType
+	return d
 }
 
 // Unmarshal decodes a YAML representation of v from b.
@@ -30,6 +50,8 @@ func Unmarshal(b []byte, v interface{}) error {
 	return err
 }
 
+var mapType = reflect.TypeOf(objconv.OrderedMap{})
+
 var unmarshalerPool = sync.Pool{
 	New: func() interface{} { return newUnmarshaler() },
 }
@@ -2,78 +2,165 @@ package yaml
 
 import (
 	"encoding/base64"
+	"errors"
 	"io"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
-	yaml "gopkg.in/yaml.v2"
+	"github.com/dolab/objconv"
 )
 
 // Emitter implements a YAML emitter that satisfies the objconv.Emitter
 // interface.
+//
+// The emitter is built on the port of libyaml that gopkg.in/yaml.v2 uses, values
+// are written in block style as they are emitted, and each document is flushed
+// to the writer when it is complete.
 type Emitter struct {
 	w io.Writer
-	// The stack is used to keep track of the container being built by the
-	// emitter, which may be an arrayEmitter or mapEmitter.
-	stack []emitter
+	// In stream mode, the top-level array is written as a sequence of
+	// documents, docs is set while the documents are being written.
+	stream bool
+	docs   bool
+
+	emitter yaml_emitter_t
+	event   yaml_event_t
+	started bool  // set once the start of the stream was written
+	depth   int   // nesting level of sequences and mappings
+	err     error // the libyaml emitter cannot recover from errors
+
+	// Settings of the pretty emitter, flow is the maximum width of sequences
+	// of scalars that are written in flow style.
+	indent int
+	flow   int
+
+	// When flow is set, the events of the innermost sequence are held until
+	// the emitter can tell whether it fits on a single line.
+	seq     []yaml_event_t
+	width   int
+	pending bool
 }
 
 func NewEmitter(w io.Writer) *Emitter {
 	return &Emitter{w: w}
 }
 
+// NewStreamEmitter returns an emitter which writes the elements of the
+// top-level array as documents, libyaml starts each document after the first
+// one with a "---" line.
+func NewStreamEmitter(w io.Writer) *Emitter {
+	return &Emitter{w: w, stream: true}
+}
+
 func (e *Emitter) Reset(w io.Writer) {
 	e.w = w
-	e.stack = e.stack[:0]
+	e.docs = false
+	e.started = false
+	e.depth = 0
+	e.err = nil
+	e.seq = e.seq[:0]
+	e.width = 0
+	e.pending = false
 }
 
 func (e *Emitter) EmitNil() error {
-	return e.emit(nil)
+	return e.scalar("null", yaml_PLAIN_SCALAR_STYLE)
 }
 
 func (e *Emitter) EmitBool(v bool) error {
-	return e.emit(v)
+	return e.scalar(strconv.FormatBool(v), yaml_PLAIN_SCALAR_STYLE)
 }
 
 func (e *Emitter) EmitInt(v int64, _ int) error {
-	return e.emit(v)
+	return e.scalar(strconv.FormatInt(v, 10), yaml_PLAIN_SCALAR_STYLE)
 }
 
 func (e *Emitter) EmitUint(v uint64, _ int) error {
-	return e.emit(v)
+	return e.scalar(strconv.FormatUint(v, 10), yaml_PLAIN_SCALAR_STYLE)
 }
 
-func (e *Emitter) EmitFloat(v float64, _ int) error {
-	return e.emit(v)
+func (e *Emitter) EmitFloat(v float64, bitSize int) error {
+	return e.scalar(formatFloat(v, bitSize), yaml_PLAIN_SCALAR_STYLE)
 }
 
 func (e *Emitter) EmitString(v string) error {
-	return e.emit(v)
+	if !utf8.ValidString(v) {
+		// Like gopkg.in/yaml.v2, strings that are not valid UTF-8 are written
+		// as binary data.
+		return e.tagged(binaryTag, base64.StdEncoding.EncodeToString([]byte(v)))
+	}
+
+	style := yaml_PLAIN_SCALAR_STYLE
+
+	if t, _ := resolvePlain(v); t != objconv.String || isBase60Float(v) || isTimestamp(v) {
+		// Quoted so it isn't parsed as a value of a different type.
+		style = yaml_DOUBLE_QUOTED_SCALAR_STYLE
+	} else if strings.Contains(v, "\n") {
+		style = yaml_LITERAL_SCALAR_STYLE
+	}
+
+	return e.scalar(v, style)
 }
 
 func (e *Emitter) EmitBytes(v []byte) error {
-	return e.emit(base64.StdEncoding.EncodeToString(v))
+	return e.EmitString(base64.StdEncoding.EncodeToString(v))
 }
 
 func (e *Emitter) EmitTime(v time.Time) error {
-	return e.emit(v.Format(time.RFC3339Nano))
+	return e.EmitString(v.Format(time.RFC3339Nano))
 }
 
 func (e *Emitter) EmitDuration(v time.Duration) error {
-	return e.emit(v.String())
+	return e.EmitString(v.String())
 }
 
 func (e *Emitter) EmitError(v error) error {
-	return e.emit(v.Error())
+	return e.EmitString(v.Error())
 }
 
 func (e *Emitter) EmitArrayBegin(_ int) (err error) {
-	e.push(&arrayEmitter{})
-	return
+	if e.stream && !e.docs && e.depth == 0 {
+		e.docs = true
+		return
+	}
+
+	if err = e.begin(); err != nil {
+		return
+	}
+
+	yaml_sequence_start_event_initialize(&e.event, nil, nil, true, yaml_BLOCK_SEQUENCE_STYLE)
+	e.depth++
+
+	if e.flow > 0 {
+		// The sequence is written in flow style when it ends if it only
+		// contains scalars and fits within the configured width.
+		e.seq = append(e.seq[:0], e.event)
+		e.width = 0
+		e.pending = true
+		return
+	}
+
+	return e.emit()
 }
 
 func (e *Emitter) EmitArrayEnd() (err error) {
-	e.emit(e.pop().value())
-	return
+	if e.docs && e.depth == 0 {
+		e.docs = false
+		return
+	}
+
+	if e.pending {
+		e.seq[0].style = yaml_style_t(yaml_FLOW_SEQUENCE_STYLE)
+		if err = e.release(); err != nil {
+			return
+		}
+	}
+
+	yaml_sequence_end_event_initialize(&e.event)
+	return e.close()
 }
 
 func (e *Emitter) EmitArrayNext() (err error) {
@@ -81,13 +168,18 @@ func (e *Emitter) EmitArrayNext() (err error) {
 }
 
 func (e *Emitter) EmitMapBegin(_ int) (err error) {
-	e.push(&mapEmitter{})
-	return
+	if err = e.begin(); err != nil {
+		return
+	}
+
+	yaml_mapping_start_event_initialize(&e.event, nil, nil, true, yaml_BLOCK_MAPPING_STYLE)
+	e.depth++
+	return e.emit()
 }
 
 func (e *Emitter) EmitMapEnd() (err error) {
-	e.emit(e.pop().value())
-	return
+	yaml_mapping_end_event_initialize(&e.event)
+	return e.close()
 }
 
 func (e *Emitter) EmitMapValue() (err error) {
@@ -102,65 +194,255 @@ func (e *Emitter) TextEmitter() bool {
 	return true
 }
 
-func (e *Emitter) emit(v interface{}) (err error) {
-	var b []byte
+func (e *Emitter) PrettyEmitter() objconv.Emitter {
+	p := NewPrettyEmitter(e.w)
+	p.stream = e.stream
+	return p
+}
+
+// PrettyEmitter is a YAML emitter which writes sequences of short scalars in
+// flow style, and indents nested values by a configurable number of spaces.
+type PrettyEmitter struct {
+	Emitter
+}
 
-	if n := len(e.stack); n != 0 {
-		e.stack[n-1].emit(v)
-		return
+func NewPrettyEmitter(w io.Writer) *PrettyEmitter {
+	return &PrettyEmitter{
+		Emitter: Emitter{w: w, indent: 2, flow: 60},
+	}
+}
+
+// SetIndent sets the number of spaces that nested values are indented by, it
+// must be between 2 and 9 and takes effect on the next stream written by the
+// emitter.
+func (e *PrettyEmitter) SetIndent(n int) {
+	e.indent = n
+}
+
+// SetFlowWidth sets the maximum width of the sequences of scalars that are
+// written in flow style, zero disables the flow style.
+func (e *PrettyEmitter) SetFlowWidth(n int) {
+	e.flow = n
+}
+
+// begin starts a new document if the emitter is about to write a value at the
+// top level, and writes the sequence being held if a collection is nested in
+// it.
+func (e *Emitter) begin() (err error) {
+	if e.err != nil {
+		return e.err
+	}
+
+	if e.pending {
+		return e.release()
 	}
 
-	if b, err = yaml.Marshal(v); err != nil {
+	if e.depth != 0 {
 		return
 	}
 
-	_, err = e.w.Write(b)
+	if !e.started {
+		e.start()
+
+		yaml_stream_start_event_initialize(&e.event, yaml_UTF8_ENCODING)
+		if err = e.emit(); err != nil {
+			return
+		}
+
+		e.started = true
+	}
+
+	yaml_document_start_event_initialize(&e.event, nil, nil, true)
+	return e.emit()
+}
+
+// start initializes the libyaml emitter, reusing the buffers of the previous
+// stream.
+func (e *Emitter) start() {
+	if cap(e.emitter.buffer) == 0 {
+		yaml_emitter_initialize(&e.emitter)
+	} else {
+		e.emitter = yaml_emitter_t{
+			buffer:     e.emitter.buffer,
+			raw_buffer: e.emitter.raw_buffer[:0],
+			states:     e.emitter.states[:0],
+			events:     e.emitter.events[:0],
+		}
+	}
+
+	e.emitter.write_handler = e.write
+	yaml_emitter_set_unicode(&e.emitter, true)
+
+	if e.indent != 0 {
+		yaml_emitter_set_indent(&e.emitter, e.indent)
+	}
+}
+
+// close writes the end of the sequence or mapping held in e.event, and ends
+// the document if it was the top-level value.
+func (e *Emitter) close() (err error) {
+	if e.err != nil {
+		return e.err
+	}
+
+	e.depth--
+
+	if err = e.emit(); err == nil && e.depth == 0 {
+		err = e.end()
+	}
+
 	return
 }
 
-func (e *Emitter) push(v emitter) {
-	e.stack = append(e.stack, v)
+// end writes the end of the current document, which flushes the output.
+func (e *Emitter) end() error {
+	yaml_document_end_event_initialize(&e.event, true)
+	return e.emit()
 }
 
-func (e *Emitter) pop() emitter {
-	i := len(e.stack) - 1
-	v := e.stack[i]
-	e.stack = e.stack[:i]
-	return v
+func (e *Emitter) scalar(s string, style yaml_scalar_style_t) error {
+	return e.value(nil, s, style)
 }
 
-type emitter interface {
-	emit(interface{})
-	value() interface{}
+func (e *Emitter) tagged(tag string, s string) error {
+	return e.value([]byte(tag), s, yaml_PLAIN_SCALAR_STYLE)
 }
 
-type arrayEmitter struct {
-	self []interface{}
+func (e *Emitter) value(tag []byte, s string, style yaml_scalar_style_t) (err error) {
+	if e.pending {
+		// Values are never nested in scalars, the pending sequence doesn't
+		// have to be written when it can still be written in flow style.
+		if e.err != nil {
+			return e.err
+		}
+
+		implicit := len(tag) == 0
+		yaml_scalar_event_initialize(&e.event, nil, tag, []byte(s), implicit, implicit, style)
+		e.seq = append(e.seq, e.event)
+
+		if e.width += len(s) + 2; e.width > e.flow || style == yaml_LITERAL_SCALAR_STYLE {
+			err = e.release()
+		}
+		return
+	}
+
+	if err = e.begin(); err != nil {
+		return
+	}
+
+	implicit := len(tag) == 0
+	yaml_scalar_event_initialize(&e.event, nil, tag, []byte(s), implicit, implicit, style)
+
+	if err = e.emit(); err == nil && e.depth == 0 {
+		err = e.end()
+	}
+
+	return
 }
 
-func (e *arrayEmitter) emit(v interface{}) {
-	e.self = append(e.self, v)
+// release writes the events of the sequence being held.
+func (e *Emitter) release() (err error) {
+	e.pending = false
+
+	for i := range e.seq {
+		e.event = e.seq[i]
+		if err = e.emit(); err != nil {
+			break
+		}
+	}
+
+	e.seq = e.seq[:0]
+	return
 }
 
-func (e *arrayEmitter) value() interface{} {
-	return e.self
+// emit passes e.event to the libyaml emitter.
+func (e *Emitter) emit() error {
+	if e.err != nil {
+		return e.err
+	}
+
+	if !yaml_emitter_emit(&e.emitter, &e.event) {
+		if e.err == nil {
+			problem := e.emitter.problem
+			if len(problem) == 0 {
+				problem = "unknown problem generating YAML content"
+			}
+			e.err = errors.New("objconv/yaml: " + problem)
+		}
+		return e.err
+	}
+
+	// The libyaml emitter queues the events it needs to look ahead at, the
+	// queue is reset when it is empty so it doesn't grow with the output.
+	if e.emitter.events_head == len(e.emitter.events) {
+		e.emitter.events = e.emitter.events[:0]
+		e.emitter.events_head = 0
+	}
+
+	return nil
 }
 
-type mapEmitter struct {
-	self yaml.MapSlice
-	val  bool
+// write is the write handler of the libyaml emitter.
+func (e *Emitter) write(_ *yaml_emitter_t, b []byte) (err error) {
+	if _, err = e.w.Write(b); err != nil {
+		e.err = err
+	}
+	return
 }
 
-func (e *mapEmitter) emit(v interface{}) {
-	if e.val {
-		e.val = false
-		e.self[len(e.self)-1].Value = v
-	} else {
-		e.val = true
-		e.self = append(e.self, yaml.MapItem{Key: v})
+func formatFloat(v float64, bitSize int) string {
+	s := strconv.FormatFloat(v, 'g', -1, bitSize)
+
+	switch s {
+	case "+Inf":
+		s = ".inf"
+	case "-Inf":
+		s = "-.inf"
+	case "NaN":
+		s = ".nan"
 	}
+
+	return s
+}
+
+// isBase60Float returns whether s is in base 60 notation as defined in YAML
+// 1.1, such strings are quoted for compatibility with other parsers.
+func isBase60Float(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	if c := s[0]; !(c == '+' || c == '-' || (c >= '0' && c <= '9')) || strings.IndexByte(s, ':') < 0 {
+		return false
+	}
+	return base60float.MatchString(s)
 }
 
-func (e *mapEmitter) value() interface{} {
-	return e.self
+var base60float = regexp.MustCompile(`^[-+]?[0-9][0-9_]*(?::[0-5]?[0-9])+(?:\.[0-9_]*)?$`)
+
+// timestampFormats are the formats of the timestamps that gopkg.in/yaml.v2
+// recognizes in plain scalars.
+var timestampFormats = [...]string{
+	"2006-1-2T15:4:5.999999999Z07:00",
+	"2006-1-2t15:4:5.999999999Z07:00",
+	"2006-1-2 15:4:5.999999999",
+	"2006-1-2",
+}
+
+// isTimestamp returns true if s would be read as a timestamp by parsers that
+// resolve the !!timestamp tag, the parser reads such plain scalars as strings.
+func isTimestamp(s string) bool {
+	// All the formats start with YYYY-.
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	if i != 4 || i == len(s) || s[i] != '-' {
+		return false
+	}
+	for _, format := range timestampFormats {
+		if _, err := time.Parse(format, s); err == nil {
+			return true
+		}
+	}
+	return false
 }
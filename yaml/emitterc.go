@@ -25,6 +25,9 @@ func put(emitter *yaml_emitter_t, value byte) bool {
 
 // Put a line break to the output buffer.
 func put_break(emitter *yaml_emitter_t) bool {
+	if len(emitter.line_comment) > 0 && !yaml_emitter_write_line_comment(emitter) {
+		return false
+	}
 	if emitter.buffer_pos+5 >= len(emitter.buffer) && !yaml_emitter_flush(emitter) {
 		return false
 	}
@@ -129,10 +132,9 @@ func yaml_emitter_emit(emitter *yaml_emitter_t, event *yaml_event_t) bool {
 // Check if we need to accumulate more events before emitting.
 //
 // We accumulate extra
-//  - 1 event for DOCUMENT-START
-//  - 2 events for SEQUENCE-START
-//  - 3 events for MAPPING-START
-//
+//   - 1 event for DOCUMENT-START
+//   - 2 events for SEQUENCE-START
+//   - 3 events for MAPPING-START
 func yaml_emitter_need_more_events(emitter *yaml_emitter_t) bool {
 	if emitter.events_head == len(emitter.events) {
 		return true
@@ -423,6 +425,9 @@ func yaml_emitter_emit_document_start(emitter *yaml_emitter_t, event *yaml_event
 
 // Expect the root node.
 func yaml_emitter_emit_document_content(emitter *yaml_emitter_t, event *yaml_event_t) bool {
+	if !yaml_emitter_write_comment_lines(emitter, event.head_comment) {
+		return false
+	}
 	emitter.states = append(emitter.states, yaml_EMIT_DOCUMENT_END_STATE)
 	return yaml_emitter_emit_node(emitter, event, true, false, false, false)
 }
@@ -435,6 +440,9 @@ func yaml_emitter_emit_document_end(emitter *yaml_emitter_t, event *yaml_event_t
 	if !yaml_emitter_write_indent(emitter) {
 		return false
 	}
+	if !yaml_emitter_write_comment_lines(emitter, event.foot_comment) {
+		return false
+	}
 	if !event.implicit {
 		// [Go] Allocate the slice elsewhere.
 		if !yaml_emitter_write_indicator(emitter, []byte("..."), true, false, false) {
@@ -582,12 +590,18 @@ func yaml_emitter_emit_block_sequence_item(emitter *yaml_emitter_t, event *yaml_
 		}
 	}
 	if event.typ == yaml_SEQUENCE_END_EVENT {
+		if !yaml_emitter_write_comment_lines(emitter, event.foot_comment) {
+			return false
+		}
 		emitter.indent = emitter.indents[len(emitter.indents)-1]
 		emitter.indents = emitter.indents[:len(emitter.indents)-1]
 		emitter.state = emitter.states[len(emitter.states)-1]
 		emitter.states = emitter.states[:len(emitter.states)-1]
 		return true
 	}
+	if !yaml_emitter_write_comment_lines(emitter, event.head_comment) {
+		return false
+	}
 	if !yaml_emitter_write_indent(emitter) {
 		return false
 	}
@@ -606,12 +620,18 @@ func yaml_emitter_emit_block_mapping_key(emitter *yaml_emitter_t, event *yaml_ev
 		}
 	}
 	if event.typ == yaml_MAPPING_END_EVENT {
+		if !yaml_emitter_write_comment_lines(emitter, event.foot_comment) {
+			return false
+		}
 		emitter.indent = emitter.indents[len(emitter.indents)-1]
 		emitter.indents = emitter.indents[:len(emitter.indents)-1]
 		emitter.state = emitter.states[len(emitter.states)-1]
 		emitter.states = emitter.states[:len(emitter.states)-1]
 		return true
 	}
+	if !yaml_emitter_write_comment_lines(emitter, event.head_comment) {
+		return false
+	}
 	if !yaml_emitter_write_indent(emitter) {
 		return false
 	}
@@ -666,7 +686,6 @@ func yaml_emitter_emit_node(emitter *yaml_emitter_t, event *yaml_event_t,
 		return yaml_emitter_set_emitter_error(emitter,
 			"expected SCALAR, SEQUENCE-START, MAPPING-START, or ALIAS")
 	}
-	return false
 }
 
 // Expect ALIAS.
@@ -674,6 +693,7 @@ func yaml_emitter_emit_alias(emitter *yaml_emitter_t, event *yaml_event_t) bool
 	if !yaml_emitter_process_anchor(emitter) {
 		return false
 	}
+	yaml_emitter_append_line_comment(emitter, event.line_comment)
 	emitter.state = emitter.states[len(emitter.states)-1]
 	emitter.states = emitter.states[:len(emitter.states)-1]
 	return true
@@ -693,9 +713,24 @@ func yaml_emitter_emit_scalar(emitter *yaml_emitter_t, event *yaml_event_t) bool
 	if !yaml_emitter_increase_indent(emitter, true, false) {
 		return false
 	}
+
+	// [Go] The comment of block scalars is written after their header, other
+	// scalars may span multiple lines so the comment is written after them.
+	style := emitter.scalar_data.style
+	block := style == yaml_LITERAL_SCALAR_STYLE || style == yaml_FOLDED_SCALAR_STYLE
+	comment := emitter.line_comment
+	if block {
+		yaml_emitter_append_line_comment(emitter, event.line_comment)
+	} else {
+		emitter.line_comment = nil
+	}
 	if !yaml_emitter_process_scalar(emitter) {
 		return false
 	}
+	if !block {
+		emitter.line_comment = comment
+		yaml_emitter_append_line_comment(emitter, event.line_comment)
+	}
 	emitter.indent = emitter.indents[len(emitter.indents)-1]
 	emitter.indents = emitter.indents[:len(emitter.indents)-1]
 	emitter.state = emitter.states[len(emitter.states)-1]
@@ -711,6 +746,7 @@ func yaml_emitter_emit_sequence_start(emitter *yaml_emitter_t, event *yaml_event
 	if !yaml_emitter_process_tag(emitter) {
 		return false
 	}
+	yaml_emitter_append_line_comment(emitter, event.line_comment)
 	if emitter.flow_level > 0 || emitter.canonical || event.sequence_style() == yaml_FLOW_SEQUENCE_STYLE ||
 		yaml_emitter_check_empty_sequence(emitter) {
 		emitter.state = yaml_EMIT_FLOW_SEQUENCE_FIRST_ITEM_STATE
@@ -728,6 +764,7 @@ func yaml_emitter_emit_mapping_start(emitter *yaml_emitter_t, event *yaml_event_
 	if !yaml_emitter_process_tag(emitter) {
 		return false
 	}
+	yaml_emitter_append_line_comment(emitter, event.line_comment)
 	if emitter.flow_level > 0 || emitter.canonical || event.mapping_style() == yaml_FLOW_MAPPING_STYLE ||
 		yaml_emitter_check_empty_mapping(emitter) {
 		emitter.state = yaml_EMIT_FLOW_MAPPING_FIRST_KEY_STATE
@@ -1218,6 +1255,71 @@ func yaml_emitter_write_indent(emitter *yaml_emitter_t) bool {
 	return true
 }
 
+// [Go] Write comment lines at the current indentation, the comment lines must
+// start with a '#' indicator or be empty, empty lines are written as blank
+// lines.
+func yaml_emitter_write_comment_lines(emitter *yaml_emitter_t, comment []byte) bool {
+	for len(comment) > 0 {
+		line := comment
+		if i := bytes.IndexByte(comment, '\n'); i >= 0 {
+			line, comment = comment[:i], comment[i+1:]
+		} else {
+			comment = nil
+		}
+		if len(line) == 0 {
+			if emitter.column > 0 && !put_break(emitter) {
+				return false
+			}
+			if !put_break(emitter) {
+				return false
+			}
+			emitter.whitespace = true
+			emitter.indention = true
+			continue
+		}
+		if !yaml_emitter_write_indent(emitter) {
+			return false
+		}
+		for i := 0; i < len(line); {
+			if !write(emitter, line, &i) {
+				return false
+			}
+		}
+		if !put_break(emitter) {
+			return false
+		}
+		emitter.whitespace = true
+		emitter.indention = true
+	}
+	return true
+}
+
+// [Go] Add a comment to the one written at the end of the current line.
+func yaml_emitter_append_line_comment(emitter *yaml_emitter_t, comment []byte) {
+	if len(comment) == 0 {
+		return
+	}
+	if len(emitter.line_comment) > 0 {
+		emitter.line_comment = append(emitter.line_comment, ' ')
+	}
+	emitter.line_comment = append(emitter.line_comment, comment...)
+}
+
+// [Go] Write the comment that ends the current line.
+func yaml_emitter_write_line_comment(emitter *yaml_emitter_t) bool {
+	comment := emitter.line_comment
+	emitter.line_comment = nil
+	if emitter.column > 0 && !put(emitter, ' ') {
+		return false
+	}
+	for i := 0; i < len(comment); {
+		if !write(emitter, comment, &i) {
+			return false
+		}
+	}
+	return true
+}
+
 func yaml_emitter_write_indicator(emitter *yaml_emitter_t, indicator []byte, need_whitespace, is_whitespace, is_indention bool) bool {
 	if need_whitespace && !emitter.whitespace {
 		if !put(emitter, ' ') {
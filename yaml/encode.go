@@ -13,15 +13,17 @@ func NewEncoder(w io.Writer) *objconv.Encoder {
 	return objconv.NewEncoder(NewEmitter(w))
 }
 
-// NewStreamEncoder returns a new YAML stream encoder that writes to w.
+// NewStreamEncoder returns a new YAML stream encoder that writes to w, each
+// value is written as a separate document.
 func NewStreamEncoder(w io.Writer) *objconv.StreamEncoder {
-	return objconv.NewStreamEncoder(NewEmitter(w))
+	return objconv.NewStreamEncoder(NewStreamEmitter(w))
 }
 
 // Marshal writes the YAML representation of v to a byte slice returned in b.
 func Marshal(v interface{}) (b []byte, err error) {
 	m := marshalerPool.Get().(*marshaler)
 	m.b.Truncate(0)
+	m.Reset(&m.b)
 
 	if err = (objconv.Encoder{Emitter: m}).Encode(v); err == nil {
 		b = make([]byte, m.b.Len())
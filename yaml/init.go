@@ -10,6 +10,9 @@ import (
 var Codec = objconv.Codec{
 	NewEmitter: func(w io.Writer) objconv.Emitter { return NewEmitter(w) },
 	NewParser:  func(r io.Reader) objconv.Parser { return NewParser(r) },
+
+	NewStreamEmitter: func(w io.Writer) objconv.Emitter { return NewStreamEmitter(w) },
+	NewStreamParser:  func(r io.Reader) objconv.Parser { return NewStreamParser(r) },
 }
 
 func init() {
@@ -0,0 +1,730 @@
+package yaml
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dolab/objconv"
+)
+
+// Kind identifies the kind of a YAML node.
+type Kind uint32
+
+const (
+	DocumentNode Kind = 1 << iota
+	SequenceNode
+	MappingNode
+	ScalarNode
+	AliasNode
+)
+
+// Style controls the representation of a YAML node.
+type Style uint32
+
+const (
+	DoubleQuotedStyle Style = 1 << iota
+	SingleQuotedStyle
+	LiteralStyle
+	FoldedStyle
+	FlowStyle
+)
+
+// Node represents a YAML document, or a value in a document, with the details
+// of its representation: comments, anchors, aliases, tags, scalar styles and
+// the order of mapping keys.
+//
+// Nodes are decoded and encoded like any other value. When they are decoded by
+// a YAML parser and encoded by a YAML emitter the details of the document are
+// preserved, so documents can be modified and written back with minimal
+// changes. Other formats load and produce plain values.
+type Node struct {
+	Kind  Kind
+	Style Style
+
+	// Tag is the explicit tag of the node in its short form (e.g. "!!str"),
+	// it is empty if the tag is implied by the value.
+	Tag string
+
+	// Value is the value of scalars, or the name of the anchor referenced by
+	// aliases.
+	Value string
+
+	// Anchor is the name of the anchor defined on the node.
+	Anchor string
+
+	// Alias is the node referenced by aliases, it is nil if the anchor was
+	// defined outside of the node that was decoded.
+	Alias *Node
+
+	// Content holds the root node of documents, the items of sequences, or
+	// the keys and values of mappings in alternation.
+	Content []*Node
+
+	// HeadComment holds the comment lines before the node, LineComment the
+	// comment at the end of its line, and FootComment the comment lines at
+	// the end of documents and collections. Comments include their '#'
+	// indicator, it is added when the comments are written if it is missing.
+	HeadComment string
+	LineComment string
+	FootComment string
+
+	// Line and Column are the position of the node in the input, they are
+	// zero if the node was not decoded from YAML.
+	Line   int
+	Column int
+}
+
+// DecodeValue satisfies the objconv.ValueDecoder interface.
+func (n *Node) DecodeValue(d objconv.Decoder) error {
+	if p, ok := d.Parser.(nodeParser); ok {
+		return p.parseNode(n)
+	}
+	return n.decode(d)
+}
+
+// EncodeValue satisfies the objconv.ValueEncoder interface.
+func (n Node) EncodeValue(e objconv.Encoder) error {
+	if m, ok := e.Emitter.(nodeEmitter); ok {
+		return m.emitNode(&n)
+	}
+	return n.encode(e)
+}
+
+// nodeParser is implemented by the YAML parser to load nodes from the events
+// of the document instead of their values.
+type nodeParser interface {
+	parseNode(*Node) error
+}
+
+// nodeEmitter is implemented by the YAML emitter to write nodes with the
+// details of their representation.
+type nodeEmitter interface {
+	emitNode(*Node) error
+}
+
+func (n *Node) decode(d objconv.Decoder) (err error) {
+	var t objconv.Type
+
+	if t, err = d.Parser.ParseType(); err != nil {
+		return
+	}
+
+	*n = Node{Kind: ScalarNode}
+
+	switch t {
+	case objconv.Nil:
+		err = d.Parser.ParseNil()
+		n.Value = "null"
+
+	case objconv.Bool:
+		var v bool
+		v, err = d.Parser.ParseBool()
+		n.Value = strconv.FormatBool(v)
+
+	case objconv.Int:
+		var v int64
+		v, err = d.Parser.ParseInt()
+		n.Value = strconv.FormatInt(v, 10)
+
+	case objconv.Uint:
+		var v uint64
+		v, err = d.Parser.ParseUint()
+		n.Value = strconv.FormatUint(v, 10)
+
+	case objconv.Float:
+		var v float64
+		v, err = d.Parser.ParseFloat()
+		n.Value = formatFloat(v, 64)
+
+	case objconv.String:
+		var v []byte
+		v, err = d.Parser.ParseString()
+		n.setString(string(v))
+
+	case objconv.Bytes:
+		var v []byte
+		v, err = d.Parser.ParseBytes()
+		n.Tag, n.Value = "!!binary", base64.StdEncoding.EncodeToString(v)
+
+	case objconv.Time:
+		var v time.Time
+		v, err = d.Parser.ParseTime()
+		n.setString(v.Format(time.RFC3339Nano))
+
+	case objconv.Duration:
+		var v time.Duration
+		v, err = d.Parser.ParseDuration()
+		n.setString(v.String())
+
+	case objconv.Error:
+		var v error
+		v, err = d.Parser.ParseError()
+		n.setString(v.Error())
+
+	case objconv.Array:
+		n.Kind = SequenceNode
+		err = d.DecodeArray(func(d objconv.Decoder) error {
+			c := &Node{}
+			n.Content = append(n.Content, c)
+			return d.Decode(c)
+		})
+
+	case objconv.Map:
+		n.Kind = MappingNode
+		err = d.DecodeMap(func(k objconv.Decoder, v objconv.Decoder) (err error) {
+			key, val := &Node{}, &Node{}
+			n.Content = append(n.Content, key, val)
+			if err = k.Decode(key); err != nil {
+				return
+			}
+			return v.Decode(val)
+		})
+
+	default:
+		err = fmt.Errorf("objconv/yaml: cannot decode %s values into a node", t)
+	}
+
+	return
+}
+
+func (n *Node) setString(s string) {
+	n.Value = s
+
+	if t, _ := resolvePlain(s); t != objconv.String {
+		n.Style = DoubleQuotedStyle
+	}
+}
+
+func (n *Node) encode(e objconv.Encoder) error {
+	switch n.Kind {
+	case DocumentNode:
+		if len(n.Content) == 0 {
+			return e.Encode(nil)
+		}
+		return n.Content[0].encode(e)
+
+	case AliasNode:
+		if n.Alias == nil {
+			return fmt.Errorf("objconv/yaml: unknown anchor %q referenced", n.Value)
+		}
+		return n.Alias.encode(e)
+
+	case ScalarNode:
+		plain := n.Style&(DoubleQuotedStyle|SingleQuotedStyle|LiteralStyle|FoldedStyle) == 0
+		t, v, err := resolve(longTag(n.Tag), []byte(n.Value), plain)
+		if err != nil {
+			return err
+		}
+		switch t {
+		case objconv.Nil:
+			return e.Encode(nil)
+		case objconv.String:
+			if b, ok := v.([]byte); ok {
+				return e.Encode(b)
+			}
+			return e.Encode(n.Value)
+		default:
+			return e.Encode(v)
+		}
+
+	case SequenceNode:
+		i := 0
+		return e.EncodeArray(len(n.Content), func(e objconv.Encoder) (err error) {
+			err = n.Content[i].encode(e)
+			i++
+			return
+		})
+
+	case MappingNode:
+		if len(n.Content)%2 != 0 {
+			return errors.New("objconv/yaml: mapping nodes must have an even number of keys and values")
+		}
+		i := 0
+		return e.EncodeMap(len(n.Content)/2, func(k objconv.Encoder, v objconv.Encoder) (err error) {
+			if err = n.Content[i].encode(k); err != nil {
+				return
+			}
+			err = n.Content[i+1].encode(v)
+			i += 2
+			return
+		})
+	}
+
+	return fmt.Errorf("objconv/yaml: invalid node kind: %d", n.Kind)
+}
+
+// parseNode loads in n the node that the parser is positioned on, the node is
+// a document node with the comments around its root if it is at the top level.
+func (p *Parser) parseNode(n *Node) (err error) {
+	var ev *yaml_event_t
+	var root = p.depth == 0
+
+	p.nodes++
+	defer func() { p.nodes-- }()
+
+	if root {
+		if ev, err = p.document(); err != nil {
+			return
+		}
+		if ev.typ == yaml_STREAM_END_EVENT {
+			return io.EOF
+		}
+	}
+
+	b := nodeBuilder{
+		p:       p,
+		anchors: make(map[string]*Node),
+		lines:   make(map[int]*span),
+	}
+
+	// Comments don't match the positions of events that are replayed from
+	// aliases.
+	comments := !p.replay
+
+	var c *Node
+	var end yaml_mark_t
+
+	if c, end, err = b.node(true); err != nil {
+		return
+	}
+
+	if !root {
+		if comments {
+			b.attach(c, end.index)
+		}
+		*n = *c
+		return
+	}
+
+	// The next event is loaded so the scanner reads the comments that follow
+	// the root node.
+	if ev, err = p.peek(); err != nil {
+		return
+	}
+
+	*n = Node{
+		Kind:    DocumentNode,
+		Content: []*Node{c},
+		Line:    c.Line,
+		Column:  c.Column,
+	}
+
+	if comments {
+		b.attach(n, ev.start_mark.index)
+		n.HeadComment, c.HeadComment = c.HeadComment, ""
+	}
+
+	return
+}
+
+// nodeBuilder loads nodes from the events of the parser, and keeps track of
+// their positions to attach comments to them.
+type nodeBuilder struct {
+	p       *Parser
+	anchors map[string]*Node
+	entries []*span       // keys and items, in the order of the input
+	lines   map[int]*span // last node ending on each line
+	next    int           // index of the next entry in entries
+}
+
+type span struct {
+	node       *Node
+	start, end yaml_mark_t
+}
+
+func (b *nodeBuilder) node(entry bool) (n *Node, end yaml_mark_t, err error) {
+	var ev *yaml_event_t
+	var p = b.p
+
+	if ev, err = p.peek(); err != nil {
+		return
+	}
+
+	s := &span{
+		node:  &Node{Line: ev.start_mark.line + 1, Column: ev.start_mark.column + 1},
+		start: ev.start_mark,
+	}
+	n = s.node
+
+	if entry {
+		b.entries = append(b.entries, s)
+	}
+
+	switch ev.typ {
+	case yaml_ALIAS_EVENT:
+		name := string(ev.anchor)
+		events, ok := p.anchors[name]
+
+		n.Kind, n.Value, n.Alias = AliasNode, name, b.anchors[name]
+		end = ev.end_mark
+		p.skip()
+
+		if !ok && n.Alias == nil {
+			err = p.fail(fmt.Errorf("objconv/yaml: unknown anchor %q referenced", name))
+			return
+		}
+
+		// The events of anchored nodes that contain the alias are recorded
+		// as if it was expanded, like the parser does.
+		for i := range p.records {
+			p.records[i].events = append(p.records[i].events, events...)
+		}
+
+		b.inline(s, end)
+		return
+
+	case yaml_SCALAR_EVENT:
+		n.Kind, n.Value = ScalarNode, string(ev.value)
+		n.Anchor, n.Tag = string(ev.anchor), shortTag(ev.tag)
+
+		switch ev.scalar_style() {
+		case yaml_DOUBLE_QUOTED_SCALAR_STYLE:
+			n.Style = DoubleQuotedStyle
+		case yaml_SINGLE_QUOTED_SCALAR_STYLE:
+			n.Style = SingleQuotedStyle
+		case yaml_LITERAL_SCALAR_STYLE:
+			n.Style = LiteralStyle
+		case yaml_FOLDED_SCALAR_STYLE:
+			n.Style = FoldedStyle
+		}
+
+		end = ev.end_mark
+		if _, err = p.next(); err != nil {
+			return
+		}
+
+		b.define(n)
+		b.inline(s, end)
+		return
+
+	case yaml_SEQUENCE_START_EVENT:
+		n.Kind = SequenceNode
+		if ev.sequence_style() == yaml_FLOW_SEQUENCE_STYLE {
+			n.Style = FlowStyle
+		}
+
+	case yaml_MAPPING_START_EVENT:
+		n.Kind = MappingNode
+		if ev.mapping_style() == yaml_FLOW_MAPPING_STYLE {
+			n.Style = FlowStyle
+		}
+
+	default:
+		err = p.fail(fmt.Errorf("objconv/yaml: expected a value but found %s", eventString(ev.typ)))
+		return
+	}
+
+	n.Anchor, n.Tag = string(ev.anchor), shortTag(ev.tag)
+
+	if _, err = p.next(); err != nil {
+		return
+	}
+
+	for {
+		if ev, err = p.peek(); err != nil {
+			return
+		}
+
+		if ev.typ == yaml_SEQUENCE_END_EVENT || ev.typ == yaml_MAPPING_END_EVENT {
+			end = ev.end_mark
+			if _, err = p.next(); err != nil {
+				return
+			}
+			break
+		}
+
+		var c *Node
+		// Keys of mappings and items of sequences are the entries that head
+		// comments are attached to.
+		if c, _, err = b.node(n.Kind == SequenceNode || len(n.Content)%2 == 0); err != nil {
+			return
+		}
+
+		n.Content = append(n.Content, c)
+	}
+
+	b.define(n)
+
+	if n.Style == FlowStyle {
+		b.inline(s, end)
+	}
+
+	return
+}
+
+func (b *nodeBuilder) define(n *Node) {
+	if len(n.Anchor) != 0 {
+		b.anchors[n.Anchor] = n
+	}
+}
+
+// inline records that the node of s is the last one ending on its line so far,
+// it receives the comment at the end of that line if there is one.
+func (b *nodeBuilder) inline(s *span, end yaml_mark_t) {
+	s.end = end
+	b.lines[end.line] = s
+}
+
+// attach sets the comments read before the limit offset on the nodes that were
+// loaded, comments which are not followed by any node are set as the foot
+// comment of n.
+func (b *nodeBuilder) attach(n *Node, limit int) {
+	var comments = b.p.parser.comments
+	var lines []*string
+	var i int
+
+	for ; i < len(comments) && comments[i].start_mark.index < limit; i++ {
+		c := &comments[i]
+
+		if s := b.lines[c.start_mark.line]; s != nil && len(c.value) != 0 && s.end.index <= c.start_mark.index {
+			if len(s.node.LineComment) != 0 {
+				s.node.LineComment += " "
+			}
+			s.node.LineComment += string(c.value)
+			continue
+		}
+
+		for b.next < len(b.entries) && b.entries[b.next].start.index < c.start_mark.index {
+			b.next++
+		}
+
+		var text *string
+
+		if b.next < len(b.entries) {
+			text = &b.entries[b.next].node.HeadComment
+		} else {
+			text = &n.FootComment
+		}
+
+		// Lines are terminated by line breaks until all comments are set,
+		// so that blank lines are not lost.
+		if len(*text) == 0 {
+			lines = append(lines, text)
+		}
+		*text += string(c.value) + "\n"
+	}
+
+	for _, text := range lines {
+		// The last line break is kept if the comment ends with a blank line.
+		if s := *text; len(s) > 1 && s[len(s)-2] != '\n' {
+			*text = s[:len(s)-1]
+		}
+	}
+
+	b.p.parser.comments = comments[:copy(comments, comments[i:])]
+}
+
+// emitNode writes the events of n, the node starts a new document if it is
+// written at the top level.
+func (e *Emitter) emitNode(n *Node) (err error) {
+	var doc *Node
+
+	if n.Kind == DocumentNode {
+		doc = n
+		if len(n.Content) == 0 {
+			n = &Node{Kind: ScalarNode, Value: "null"}
+		} else {
+			n = n.Content[0]
+		}
+	}
+
+	if err = e.begin(); err != nil {
+		return
+	}
+
+	root := e.depth == 0
+	head := ""
+
+	if root && doc != nil {
+		head = doc.HeadComment
+	}
+
+	if err = e.node(n, head, ""); err != nil || !root {
+		return
+	}
+
+	yaml_document_end_event_initialize(&e.event, true)
+
+	if doc != nil {
+		e.event.foot_comment = commentLines(doc.FootComment)
+	}
+
+	return e.emit()
+}
+
+// node writes the events of n, head and line are comments added to the ones
+// of the node.
+func (e *Emitter) node(n *Node, head string, line string) (err error) {
+	if len(head) != 0 && len(n.HeadComment) != 0 {
+		head += "\n"
+	}
+	head += n.HeadComment
+
+	if len(line) != 0 && len(n.LineComment) != 0 {
+		line += " "
+	}
+	line += n.LineComment
+
+	tag := []byte(longTag(n.Tag))
+	implicit := len(tag) == 0
+
+	switch n.Kind {
+	case DocumentNode:
+		if len(n.Content) == 0 {
+			return e.node(&Node{Kind: ScalarNode, Value: "null"}, head, line)
+		}
+		return e.node(n.Content[0], head, line)
+
+	case AliasNode:
+		name := n.Value
+		if len(name) == 0 && n.Alias != nil {
+			name = n.Alias.Anchor
+		}
+		yaml_alias_event_initialize(&e.event, []byte(name))
+
+	case ScalarNode:
+		style := yaml_PLAIN_SCALAR_STYLE
+		switch {
+		case n.Style&DoubleQuotedStyle != 0:
+			style = yaml_DOUBLE_QUOTED_SCALAR_STYLE
+		case n.Style&SingleQuotedStyle != 0:
+			style = yaml_SINGLE_QUOTED_SCALAR_STYLE
+		case n.Style&LiteralStyle != 0:
+			style = yaml_LITERAL_SCALAR_STYLE
+		case n.Style&FoldedStyle != 0:
+			style = yaml_FOLDED_SCALAR_STYLE
+		}
+		yaml_scalar_event_initialize(&e.event, []byte(n.Anchor), tag, []byte(n.Value), implicit, implicit, style)
+
+	case SequenceNode:
+		style := yaml_BLOCK_SEQUENCE_STYLE
+		if n.Style&FlowStyle != 0 {
+			style = yaml_FLOW_SEQUENCE_STYLE
+		}
+		yaml_sequence_start_event_initialize(&e.event, []byte(n.Anchor), tag, implicit, style)
+
+	case MappingNode:
+		if len(n.Content)%2 != 0 {
+			return errors.New("objconv/yaml: mapping nodes must have an even number of keys and values")
+		}
+		style := yaml_BLOCK_MAPPING_STYLE
+		if n.Style&FlowStyle != 0 {
+			style = yaml_FLOW_MAPPING_STYLE
+		}
+		yaml_mapping_start_event_initialize(&e.event, []byte(n.Anchor), tag, implicit, style)
+
+	default:
+		return fmt.Errorf("objconv/yaml: invalid node kind: %d", n.Kind)
+	}
+
+	e.event.head_comment = commentLines(head)
+	e.event.line_comment = commentLine(line)
+
+	if err = e.emit(); err != nil {
+		return
+	}
+
+	switch n.Kind {
+	case SequenceNode:
+		for _, c := range n.Content {
+			if err = e.node(c, "", ""); err != nil {
+				return
+			}
+		}
+		yaml_sequence_end_event_initialize(&e.event)
+
+	case MappingNode:
+		for i := 0; i < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+
+			if err = e.node(k, "", ""); err != nil {
+				return
+			}
+
+			if err = e.node(v, "", ""); err != nil {
+				return
+			}
+		}
+		yaml_mapping_end_event_initialize(&e.event)
+
+	default:
+		return
+	}
+
+	e.event.foot_comment = commentLines(n.FootComment)
+	return e.emit()
+}
+
+// commentLines returns the lines of s with a '#' indicator at the beginning
+// of each of them, empty lines are kept as blank lines.
+func commentLines(s string) []byte {
+	if len(s) == 0 {
+		return nil
+	}
+
+	var b []byte
+
+	for i, line := range strings.Split(s, "\n") {
+		if i != 0 {
+			b = append(b, '\n')
+		}
+		if len(strings.TrimSpace(line)) != 0 {
+			b = appendComment(b, line)
+		}
+	}
+
+	return b
+}
+
+// commentLine returns s as a comment which fits on a single line.
+func commentLine(s string) []byte {
+	if len(s) == 0 {
+		return nil
+	}
+
+	var b []byte
+
+	for i, line := range strings.Split(s, "\n") {
+		if i != 0 {
+			b = append(b, ' ')
+		}
+		b = appendComment(b, line)
+	}
+
+	return b
+}
+
+func appendComment(b []byte, s string) []byte {
+	s = strings.TrimSpace(s)
+
+	if !strings.HasPrefix(s, "#") {
+		b = append(b, '#')
+		if len(s) != 0 {
+			b = append(b, ' ')
+		}
+	}
+
+	return append(b, s...)
+}
+
+// shortTag returns the short form of tags from the YAML namespace.
+func shortTag(tag []byte) string {
+	s := string(tag)
+	if strings.HasPrefix(s, tagPrefix) {
+		return "!!" + s[len(tagPrefix):]
+	}
+	return s
+}
+
+// longTag returns the long form of tags from the YAML namespace.
+func longTag(tag string) string {
+	if strings.HasPrefix(tag, "!!") {
+		return tagPrefix + tag[2:]
+	}
+	return tag
+}
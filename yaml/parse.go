@@ -3,127 +3,201 @@ package yaml
 import (
 	"bytes"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"time"
-
-	yaml "gopkg.in/yaml.v2"
+	"unicode/utf8"
 
 	"github.com/dolab/objconv"
 )
 
+// Parser implements a YAML parser that satisfies the objconv.Parser interface.
+//
+// The parser is built on the port of libyaml that gopkg.in/yaml.v2 uses, it
+// produces values as events are read from the input so documents don't have to
+// be loaded in memory. Only the nodes that have an anchor are retained, to be
+// replayed where they are referenced by aliases.
 type Parser struct {
-	r io.Reader // reader to load bytes from
-	s []byte    // string buffer
-	// This stack is used to iterate over the arrays and maps that get loaded in
-	// the value field.
-	stack []parser
+	r      io.Reader
+	parser yaml_parser_t
+	event  yaml_event_t // next event, valid if peeked is true
+	mark   yaml_mark_t  // end of the last event consumed
+	peeked bool         // set when event was loaded but not consumed yet
+	replay bool         // set when event comes from an alias
+	err    error        // the libyaml parser cannot recover from errors
+
+	// In stream mode, the documents are parsed as elements of a top-level
+	// array, docs is set once the array was entered.
+	stream bool
+	docs   bool
+
+	// Type and value of the scalar that the parser is positioned on.
+	typ objconv.Type
+	val interface{}
+
+	s []byte // string buffer
+
+	// Bytes read from r, the part after the last event consumed is returned
+	// by Buffered.
+	b   []byte
+	i   int // offset in b where the last event consumed ends
+	idx int // character index in the input that i matches
+
+	depth   int                       // nesting level of sequences and mappings
+	keys    [][]byte                  // keys of the mappings being parsed
+	levels  []int                     // offsets in keys where each mapping starts
+	anchors map[string][]yaml_event_t // events of the anchored nodes
+	records []record                  // anchored nodes being recorded
+	nodes   int                       // number of nodes being loaded by parseNode
+	replays [][]yaml_event_t          // events of aliased nodes being replayed
+}
+
+type record struct {
+	name   string
+	depth  int
+	events []yaml_event_t
 }
 
 func NewParser(r io.Reader) *Parser {
 	return &Parser{r: r}
 }
 
+// NewStreamParser returns a parser which produces the documents read from r as
+// the elements of an array.
+func NewStreamParser(r io.Reader) *Parser {
+	return &Parser{r: r, stream: true}
+}
+
 func (p *Parser) Reset(r io.Reader) {
-	p.r = r
-	p.s = nil
-	p.stack = nil
+	raw, buf := p.parser.raw_buffer[:0], p.parser.buffer[:0]
+	*p = Parser{
+		r:      r,
+		parser: yaml_parser_t{raw_buffer: raw, buffer: buf},
+		stream: p.stream,
+		s:      p.s,
+		b:      p.b[:0],
+		keys:   p.keys[:0],
+		levels: p.levels[:0],
+	}
 }
 
+// Buffered returns the bytes that were read from the input but were not
+// consumed by the parser yet.
 func (p *Parser) Buffered() io.Reader {
-	return bytes.NewReader(nil)
+	if p.parser.encoding != yaml_UTF8_ENCODING && p.parser.encoding != yaml_ANY_ENCODING {
+		// The positions reported by libyaml are in characters, they only
+		// match the bytes of the input in UTF-8.
+		return bytes.NewReader(nil)
+	}
+	return bytes.NewReader(p.b[p.i:])
 }
 
-func (p *Parser) ParseType() (typ objconv.Type, err error) {
-	if p.stack == nil {
-		var b []byte
-		var v interface{}
+// Position returns the line and column of the next value to be parsed, or of
+// the syntax error that the parser ran into.
+func (p *Parser) Position() (line int, column int) {
+	m := p.mark
 
-		if b, err = ioutil.ReadAll(p.r); err != nil {
-			return
-		}
-		if err = yaml.Unmarshal(b, &v); err != nil {
-			return
+	switch {
+	case p.parser.error != yaml_NO_ERROR:
+		if m = p.parser.problem_mark; m.index == 0 && m.line == 0 && m.column == 0 {
+			m = p.parser.mark
 		}
-		p.push(newParser(v))
+	case p.peeked:
+		m = p.event.start_mark
 	}
 
-	switch v := p.value(); v.(type) {
-	case nil:
-		typ = objconv.Nil
+	return m.line + 1, m.column + 1
+}
 
-	case bool:
-		typ = objconv.Bool
+func (p *Parser) ParseType() (typ objconv.Type, err error) {
+	var ev *yaml_event_t
 
-	case int, int64:
-		typ = objconv.Int
+	if p.stream && !p.docs {
+		return objconv.Array, nil
+	}
 
-	case uint64:
-		typ = objconv.Uint
+	for {
+		if ev, err = p.document(); err != nil {
+			return
+		}
 
-	case float64:
-		typ = objconv.Float
+		switch ev.typ {
+		case yaml_STREAM_END_EVENT:
+			err = io.EOF
 
-	case string:
-		typ = objconv.String
+		case yaml_ALIAS_EVENT:
+			if _, err = p.alias(); err != nil {
+				return
+			}
+			continue
 
-	case yaml.MapSlice:
-		typ = objconv.Map
+		case yaml_SCALAR_EVENT:
+			typ, p.val, err = resolve(string(ev.tag), ev.value, ev.scalar_style() == yaml_PLAIN_SCALAR_STYLE)
+			p.typ = typ
 
-	case []interface{}:
-		typ = objconv.Array
+		case yaml_SEQUENCE_START_EVENT:
+			typ = objconv.Array
 
-	case eof:
-		err = io.EOF
+		case yaml_MAPPING_START_EVENT:
+			typ = objconv.Map
 
-	default:
-		err = fmt.Errorf("objconv/yaml: gopkg.in/yaml.v2 generated an unsupported value of type %T", v)
-	}
+		default:
+			err = fmt.Errorf("objconv/yaml: expected a value but found %s", eventString(ev.typ))
+		}
 
-	return
+		return
+	}
 }
 
 func (p *Parser) ParseNil() (err error) {
-	p.pop()
+	_, err = p.next()
 	return
 }
 
 func (p *Parser) ParseBool() (v bool, err error) {
-	v = p.pop().value().(bool)
+	if _, err = p.next(); err == nil {
+		v = p.val.(bool)
+	}
 	return
 }
 
 func (p *Parser) ParseInt() (v int64, err error) {
-	switch x := p.pop().value().(type) {
-	case int:
-		v = int64(x)
-	default:
-		v = x.(int64)
+	if _, err = p.next(); err == nil {
+		v = p.val.(int64)
 	}
 	return
 }
 
 func (p *Parser) ParseUint() (v uint64, err error) {
-	v = p.pop().value().(uint64)
+	if _, err = p.next(); err == nil {
+		v = p.val.(uint64)
+	}
 	return
 }
 
 func (p *Parser) ParseFloat() (v float64, err error) {
-	v = p.pop().value().(float64)
+	if _, err = p.next(); err == nil {
+		v = p.val.(float64)
+	}
 	return
 }
 
 func (p *Parser) ParseString() (v []byte, err error) {
-	s := p.pop().value().(string)
-	n := len(s)
+	var ev yaml_event_t
+
+	if ev, err = p.next(); err != nil {
+		return
+	}
 
-	if cap(p.s) < n {
-		p.s = make([]byte, 0, ((n/1024)+1)*1024)
+	if b, ok := p.val.([]byte); ok {
+		ev.value = b
 	}
 
-	v = p.s[:n]
-	copy(v, s)
+	// The value is copied because the decoder may modify it, and the event
+	// may be replayed if it was part of an anchored node.
+	p.s = append(p.s[:0], ev.value...)
+	v = p.s
 	return
 }
 
@@ -132,7 +206,7 @@ func (p *Parser) ParseBytes() (v []byte, err error) {
 }
 
 func (p *Parser) ParseTime() (v time.Time, err error) {
-	panic("objconv/yaml: ParseBytes should never be called because YAML has no time type, this is likely a bug in the decoder code")
+	panic("objconv/yaml: ParseTime should never be called because YAML has no time type, this is likely a bug in the decoder code")
 }
 
 func (p *Parser) ParseDuration() (v time.Duration, err error) {
@@ -144,42 +218,87 @@ func (p *Parser) ParseError() (v error, err error) {
 }
 
 func (p *Parser) ParseArrayBegin() (n int, err error) {
-	if n = p.top().len(); n != 0 {
-		p.push(newParser(p.top().next()))
+	if p.stream && !p.docs {
+		p.docs = true
+		return -1, nil
 	}
-	return
+	_, err = p.next()
+	return -1, err
 }
 
 func (p *Parser) ParseArrayEnd(n int) (err error) {
-	p.pop()
-	return
+	if p.docs && p.depth == 0 {
+		if _, err = p.document(); err == nil {
+			err = p.end(yaml_STREAM_END_EVENT)
+		}
+		return
+	}
+	return p.end(yaml_SEQUENCE_END_EVENT)
 }
 
 func (p *Parser) ParseArrayNext(n int) (err error) {
-	p.push(newParser(p.top().next()))
+	var ev *yaml_event_t
+
+	if p.docs && p.depth == 0 {
+		if ev, err = p.document(); err == nil && ev.typ == yaml_STREAM_END_EVENT {
+			err = objconv.End
+		}
+		return
+	}
+
+	if ev, err = p.peek(); err == nil && ev.typ == yaml_SEQUENCE_END_EVENT {
+		err = objconv.End
+	}
+
 	return
 }
 
 func (p *Parser) ParseMapBegin() (n int, err error) {
-	if n = p.top().len(); n != 0 {
-		p.push(newParser(p.top().next()))
+	if _, err = p.next(); err == nil {
+		p.levels = append(p.levels, len(p.keys))
 	}
-	return
+	return -1, err
 }
 
 func (p *Parser) ParseMapEnd(n int) (err error) {
-	p.pop()
+	if err = p.end(yaml_MAPPING_END_EVENT); err == nil {
+		i := len(p.levels) - 1
+		p.keys = p.keys[:p.levels[i]]
+		p.levels = p.levels[:i]
+	}
 	return
 }
 
 func (p *Parser) ParseMapValue(n int) (err error) {
-	p.push(newParser(p.top().next()))
 	return
 }
 
 func (p *Parser) ParseMapNext(n int) (err error) {
-	p.push(newParser(p.top().next()))
-	return
+	var ev *yaml_event_t
+
+	for {
+		if ev, err = p.peek(); err != nil {
+			return
+		}
+
+		switch {
+		case ev.typ == yaml_MAPPING_END_EVENT:
+			err = objconv.End
+
+		case isMergeKey(ev):
+			if err = p.merge(); err != nil {
+				return
+			}
+			continue
+
+		case ev.typ == yaml_SCALAR_EVENT:
+			// Keys are remembered in case the mapping has a merge key, the
+			// merged keys must not overwrite them.
+			p.keys = append(p.keys, ev.value)
+		}
+
+		return
+	}
 }
 
 func (p *Parser) TextParser() bool {
@@ -195,122 +314,428 @@ func (p *Parser) DecodeBytes(b []byte) (v []byte, err error) {
 	return
 }
 
-func (p *Parser) push(v parser) {
-	p.stack = append(p.stack, v)
-}
+// document skips the events that delimit documents and returns the event
+// that the parser is positioned on after them.
+func (p *Parser) document() (ev *yaml_event_t, err error) {
+	for {
+		if ev, err = p.peek(); err != nil {
+			return
+		}
 
-func (p *Parser) pop() parser {
-	i := len(p.stack) - 1
-	v := p.stack[i]
-	p.stack = p.stack[:i]
-	return v
-}
+		switch ev.typ {
+		case yaml_STREAM_START_EVENT, yaml_DOCUMENT_END_EVENT:
+			p.skip()
 
-func (p *Parser) top() parser {
-	return p.stack[len(p.stack)-1]
-}
+		case yaml_DOCUMENT_START_EVENT:
+			// Anchors are scoped to the document that defines them.
+			p.anchors = nil
+			p.skip()
 
-func (p *Parser) value() interface{} {
-	n := len(p.stack)
-	if n == 0 {
-		return eof{}
+		default:
+			return
+		}
 	}
-	return p.stack[n-1].value()
 }
 
-type parser interface {
-	value() interface{}
-	next() interface{}
-	len() int
-}
+// end consumes the end of the sequence or mapping that the parser is
+// positioned on.
+func (p *Parser) end(typ yaml_event_type_t) (err error) {
+	var ev *yaml_event_t
 
-type valueParser struct {
-	self interface{}
-}
+	if ev, err = p.peek(); err == nil {
+		if ev.typ != typ {
+			err = fmt.Errorf("objconv/yaml: expected %s but found %s", eventString(typ), eventString(ev.typ))
+		} else {
+			_, err = p.next()
+		}
+	}
 
-func (p *valueParser) value() interface{} {
-	return p.self
+	return
 }
 
-func (p *valueParser) next() interface{} {
-	panic("objconv/yaml: invalid call of next method on simple value parser")
-}
+// merge expands the merge keys ("<<") of the mapping that the parser is
+// positioned in. The remaining entries of the mapping are loaded and replayed
+// after the entries of the merged mappings, which are skipped if their keys
+// are defined by the mapping itself or by a mapping merged before them.
+func (p *Parser) merge() (err error) {
+	var ev *yaml_event_t
+	var merged [][]yaml_event_t
+	var entries []yaml_event_t
+	var keys = make(map[string]struct{})
 
-func (p *valueParser) len() int {
-	panic("objconv/yaml: invalid call of len method on simple value parser")
-}
+	for _, k := range p.keys[p.levels[len(p.levels)-1]:] {
+		keys[string(k)] = struct{}{}
+	}
+
+	for {
+		var k, v []yaml_event_t
+
+		if ev, err = p.peek(); err != nil {
+			return
+		}
+
+		if ev.typ == yaml_MAPPING_END_EVENT {
+			break
+		}
+
+		if k, err = p.node(); err != nil {
+			return
+		}
+
+		if v, err = p.node(); err != nil {
+			return
+		}
+
+		if isMergeKey(&k[0]) {
+			var m [][]yaml_event_t
+			if m, err = p.mergeValue(v); err != nil {
+				return
+			}
+			merged = append(merged, m...)
+			continue
+		}
+
+		if k[0].typ == yaml_SCALAR_EVENT {
+			keys[string(k[0].value)] = struct{}{}
+		}
+
+		entries = append(entries, k...)
+		entries = append(entries, v...)
+	}
+
+	var events []yaml_event_t
+
+	for _, m := range merged {
+		for len(m) != 0 {
+			n := nodeLen(m)
+			n += nodeLen(m[n:])
+
+			if m[0].typ == yaml_SCALAR_EVENT {
+				if _, exists := keys[string(m[0].value)]; exists {
+					m = m[n:]
+					continue
+				}
+				keys[string(m[0].value)] = struct{}{}
+			}
+
+			events = append(events, m[:n]...)
+			m = m[n:]
+		}
+	}
+
+	// The end of the mapping was loaded already, it is replayed after the
+	// entries.
+	end := p.event
+	p.peeked = false
 
-type arrayParser struct {
-	self []interface{}
-	off  int
+	if !p.replay {
+		p.mark = end.end_mark
+		p.advance(false)
+	}
+
+	events = append(events, entries...)
+	p.replays = append(p.replays, append(events, end))
+	return
 }
 
-func (p *arrayParser) value() interface{} {
-	return p.self
+// mergeValue returns the entries of the mappings that the value of a merge key
+// refers to, which must be a mapping, an alias to a mapping, or a sequence of
+// those.
+func (p *Parser) mergeValue(v []yaml_event_t) (merged [][]yaml_event_t, err error) {
+	switch v[0].typ {
+	case yaml_ALIAS_EVENT:
+		events, ok := p.anchors[string(v[0].anchor)]
+		if !ok {
+			err = p.fail(fmt.Errorf("objconv/yaml: unknown anchor %q referenced", v[0].anchor))
+			return
+		}
+		return p.mergeValue(events)
+
+	case yaml_MAPPING_START_EVENT:
+		merged = append(merged, v[1:len(v)-1])
+		return
+
+	case yaml_SEQUENCE_START_EVENT:
+		for v = v[1 : len(v)-1]; len(v) != 0; {
+			var m [][]yaml_event_t
+			n := nodeLen(v)
+
+			if v[0].typ == yaml_SEQUENCE_START_EVENT {
+				break
+			}
+
+			if m, err = p.mergeValue(v[:n]); err != nil {
+				return
+			}
+
+			merged = append(merged, m...)
+			v = v[n:]
+		}
+
+		if len(v) == 0 {
+			return
+		}
+	}
+
+	err = p.fail(errors.New("objconv/yaml: the value of a merge key must be a mapping or a sequence of mappings"))
+	return
 }
 
-func (p *arrayParser) next() interface{} {
-	v := p.self[p.off]
-	p.off++
-	return v
+// node loads the events of the node that the parser is positioned on without
+// recording them.
+func (p *Parser) node() (events []yaml_event_t, err error) {
+	var ev *yaml_event_t
+	var depth = p.depth
+
+	for {
+		if ev, err = p.peek(); err != nil {
+			return
+		}
+
+		if ev.typ == yaml_STREAM_END_EVENT || ev.typ == yaml_DOCUMENT_END_EVENT {
+			err = p.fail(fmt.Errorf("objconv/yaml: unexpected %s", eventString(ev.typ)))
+			return
+		}
+
+		events = append(events, *ev)
+		p.skip()
+
+		if p.depth == depth {
+			return
+		}
+	}
 }
 
-func (p *arrayParser) len() int {
-	return len(p.self)
+// nodeLen returns the number of events that the first node of events is made
+// of.
+func nodeLen(events []yaml_event_t) int {
+	depth := 0
+
+	for i := range events {
+		switch events[i].typ {
+		case yaml_SEQUENCE_START_EVENT, yaml_MAPPING_START_EVENT:
+			depth++
+		case yaml_SEQUENCE_END_EVENT, yaml_MAPPING_END_EVENT:
+			depth--
+		}
+		if depth == 0 {
+			return i + 1
+		}
+	}
+
+	return len(events)
 }
 
-type mapParser struct {
-	self yaml.MapSlice
-	off  int
-	val  bool
+// alias consumes the alias event that the parser is positioned on, and pushes
+// the events of the anchored node so they are replayed next.
+func (p *Parser) alias() (events []yaml_event_t, err error) {
+	ev := p.event
+	p.skip()
+
+	var ok bool
+	if events, ok = p.anchors[string(ev.anchor)]; !ok {
+		err = p.fail(fmt.Errorf("objconv/yaml: unknown anchor %q referenced", ev.anchor))
+		return
+	}
+
+	p.replays = append(p.replays, events)
+	return
 }
 
-func (p *mapParser) value() interface{} {
-	return p.self
+// peek loads the next event, either from the aliased node being replayed or
+// from the input.
+func (p *Parser) peek() (*yaml_event_t, error) {
+	if p.peeked {
+		return &p.event, nil
+	}
+
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	for n := len(p.replays); n != 0; n = len(p.replays) {
+		if events := p.replays[n-1]; len(events) != 0 {
+			p.event, p.replays[n-1] = events[0], events[1:]
+			p.peeked, p.replay = true, true
+			return &p.event, nil
+		}
+		p.replays = p.replays[:n-1]
+	}
+
+	if p.parser.read_handler == nil {
+		if cap(p.parser.buffer) == 0 {
+			yaml_parser_initialize(&p.parser)
+		}
+		p.parser.read_handler = p.read
+	}
+
+	if !yaml_parser_parse(&p.parser, &p.event) {
+		return nil, p.fail(p.syntaxError())
+	}
+
+	if p.event.typ == yaml_NO_EVENT {
+		p.event.typ = yaml_STREAM_END_EVENT
+	}
+
+	p.peeked, p.replay = true, false
+	return &p.event, nil
 }
 
-func (p *mapParser) next() (v interface{}) {
-	if p.val {
-		v = p.self[p.off].Value
-		p.val = false
-		p.off++
-	} else {
-		v = p.self[p.off].Key
-		p.val = true
+// next consumes the event that the parser is positioned on, recording it if
+// it is part of an anchored node.
+func (p *Parser) next() (ev yaml_event_t, err error) {
+	if _, err = p.peek(); err != nil {
+		return
+	}
+
+	ev = p.event
+	depth := p.depth
+	p.skip()
+
+	if len(ev.anchor) != 0 {
+		p.records = append(p.records, record{name: string(ev.anchor), depth: depth})
 	}
+
+	for i := range p.records {
+		p.records[i].events = append(p.records[i].events, ev)
+	}
+
+	if ev.typ == yaml_SEQUENCE_START_EVENT || ev.typ == yaml_MAPPING_START_EVENT {
+		return
+	}
+
+	// The node is complete when the parser is back to the level it was found
+	// at.
+	for n := len(p.records); n != 0 && p.records[n-1].depth == p.depth; n-- {
+		r := p.records[n-1]
+		if p.anchors == nil {
+			p.anchors = make(map[string][]yaml_event_t)
+		}
+		p.anchors[r.name] = r.events
+		p.records = p.records[:n-1]
+	}
+
 	return
 }
 
-func (p *mapParser) len() int {
-	return len(p.self)
+// skip consumes the event that the parser is positioned on without recording
+// it.
+func (p *Parser) skip() {
+	ev := &p.event
+	p.peeked = false
+
+	switch ev.typ {
+	case yaml_SEQUENCE_START_EVENT, yaml_MAPPING_START_EVENT:
+		p.depth++
+	case yaml_SEQUENCE_END_EVENT, yaml_MAPPING_END_EVENT:
+		p.depth--
+	}
+
+	if !p.replay {
+		p.mark = ev.end_mark
+		p.advance(ev.typ == yaml_STREAM_START_EVENT)
+
+		if p.nodes == 0 {
+			p.dropComments(ev.end_mark.index)
+		}
+	}
+}
+
+// dropComments discards the comments read by the scanner before offset, they
+// are only kept while nodes are loaded.
+func (p *Parser) dropComments(offset int) {
+	c := p.parser.comments
+	i := 0
+
+	for i < len(c) && c[i].start_mark.index < offset {
+		i++
+	}
+
+	if i != 0 {
+		p.parser.comments = c[:copy(c, c[i:])]
+	}
 }
 
-func newParser(v interface{}) parser {
-	switch x := v.(type) {
-	case map[interface{}]interface{}:
-		return &mapParser{self: makeMapSlice(x)}
+// advance moves the offset of the first byte returned by Buffered to the
+// position of the last event consumed.
+func (p *Parser) advance(start bool) {
+	if start && bytes.HasPrefix(p.b, []byte(bom_UTF8)) {
+		// The byte order mark is not counted as a character.
+		p.i += len(bom_UTF8)
+	}
 
-	case []interface{}:
-		return &arrayParser{self: x}
+	for p.idx < p.mark.index && p.i < len(p.b) {
+		_, n := utf8.DecodeRune(p.b[p.i:])
+		p.i += n
+		p.idx++
+	}
 
-	default:
-		return &valueParser{self: x}
+	// Release the bytes that were consumed once they make up the most of the
+	// buffer, this keeps it from growing with the size of the input.
+	if p.i >= 4096 && p.i >= len(p.b)/2 {
+		p.b = p.b[:copy(p.b, p.b[p.i:])]
+		p.i = 0
 	}
 }
 
-func makeMapSlice(m map[interface{}]interface{}) yaml.MapSlice {
-	s := make(yaml.MapSlice, 0, len(m))
+// read is the read handler of the libyaml parser, it keeps the bytes read
+// from the input so they can be returned by Buffered.
+func (p *Parser) read(_ *yaml_parser_t, b []byte) (n int, err error) {
+	n, err = p.r.Read(b)
+	p.b = append(p.b, b[:n]...)
+	return
+}
+
+func (p *Parser) fail(err error) error {
+	p.err = err
+	return err
+}
+
+func (p *Parser) syntaxError() error {
+	problem := p.parser.problem
 
-	for k, v := range m {
-		s = append(s, yaml.MapItem{
-			Key:   k,
-			Value: v,
-		})
+	if len(problem) == 0 {
+		problem = "unknown problem parsing YAML content"
 	}
 
-	return s
+	if len(p.parser.context) != 0 {
+		problem = p.parser.context + ": " + problem
+	}
+
+	return errors.New("objconv/yaml: " + problem)
 }
 
-// eof values are returned by the top method to indicate that all values have
-// already been consumed.
-type eof struct{}
+func isMergeKey(ev *yaml_event_t) bool {
+	if ev.typ != yaml_SCALAR_EVENT || string(ev.value) != "<<" {
+		return false
+	}
+	tag := string(ev.tag)
+	return tag == mergeTag || (len(tag) == 0 && ev.scalar_style() == yaml_PLAIN_SCALAR_STYLE)
+}
+
+func eventString(typ yaml_event_type_t) string {
+	switch typ {
+	case yaml_STREAM_START_EVENT:
+		return "the start of the stream"
+	case yaml_STREAM_END_EVENT:
+		return "the end of the stream"
+	case yaml_DOCUMENT_START_EVENT:
+		return "the start of a document"
+	case yaml_DOCUMENT_END_EVENT:
+		return "the end of a document"
+	case yaml_ALIAS_EVENT:
+		return "an alias"
+	case yaml_SCALAR_EVENT:
+		return "a scalar"
+	case yaml_SEQUENCE_START_EVENT:
+		return "the start of a sequence"
+	case yaml_SEQUENCE_END_EVENT:
+		return "the end of a sequence"
+	case yaml_MAPPING_START_EVENT:
+		return "the start of a mapping"
+	case yaml_MAPPING_END_EVENT:
+		return "the end of a mapping"
+	default:
+		return "no event"
+	}
+}
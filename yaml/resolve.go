@@ -0,0 +1,157 @@
+package yaml
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/dolab/objconv"
+)
+
+const (
+	tagPrefix = "tag:yaml.org,2002:"
+	nullTag   = tagPrefix + "null"
+	boolTag   = tagPrefix + "bool"
+	strTag    = tagPrefix + "str"
+	intTag    = tagPrefix + "int"
+	floatTag  = tagPrefix + "float"
+	mergeTag  = tagPrefix + "merge"
+	binaryTag = tagPrefix + "binary"
+)
+
+var resolveMap = map[string]interface{}{}
+
+func init() {
+	for _, item := range []struct {
+		v interface{}
+		l []string
+	}{
+		{true, []string{"y", "Y", "yes", "Yes", "YES", "true", "True", "TRUE", "on", "On", "ON"}},
+		{false, []string{"n", "N", "no", "No", "NO", "false", "False", "FALSE", "off", "Off", "OFF"}},
+		{nil, []string{"", "~", "null", "Null", "NULL"}},
+		{math.NaN(), []string{".nan", ".NaN", ".NAN"}},
+		{math.Inf(+1), []string{".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF"}},
+		{math.Inf(-1), []string{"-.inf", "-.Inf", "-.INF"}},
+	} {
+		for _, s := range item.l {
+			resolveMap[s] = item.v
+		}
+	}
+}
+
+// resolve returns the type and value of a scalar, plain scalars without tags
+// are resolved with the same rules as gopkg.in/yaml.v2 so documents decode the
+// same way they did before the parser was streaming.
+//
+// The value is nil for strings, the parser reads them from the scalar event,
+// except for binary data.
+func resolve(tag string, value []byte, plain bool) (t objconv.Type, v interface{}, err error) {
+	switch tag {
+	case "", "!":
+		if !plain {
+			return objconv.String, nil, nil
+		}
+
+	case strTag:
+		return objconv.String, nil, nil
+
+	case binaryTag:
+		// Binary data is decoded to a string, which is how strings that are
+		// not valid UTF-8 are encoded.
+		b, e := base64.StdEncoding.DecodeString(strings.Map(dropSpace, string(value)))
+		if e != nil {
+			return objconv.String, nil, fmt.Errorf("objconv/yaml: invalid !!binary value: %s", e)
+		}
+		return objconv.String, b, nil
+
+	case nullTag, boolTag, intTag, floatTag:
+
+	default:
+		// Other tags, like !!binary, !!timestamp or application specific
+		// tags, are not interpreted by the parser.
+		if !plain {
+			return objconv.String, nil, nil
+		}
+		tag = ""
+	}
+
+	if t, v = resolvePlain(string(value)); tag == "" || tag == typeTag(t) {
+		return
+	}
+
+	if tag == floatTag {
+		switch x := v.(type) {
+		case int64:
+			return objconv.Float, float64(x), nil
+		case uint64:
+			return objconv.Float, float64(x), nil
+		}
+	}
+
+	err = fmt.Errorf("objconv/yaml: cannot decode %q as a %s", value, "!!"+tag[len(tagPrefix):])
+	return
+}
+
+func resolvePlain(s string) (objconv.Type, interface{}) {
+	if v, ok := resolveMap[s]; ok {
+		switch v.(type) {
+		case nil:
+			return objconv.Nil, nil
+		case bool:
+			return objconv.Bool, v
+		default:
+			return objconv.Float, v
+		}
+	}
+
+	// Only scalars that start like numbers are candidates to be parsed as
+	// numbers, this excludes values like "Inf" or "NaN".
+	switch c := s[0]; {
+	case c == '.':
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return objconv.Float, f
+		}
+
+	case c == '+' || c == '-' || (c >= '0' && c <= '9'):
+		plain := strings.Replace(s, "_", "", -1)
+
+		if i, err := strconv.ParseInt(plain, 0, 64); err == nil {
+			return objconv.Int, i
+		}
+
+		if u, err := strconv.ParseUint(plain, 0, 64); err == nil {
+			return objconv.Uint, u
+		}
+
+		if f, err := strconv.ParseFloat(plain, 64); err == nil {
+			return objconv.Float, f
+		}
+	}
+
+	return objconv.String, nil
+}
+
+func dropSpace(r rune) rune {
+	switch r {
+	case ' ', '\t', '\n', '\r':
+		return -1
+	}
+	return r
+}
+
+func typeTag(t objconv.Type) string {
+	switch t {
+	case objconv.Nil:
+		return nullTag
+	case objconv.Bool:
+		return boolTag
+	case objconv.Int, objconv.Uint:
+		return intTag
+	case objconv.Float:
+		return floatTag
+	default:
+		return strTag
+	}
+}
@@ -1,8 +1,15 @@
 package yaml
 
 import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/dolab/objconv"
+	"github.com/dolab/objconv/json"
 	"github.com/dolab/objconv/objtests"
 )
 
@@ -13,3 +20,603 @@ func TestCodec(t *testing.T) {
 func BenchmarkCodec(b *testing.B) {
 	objtests.BenchmarkCodec(b, Codec)
 }
+
+func TestUnmarshalAliases(t *testing.T) {
+	const document = `
+base: &base
+  a: 1
+  b: [x, y]
+other: &other {c: 3}
+list: [*other, *other]
+merged:
+  b: override
+  <<: [*base, *other]
+  c: 4
+inline:
+  <<: {a: 1}
+  d: 2
+`
+
+	var v map[string]interface{}
+
+	if err := Unmarshal([]byte(document), &v); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"base":   map[interface{}]interface{}{"a": int64(1), "b": []interface{}{"x", true}},
+		"other":  map[interface{}]interface{}{"c": int64(3)},
+		"list":   []interface{}{map[interface{}]interface{}{"c": int64(3)}, map[interface{}]interface{}{"c": int64(3)}},
+		"merged": map[interface{}]interface{}{"a": int64(1), "b": "override", "c": int64(4)},
+		"inline": map[interface{}]interface{}{"a": int64(1), "d": int64(2)},
+	}
+
+	if !reflect.DeepEqual(v, expected) {
+		t.Errorf("%#v", v)
+	}
+}
+
+func TestUnmarshalUnknownAnchor(t *testing.T) {
+	var v interface{}
+
+	if err := Unmarshal([]byte("a: *b\n"), &v); err == nil || !strings.Contains(err.Error(), `unknown anchor "b"`) {
+		t.Error(err)
+	}
+}
+
+func TestParseKeyOrder(t *testing.T) {
+	var keys []string
+
+	dec := NewDecoder(strings.NewReader("c: 1\n<<: {b: 2, c: 0}\na: 3\nz: 4\n"))
+
+	if err := dec.DecodeMap(func(k objconv.Decoder, v objconv.Decoder) error {
+		var key string
+		if err := k.Decode(&key); err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		return v.Decode(nil)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(keys, []string{"c", "b", "a", "z"}) {
+		t.Error(keys)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	var v interface{}
+
+	err := Unmarshal([]byte("a:\n  - [1, 2\n  - 3\n"), &v)
+
+	e, ok := err.(*objconv.DecodeError)
+	if !ok {
+		t.Fatalf("%T: %v", err, err)
+	}
+
+	if e.Line != 4 || e.Column != 1 {
+		t.Errorf("line %d, column %d: %v", e.Line, e.Column, err)
+	}
+
+	if !strings.Contains(err.Error(), "did not find expected ',' or ']'") {
+		t.Error(err)
+	}
+}
+
+func TestParseBuffered(t *testing.T) {
+	p := NewParser(strings.NewReader("a: 1\n...\nrest of the input"))
+
+	var v map[string]int
+
+	if err := objconv.NewDecoder(p).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(v, map[string]int{"a": 1}) {
+		t.Error(v)
+	}
+
+	b, _ := ioutil.ReadAll(p.Buffered())
+
+	if s := string(b); !strings.HasSuffix(s, "rest of the input") || strings.Contains(s, "a: 1") {
+		t.Errorf("%q", s)
+	}
+}
+
+func TestParseStreaming(t *testing.T) {
+	r, w := io.Pipe()
+	defer r.Close()
+
+	values := make(chan int)
+	errors := make(chan error, 1)
+
+	go func() {
+		errors <- NewDecoder(r).DecodeArray(func(d objconv.Decoder) error {
+			var v int
+			if err := d.Decode(&v); err != nil {
+				return err
+			}
+			values <- v
+			return nil
+		})
+		close(values)
+	}()
+
+	// The first values must be produced before the input is complete.
+	io.WriteString(w, "- 1\n- 2\n- ")
+
+	for i := 1; i <= 2; i++ {
+		if v := <-values; v != i {
+			t.Errorf("value #%d: %d", i, v)
+		}
+	}
+
+	io.WriteString(w, "3\n")
+	w.Close()
+
+	if v := <-values; v != 3 {
+		t.Errorf("value #3: %d", v)
+	}
+
+	if err := <-errors; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDecodeDocuments(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("--- 1\n--- &a [2]\n--- 3\n"))
+
+	var values []interface{}
+
+	for {
+		var v interface{}
+		if err := dec.Decode(&v); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, v)
+	}
+
+	if !reflect.DeepEqual(values, []interface{}{int64(1), []interface{}{int64(2)}, int64(3)}) {
+		t.Error(values)
+	}
+}
+
+func TestStreamDecoder(t *testing.T) {
+	const stream = `a: &a 1
+b: *a
+---
+- 2
+--- 3
+---
+...
+`
+
+	dec := NewStreamDecoder(strings.NewReader(stream))
+
+	var values []interface{}
+
+	for {
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			break
+		}
+		values = append(values, v)
+	}
+
+	if err := dec.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []interface{}{
+		map[interface{}]interface{}{"a": int64(1), "b": int64(1)},
+		[]interface{}{int64(2)},
+		int64(3),
+		nil,
+	}
+
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("%#v", values)
+	}
+}
+
+func TestStreamDecoderEmpty(t *testing.T) {
+	dec := NewStreamDecoder(strings.NewReader(""))
+
+	if err := dec.Decode(nil); err != objconv.End {
+		t.Error(err)
+	}
+
+	if err := dec.Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStreamEncoder(t *testing.T) {
+	var b bytes.Buffer
+
+	enc := NewStreamEncoder(&b)
+
+	for _, v := range []interface{}{
+		map[string]int{"a": 1},
+		[]int{2, 3},
+		"4",
+	} {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := b.String(); s != "a: 1\n---\n- 2\n- 3\n--- \"4\"\n" {
+		t.Errorf("%q", s)
+	}
+
+	var values []interface{}
+	var dec = Codec.NewStreamDecoder(&b)
+
+	for {
+		var v interface{}
+		if dec.Decode(&v) != nil {
+			break
+		}
+		values = append(values, v)
+	}
+
+	if err := dec.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(values, []interface{}{
+		map[interface{}]interface{}{"a": int64(1)},
+		[]interface{}{int64(2), int64(3)},
+		"4",
+	}) {
+		t.Errorf("%#v", values)
+	}
+}
+
+func TestEmitterKeyOrder(t *testing.T) {
+	var b bytes.Buffer
+
+	keys := []string{"z", "a", "m"}
+	enc := NewEncoder(&b)
+
+	i := 0
+	if err := enc.EncodeMap(len(keys), func(k objconv.Encoder, v objconv.Encoder) error {
+		if err := k.Encode(keys[i]); err != nil {
+			return err
+		}
+		i++
+		return v.Encode(i)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := b.String(); s != "z: 1\na: 2\nm: 3\n" {
+		t.Errorf("%q", s)
+	}
+}
+
+func TestEmitterIncremental(t *testing.T) {
+	var b bytes.Buffer
+
+	enc := NewStreamEncoder(&b)
+
+	if err := enc.Encode(map[string]int{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Documents are written when they are complete, before the stream ends.
+	if s := b.String(); s != "a: 1\n" {
+		t.Errorf("%q", s)
+	}
+}
+
+func TestEmitterStrings(t *testing.T) {
+	for _, test := range []struct {
+		in  string
+		out string
+	}{
+		{"hello", "hello\n"},
+		{"", "\"\"\n"},
+		{"true", "\"true\"\n"},
+		{"1.5", "\"1.5\"\n"},
+		{"1:20", "\"1:20\"\n"},
+		{"2002-12-14", "\"2002-12-14\"\n"},
+		{"2001-12-14t21:59:43.10-05:00", "\"2001-12-14t21:59:43.10-05:00\"\n"},
+		{"2002-12", "2002-12\n"},
+		{"1e3", "\"1e3\"\n"},
+		{"1e+3", "\"1e+3\"\n"},
+		{"a\nb", "|-\n  a\n  b\n"},
+		{"\xff", "!!binary /w==\n"},
+	} {
+		t.Run(test.out, func(t *testing.T) {
+			b, err := Marshal(test.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if s := string(b); s != test.out {
+				t.Errorf("%q", s)
+			}
+
+			var v string
+			if err := Unmarshal(b, &v); err != nil {
+				t.Fatal(err)
+			}
+
+			if v != test.in {
+				t.Errorf("%q", v)
+			}
+		})
+	}
+}
+
+func TestUnmarshalPlainScalars(t *testing.T) {
+	for _, test := range []struct {
+		in  string
+		out interface{}
+	}{
+		{"1e3", 1000.0},
+		{"1e+3", 1000.0},
+		{"1.5e-3", 0.0015},
+		{".5", 0.5},
+		{"1.", 1.0},
+		{"0x10", int64(16)},
+		{"2002-12-14", "2002-12-14"},
+		// Like in YAML 1.1, the plain scalar y is a boolean.
+		{"y: 1e3", map[interface{}]interface{}{true: 1000.0}},
+	} {
+		t.Run(test.in, func(t *testing.T) {
+			var v interface{}
+
+			if err := Unmarshal([]byte(test.in), &v); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(v, test.out) {
+				t.Errorf("%#v != %#v", v, test.out)
+			}
+		})
+	}
+}
+
+func TestUnmarshalUnhashableKey(t *testing.T) {
+	for _, in := range []string{
+		"? [a, b]\n: c\n",
+		"? {a: b}\n: c\n",
+	} {
+		t.Run(in, func(t *testing.T) {
+			var v interface{}
+
+			if err := Unmarshal([]byte(in), &v); err == nil {
+				t.Errorf("expected an error but got %#v", v)
+			} else if !strings.Contains(err.Error(), "invalid map key") {
+				t.Error(err)
+			}
+
+			var m map[interface{}]string
+
+			if err := Unmarshal([]byte(in), &m); err == nil {
+				t.Errorf("expected an error but got %#v", m)
+			}
+		})
+	}
+}
+
+func TestPrettyEmitter(t *testing.T) {
+	var b bytes.Buffer
+
+	e := NewPrettyEmitter(&b)
+	e.SetIndent(4)
+	e.SetFlowWidth(20)
+
+	type value struct {
+		Long   []string `objconv:"long"`
+		Nested [][]int  `objconv:"nested"`
+		Short  []int    `objconv:"short"`
+	}
+
+	v := map[string]value{
+		"a": {
+			Short:  []int{1, 2, 3},
+			Long:   []string{"0123456789", "0123456789"},
+			Nested: [][]int{{1}, {2}},
+		},
+	}
+
+	if err := objconv.NewEncoder(e).Encode(v); err != nil {
+		t.Fatal(err)
+	}
+
+	const expected = `a:
+    long:
+    - "0123456789"
+    - "0123456789"
+    nested:
+    - [1]
+    - [2]
+    short: [1, 2, 3]
+`
+
+	if s := b.String(); s != expected {
+		t.Errorf("%s", s)
+	}
+}
+
+const nodeDocument = `# Service configuration.
+name: api # the service name
+
+# Defaults shared by environments.
+defaults: &defaults
+  timeout: 30s
+  retries: 3 # attempts
+  tags: [a, 'b', "c"]
+
+environments:
+  # Production settings.
+  production:
+    <<: *defaults
+    host: "prod.example.com"
+  staging: *defaults
+
+script: |
+  echo hello
+  echo world
+version: !!str 1.0
+# Trailing comment.
+`
+
+func TestNodeRoundTrip(t *testing.T) {
+	var n Node
+
+	if err := Unmarshal([]byte(nodeDocument), &n); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := Marshal(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := string(b); s != nodeDocument {
+		t.Errorf("%s", s)
+	}
+}
+
+func TestNodeModify(t *testing.T) {
+	var n Node
+
+	if err := Unmarshal([]byte(nodeDocument), &n); err != nil {
+		t.Fatal(err)
+	}
+
+	if n.Kind != DocumentNode || n.HeadComment != "# Service configuration." || n.FootComment != "# Trailing comment." {
+		t.Fatalf("%+v", n)
+	}
+
+	root := n.Content[0]
+	defaults := root.Content[3]
+	staging := root.Content[5].Content[3]
+
+	if defaults.Anchor != "defaults" || root.Content[2].HeadComment != "\n# Defaults shared by environments." {
+		t.Errorf("%+v", root.Content[2])
+	}
+
+	if staging.Kind != AliasNode || staging.Alias != defaults {
+		t.Errorf("%+v", staging)
+	}
+
+	if retries := defaults.Content[3]; retries.Value != "3" || retries.LineComment != "# attempts" || retries.Line != 7 || retries.Column != 12 {
+		t.Errorf("%+v", retries)
+	}
+
+	defaults.Content[3].Value = "5"
+	root.Content = append(root.Content,
+		&Node{Kind: ScalarNode, Value: "owner", HeadComment: "Added by the test."},
+		&Node{Kind: ScalarNode, Value: "1.5", Style: DoubleQuotedStyle, LineComment: "not a number"},
+	)
+
+	b, err := Marshal(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := strings.Replace(nodeDocument, "retries: 3", "retries: 5", 1)
+	expected = strings.Replace(expected, "# Trailing comment.\n", "# Added by the test.\nowner: \"1.5\" # not a number\n# Trailing comment.\n", 1)
+
+	if s := string(b); s != expected {
+		t.Errorf("%s", s)
+	}
+}
+
+func TestNodeStreamDecoder(t *testing.T) {
+	dec := NewStreamDecoder(strings.NewReader("# first\na: 1\n--- # second\n- b\n"))
+
+	var nodes []Node
+
+	for {
+		var n Node
+		if err := dec.Decode(&n); err != nil {
+			break
+		}
+		nodes = append(nodes, n)
+	}
+
+	if err := dec.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(nodes) != 2 || nodes[0].HeadComment != "# first" || nodes[1].Content[0].Kind != SequenceNode {
+		t.Errorf("%+v", nodes)
+	}
+}
+
+func TestNodeJSON(t *testing.T) {
+	var n Node
+
+	if err := Unmarshal([]byte("a: &x [1, true, ~]\nb: *x\nc: !!binary aGk=\n"), &n); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := string(b); s != `{"a":[1,true,null],"b":[1,true,null],"c":"aGk="}` {
+		t.Error(s)
+	}
+
+	var m Node
+
+	if err := json.Unmarshal([]byte(`{"z":["1.5","x"],"a":2.5}`), &m); err != nil {
+		t.Fatal(err)
+	}
+
+	if b, err = Marshal(m); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := string(b); s != "z:\n- \"1.5\"\n- x\na: 2.5\n" {
+		t.Errorf("%q", s)
+	}
+}
+
+func TestOrderedStreamDecoder(t *testing.T) {
+	src := "z: 1\na:\n  k:\n  - c: true\n    b: null\n  x: \"2\"\n---\nm: 3.5\nb: []\n"
+
+	var b bytes.Buffer
+	var v interface{}
+
+	dec := NewOrderedStreamDecoder(strings.NewReader(src))
+	enc := NewStreamEncoder(&b)
+
+	for dec.Decode(&v) == nil {
+		if _, ok := v.(objconv.OrderedMap); !ok {
+			t.Fatalf("bad value: %#v", v)
+		}
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+		v = nil
+	}
+
+	if err := dec.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := b.String(); s != src {
+		t.Errorf("bad round-trip:\n%s", s)
+	}
+}
@@ -275,6 +275,15 @@ type yaml_event_t struct {
 
 	// The style (for yaml_SCALAR_EVENT, yaml_SEQUENCE_START_EVENT, yaml_MAPPING_START_EVENT).
 	style yaml_style_t
+
+	// [Go] The comments written by the emitter before the node, at the end of
+	// its line, and before the end of the collection or document (for
+	// yaml_SCALAR_EVENT, yaml_ALIAS_EVENT, yaml_SEQUENCE_START_EVENT,
+	// yaml_MAPPING_START_EVENT, yaml_SEQUENCE_END_EVENT,
+	// yaml_MAPPING_END_EVENT, yaml_DOCUMENT_END_EVENT).
+	head_comment []byte
+	line_comment []byte
+	foot_comment []byte
 }
 
 func (e *yaml_event_t) scalar_style() yaml_scalar_style_t     { return yaml_scalar_style_t(e.style) }
@@ -386,7 +395,9 @@ type yaml_document_t struct {
 // The number of written bytes should be set to the size_read variable.
 //
 // [in,out]   data        A pointer to an application data specified by
-//                        yaml_parser_set_input().
+//
+//	yaml_parser_set_input().
+//
 // [out]      buffer      The buffer to write the data from the source.
 // [in]       size        The size of the buffer.
 // [out]      size_read   The actual number of bytes read from the source.
@@ -496,6 +507,13 @@ type yaml_alias_data_t struct {
 	mark   yaml_mark_t // The anchor mark.
 }
 
+// [Go] A comment read by the scanner, blank lines are recorded as comments
+// with an empty value.
+type yaml_comment_t struct {
+	start_mark, end_mark yaml_mark_t
+	value                []byte // The comment, including the '#' indicator.
+}
+
 // The parser structure.
 //
 // All members are internal. Manage the structure using the
@@ -570,6 +588,9 @@ type yaml_parser_t struct {
 	aliases []yaml_alias_data_t // The alias data.
 
 	document *yaml_document_t // The currently parsed document.
+
+	// [Go] Comments read by the scanner, in the order of the input.
+	comments []yaml_comment_t
 }
 
 // Emitter Definitions
@@ -581,13 +602,14 @@ type yaml_parser_t struct {
 // @a buffer to the output.
 //
 // @param[in,out]   data        A pointer to an application data specified by
-//                              yaml_emitter_set_output().
+//
+//	yaml_emitter_set_output().
+//
 // @param[in]       buffer      The buffer with bytes to be written.
 // @param[in]       size        The size of the buffer.
 //
 // @returns On success, the handler should return @c 1.  If the handler failed,
 // the returned value should be @c 0.
-//
 type yaml_write_handler_t func(emitter *yaml_emitter_t, buffer []byte) error
 
 type yaml_emitter_state_t int
@@ -713,4 +735,7 @@ type yaml_emitter_t struct {
 	last_anchor_id int // The last assigned anchor id.
 
 	document *yaml_document_t // The currently emitted document.
+
+	// [Go] Comment written at the end of the current line.
+	line_comment []byte
 }